@@ -1,26 +1,27 @@
 package api
 
 import (
-	"database/sql"
-	"fmt"
-	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/apperror"
 	db "github.com/pakojabi/simplebank/db/sqlc"
 	"github.com/pakojabi/simplebank/token"
 )
 
 type createAccountRequest struct {
 	Currency string `json:"currency" binding:"required,currency"`
+	// Product defaults to checking
+	Product string `json:"product"`
 }
 
 func (server *Server) createAccount(ctx *gin.Context) {
 	var req createAccountRequest
 	// validation - with the help of gin's validator
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, invalidRequest(err))
 		return
 	}
 
@@ -31,17 +32,31 @@ func (server *Server) createAccount(ctx *gin.Context) {
 		Currency: req.Currency,
 	}
 
-	account, err := server.store.CreateAccount(ctx, arg)
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code.Name() {
-			case "foreign_key_violation", "unique_violation":
-				ctx.JSON(http.StatusForbidden, errorResponse(err))
+	if req.Product != "" {
+		_, err := server.store.GetAccountProduct(ctx, req.Product)
+		if err != nil {
+			if err == db.ErrRecordNotFound {
+				abortWithError(ctx, apperror.InvalidArgument(apperror.FieldViolation{Field: "product", Description: "unknown account product"}))
 				return
 			}
-			log.Println(pqErr.Code.Name())
+			abortWithError(ctx, err)
+			return
+		}
+		arg.Product = pgtype.Text{String: req.Product, Valid: true}
+	}
+
+	account, err := server.store.CreateAccount(ctx, arg)
+	if err != nil {
+		switch {
+		case db.IsForeignKeyViolation(err):
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodePermissionDenied, "user cannot own accounts"))
+			return
+		case db.IsUniqueViolation(err):
+			err := apperror.Wrap(err, apperror.CodeAccountAlreadyExists, "user already has an account in this currency")
+			abortWithError(ctx, err.With("currency", req.Currency))
+			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 
@@ -55,23 +70,23 @@ type getAccountRequest struct {
 func (server *Server) getAccount(ctx *gin.Context) {
 	var req getAccountRequest
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, invalidRequest(err))
 		return
 	}
 
 	account, err := server.store.GetAccount(ctx, req.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, accountNotFoundError(err, req.ID))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Username != account.Owner {
-		ctx.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("%s is not the owner of account %d", authPayload.Username, account.ID)))
+		abortWithError(ctx, accountNotOwnedError(account.ID))
 		return
 	}
 	ctx.JSON(http.StatusOK, account)
@@ -85,7 +100,7 @@ type listAccountsRequest struct {
 func (server *Server) listAccounts(ctx *gin.Context) {
 	var req listAccountsRequest
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, invalidRequest(err))
 		return
 	}
 
@@ -97,7 +112,7 @@ func (server *Server) listAccounts(ctx *gin.Context) {
 	})
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 
@@ -116,12 +131,12 @@ func (server *Server) updateAccount(ctx *gin.Context) {
 	var uri updateAccountUri
 	var body updateAccountBody
 	if err := ctx.ShouldBindUri(&uri); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, invalidRequest(err))
 		return
 	}
 
 	if err := ctx.ShouldBindJSON(&body); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, invalidRequest(err))
 		return
 	}
 
@@ -130,11 +145,11 @@ func (server *Server) updateAccount(ctx *gin.Context) {
 		Balance: body.NewBalance,
 	})
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, accountNotFoundError(err, uri.ID))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, account)
@@ -147,17 +162,27 @@ type deleteAccountUri struct {
 func (server *Server) deleteAccount(ctx *gin.Context) {
 	var uri deleteAccountUri
 	if err := ctx.ShouldBindUri(&uri); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, invalidRequest(err))
 		return
 	}
 	if err := server.store.DeleteAccount(ctx, uri.ID); err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, accountNotFoundError(err, uri.ID))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusNoContent, nil)
 
 }
+
+func accountNotFoundError(err error, accountID int64) error {
+	return apperror.Wrap(err, apperror.CodeAccountNotFound, "account not found").
+		With("account_id", strconv.FormatInt(accountID, 10))
+}
+
+func accountNotOwnedError(accountID int64) error {
+	return apperror.New(apperror.CodeAccountNotOwned, "account does not belong to the authenticated user").
+		With("account_id", strconv.FormatInt(accountID, 10))
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func randomAccountProduct() db.AccountProduct {
+	return db.AccountProduct{
+		Code:          util.RandomString(8),
+		Name:          util.RandomOwner(),
+		AnnualRatePpm: util.RandomInt(0, 50_000),
+		DayCount:      "ACT/365",
+		Compounding:   "monthly",
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func TestListAccountProductsAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	products := []db.AccountProduct{randomAccountProduct(), randomAccountProduct()}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccountProducts(gomock.Any()).
+					Times(1).
+					Return(products, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got []db.AccountProduct
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, products, got)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccountProducts(gomock.Any()).
+					Times(1).
+					Return(nil, errors.New("some error"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/account_products", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestCreateAccountWithProductAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	product := randomAccountProduct()
+
+	testCases := []struct {
+		name          string
+		product       string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "OK",
+			product: product.Code,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccountProduct(gomock.Any(), gomock.Eq(product.Code)).
+					Times(1).
+					Return(product, nil)
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Eq(db.CreateAccountParams{
+						Owner:    user.Username,
+						Currency: util.USD,
+						Product:  pgtype.Text{String: product.Code, Valid: true},
+					})).
+					Times(1).
+					Return(db.Account{ID: 1, Owner: user.Username, Currency: util.USD, Product: product.Code}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var account db.Account
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &account))
+				require.Equal(t, product.Code, account.Product)
+			},
+		},
+		{
+			name:    "UnknownProduct",
+			product: product.Code,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccountProduct(gomock.Any(), gomock.Eq(product.Code)).
+					Times(1).
+					Return(db.AccountProduct{}, db.ErrRecordNotFound)
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusBadRequest, "INVALID_ARGUMENT")
+			},
+		},
+		{
+			name:    "InternalError",
+			product: product.Code,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccountProduct(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.AccountProduct{}, errors.New("some error"))
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"currency": util.USD, "product": tc.product})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/accounts", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
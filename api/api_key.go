@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/apikey"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
+)
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,scope"`
+	// ExpiresInDays defaults to the configured API key duration
+	ExpiresInDays int `json:"expires_in_days" binding:"omitempty,min=1"`
+}
+
+type apiKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	IsRevoked  bool       `json:"is_revoked"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(apiKey db.ApiKey) apiKeyResponse {
+	rsp := apiKeyResponse{
+		ID:        apiKey.ID,
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		IsRevoked: apiKey.IsRevoked,
+		ExpiresAt: apiKey.ExpiresAt,
+		CreatedAt: apiKey.CreatedAt,
+	}
+	if apiKey.LastUsedAt.Valid {
+		rsp.LastUsedAt = &apiKey.LastUsedAt.Time
+	}
+	return rsp
+}
+
+type createAPIKeyResponse struct {
+	// Key is only returned once: it is stored hashed
+	Key    string         `json:"key"`
+	APIKey apiKeyResponse `json:"api_key"`
+}
+
+func (server *Server) createAPIKey(ctx *gin.Context) {
+	var req createAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	key, apiKey, err := server.apiKeys.Create(ctx, apikey.CreateParams{
+		Username: authPayload.Username,
+		Name:     req.Name,
+		Scopes:   req.Scopes,
+		Duration: time.Duration(req.ExpiresInDays) * 24 * time.Hour,
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, createAPIKeyResponse{
+		Key:    key,
+		APIKey: newAPIKeyResponse(apiKey),
+	})
+}
+
+type listAPIKeysRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+func (server *Server) listAPIKeys(ctx *gin.Context) {
+	var req listAPIKeysRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	apiKeys, err := server.store.ListAPIKeys(ctx, db.ListAPIKeysParams{
+		Username: authPayload.Username,
+		Limit:    int64(req.PageSize),
+		Offset:   int64(req.PageID-1) * int64(req.PageSize),
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	rsp := make([]apiKeyResponse, len(apiKeys))
+	for i, apiKey := range apiKeys {
+		rsp[i] = newAPIKeyResponse(apiKey)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type revokeAPIKeyURI struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+func (server *Server) revokeAPIKey(ctx *gin.Context) {
+	var uri revokeAPIKeyURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	// users can only revoke their own keys; other keys look like they do not exist
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	apiKey, err := server.store.RevokeAPIKey(ctx, db.RevokeAPIKeyParams{
+		ID:       uuid.MustParse(uri.ID),
+		Username: authPayload.Username,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodeAPIKeyNotFound, "api key not found"))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAPIKeyResponse(apiKey))
+}
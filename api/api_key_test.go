@@ -0,0 +1,352 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/apikey"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreateAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	key, apiKey := newTestAPIKey(t, user.Username, []string{util.AccountsReadScope})
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"name":            "reporting",
+				"scopes":          []string{util.AccountsReadScope, util.TransfersWriteScope},
+				"expires_in_days": 7,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, "reporting", arg.Name)
+						require.Equal(t, []string{util.AccountsReadScope, util.TransfersWriteScope}, arg.Scopes)
+						require.WithinDuration(t, time.Now().Add(7*24*time.Hour), arg.ExpiresAt, time.Second)
+						return db.ApiKey{
+							ID:        arg.ID,
+							Username:  arg.Username,
+							Name:      arg.Name,
+							Prefix:    arg.Prefix,
+							HashedKey: arg.HashedKey,
+							Scopes:    arg.Scopes,
+							ExpiresAt: arg.ExpiresAt,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp createAPIKeyResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.True(t, apikey.IsKey(rsp.Key))
+				require.Equal(t, rsp.Key[:len(rsp.APIKey.Prefix)], rsp.APIKey.Prefix)
+				require.NotContains(t, recorder.Body.String(), "hashed_key")
+			},
+		},
+		{
+			name: "UnsupportedScope",
+			body: gin.H{
+				"name":   "reporting",
+				"scopes": []string{"users:write"},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "DurationTooLong",
+			body: gin.H{
+				"name":            "reporting",
+				"scopes":          []string{util.AccountsReadScope},
+				"expires_in_days": 1000,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "APIKeyCannotCreateKeys",
+			body: gin.H{
+				"name":   "reporting",
+				"scopes": []string{util.AccountsReadScope},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeAPIKey, key))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				allowAPIKey(store, apiKey)
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			body: gin.H{
+				"name":   "reporting",
+				"scopes": []string{util.AccountsReadScope},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/api_keys", getReaderFor(t, tc.body))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListAPIKeysAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	n := 5
+	apiKeys := make([]db.ApiKey, n)
+	for i := range apiKeys {
+		_, apiKeys[i] = newTestAPIKey(t, user.Username, []string{util.AccountsReadScope})
+	}
+	apiKeys[0].LastUsedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	testCases := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: fmt.Sprintf("page_id=%d&page_size=%d", 1, n),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAPIKeysParams{
+					Username: user.Username,
+					Limit:    int64(n),
+					Offset:   0,
+				}
+				store.EXPECT().
+					ListAPIKeys(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(apiKeys, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp []apiKeyResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Len(t, rsp, n)
+				require.Equal(t, apiKeys[0].Prefix, rsp[0].Prefix)
+				require.NotNil(t, rsp[0].LastUsedAt)
+				require.Nil(t, rsp[1].LastUsedAt)
+				require.NotContains(t, recorder.Body.String(), "hashed_key")
+			},
+		},
+		{
+			name:  "InvalidPageSize",
+			query: fmt.Sprintf("page_id=%d&page_size=%d", 1, 100),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAPIKeys(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: fmt.Sprintf("page_id=%d&page_size=%d", 1, n),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAPIKeys(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.ApiKey{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/api_keys?"+tc.query, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRevokeAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	_, apiKey := newTestAPIKey(t, user.Username, []string{util.AccountsReadScope})
+
+	testCases := []struct {
+		name          string
+		id            string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			id:   apiKey.ID.String(),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.RevokeAPIKeyParams{
+					ID:       apiKey.ID,
+					Username: user.Username,
+				}
+				revoked := apiKey
+				revoked.IsRevoked = true
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(revoked, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp apiKeyResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Equal(t, apiKey.ID, rsp.ID)
+				require.True(t, rsp.IsRevoked)
+			},
+		},
+		{
+			name: "NotFound",
+			id:   uuid.NewString(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKey{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidID",
+			id:   "1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodDelete, "/api_keys/"+tc.id, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
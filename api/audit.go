@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
+)
+
+// listAuditEventsRequest filters the audit log. Filters left empty match every event.
+type listAuditEventsRequest struct {
+	Actor        string    `form:"actor"`
+	Action       string    `form:"action"`
+	ResourceType string    `form:"resource_type"`
+	ResourceID   string    `form:"resource_id"`
+	Since        time.Time `form:"since"`
+	Until        time.Time `form:"until"`
+	PageID       int32     `form:"page_id" binding:"required,min=1"`
+	PageSize     int32     `form:"page_size" binding:"required,min=5,max=50"`
+}
+
+type auditEventResponse struct {
+	ID           int64           `json:"id"`
+	Actor        string          `json:"actor"`
+	ActorRole    string          `json:"actor_role"`
+	ClientIP     string          `json:"client_ip"`
+	UserAgent    string          `json:"user_agent"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+func newAuditEventResponse(event db.AuditEvent) auditEventResponse {
+	return auditEventResponse{
+		ID:           event.ID,
+		Actor:        event.Actor,
+		ActorRole:    event.ActorRole,
+		ClientIP:     event.ClientIp,
+		UserAgent:    event.UserAgent,
+		Action:       event.Action,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		Before:       auditState(event.Before),
+		After:        auditState(event.After),
+		CreatedAt:    event.CreatedAt,
+	}
+}
+
+// auditState keeps the JSON of a state as it is, and a missing state as null
+func auditState(state []byte) json.RawMessage {
+	if state == nil {
+		return json.RawMessage("null")
+	}
+	return state
+}
+
+// listAuditEvents returns the audit log, newest first. Admins only.
+func (server *Server) listAuditEvents(ctx *gin.Context) {
+	var req listAuditEventsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != util.AdminRole {
+		abortWithError(ctx, apperror.New(apperror.CodePermissionDenied, "only admins can read the audit log"))
+		return
+	}
+
+	events, err := server.store.ListAuditEvents(ctx, db.ListAuditEventsParams{
+		Actor:        optionalText(req.Actor),
+		Action:       optionalText(req.Action),
+		ResourceType: optionalText(req.ResourceType),
+		ResourceID:   optionalText(req.ResourceID),
+		Since:        optionalTime(req.Since),
+		Until:        optionalTime(req.Until),
+		Limit:        int64(req.PageSize),
+		Offset:       int64(req.PageID-1) * int64(req.PageSize),
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	rsp := make([]auditEventResponse, len(events))
+	for i, event := range events {
+		rsp[i] = newAuditEventResponse(event)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+func optionalText(s string) pgtype.Text {
+	return pgtype.Text{String: s, Valid: s != ""}
+}
+
+func optionalTime(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t, Valid: !t.IsZero()}
+}
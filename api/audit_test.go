@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestListAuditEventsAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = util.AdminRole
+	depositor, _ := randomUser(t)
+
+	since := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	events := []db.AuditEvent{
+		{
+			ID:           2,
+			Actor:        depositor.Username,
+			ActorRole:    depositor.Role,
+			Action:       db.AuditAccountDelete,
+			ResourceType: db.AuditResourceAccount,
+			ResourceID:   "1",
+			Before:       []byte(`{"id":1,"balance":0}`),
+			CreatedAt:    time.Now(),
+		},
+		{
+			ID:           1,
+			Actor:        depositor.Username,
+			ActorRole:    depositor.Role,
+			Action:       db.AuditAccountCreate,
+			ResourceType: db.AuditResourceAccount,
+			ResourceID:   "1",
+			After:        []byte(`{"id":1,"balance":0}`),
+			CreatedAt:    time.Now(),
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		query         url.Values
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: admin,
+			query: url.Values{
+				"page_id":       {"2"},
+				"page_size":     {"5"},
+				"actor":         {depositor.Username},
+				"resource_type": {db.AuditResourceAccount},
+				"since":         {since.Format(time.RFC3339)},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAuditEventsParams{
+					Actor:        pgtype.Text{String: depositor.Username, Valid: true},
+					ResourceType: pgtype.Text{String: db.AuditResourceAccount, Valid: true},
+					Since:        pgtype.Timestamptz{Time: since, Valid: true},
+					Limit:        5,
+					Offset:       5,
+				}
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, got db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+						require.True(t, arg.Since.Time.Equal(got.Since.Time))
+						got.Since.Time = arg.Since.Time
+						require.Equal(t, arg, got)
+						// the store sees who is asking
+						require.Equal(t, admin.Username, db.AuditActorFrom(ctx).Username)
+						return events, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp []auditEventResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Len(t, rsp, len(events))
+				require.Equal(t, db.AuditAccountDelete, rsp[0].Action)
+				require.JSONEq(t, `{"id":1,"balance":0}`, string(rsp[0].Before))
+				require.Equal(t, "null", string(rsp[0].After))
+			},
+		},
+		{
+			name: "NotAdmin",
+			user: depositor,
+			query: url.Values{
+				"page_id":   {"1"},
+				"page_size": {"5"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusForbidden, "PERMISSION_DENIED")
+			},
+		},
+		{
+			name: "InvalidPageSize",
+			user: admin,
+			query: url.Values{
+				"page_id":   {"1"},
+				"page_size": {"100"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidSince",
+			user: admin,
+			query: url.Values{
+				"page_id":   {"1"},
+				"page_size": {"5"},
+				"since":     {"yesterday"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			user: admin,
+			query: url.Values{
+				"page_id":   {"1"},
+				"page_size": {"5"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.AuditEvent{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/audit_events?%s", tc.query.Encode()), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, tc.user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
+)
+
+type getAccountBalanceUri struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type getAccountBalanceQuery struct {
+	// AsOf defaults to now
+	AsOf time.Time `form:"as_of"`
+}
+
+type accountBalanceResponse struct {
+	AccountID int64     `json:"account_id"`
+	Balance   int64     `json:"balance"`
+	Currency  string    `json:"currency"`
+	AsOf      time.Time `json:"as_of"`
+}
+
+// getAccountBalance returns the balance of an account at a point in time
+func (server *Server) getAccountBalance(ctx *gin.Context) {
+	var uri getAccountBalanceUri
+	var query getAccountBalanceQuery
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	now := time.Now()
+	if query.AsOf.IsZero() {
+		query.AsOf = now
+	}
+	if query.AsOf.After(now) {
+		abortWithError(ctx, apperror.InvalidArgument(apperror.FieldViolation{Field: "as_of", Description: "must not be in the future"}))
+		return
+	}
+
+	result, err := server.store.GetBalanceAsOf(ctx, db.GetBalanceAsOfParams{
+		AccountID: uri.ID,
+		AsOf:      query.AsOf,
+	})
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, accountNotFoundError(err, uri.ID))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Username != result.Account.Owner {
+		abortWithError(ctx, accountNotOwnedError(result.Account.ID))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, accountBalanceResponse{
+		AccountID: result.Account.ID,
+		Balance:   result.Balance,
+		Currency:  result.Account.Currency,
+		AsOf:      result.AsOf,
+	})
+}
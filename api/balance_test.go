@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetAccountBalanceAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	asOf := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second)
+
+	testCases := []struct {
+		name          string
+		accountID     int64
+		query         url.Values
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			accountID: account.ID,
+			query:     url.Values{"as_of": {asOf.Format(time.RFC3339)}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetBalanceAsOf(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.GetBalanceAsOfParams) (db.GetBalanceAsOfResult, error) {
+						require.Equal(t, account.ID, arg.AccountID)
+						require.True(t, asOf.Equal(arg.AsOf))
+						return db.GetBalanceAsOfResult{Account: account, Balance: 42, AsOf: arg.AsOf}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp accountBalanceResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, account.ID, rsp.AccountID)
+				require.Equal(t, int64(42), rsp.Balance)
+				require.Equal(t, account.Currency, rsp.Currency)
+				require.True(t, asOf.Equal(rsp.AsOf))
+			},
+		},
+		{
+			name:      "DefaultsToNow",
+			accountID: account.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetBalanceAsOf(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.GetBalanceAsOfParams) (db.GetBalanceAsOfResult, error) {
+						require.WithinDuration(t, time.Now(), arg.AsOf, time.Second)
+						return db.GetBalanceAsOfResult{Account: account, Balance: account.Balance, AsOf: arg.AsOf}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:      "NotOwned",
+			accountID: account.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetBalanceAsOf(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetBalanceAsOfResult{Account: randomAccount(other.Username)}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusForbidden, "ACCOUNT_NOT_OWNED")
+			},
+		},
+		{
+			name:      "NotFound",
+			accountID: account.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetBalanceAsOf(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetBalanceAsOfResult{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusNotFound, "ACCOUNT_NOT_FOUND")
+			},
+		},
+		{
+			name:      "FutureAsOf",
+			accountID: account.ID,
+			query:     url.Values{"as_of": {time.Now().Add(time.Hour).Format(time.RFC3339)}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetBalanceAsOf(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusBadRequest, "INVALID_ARGUMENT")
+			},
+		},
+		{
+			name:      "InvalidAsOf",
+			accountID: account.ID,
+			query:     url.Values{"as_of": {"last month"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetBalanceAsOf(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidID",
+			accountID: 0,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetBalanceAsOf(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "InternalError",
+			accountID: account.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetBalanceAsOf(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetBalanceAsOfResult{}, errors.New("some error"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/accounts/%d/balance?%s", tc.accountID, tc.query.Encode()), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/pakojabi/simplebank/apperror"
+	"github.com/pakojabi/simplebank/logger"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details object, extended with the stable error code
+type problem struct {
+	Type      string                    `json:"type"`
+	Title     string                    `json:"title"`
+	Status    int                       `json:"status"`
+	Detail    string                    `json:"detail"`
+	Instance  string                    `json:"instance"`
+	Code      apperror.Code             `json:"code"`
+	RequestID string                    `json:"request_id,omitempty"`
+	Metadata  map[string]string         `json:"metadata,omitempty"`
+	Errors    []apperror.FieldViolation `json:"errors,omitempty"`
+}
+
+// abortWithError replies with the problem details of err. Errors that are not
+// *apperror.Error are internal: they are logged, and clients only get a generic message.
+func abortWithError(ctx *gin.Context, err error) {
+	appErr := apperror.From(err)
+	if appErr.Code == apperror.CodeInternal {
+		logger.FromContext(ctx.Request.Context()).Error("request failed", slog.Any("error", err))
+	}
+
+	status := appErr.Code.HTTPStatus()
+	if appErr.RetryAfter > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
+
+	ctx.Header("Content-Type", problemContentType)
+	ctx.AbortWithStatusJSON(status, problem{
+		Type:      "urn:simplebank:error:" + string(appErr.Code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    appErr.Message,
+		Instance:  ctx.Request.URL.Path,
+		Code:      appErr.Code,
+		RequestID: logger.RequestID(ctx.Request.Context()),
+		Metadata:  appErr.Metadata,
+		Errors:    appErr.Violations,
+	})
+}
+
+// invalidRequest describes the binding error of a request. Only validation errors are detailed:
+// decoding errors mention the Go types of the request.
+func invalidRequest(err error) error {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return apperror.Wrap(err, apperror.CodeInvalidArgument, "malformed request")
+	}
+
+	violations := make([]apperror.FieldViolation, len(validationErrors))
+	for i, fieldErr := range validationErrors {
+		violations[i] = apperror.FieldViolation{
+			Field:       fieldErr.Field(),
+			Description: fieldDescription(fieldErr),
+		}
+	}
+	return apperror.InvalidArgument(violations...)
+}
+
+// fieldViolation describes a field rejected by one of the val validators
+func fieldViolation(field string, err error) apperror.FieldViolation {
+	return apperror.FieldViolation{
+		Field:       field,
+		Description: err.Error(),
+	}
+}
+
+func fieldDescription(fieldErr validator.FieldError) string {
+	if fieldErr.Param() != "" {
+		return fmt.Sprintf("failed on the %s=%s rule", fieldErr.Tag(), fieldErr.Param())
+	}
+	return fmt.Sprintf("failed on the %s rule", fieldErr.Tag())
+}
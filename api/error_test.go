@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pakojabi/simplebank/apperror"
+	"github.com/stretchr/testify/require"
+)
+
+// requireProblem checks that recorder holds the problem details of an error with the given code
+func requireProblem(t *testing.T, recorder *httptest.ResponseRecorder, status int, code apperror.Code) problem {
+	require.Equal(t, status, recorder.Code)
+	require.Equal(t, problemContentType, recorder.Header().Get("Content-Type"))
+
+	var got problem
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+	require.Equal(t, status, got.Status)
+	require.Equal(t, code, got.Code)
+	require.Equal(t, "urn:simplebank:error:"+string(code), got.Type)
+	return got
+}
+
+func TestAbortWithError(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           error
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "DomainError",
+			err:  apperror.New(apperror.CodeAccountNotFound, "account not found").With("account_id", "1"),
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				got := requireProblem(t, recorder, http.StatusNotFound, apperror.CodeAccountNotFound)
+				require.Equal(t, "account not found", got.Detail)
+				require.Equal(t, "/test", got.Instance)
+				require.Equal(t, map[string]string{"account_id": "1"}, got.Metadata)
+			},
+		},
+		{
+			name: "Internal",
+			err:  errors.New(`pq: relation "accounts" does not exist`),
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				got := requireProblem(t, recorder, http.StatusInternalServerError, apperror.CodeInternal)
+				require.Equal(t, "internal error", got.Detail)
+				require.NotContains(t, recorder.Body.String(), "accounts")
+			},
+		},
+		{
+			name: "RetryAfter",
+			err:  &apperror.Error{Code: apperror.CodeLoginLocked, Message: "too many failed login attempts", RetryAfter: 1500 * time.Millisecond},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusTooManyRequests, apperror.CodeLoginLocked)
+				require.Equal(t, "2", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name: "Violations",
+			err:  apperror.InvalidArgument(apperror.FieldViolation{Field: "amount", Description: "failed on the gt=0 rule"}),
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				got := requireProblem(t, recorder, http.StatusBadRequest, apperror.CodeInvalidArgument)
+				require.Equal(t, []apperror.FieldViolation{{Field: "amount", Description: "failed on the gt=0 rule"}}, got.Errors)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/test", nil)
+
+			abortWithError(ctx, tc.err)
+			require.True(t, ctx.IsAborted())
+			tc.checkResponse(recorder)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pakojabi/simplebank/apperror"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
+)
+
+type glBalanceResponse struct {
+	Currency string `json:"currency"`
+	Accounts int64  `json:"accounts"`
+	Balance  int64  `json:"balance"`
+}
+
+type glAccountResponse struct {
+	Code     string              `json:"code"`
+	Name     string              `json:"name"`
+	Type     string              `json:"type"`
+	Balances []glBalanceResponse `json:"balances"`
+}
+
+// listGLAccounts returns the chart of accounts, with the balance of the accounts of each GL account in every
+// currency. Admins only.
+func (server *Server) listGLAccounts(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != util.AdminRole {
+		abortWithError(ctx, apperror.New(apperror.CodePermissionDenied, "only admins can read the chart of accounts"))
+		return
+	}
+
+	glAccounts, err := server.store.ListGLAccounts(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	balances, err := server.store.ListGLBalances(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	byCode := make(map[string][]glBalanceResponse)
+	for _, balance := range balances {
+		byCode[balance.Code] = append(byCode[balance.Code], glBalanceResponse{
+			Currency: balance.Currency,
+			Accounts: balance.Accounts,
+			Balance:  balance.Balance,
+		})
+	}
+
+	rsp := make([]glAccountResponse, len(glAccounts))
+	for i, glAccount := range glAccounts {
+		rsp[i] = glAccountResponse{
+			Code:     glAccount.Code,
+			Name:     glAccount.Name,
+			Type:     glAccount.Type,
+			Balances: byCode[glAccount.Code],
+		}
+		if rsp[i].Balances == nil {
+			rsp[i].Balances = []glBalanceResponse{}
+		}
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// getTrialBalance returns the trial balance of all the accounts, which shows whether debits equal credits.
+// Admins only.
+func (server *Server) getTrialBalance(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != util.AdminRole {
+		abortWithError(ctx, apperror.New(apperror.CodePermissionDenied, "only admins can read the trial balance"))
+		return
+	}
+
+	trialBalance, err := server.store.GetTrialBalance(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, trialBalance)
+}
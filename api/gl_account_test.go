@@ -0,0 +1,188 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestListGLAccountsAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = util.AdminRole
+	depositor, _ := randomUser(t)
+
+	glAccounts := []db.GlAccount{
+		{Code: db.GLCustomerDeposits, Name: "Customer deposits", Type: db.GLLiability},
+		{Code: db.SystemFeeRevenue, Name: "Fee revenue", Type: db.GLRevenue},
+		{Code: db.SystemInterestExpense, Name: "Interest expense", Type: db.GLExpense},
+	}
+	balances := []db.ListGLBalancesRow{
+		{Code: db.GLCustomerDeposits, Type: db.GLLiability, Currency: util.EUR, Accounts: 3, Balance: 1_000},
+		{Code: db.GLCustomerDeposits, Type: db.GLLiability, Currency: util.USD, Accounts: 2, Balance: 500},
+		{Code: db.SystemInterestExpense, Type: db.GLExpense, Currency: util.USD, Accounts: 1, Balance: -20},
+	}
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListGLAccounts(gomock.Any()).Times(1).Return(glAccounts, nil)
+				store.EXPECT().ListGLBalances(gomock.Any()).Times(1).Return(balances, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp []glAccountResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, len(glAccounts))
+
+				require.Equal(t, db.GLLiability, rsp[0].Type)
+				require.Equal(t, []glBalanceResponse{
+					{Currency: util.EUR, Accounts: 3, Balance: 1_000},
+					{Currency: util.USD, Accounts: 2, Balance: 500},
+				}, rsp[0].Balances)
+				require.Empty(t, rsp[1].Balances)
+				require.Equal(t, []glBalanceResponse{{Currency: util.USD, Accounts: 1, Balance: -20}}, rsp[2].Balances)
+			},
+		},
+		{
+			name: "NotAdmin",
+			user: depositor,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListGLAccounts(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusForbidden, "PERMISSION_DENIED")
+			},
+		},
+		{
+			name: "InternalError",
+			user: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListGLAccounts(gomock.Any()).Times(1).Return(glAccounts, nil)
+				store.EXPECT().ListGLBalances(gomock.Any()).Times(1).Return(nil, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/gl_accounts", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, tc.user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetTrialBalanceAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = util.AdminRole
+	depositor, _ := randomUser(t)
+
+	trialBalance := db.NewTrialBalance([]db.ListGLBalancesRow{
+		{Code: db.GLCustomerDeposits, Type: db.GLLiability, Currency: util.USD, Accounts: 2, Balance: 120},
+		{Code: db.SystemFeeRevenue, Type: db.GLRevenue, Currency: util.USD, Accounts: 1, Balance: 30},
+		{Code: db.SystemInterestExpense, Type: db.GLExpense, Currency: util.USD, Accounts: 1, Balance: -150},
+	})
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTrialBalance(gomock.Any()).Times(1).Return(trialBalance, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp db.TrialBalance
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, trialBalance, rsp)
+				require.True(t, rsp.Balanced)
+				require.Equal(t, int64(150), rsp.Currencies[0].TotalDebits)
+				require.Equal(t, int64(150), rsp.Currencies[0].TotalCredits)
+			},
+		},
+		{
+			name: "NotAdmin",
+			user: depositor,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTrialBalance(gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusForbidden, "PERMISSION_DENIED")
+			},
+		},
+		{
+			name: "InternalError",
+			user: admin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTrialBalance(gomock.Any()).Times(1).Return(db.TrialBalance{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/trial_balance", nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, tc.user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
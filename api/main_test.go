@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"os"
@@ -11,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	db "github.com/pakojabi/simplebank/db/sqlc"
 	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/worker"
 	"github.com/stretchr/testify/require"
 )
 
@@ -19,13 +21,28 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+// testTOTPEncryptionKey is the TOTP encryption key of the test servers
+var testTOTPEncryptionKey = base64.StdEncoding.EncodeToString([]byte(util.RandomString(32)))
+
 func newTestServer(t *testing.T, store db.Store) *Server {
 	config := util.Config{
 		TokenSymmetricKey: util.RandomString(32),
 		AccessTokenDuration: time.Minute,
+		LoginMaxFailures: 5,
+		LoginMaxFailuresPerIP: 20,
+		LoginFailureWindow: 15 * time.Minute,
+		LoginLockoutDuration: time.Minute,
+		LoginChallengeDuration: 5 * time.Minute,
+		TOTPIssuer: "Simple Bank",
+		TOTPEncryptionKey: testTOTPEncryptionKey,
+		TransferStepUpThreshold: 10000,
+		APIKeyDuration: 30 * 24 * time.Hour,
+		APIKeyMaxDuration: 365 * 24 * time.Hour,
+		OAuthCodeDuration: time.Minute,
+		OAuthAccessTokenDuration: time.Hour,
 	}
 
-	server, err := NewServer(config, store)
+	server, err := NewServer(config, store, worker.NewPGTaskDistributor())
 	require.NoError(t, err)
 
 	return server
@@ -1,52 +1,153 @@
 package api
 
 import (
-	"errors"
-	"fmt"
-	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pakojabi/simplebank/apikey"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/logger"
+	"github.com/pakojabi/simplebank/metrics"
+	"github.com/pakojabi/simplebank/oauth"
 	"github.com/pakojabi/simplebank/token"
 )
 
 const (
 	authorizationHeaderKey  = "authorization"
 	authorizationTypeBearer = "bearer"
+	authorizationTypeAPIKey = "apikey"
 	authorizationPayloadKey = "authorization_payload"
 )
 
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+// authMiddleware accepts either a bearer access token or an API key.
+// Payloads of API keys and of tokens issued to OAuth clients are limited to scopes, which routes check with requireScope or requireSession.
+func authMiddleware(tokenMaker token.Maker, apiKeys *apikey.Manager, oauthProvider *oauth.Provider, store db.Store) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
 		if len(authorizationHeader) == 0 {
-			err := errors.New("authorization header is not provided")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			abortWithError(ctx, apperror.New(apperror.CodeUnauthenticated, "authorization header is not provided"))
 			return
 		}
 
 		fields := strings.Fields(authorizationHeader)
 		if len(fields) < 2 {
-			err := errors.New("authorization header format not supported")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			abortWithError(ctx, apperror.New(apperror.CodeUnauthenticated, "authorization header format not supported"))
 			return
 		}
 
+		var payload *token.Payload
+		var err error
+
 		authorizationType := strings.ToLower(fields[0])
-		if authorizationType != authorizationTypeBearer {
-			err := fmt.Errorf("unsupported authorization type: %s", authorizationType)
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		switch authorizationType {
+		case authorizationTypeBearer:
+			payload, err = tokenMaker.Verify(fields[1])
+			if err != nil {
+				abortWithError(ctx, err)
+				return
+			}
+			// tokens issued to OAuth clients are only valid as long as the user's consent
+			if err := oauthProvider.CheckToken(ctx, payload); err != nil {
+				abortWithError(ctx, err)
+				return
+			}
+		case authorizationTypeAPIKey:
+			payload, err = apiKeys.Authenticate(ctx, fields[1])
+			if err != nil {
+				abortWithError(ctx, err)
+				return
+			}
+		default:
+			err := apperror.New(apperror.CodeUnauthenticated, "unsupported authorization type")
+			abortWithError(ctx, err.With("authorization_type", authorizationType))
 			return
 		}
 
-		accessToken := fields[1]
-		payload, err := tokenMaker.Verify(accessToken)
+		// tokens and API keys issued before the last password change are no longer valid
+		passwordChangedAt, err := store.GetUserPasswordChangedAt(ctx, payload.Username)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			if err == db.ErrRecordNotFound {
+				abortWithError(ctx, token.ErrInvalidToken)
+				return
+			}
+			abortWithError(ctx, err)
+			return
+		}
+		if err := payload.ValidSince(passwordChangedAt); err != nil {
+			abortWithError(ctx, err)
 			return
 		}
 
+		logger.SetUsername(ctx.Request.Context(), payload.Username)
+		ctx.Request = ctx.Request.WithContext(db.WithAuditUser(ctx.Request.Context(), payload.Username, payload.Role))
 		ctx.Set(authorizationPayloadKey, payload)
 		ctx.Next()
 	}
 }
+
+// requireScope only lets through payloads that grant scope, i.e. access tokens and API keys with that scope
+func requireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		if err := payload.CheckScope(scope); err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// requireSession only lets through access tokens of a logged in user, e.g. to manage credentials
+func requireSession() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		if err := payload.CheckSession(); err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// metricsMiddleware records every request by route, once it has been handled
+func metricsMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		startTime := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTPRequest(metrics.StackGin, route, ctx.Request.Method, ctx.Writer.Status(), time.Since(startTime))
+	}
+}
+
+// auditMiddleware records the client of every request as the actor of the changes it makes,
+// which are anonymous until authMiddleware tells who the user is
+func auditMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		actor := db.AuditActor{
+			ClientIP:  ctx.ClientIP(),
+			UserAgent: ctx.Request.UserAgent(),
+		}
+		ctx.Request = ctx.Request.WithContext(db.WithAuditActor(ctx.Request.Context(), actor))
+		ctx.Next()
+	}
+}
+
+// loggerMiddleware logs every request once it has been handled, tagged with a request ID
+func loggerMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		startTime := time.Now()
+		requestCtx, req := logger.StartRequest(ctx.Request.Context(), ctx.GetHeader(logger.RequestIDHeader))
+		ctx.Request = ctx.Request.WithContext(requestCtx)
+		ctx.Header(logger.RequestIDHeader, req.ID())
+
+		ctx.Next()
+
+		req.LogHTTP(ctx.Request.Method, ctx.Request.URL.Path, ctx.Writer.Status(), time.Since(startTime), ctx.ClientIP())
+	}
+}
@@ -1,15 +1,27 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/apikey"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/logger"
+	"github.com/pakojabi/simplebank/metrics"
 	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 )
 
 func addAuthorization(
@@ -18,25 +30,103 @@ func addAuthorization(
 	tokenMaker token.Maker,
 	authorizationType string,
 	username string,
+	role string,
 	duration time.Duration,
 ) {
-	token, _, err := tokenMaker.Make(username, duration)
+	token, _, err := tokenMaker.Make(username, role, duration)
 	require.NoError(t, err)
 
 	authorizationHeader := fmt.Sprintf("%s %s", authorizationType, token)
 	request.Header.Set(authorizationHeaderKey, authorizationHeader)
 }
 
+// addOAuthAuthorization sets an access token issued to an OAuth client for username
+func addOAuthAuthorization(t *testing.T, request *http.Request, tokenMaker token.Maker, username string, clientID string) {
+	token, _, err := tokenMaker.MakeDelegated(username, util.DepositorRole, clientID, []string{util.AccountsReadScope}, time.Minute)
+	require.NoError(t, err)
+
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, token))
+}
+
+// allowAuthorization lets the auth middleware accept any token, as if no password had been changed since it was issued
+func allowAuthorization(store *mockdb.MockStore) {
+	store.EXPECT().
+		GetUserPasswordChangedAt(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(time.Time{}, nil)
+}
+
+// newTestAPIKey issues an API key the way the server does, and returns it along with its stored record
+func newTestAPIKey(t *testing.T, username string, scopes []string) (string, db.ApiKey) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	var apiKey db.ApiKey
+	store.EXPECT().
+		CreateAPIKey(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+			apiKey = db.ApiKey{
+				ID:        arg.ID,
+				Username:  arg.Username,
+				Name:      arg.Name,
+				Prefix:    arg.Prefix,
+				HashedKey: arg.HashedKey,
+				Scopes:    arg.Scopes,
+				ExpiresAt: arg.ExpiresAt,
+				CreatedAt: time.Now(),
+			}
+			return apiKey, nil
+		})
+
+	config := util.Config{APIKeyDuration: time.Hour, APIKeyMaxDuration: time.Hour}
+	key, _, err := apikey.NewManager(config, store).Create(context.Background(), apikey.CreateParams{
+		Username: username,
+		Name:     "test",
+		Scopes:   scopes,
+	})
+	require.NoError(t, err)
+
+	return key, apiKey
+}
+
+// allowAPIKey lets the auth middleware look up apiKey and its owner
+func allowAPIKey(store *mockdb.MockStore, apiKey db.ApiKey) {
+	store.EXPECT().
+		GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+		AnyTimes().
+		Return(apiKey, nil)
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(apiKey.Username)).
+		AnyTimes().
+		Return(db.User{Username: apiKey.Username, Role: util.DepositorRole}, nil)
+	store.EXPECT().
+		TouchAPIKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+		AnyTimes()
+}
+
 func TestAuthMiddleware(t *testing.T) {
+	key, apiKey := newTestAPIKey(t, "user", []string{util.AccountsReadScope})
+	revokedKey, revokedAPIKey := newTestAPIKey(t, "user", []string{util.AccountsReadScope})
+	revokedAPIKey.IsRevoked = true
+	oauthClient, _ := newTestOAuthClient(t, "owner", false)
+
 	testCases := []struct {
 		name          string
 		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
 			name: "OK",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(time.Now().Add(-time.Hour), nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -52,7 +142,7 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "UnsupportedAuthorization",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, "unsupported", "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, "unsupported", "user", util.DepositorRole, time.Minute)
 
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -62,7 +152,7 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "UnsupportedAuthorization",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, "", "user", time.Minute)
+				addAuthorization(t, request, tokenMaker, "", "user", util.DepositorRole, time.Minute)
 
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -72,7 +162,161 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "ExpiredToken",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", -time.Minute)
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, -time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "PasswordChangedAfterIssue",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(time.Now().Add(time.Second), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "APIKey",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeAPIKey, key))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(db.User{Username: "user", Role: util.DepositorRole}, nil)
+				store.EXPECT().
+					TouchAPIKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1)
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(time.Now().Add(-time.Hour), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "RevokedAPIKey",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeAPIKey, revokedKey))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(revokedAPIKey.Prefix)).
+					Times(1).
+					Return(revokedAPIKey, nil)
+				store.EXPECT().
+					TouchAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "APIKeyNotFound",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeAPIKey, key))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKey{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "OAuthToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addOAuthAuthorization(t, request, tokenMaker, "user", oauthClient.ID.String())
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(oauthClient.ID)).
+					Times(1).
+					Return(oauthClient, nil)
+				store.EXPECT().
+					GetOAuthConsent(gomock.Any(), gomock.Eq(db.GetOAuthConsentParams{Username: "user", ClientID: oauthClient.ID})).
+					Times(1).
+					Return(db.OauthConsent{Username: "user", ClientID: oauthClient.ID, GrantedAt: time.Now().Add(-time.Hour)}, nil)
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(time.Now().Add(-time.Hour), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "RevokedOAuthConsent",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addOAuthAuthorization(t, request, tokenMaker, "user", oauthClient.ID.String())
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(oauthClient.ID)).
+					Times(1).
+					Return(oauthClient, nil)
+				store.EXPECT().
+					GetOAuthConsent(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.OauthConsent{
+						Username:  "user",
+						ClientID:  oauthClient.ID,
+						GrantedAt: time.Now().Add(-time.Hour),
+						RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+					}, nil)
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "DeletedOAuthClient",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addOAuthAuthorization(t, request, tokenMaker, "user", oauthClient.ID.String())
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(oauthClient.ID)).
+					Times(1).
+					Return(db.OauthClient{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "UserNotFound",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq("user")).
+					Times(1).
+					Return(time.Time{}, db.ErrRecordNotFound)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -84,13 +328,21 @@ func TestAuthMiddleware(t *testing.T) {
 		tc := testCases[i]
 
 		t.Run(tc.name, func(t *testing.T) {
-			server := newTestServer(t, nil) // no need to use a store for this test
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			if tc.buildStubs != nil {
+				tc.buildStubs(store)
+			}
+
+			server := newTestServer(t, store)
 
 			// add a dummy api call to the server which uses the auth middleware
 			authPath := "/auth"
 			server.router.GET(
 				authPath,
-				authMiddleware(server.tokenMaker),
+				authMiddleware(server.tokenMaker, server.apiKeys, server.oauthProvider, server.store),
 				func(ctx *gin.Context) {
 					ctx.JSON(http.StatusOK, gin.H{})
 				},
@@ -108,3 +360,160 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 
 }
+
+func TestRequireScope(t *testing.T) {
+	readKey, readAPIKey := newTestAPIKey(t, "user", []string{util.AccountsReadScope})
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		path          string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "APIKeyWithScope",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeAPIKey, readKey))
+			},
+			path: "/read",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "APIKeyWithoutScope",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeAPIKey, readKey))
+			},
+			path: "/transfer",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "APIKeyOnSessionRoute",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeAPIKey, readKey))
+			},
+			path: "/session",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "AccessTokenHasFullAccess",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute)
+			},
+			path: "/transfer",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "AccessTokenOnSessionRoute",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute)
+			},
+			path: "/session",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			allowAPIKey(store, readAPIKey)
+
+			server := newTestServer(t, store)
+
+			ok := func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			}
+			auth := authMiddleware(server.tokenMaker, server.apiKeys, server.oauthProvider, server.store)
+			server.router.GET("/read", auth, requireScope(util.AccountsReadScope), ok)
+			server.router.GET("/transfer", auth, requireScope(util.TransfersWriteScope), ok)
+			server.router.GET("/session", auth, requireSession(), ok)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	// rejected before reaching the store, but recorded all the same
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/accounts/42", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	// the metrics are only served by the internal metrics server
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `simplebank_http_requests_total{code="401",method="GET",route="/accounts/:id",stack="gin"}`)
+}
+
+func TestLoggerMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	log, err := logger.New(util.Config{LogFormat: logger.FormatJSON}, &logs)
+	require.NoError(t, err)
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(log)
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	allowAuthorization(store)
+	server := newTestServer(t, store)
+
+	server.router.GET(
+		"/auth",
+		authMiddleware(server.tokenMaker, server.apiKeys, server.oauthProvider, server.store),
+		func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{})
+		},
+	)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/auth", nil)
+	require.NoError(t, err)
+	request.Header.Set(logger.RequestIDHeader, "request-1")
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "request-1", recorder.Header().Get(logger.RequestIDHeader))
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	require.Equal(t, "request-1", entry["request_id"])
+	require.Equal(t, "user", entry["username"])
+	require.Equal(t, "/auth", entry["path"])
+	require.Equal(t, float64(http.StatusOK), entry["status_code"])
+	require.NotContains(t, logs.String(), request.Header.Get(authorizationHeaderKey))
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/oauth"
+	"github.com/pakojabi/simplebank/token"
+)
+
+const (
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeClientCredentials = "client_credentials"
+)
+
+type registerOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,dive,url"`
+	Scopes       []string `json:"scopes" binding:"required,min=1,dive,scope"`
+	Confidential bool     `json:"confidential"`
+}
+
+type oauthClientResponse struct {
+	ClientID uuid.UUID `json:"client_id"`
+	// ClientSecret is only returned once, for confidential clients: it is stored hashed
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// registerOAuthClient registers a third-party app owned by the authenticated user
+func (server *Server) registerOAuthClient(ctx *gin.Context) {
+	var req registerOAuthClientRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	client, secret, err := server.oauthProvider.RegisterClient(ctx, oauth.RegisterClientParams{
+		Owner:        authPayload.Username,
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       req.Scopes,
+		Confidential: req.Confidential,
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, oauthClientResponse{
+		ClientID:     client.ID,
+		ClientSecret: secret,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.HashedSecret.Valid,
+		CreatedAt:    client.CreatedAt,
+	})
+}
+
+type authorizeOAuthClientRequest struct {
+	ResponseType        string `json:"response_type" binding:"required"`
+	ClientID            string `json:"client_id" binding:"required"`
+	RedirectURI         string `json:"redirect_uri" binding:"required"`
+	Scope               string `json:"scope" binding:"required"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge" binding:"required"`
+	CodeChallengeMethod string `json:"code_challenge_method" binding:"required"`
+}
+
+type authorizeOAuthClientResponse struct {
+	// RedirectTo is where the consent screen sends the user back to the client
+	RedirectTo string `json:"redirect_to"`
+}
+
+// authorizeOAuthClient is called by the consent screen once the authenticated user approves
+// an authorization request. It records the consent and hands back the authorization code.
+func (server *Server) authorizeOAuthClient(ctx *gin.Context) {
+	var req authorizeOAuthClientRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	if req.ResponseType != "code" {
+		oauthError(ctx, &oauth.Error{Code: oauth.ErrorUnsupportedResponseType, Description: "only the code response type is supported"})
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	code, err := server.oauthProvider.Authorize(ctx, oauth.AuthorizeParams{
+		Username:            authPayload.Username,
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		Scopes:              strings.Fields(req.Scope),
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+	})
+	if err != nil {
+		oauthError(ctx, err)
+		return
+	}
+
+	redirectTo, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	query := redirectTo.Query()
+	query.Set("code", code)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	redirectTo.RawQuery = query.Encode()
+
+	ctx.JSON(http.StatusOK, authorizeOAuthClientResponse{RedirectTo: redirectTo.String()})
+}
+
+type oauthTokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	Scope        string `form:"scope"`
+}
+
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// oauthToken is the OAuth 2.0 token endpoint. Clients authenticate with HTTP basic auth or form parameters.
+func (server *Server) oauthToken(ctx *gin.Context) {
+	var req oauthTokenRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		oauthError(ctx, &oauth.Error{Code: oauth.ErrorInvalidRequest, Description: "grant_type is required"})
+		return
+	}
+
+	// RFC 6749 form-encodes the credentials before putting them in the basic auth header
+	if username, password, ok := ctx.Request.BasicAuth(); ok {
+		var err error
+		if req.ClientID, err = url.QueryUnescape(username); err != nil {
+			oauthError(ctx, &oauth.Error{Code: oauth.ErrorInvalidClient, Description: "malformed client credentials"})
+			return
+		}
+		if req.ClientSecret, err = url.QueryUnescape(password); err != nil {
+			oauthError(ctx, &oauth.Error{Code: oauth.ErrorInvalidClient, Description: "malformed client credentials"})
+			return
+		}
+	}
+
+	var issued oauth.Token
+	var err error
+
+	switch req.GrantType {
+	case grantTypeAuthorizationCode:
+		issued, err = server.oauthProvider.ExchangeCode(ctx, oauth.ExchangeCodeParams{
+			ClientID:     req.ClientID,
+			ClientSecret: req.ClientSecret,
+			Code:         req.Code,
+			RedirectURI:  req.RedirectURI,
+			CodeVerifier: req.CodeVerifier,
+		})
+	case grantTypeClientCredentials:
+		issued, err = server.oauthProvider.ClientCredentials(ctx, req.ClientID, req.ClientSecret, strings.Fields(req.Scope))
+	default:
+		err = &oauth.Error{Code: oauth.ErrorUnsupportedGrantType, Description: "grant_type must be authorization_code or client_credentials"}
+	}
+	if err != nil {
+		oauthError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+	ctx.JSON(http.StatusOK, oauthTokenResponse{
+		AccessToken: issued.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(issued.Payload.ExpiredAt).Seconds()),
+		Scope:       strings.Join(issued.Payload.Scopes, " "),
+	})
+}
+
+type oauthConsentResponse struct {
+	ClientID   uuid.UUID `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// listOAuthConsents lists the clients the authenticated user has granted access to
+func (server *Server) listOAuthConsents(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	consents, err := server.store.ListOAuthConsents(ctx, authPayload.Username)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	rsp := make([]oauthConsentResponse, len(consents))
+	for i, consent := range consents {
+		rsp[i] = oauthConsentResponse{
+			ClientID:   consent.ClientID,
+			ClientName: consent.ClientName,
+			Scopes:     consent.Scopes,
+			GrantedAt:  consent.GrantedAt,
+		}
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type revokeOAuthConsentURI struct {
+	ClientID string `uri:"client_id" binding:"required,uuid"`
+}
+
+// revokeOAuthConsent withdraws the access granted to a client, which invalidates the tokens it holds
+func (server *Server) revokeOAuthConsent(ctx *gin.Context) {
+	var uri revokeOAuthConsentURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	_, err := server.store.RevokeOAuthConsent(ctx, db.RevokeOAuthConsentParams{
+		Username: authPayload.Username,
+		ClientID: uuid.MustParse(uri.ClientID),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodeOAuthConsentNotFound, "oauth consent not found"))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// oauthError replies with an RFC 6749 error response for OAuth errors, and problem details otherwise
+func oauthError(ctx *gin.Context, err error) {
+	var oauthErr *oauth.Error
+	if !errors.As(err, &oauthErr) {
+		abortWithError(ctx, err)
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == oauth.ErrorInvalidClient {
+		status = http.StatusUnauthorized
+		ctx.Header("WWW-Authenticate", `Basic realm="simplebank"`)
+	}
+	ctx.JSON(status, gin.H{
+		"error":             oauthErr.Code,
+		"error_description": oauthErr.Description,
+	})
+}
@@ -0,0 +1,640 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/oauth"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const testRedirectURI = "https://client.example.com/callback"
+
+// newTestOAuthClient registers a client the way the server does, and returns it along with its secret
+func newTestOAuthClient(t *testing.T, owner string, confidential bool) (db.OauthClient, string) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	store.EXPECT().
+		CreateOAuthClient(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateOAuthClientParams) (db.OauthClient, error) {
+			return db.OauthClient{
+				ID:           arg.ID,
+				Owner:        arg.Owner,
+				Name:         arg.Name,
+				HashedSecret: arg.HashedSecret,
+				RedirectUris: arg.RedirectUris,
+				Scopes:       arg.Scopes,
+				CreatedAt:    time.Now(),
+			}, nil
+		})
+
+	client, secret, err := oauth.NewProvider(util.Config{}, store, nil).RegisterClient(context.Background(), oauth.RegisterClientParams{
+		Owner:        owner,
+		Name:         "budgeting app",
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       []string{util.AccountsReadScope, util.TransfersWriteScope},
+		Confidential: confidential,
+	})
+	require.NoError(t, err)
+
+	return client, secret
+}
+
+func TestRegisterOAuthClientAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	key, apiKey := newTestAPIKey(t, user.Username, []string{util.AccountsReadScope})
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"name":          "budgeting app",
+				"redirect_uris": []string{testRedirectURI},
+				"scopes":        []string{util.AccountsReadScope},
+				"confidential":  true,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateOAuthClientParams) (db.OauthClient, error) {
+						require.Equal(t, user.Username, arg.Owner)
+						require.True(t, arg.HashedSecret.Valid)
+						return db.OauthClient{
+							ID:           arg.ID,
+							Owner:        arg.Owner,
+							Name:         arg.Name,
+							HashedSecret: arg.HashedSecret,
+							RedirectUris: arg.RedirectUris,
+							Scopes:       arg.Scopes,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp oauthClientResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.NotEmpty(t, rsp.ClientSecret)
+				require.True(t, rsp.Confidential)
+				require.NotContains(t, recorder.Body.String(), "hashed_secret")
+			},
+		},
+		{
+			name: "InvalidRedirectURI",
+			body: gin.H{
+				"name":          "budgeting app",
+				"redirect_uris": []string{"callback"},
+				"scopes":        []string{util.AccountsReadScope},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UnsupportedScope",
+			body: gin.H{
+				"name":          "budgeting app",
+				"redirect_uris": []string{testRedirectURI},
+				"scopes":        []string{"users:write"},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "APIKeyCannotRegisterClients",
+			body: gin.H{
+				"name":          "budgeting app",
+				"redirect_uris": []string{testRedirectURI},
+				"scopes":        []string{util.AccountsReadScope},
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, authorizationTypeAPIKey+" "+key)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				allowAPIKey(store, apiKey)
+				store.EXPECT().
+					CreateOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/oauth/clients", getReaderFor(t, tc.body))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAuthorizeOAuthClientAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	client, _ := newTestOAuthClient(t, util.RandomOwner(), false)
+	_, challenge := randomCodeVerifier(t)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"response_type":         "code",
+				"client_id":             client.ID,
+				"redirect_uri":          testRedirectURI,
+				"scope":                 util.AccountsReadScope,
+				"state":                 "xyz",
+				"code_challenge":        challenge,
+				"code_challenge_method": oauth.CodeChallengeMethodS256,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					UpsertOAuthConsent(gomock.Any(), gomock.Eq(db.UpsertOAuthConsentParams{
+						Username: user.Username,
+						ClientID: client.ID,
+						Scopes:   []string{util.AccountsReadScope},
+					})).
+					Times(1)
+				store.EXPECT().
+					CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp authorizeOAuthClientResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+
+				redirectTo, err := url.Parse(rsp.RedirectTo)
+				require.NoError(t, err)
+				require.True(t, strings.HasPrefix(rsp.RedirectTo, testRedirectURI+"?"))
+				require.NotEmpty(t, redirectTo.Query().Get("code"))
+				require.Equal(t, "xyz", redirectTo.Query().Get("state"))
+			},
+		},
+		{
+			name: "UnregisteredRedirectURI",
+			body: gin.H{
+				"response_type":         "code",
+				"client_id":             client.ID,
+				"redirect_uri":          "https://evil.example.com/callback",
+				"scope":                 util.AccountsReadScope,
+				"code_challenge":        challenge,
+				"code_challenge_method": oauth.CodeChallengeMethodS256,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					UpsertOAuthConsent(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorResponse(t, recorder, http.StatusBadRequest, oauth.ErrorInvalidRequest)
+			},
+		},
+		{
+			name: "UnsupportedResponseType",
+			body: gin.H{
+				"response_type":         "token",
+				"client_id":             client.ID,
+				"redirect_uri":          testRedirectURI,
+				"scope":                 util.AccountsReadScope,
+				"code_challenge":        challenge,
+				"code_challenge_method": oauth.CodeChallengeMethodS256,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorResponse(t, recorder, http.StatusBadRequest, oauth.ErrorUnsupportedResponseType)
+			},
+		},
+		{
+			name: "UnknownClient",
+			body: gin.H{
+				"response_type":         "code",
+				"client_id":             uuid.New(),
+				"redirect_uri":          testRedirectURI,
+				"scope":                 util.AccountsReadScope,
+				"code_challenge":        challenge,
+				"code_challenge_method": oauth.CodeChallengeMethodS256,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.OauthClient{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorResponse(t, recorder, http.StatusUnauthorized, oauth.ErrorInvalidClient)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/oauth/authorize", getReaderFor(t, tc.body))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestOAuthTokenAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	owner, _ := randomUser(t)
+	client, secret := newTestOAuthClient(t, owner.Username, true)
+	publicClient, _ := newTestOAuthClient(t, owner.Username, false)
+	verifier, challenge := randomCodeVerifier(t)
+
+	authorizationCode := db.OauthAuthorizationCode{
+		ClientID:      publicClient.ID,
+		Username:      user.Username,
+		RedirectUri:   testRedirectURI,
+		Scopes:        []string{util.AccountsReadScope},
+		CodeChallenge: challenge,
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+
+	testCases := []struct {
+		name          string
+		form          url.Values
+		setupAuth     func(request *http.Request)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "AuthorizationCode",
+			form: url.Values{
+				"grant_type":    {"authorization_code"},
+				"client_id":     {publicClient.ID.String()},
+				"code":          {"code"},
+				"redirect_uri":  {testRedirectURI},
+				"code_verifier": {verifier},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(publicClient.ID)).
+					Times(1).
+					Return(publicClient, nil)
+				store.EXPECT().
+					UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(authorizationCode, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				rsp := requireOAuthTokenResponse(t, recorder)
+				require.Equal(t, util.AccountsReadScope, rsp.Scope)
+			},
+		},
+		{
+			name: "WrongCodeVerifier",
+			form: url.Values{
+				"grant_type":    {"authorization_code"},
+				"client_id":     {publicClient.ID.String()},
+				"code":          {"code"},
+				"redirect_uri":  {testRedirectURI},
+				"code_verifier": {util.RandomString(43)},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(publicClient.ID)).
+					Times(1).
+					Return(publicClient, nil)
+				store.EXPECT().
+					UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(authorizationCode, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorResponse(t, recorder, http.StatusBadRequest, oauth.ErrorInvalidGrant)
+			},
+		},
+		{
+			name: "UsedCode",
+			form: url.Values{
+				"grant_type":    {"authorization_code"},
+				"client_id":     {publicClient.ID.String()},
+				"code":          {"code"},
+				"redirect_uri":  {testRedirectURI},
+				"code_verifier": {verifier},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(publicClient.ID)).
+					Times(1).
+					Return(publicClient, nil)
+				store.EXPECT().
+					UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.OauthAuthorizationCode{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorResponse(t, recorder, http.StatusBadRequest, oauth.ErrorInvalidGrant)
+			},
+		},
+		{
+			name: "ClientCredentialsBasicAuth",
+			form: url.Values{
+				"grant_type": {"client_credentials"},
+				"scope":      {util.AccountsReadScope},
+			},
+			setupAuth: func(request *http.Request) {
+				request.SetBasicAuth(url.QueryEscape(client.ID.String()), url.QueryEscape(secret))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(owner.Username)).
+					Times(1).
+					Return(owner, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				rsp := requireOAuthTokenResponse(t, recorder)
+				require.Equal(t, util.AccountsReadScope, rsp.Scope)
+			},
+		},
+		{
+			name: "ClientCredentialsForm",
+			form: url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {client.ID.String()},
+				"client_secret": {secret},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(owner.Username)).
+					Times(1).
+					Return(owner, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				rsp := requireOAuthTokenResponse(t, recorder)
+				require.Equal(t, strings.Join(client.Scopes, " "), rsp.Scope)
+			},
+		},
+		{
+			name: "WrongClientSecret",
+			form: url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {client.ID.String()},
+				"client_secret": {"wrong"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorResponse(t, recorder, http.StatusUnauthorized, oauth.ErrorInvalidClient)
+			},
+		},
+		{
+			name: "UnsupportedGrantType",
+			form: url.Values{
+				"grant_type": {"password"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorResponse(t, recorder, http.StatusBadRequest, oauth.ErrorUnsupportedGrantType)
+			},
+		},
+		{
+			name: "NoGrantType",
+			form: url.Values{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorResponse(t, recorder, http.StatusBadRequest, oauth.ErrorInvalidRequest)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tc.form.Encode()))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			if tc.setupAuth != nil {
+				tc.setupAuth(request)
+			}
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestOAuthConsentsAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	client, _ := newTestOAuthClient(t, util.RandomOwner(), false)
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	allowAuthorization(store)
+
+	store.EXPECT().
+		ListOAuthConsents(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return([]db.ListOAuthConsentsRow{{
+			Username:   user.Username,
+			ClientID:   client.ID,
+			Scopes:     []string{util.AccountsReadScope},
+			GrantedAt:  time.Now(),
+			ClientName: client.Name,
+		}}, nil)
+	store.EXPECT().
+		RevokeOAuthConsent(gomock.Any(), gomock.Eq(db.RevokeOAuthConsentParams{Username: user.Username, ClientID: client.ID})).
+		Times(1).
+		Return(db.OauthConsent{}, nil)
+	store.EXPECT().
+		RevokeOAuthConsent(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.OauthConsent{}, db.ErrRecordNotFound)
+
+	server := newTestServer(t, store)
+	send := func(method string, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(method, path, nil)
+		require.NoError(t, err)
+
+		addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := send(http.MethodGet, "/oauth/consents")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp []oauthConsentResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Len(t, rsp, 1)
+	require.Equal(t, client.ID, rsp[0].ClientID)
+	require.Equal(t, client.Name, rsp[0].ClientName)
+
+	recorder = send(http.MethodDelete, "/oauth/consents/"+client.ID.String())
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = send(http.MethodDelete, "/oauth/consents/"+uuid.NewString())
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = send(http.MethodDelete, "/oauth/consents/1")
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func randomCodeVerifier(t *testing.T) (string, string) {
+	verifier, err := util.RandomSecret(32)
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func requireOAuthTokenResponse(t *testing.T, recorder *httptest.ResponseRecorder) oauthTokenResponse {
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+	var rsp oauthTokenResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+	require.NoError(t, err)
+	require.NotEmpty(t, rsp.AccessToken)
+	require.Equal(t, "Bearer", rsp.TokenType)
+	require.Positive(t, rsp.ExpiresIn)
+	return rsp
+}
+
+func requireOAuthErrorResponse(t *testing.T, recorder *httptest.ResponseRecorder, status int, code string) {
+	require.Equal(t, status, recorder.Code)
+
+	var rsp struct {
+		Error string `json:"error"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+	require.NoError(t, err)
+	require.Equal(t, code, rsp.Error)
+}
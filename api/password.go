@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/worker"
+)
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required,min=6"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// changePassword sets a new password for the authenticated user. All their sessions
+// and tokens, including the one used for this request, stop working afterwards.
+func (server *Server) changePassword(ctx *gin.Context) {
+	var req changePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodeUserNotFound, "user not found"))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	if err := util.CheckPassword(req.CurrentPassword, user.HashedPassword); err != nil {
+		abortWithError(ctx, apperror.Wrap(err, apperror.CodeInvalidCredentials, "current password is incorrect"))
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(req.NewPassword)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	txResult, err := server.store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{
+		Username:       user.Username,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(txResult.User))
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// forgotPassword emails a reset link if the address belongs to a user.
+// It answers the same either way, so it can't be used to probe for accounts.
+func (server *Server) forgotPassword(ctx *gin.Context) {
+	var req forgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	user, err := server.store.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			ctx.JSON(http.StatusAccepted, gin.H{})
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	payload := &worker.PayloadSendResetPassword{
+		Username: user.Username,
+	}
+	err = server.taskDistributor.DistributeTaskSendResetPassword(ctx, server.store, payload, worker.Queue(worker.QueueCritical))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{})
+}
+
+type resetPasswordRequest struct {
+	ResetID     int64  `json:"reset_id" binding:"required,min=1"`
+	SecretCode  string `json:"secret_code" binding:"required,min=32,max=128"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+func (server *Server) resetPassword(ctx *gin.Context) {
+	var req resetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(req.NewPassword)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	txResult, err := server.store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		ResetId:        req.ResetID,
+		SecretCode:     req.SecretCode,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodeInvalidResetCode, "invalid or expired reset code"))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(txResult.User))
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/payment"
+	"github.com/pakojabi/simplebank/token"
+)
+
+type paymentRequest struct {
+	AccountID int64  `json:"account_id" binding:"required,min=1"`
+	Amount    int64  `json:"amount" binding:"required,gt=0"`
+	Currency  string `json:"currency" binding:"required,currency"`
+	Rail      string `json:"rail" binding:"required"`
+	// TOTPCode is required for withdrawals above the step-up threshold
+	TOTPCode string `json:"totp_code"`
+}
+
+// createDeposit pulls money into an account through a payment rail. The account is only credited once the
+// rail settles the deposit.
+func (server *Server) createDeposit(ctx *gin.Context) {
+	server.createPayment(ctx, db.PaymentDeposit)
+}
+
+// createWithdrawal pushes money out of an account through a payment rail. The account is debited at once, and
+// credited back if the rail fails the withdrawal.
+func (server *Server) createWithdrawal(ctx *gin.Context) {
+	server.createPayment(ctx, db.PaymentWithdrawal)
+}
+
+func (server *Server) createPayment(ctx *gin.Context, direction string) {
+	var req paymentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	account, valid := server.validAccount(ctx, req.AccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Username != account.Owner {
+		abortWithError(ctx, accountNotOwnedError(account.ID))
+		return
+	}
+
+	// an early rejection only: CreatePaymentTx checks the balance again under the lock of the account
+	if direction == db.PaymentWithdrawal && account.Balance < req.Amount {
+		err := apperror.New(apperror.CodeInsufficientFunds, "account balance is too low for this withdrawal")
+		abortWithError(ctx, err.With("account_id", strconv.FormatInt(account.ID, 10)))
+		return
+	}
+
+	// money only moves in and out of the bank once the owner verifies their email
+	if !server.requireVerifiedEmail(ctx, authPayload.Username) {
+		return
+	}
+
+	threshold := server.config.TransferStepUpThreshold
+	if direction == db.PaymentWithdrawal && threshold > 0 && req.Amount > threshold &&
+		!server.requireStepUp(ctx, authPayload.Username, req.TOTPCode) {
+		return
+	}
+
+	// like transfers, payments move money, so they run SERIALIZABLE
+	result, err := server.payments.Initiate(db.WithTxOptions(ctx, db.Serializable()), payment.PaymentParams{
+		Direction: direction,
+		Account:   account,
+		Rail:      req.Rail,
+		Amount:    req.Amount,
+	})
+	if errors.Is(err, payment.ErrUnknownRail) || errors.Is(err, payment.ErrUnsupportedCurrency) {
+		abortWithError(ctx, apperror.InvalidArgument(apperror.FieldViolation{Field: "rail", Description: err.Error()}))
+		return
+	}
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+type getPaymentRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// getPayment returns a payment of an account of the user, with its status
+func (server *Server) getPayment(ctx *gin.Context) {
+	var req getPaymentRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	payment, err := server.store.GetPayment(ctx, req.ID)
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, paymentNotFoundError(err, req.ID))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, payment.AccountID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	// the payments of other users' accounts are not found, rather than forbidden, so their ids do not leak
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Username != account.Owner {
+		abortWithError(ctx, paymentNotFoundError(db.ErrRecordNotFound, req.ID))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, payment)
+}
+
+type listPaymentsURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type listPaymentsQuery struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+}
+
+// listPayments lists the payments of an account, newest first
+func (server *Server) listPayments(ctx *gin.Context) {
+	var uri listPaymentsURI
+	var query listPaymentsQuery
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.ID)
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, accountNotFoundError(err, uri.ID))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Username != account.Owner {
+		abortWithError(ctx, accountNotOwnedError(account.ID))
+		return
+	}
+
+	payments, err := server.store.ListPayments(ctx, db.ListPaymentsParams{
+		AccountID: account.ID,
+		Limit:     int64(query.PageSize),
+		Offset:    int64(query.PageID-1) * int64(query.PageSize),
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, payments)
+}
+
+func paymentNotFoundError(err error, paymentID int64) error {
+	return apperror.Wrap(err, apperror.CodePaymentNotFound, "payment not found").
+		With("payment_id", strconv.FormatInt(paymentID, 10))
+}
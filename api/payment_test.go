@@ -0,0 +1,466 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/payment"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const testRailReference = "test_1"
+
+// testRail accepts every payment in US dollars, and never reports an outcome
+type testRail struct{}
+
+func (testRail) Name() string                  { return "test" }
+func (testRail) Supports(currency string) bool { return currency == util.USD }
+func (testRail) Submit(ctx context.Context, instruction payment.Instruction, report payment.ReportFunc) (string, error) {
+	return testRailReference, nil
+}
+
+func randomPayment(account db.Account, direction string) db.Payment {
+	return db.Payment{
+		ID:                util.RandomInt(1, 1000),
+		AccountID:         account.ID,
+		Direction:         direction,
+		Rail:              "test",
+		Amount:            util.RandomInt(1, 100),
+		Currency:          account.Currency,
+		Status:            db.PaymentPending,
+		PendingTransferID: pgtype.Int8{Int64: util.RandomInt(1, 1000), Valid: true},
+		CreatedAt:         time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func TestCreatePaymentAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.IsEmailVerified = true
+	other, _ := randomUser(t)
+
+	account := randomAccount(user.Username)
+	account.Currency = util.USD
+	account.Balance = 1_000
+	eurAccount := randomAccount(user.Username)
+	eurAccount.Currency = util.EUR
+	otherAccount := randomAccount(other.Username)
+	otherAccount.Currency = util.USD
+
+	pending := func(direction string, amount int64) db.Payment {
+		payment := randomPayment(account, direction)
+		payment.Amount = amount
+		return payment
+	}
+
+	testCases := []struct {
+		name          string
+		path          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Deposit",
+			path: "/deposits",
+			body: gin.H{"account_id": account.ID, "amount": 500, "currency": util.USD, "rail": "test"},
+			buildStubs: func(store *mockdb.MockStore) {
+				payment := pending(db.PaymentDeposit, 500)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreatePaymentTx(gomock.Any(), gomock.Eq(db.CreatePaymentTxParams{
+						AccountID: account.ID,
+						Direction: db.PaymentDeposit,
+						Rail:      "test",
+						Amount:    500,
+					})).
+					Times(1).
+					Return(db.PaymentTxResult{Payment: payment}, nil)
+
+				payment.Reference = testRailReference
+				store.EXPECT().
+					SetPaymentReference(gomock.Any(), gomock.Eq(db.SetPaymentReferenceParams{ID: payment.ID, Reference: testRailReference})).
+					Times(1).
+					Return(payment, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var result db.PaymentTxResult
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.Equal(t, db.PaymentPending, result.Payment.Status)
+				require.Equal(t, testRailReference, result.Payment.Reference)
+			},
+		},
+		{
+			name: "Withdrawal",
+			path: "/withdrawals",
+			body: gin.H{"account_id": account.ID, "amount": 1_000, "currency": util.USD, "rail": "test"},
+			buildStubs: func(store *mockdb.MockStore) {
+				payment := pending(db.PaymentWithdrawal, 1_000)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreatePaymentTx(gomock.Any(), gomock.Eq(db.CreatePaymentTxParams{
+						AccountID: account.ID,
+						Direction: db.PaymentWithdrawal,
+						Rail:      "test",
+						Amount:    1_000,
+					})).
+					Times(1).
+					Return(db.PaymentTxResult{Payment: payment}, nil)
+				store.EXPECT().SetPaymentReference(gomock.Any(), gomock.Any()).Times(1).Return(payment, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "InsufficientFunds",
+			path: "/withdrawals",
+			body: gin.H{"account_id": account.ID, "amount": 1_001, "currency": util.USD, "rail": "test"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().CreatePaymentTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusUnprocessableEntity, "INSUFFICIENT_FUNDS")
+			},
+		},
+		{
+			// the balance checked by the handler was spent concurrently, so the transaction rolls back
+			name: "InsufficientFundsInTx",
+			path: "/withdrawals",
+			body: gin.H{"account_id": account.ID, "amount": 1_000, "currency": util.USD, "rail": "test"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().CreatePaymentTx(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.PaymentTxResult{}, db.ErrInsufficientFunds.With("account_id", "1"))
+				store.EXPECT().SetPaymentReference(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusUnprocessableEntity, "INSUFFICIENT_FUNDS")
+			},
+		},
+		{
+			name: "UnknownRail",
+			path: "/deposits",
+			body: gin.H{"account_id": account.ID, "amount": 500, "currency": util.USD, "rail": "wire"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().CreatePaymentTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				got := requireProblem(t, recorder, http.StatusBadRequest, "INVALID_ARGUMENT")
+				require.Equal(t, "rail", got.Errors[0].Field)
+			},
+		},
+		{
+			name: "UnsupportedCurrency",
+			path: "/deposits",
+			body: gin.H{"account_id": eurAccount.ID, "amount": 500, "currency": util.EUR, "rail": "test"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(eurAccount.ID)).Times(1).Return(eurAccount, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().CreatePaymentTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusBadRequest, "INVALID_ARGUMENT")
+			},
+		},
+		{
+			name: "CurrencyMismatch",
+			path: "/deposits",
+			body: gin.H{"account_id": account.ID, "amount": 500, "currency": util.EUR, "rail": "test"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().CreatePaymentTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusBadRequest, "CURRENCY_MISMATCH")
+			},
+		},
+		{
+			name: "NotOwned",
+			path: "/deposits",
+			body: gin.H{"account_id": otherAccount.ID, "amount": 500, "currency": util.USD, "rail": "test"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(otherAccount.ID)).Times(1).Return(otherAccount, nil)
+				store.EXPECT().CreatePaymentTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusForbidden, "ACCOUNT_NOT_OWNED")
+			},
+		},
+		{
+			name: "EmailNotVerified",
+			path: "/deposits",
+			body: gin.H{"account_id": account.ID, "amount": 500, "currency": util.USD, "rail": "test"},
+			buildStubs: func(store *mockdb.MockStore) {
+				unverified := user
+				unverified.IsEmailVerified = false
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(unverified, nil)
+				store.EXPECT().CreatePaymentTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusForbidden, "EMAIL_NOT_VERIFIED")
+			},
+		},
+		{
+			name: "InvalidAmount",
+			path: "/withdrawals",
+			body: gin.H{"account_id": account.ID, "amount": -1, "currency": util.USD, "rail": "test"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			path: "/deposits",
+			body: gin.H{"account_id": account.ID, "amount": 500, "currency": util.USD, "rail": "test"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().CreatePaymentTx(gomock.Any(), gomock.Any()).Times(1).Return(db.PaymentTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.payments = payment.NewProcessor(store, testRail{})
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, tc.path, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetPaymentAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	settled := randomPayment(account, db.PaymentDeposit)
+	settled.Status = db.PaymentSettled
+	settled.FinalTransferID = pgtype.Int8{Int64: util.RandomInt(1, 1000), Valid: true}
+
+	testCases := []struct {
+		name          string
+		username      string
+		paymentID     int64
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			username:  user.Username,
+			paymentID: settled.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPayment(gomock.Any(), gomock.Eq(settled.ID)).Times(1).Return(settled, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got db.Payment
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, settled, got)
+			},
+		},
+		{
+			name:      "NotFound",
+			username:  user.Username,
+			paymentID: settled.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPayment(gomock.Any(), gomock.Eq(settled.ID)).Times(1).Return(db.Payment{}, db.ErrRecordNotFound)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusNotFound, "PAYMENT_NOT_FOUND")
+			},
+		},
+		{
+			name:      "OtherUser",
+			username:  other.Username,
+			paymentID: settled.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPayment(gomock.Any(), gomock.Eq(settled.ID)).Times(1).Return(settled, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusNotFound, "PAYMENT_NOT_FOUND")
+			},
+		},
+		{
+			name:      "InvalidID",
+			username:  user.Username,
+			paymentID: 0,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetPayment(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/payments/%d", tc.paymentID), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListPaymentsAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	payments := []db.Payment{
+		randomPayment(account, db.PaymentWithdrawal),
+		randomPayment(account, db.PaymentDeposit),
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			query:    "page_id=2&page_size=5",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					ListPayments(gomock.Any(), gomock.Eq(db.ListPaymentsParams{AccountID: account.ID, Limit: 5, Offset: 5})).
+					Times(1).
+					Return(payments, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got []db.Payment
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, payments, got)
+			},
+		},
+		{
+			name:     "NotOwned",
+			username: other.Username,
+			query:    "page_id=1&page_size=5",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListPayments(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusForbidden, "ACCOUNT_NOT_OWNED")
+			},
+		},
+		{
+			name:     "AccountNotFound",
+			username: user.Username,
+			query:    "page_id=1&page_size=5",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, db.ErrRecordNotFound)
+				store.EXPECT().ListPayments(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusNotFound, "ACCOUNT_NOT_FOUND")
+			},
+		},
+		{
+			name:     "InvalidPageSize",
+			username: user.Username,
+			query:    "page_id=1&page_size=50",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/payments?%s", account.ID, tc.query)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -2,37 +2,64 @@ package api
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"github.com/pakojabi/simplebank/apikey"
 	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/lockout"
+	"github.com/pakojabi/simplebank/oauth"
+	"github.com/pakojabi/simplebank/payment"
 	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/twofactor"
 	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/worker"
 )
 
 // Server serves http requests
 type Server struct {
-	config     util.Config
-	store      db.Store
-	tokenMaker token.Maker
-	router     *gin.Engine
+	config          util.Config
+	store           db.Store
+	tokenMaker      token.Maker
+	taskDistributor worker.TaskDistributor
+	loginGuard      *lockout.Guard
+	stepUpGuard     *lockout.Guard
+	twoFactor       *twofactor.Authenticator
+	apiKeys         *apikey.Manager
+	oauthProvider   *oauth.Provider
+	payments        *payment.Processor
+	router          *gin.Engine
 }
 
 // NewServer creates a server instance ands sets up routing
-func NewServer(config util.Config, store db.Store) (*Server, error) {
+func NewServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor) (*Server, error) {
 	tokenMaker, err := token.NewJWTMaker(config.TokenSymmetricKey)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token maker: %w", err)
 	}
+	twoFactor, err := twofactor.NewAuthenticator(config, store)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create two-factor authenticator: %w", err)
+	}
 	server := &Server{
-		config:     config,
-		store:      store,
-		tokenMaker: tokenMaker,
+		config:          config,
+		store:           store,
+		tokenMaker:      tokenMaker,
+		taskDistributor: taskDistributor,
+		loginGuard:      lockout.NewGuard(config, store),
+		stepUpGuard:     lockout.NewStepUpGuard(config, store),
+		twoFactor:       twoFactor,
+		apiKeys:         apikey.NewManager(config, store),
+		oauthProvider:   oauth.NewProvider(config, store, tokenMaker),
+		payments:        payment.NewSimulatedProcessor(config, store),
 	}
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("currency", validCurrency)
+		v.RegisterValidation("scope", validScope)
+		v.RegisterTagNameFunc(fieldName)
 	}
 
 	server.setupRouter()
@@ -41,25 +68,60 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 }
 
 func (server *Server) setupRouter() {
-	router := gin.Default()
+	// gin's own access log is replaced by the structured one of loggerMiddleware
+	router := gin.New()
+	// handlers pass their gin.Context to the store, which needs the values of the request context, e.g. the audit actor
+	router.ContextWithFallback = true
+	router.Use(loggerMiddleware(), gin.Recovery(), metricsMiddleware(), auditMiddleware())
+
 	router.POST("/users", server.createUser)
 	router.POST("/users/login", server.loginUser)
+	router.POST("/users/login/totp", server.loginUserTOTP)
 	router.POST("/tokens/renew_access", server.renewAccessToken)
+	router.GET("/users/verify_email", server.verifyEmail)
+	router.POST("/users/forgot_password", server.forgotPassword)
+	router.POST("/users/reset_password", server.resetPassword)
+	router.POST("/oauth/token", server.oauthToken)
 
-	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
-	authRoutes.POST("/accounts", server.createAccount)
-	authRoutes.GET("/accounts/:id", server.getAccount)
-	authRoutes.GET("/accounts", server.listAccounts)
-	authRoutes.PUT("/accounts/:id", server.updateAccount)
-	authRoutes.DELETE("/accounts/:id", server.deleteAccount)
-	authRoutes.POST("/transfers", server.createTransfer)
+	// API keys only reach the routes one of their scopes is required for
+	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker, server.apiKeys, server.oauthProvider, server.store))
+	authRoutes.PUT("/users/password", requireSession(), server.changePassword)
+	authRoutes.POST("/users/totp", requireSession(), server.enrollTOTP)
+	authRoutes.POST("/users/totp/confirm", requireSession(), server.confirmTOTP)
+	authRoutes.POST("/users/totp/disable", requireSession(), server.disableTOTP)
+	authRoutes.PATCH("/users/:username", requireSession(), server.updateUser)
+	authRoutes.POST("/users/:username/unlock", requireSession(), server.unlockUser)
+	authRoutes.GET("/audit_events", requireSession(), server.listAuditEvents)
+	authRoutes.GET("/gl_accounts", requireSession(), server.listGLAccounts)
+	authRoutes.GET("/trial_balance", requireSession(), server.getTrialBalance)
+	authRoutes.POST("/api_keys", requireSession(), server.createAPIKey)
+	authRoutes.GET("/api_keys", requireSession(), server.listAPIKeys)
+	authRoutes.DELETE("/api_keys/:id", requireSession(), server.revokeAPIKey)
+	authRoutes.POST("/oauth/clients", requireSession(), server.registerOAuthClient)
+	authRoutes.POST("/oauth/authorize", requireSession(), server.authorizeOAuthClient)
+	authRoutes.GET("/oauth/consents", requireSession(), server.listOAuthConsents)
+	authRoutes.DELETE("/oauth/consents/:client_id", requireSession(), server.revokeOAuthConsent)
+	authRoutes.GET("/account_products", requireScope(util.AccountsReadScope), server.listAccountProducts)
+	authRoutes.POST("/accounts", requireScope(util.AccountsWriteScope), server.createAccount)
+	authRoutes.GET("/accounts/:id", requireScope(util.AccountsReadScope), server.getAccount)
+	authRoutes.GET("/accounts/:id/balance", requireScope(util.AccountsReadScope), server.getAccountBalance)
+	authRoutes.GET("/accounts", requireScope(util.AccountsReadScope), server.listAccounts)
+	authRoutes.PUT("/accounts/:id", requireScope(util.AccountsWriteScope), server.updateAccount)
+	authRoutes.DELETE("/accounts/:id", requireScope(util.AccountsWriteScope), server.deleteAccount)
+	authRoutes.POST("/transfers", requireScope(util.TransfersWriteScope), server.createTransfer)
+	authRoutes.GET("/transfers/quote", requireScope(util.AccountsReadScope), server.quoteTransfer)
+	authRoutes.POST("/deposits", requireScope(util.TransfersWriteScope), server.createDeposit)
+	authRoutes.POST("/withdrawals", requireScope(util.TransfersWriteScope), server.createWithdrawal)
+	authRoutes.GET("/payments/:id", requireScope(util.AccountsReadScope), server.getPayment)
+	authRoutes.GET("/accounts/:id/payments", requireScope(util.AccountsReadScope), server.listPayments)
 
 	router.SetTrustedProxies(nil)
 	server.router = router
 }
 
-func errorResponse(err error) gin.H {
-	return gin.H{"error": err.Error()}
+// Handler returns the router, to be served by an http.Server
+func (server *Server) Handler() http.Handler {
+	return server.router
 }
 
 // Start runs the HTTP server on a specific address
@@ -1,12 +1,13 @@
 package api
 
 import (
-	"database/sql"
-	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
 )
 
 type renewAccessTokenRequest struct {
@@ -21,53 +22,49 @@ type renewAccessTokenResponse struct {
 func (server *Server) renewAccessToken(ctx *gin.Context) {
 	var req renewAccessTokenRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, invalidRequest(err))
 		return
 	}
 
 	payload, err := server.tokenMaker.Verify(req.RefreshToken)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 
 	session, err := server.store.GetSession(ctx, payload.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodeSessionNotFound, "session not found"))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 
 	if session.IsBlocked {
-		err := fmt.Errorf("blocked session")
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		abortWithError(ctx, apperror.New(apperror.CodeInvalidSession, "blocked session"))
 		return
 	}
 
 	if session.Username != payload.Username {
-		err := fmt.Errorf("user mismatch")
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		abortWithError(ctx, apperror.New(apperror.CodeInvalidSession, "user mismatch"))
 		return
 	}
 
 	if session.RefreshToken != req.RefreshToken {
-		err := fmt.Errorf("refresh token mismatch")
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		abortWithError(ctx, apperror.New(apperror.CodeInvalidSession, "refresh token mismatch"))
 		return
 	}
 
 	if time.Now().After(payload.ExpiredAt) {
-		err := fmt.Errorf("refresh token is expired")
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		abortWithError(ctx, token.ErrExpiredToken)
 		return
 	}
 
-	accessToken, _, err := server.tokenMaker.Make(payload.Username, server.config.AccessTokenDuration)
+	accessToken, _, err := server.tokenMaker.Make(payload.Username, payload.Role, server.config.AccessTokenDuration)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 
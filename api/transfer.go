@@ -1,13 +1,13 @@
 package api
 
 import (
-	"database/sql"
-	"errors"
-	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pakojabi/simplebank/apperror"
 	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/fee"
 	"github.com/pakojabi/simplebank/token"
 )
 
@@ -16,13 +16,15 @@ type transferRequest struct {
 	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1"`
 	Amount        int64  `json:"amount" binding:"required,gt=0"`
 	Currency      string `json:"currency" binding:"required,currency"`
+	// TOTPCode is required for transfers above the step-up threshold
+	TOTPCode string `json:"totp_code"`
 }
 
 func (server *Server) createTransfer(ctx *gin.Context) {
 	var req transferRequest
 	// validation - with the help of gin's validator
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, invalidRequest(err))
 		return
 	}
 
@@ -33,7 +35,25 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 	if authPayload.Username != fromAccount.Owner {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("authenticated user cannot operate on this account")))
+		abortWithError(ctx, accountNotOwnedError(fromAccount.ID))
+		return
+	}
+
+	// the sender pays the fee on top of the amount
+	quote, valid := server.transferFee(ctx, req.Currency, req.Amount)
+	if !valid {
+		return
+	}
+
+	// an early rejection only: TransferTx checks the balance again under the lock of the account
+	if fromAccount.Balance < req.Amount+quote.Total {
+		err := apperror.New(apperror.CodeInsufficientFunds, "account balance is too low for this transfer")
+		abortWithError(ctx, err.With("account_id", strconv.FormatInt(fromAccount.ID, 10)))
+		return
+	}
+
+	// outgoing transfers are blocked until the owner verifies their email
+	if !server.requireVerifiedEmail(ctx, authPayload.Username) {
 		return
 	}
 
@@ -42,37 +62,98 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 		return
 	}
 
+	threshold := server.config.TransferStepUpThreshold
+	if threshold > 0 && req.Amount > threshold && !server.requireStepUp(ctx, authPayload.Username, req.TOTPCode) {
+		return
+	}
 
 
 	arg := db.TransferTxParams{
 		FromAccountID: req.FromAccountID,
 		ToAccountID:   req.ToAccountID,
 		Amount:        req.Amount,
+		FeeSchedule:   server.config.TransferFeeSchedule,
 	}
 
-	result, err := server.store.TransferTx(ctx, arg)
+	// transfers move money, so they run SERIALIZABLE: the store retries the ones aborted by a conflict
+	result, err := server.store.TransferTx(db.WithTxOptions(ctx, db.Serializable()), arg)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, result)
 }
 
+// transferFee quotes the fee of a transfer with the fee schedule of the server. Transfers are free without one.
+func (server *Server) transferFee(ctx *gin.Context, currency string, amount int64) (fee.Breakdown, bool) {
+	if server.config.TransferFeeSchedule == "" {
+		return fee.Breakdown{}, true
+	}
+
+	quote, err := server.store.QuoteTransferFee(ctx, db.QuoteTransferFeeParams{
+		Schedule: server.config.TransferFeeSchedule,
+		Currency: currency,
+		Amount:   amount,
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return quote, false
+	}
+	return quote, true
+}
+
+type quoteTransferRequest struct {
+	Amount   int64  `form:"amount" binding:"required,gt=0"`
+	Currency string `form:"currency" binding:"required,currency"`
+}
+
+type quoteTransferResponse struct {
+	Amount   int64         `json:"amount"`
+	Currency string        `json:"currency"`
+	Fee      fee.Breakdown `json:"fee"`
+	// Total is what the sender is charged: the amount and its fee
+	Total int64 `json:"total"`
+}
+
+// quoteTransfer computes the fee of a transfer without moving any money
+func (server *Server) quoteTransfer(ctx *gin.Context) {
+	var req quoteTransferRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	quote, valid := server.transferFee(ctx, req.Currency, req.Amount)
+	if !valid {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, quoteTransferResponse{
+		Amount:   req.Amount,
+		Currency: req.Currency,
+		Fee:      quote,
+		Total:    req.Amount + quote.Total,
+	})
+}
+
 func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency string) (db.Account, bool) {
 	account, err := server.store.GetAccount(ctx, accountID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, accountNotFoundError(err, accountID))
 			return account, false
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return account, false
 	}
 
 	if account.Currency != currency {
-		err := fmt.Errorf("account [%d] currency mismatch %s vs %s", accountID, account.Currency, currency)
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		err := apperror.New(apperror.CodeCurrencyMismatch, "account currency does not match the transfer currency").
+			With("account_id", strconv.FormatInt(accountID, 10)).
+			With("account_currency", account.Currency).
+			With("currency", currency)
+		abortWithError(ctx, err)
 		return account, false
 	}
 	return account, true
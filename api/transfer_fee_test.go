@@ -0,0 +1,238 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/fee"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const testFeeSchedule = "standard"
+
+func TestTransferWithFeeAPI(t *testing.T) {
+	amount := int64(1000)
+	quote := fee.Breakdown{RuleID: 1, Flat: 25, Total: 25}
+
+	user1, _ := randomUser(t)
+	user2, _ := randomUser(t)
+	user1.IsEmailVerified = true
+
+	account1 := randomAccount(user1.Username)
+	account2 := randomAccount(user2.Username)
+	account1.Currency = util.USD
+	account2.Currency = util.USD
+	account1.Balance = amount + quote.Total
+
+	quoteArg := db.QuoteTransferFeeParams{Schedule: testFeeSchedule, Currency: util.USD, Amount: amount}
+
+	testCases := []struct {
+		name          string
+		balance       int64
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "OK",
+			balance: amount + quote.Total,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Eq(quoteArg)).Times(1).Return(quote, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user1.Username)).Times(1).Return(user1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+
+				arg := db.TransferTxParams{
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        amount,
+					FeeSchedule:   testFeeSchedule,
+				}
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.TransferTxResult{Fee: &db.FeeCharge{Schedule: testFeeSchedule, Breakdown: quote}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var result db.TransferTxResult
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.NotNil(t, result.Fee)
+				require.Equal(t, quote, result.Fee.Breakdown)
+			},
+		},
+		{
+			name:    "InsufficientFundsForFee",
+			balance: amount + quote.Total - 1,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Eq(quoteArg)).Times(1).Return(quote, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblem(t, recorder, http.StatusUnprocessableEntity, "INSUFFICIENT_FUNDS")
+			},
+		},
+		{
+			name:    "QuoteError",
+			balance: amount + quote.Total,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Any()).Times(1).Return(fee.Breakdown{}, errors.New("some error"))
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			account1.Balance = tc.balance
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.config.TransferFeeSchedule = testFeeSchedule
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount,
+				"currency":        util.USD,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user1.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestQuoteTransferAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	quote := fee.Breakdown{RuleID: 2, Percentage: 100, Adjustment: 50, Total: 150}
+
+	testCases := []struct {
+		name          string
+		schedule      string
+		query         url.Values
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			schedule: testFeeSchedule,
+			query:    url.Values{"amount": {"100000"}, "currency": {util.USD}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					QuoteTransferFee(gomock.Any(), gomock.Eq(db.QuoteTransferFeeParams{Schedule: testFeeSchedule, Currency: util.USD, Amount: 100000})).
+					Times(1).
+					Return(quote, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp quoteTransferResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, quote, rsp.Fee)
+				require.Equal(t, int64(100150), rsp.Total)
+			},
+		},
+		{
+			name:  "NoFeeSchedule",
+			query: url.Values{"amount": {"100000"}, "currency": {util.USD}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp quoteTransferResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Zero(t, rsp.Fee.Total)
+				require.Equal(t, int64(100000), rsp.Total)
+			},
+		},
+		{
+			name:     "InvalidCurrency",
+			schedule: testFeeSchedule,
+			query:    url.Values{"amount": {"100000"}, "currency": {"YEN"}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidAmount",
+			schedule: testFeeSchedule,
+			query:    url.Values{"amount": {"-5"}, "currency": {util.USD}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "InternalError",
+			schedule: testFeeSchedule,
+			query:    url.Values{"amount": {"100000"}, "currency": {util.USD}},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().QuoteTransferFee(gomock.Any(), gomock.Any()).Times(1).Return(fee.Breakdown{}, errors.New("some error"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.config.TransferFeeSchedule = tc.schedule
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/transfers/quote?"+tc.query.Encode(), nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, util.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/lockout"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/twofactor"
+)
+
+type enrollTOTPResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// enrollTOTP starts two-factor enrollment for the authenticated user.
+// It only takes effect once confirmed with a code from the authenticator app.
+func (server *Server) enrollTOTP(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	enrollment, err := server.twoFactor.Enroll(ctx, authPayload.Username)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollTOTPResponse{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.ProvisioningURI,
+	})
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code" binding:"required,numeric,len=6"`
+}
+
+type confirmTOTPResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (server *Server) confirmTOTP(ctx *gin.Context) {
+	var req confirmTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	recoveryCodes, err := server.twoFactor.Confirm(ctx, authPayload.Username, req.Code)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, confirmTOTPResponse{RecoveryCodes: recoveryCodes})
+}
+
+type disableTOTPRequest struct {
+	// TOTPCode is a code from the authenticator app, or a recovery code, checked like a step-up
+	TOTPCode string `json:"totp_code"`
+}
+
+// disableTOTP turns two-factor authentication off for the authenticated user, who can then enroll again,
+// e.g. with a new device. It takes a code like any other high-value operation.
+func (server *Server) disableTOTP(ctx *gin.Context) {
+	var req disableTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !server.requireStepUp(ctx, authPayload.Username, req.TOTPCode) {
+		return
+	}
+
+	if err := server.twoFactor.Disable(ctx, authPayload.Username); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+type loginUserTOTPRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required,uuid"`
+	// Code is either a TOTP code or a recovery code
+	Code string `json:"code" binding:"required,min=6,max=20"`
+}
+
+// loginUserTOTP is the second step of logging in with two-factor authentication enabled
+func (server *Server) loginUserTOTP(ctx *gin.Context) {
+	var req loginUserTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	attempt := lockout.Attempt{
+		ClientIP:  ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	}
+
+	// the username is only known from the challenge, which was issued after checking it
+	if err := server.loginGuard.Begin(ctx, &attempt); err != nil {
+		var lockedErr *lockout.LockedError
+		if errors.As(err, &lockedErr) {
+			err = loginLockedError(lockedErr)
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	username, err := server.twoFactor.AnswerChallenge(ctx, uuid.MustParse(req.ChallengeToken), req.Code)
+	attempt.Username = username
+	if err != nil {
+		if errors.Is(err, twofactor.ErrInvalidCode) || errors.Is(err, twofactor.ErrInvalidChallenge) {
+			server.recordLoginFailure(ctx, attempt, err)
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, username)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	server.completeLogin(ctx, attempt, user)
+}
+
+// requireStepUp checks the TOTP code sent with a high-value operation. It replies and returns false if it is not valid.
+// Invalid codes are counted like failed logins, so step-up is locked out for a while after too many of them.
+func (server *Server) requireStepUp(ctx *gin.Context, username string, code string) bool {
+	if code == "" {
+		abortWithError(ctx, apperror.New(apperror.CodeTwoFactorRequired, "a two-factor authentication code is required for this operation"))
+		return false
+	}
+
+	attempt := lockout.Attempt{
+		Username:  username,
+		ClientIP:  ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	}
+
+	if err := server.stepUpGuard.Begin(ctx, &attempt); err != nil {
+		var lockedErr *lockout.LockedError
+		if errors.As(err, &lockedErr) {
+			err = stepUpLockedError(lockedErr)
+		}
+		abortWithError(ctx, err)
+		return false
+	}
+
+	// the attempt stays recorded as a failure unless the code is valid
+	if err := server.twoFactor.Verify(ctx, username, code); err != nil {
+		abortWithError(ctx, err)
+		return false
+	}
+
+	attempt.Outcome = db.LoginOutcomeSuccess
+	if err := server.stepUpGuard.Finish(ctx, attempt); err != nil {
+		abortWithError(ctx, err)
+		return false
+	}
+	return true
+}
+
+func stepUpLockedError(err *lockout.LockedError) error {
+	appErr := apperror.Wrap(err, apperror.CodeTwoFactorLocked, "too many invalid two-factor authentication codes")
+	appErr.RetryAfter = err.RetryAfter()
+	return appErr
+}
@@ -0,0 +1,528 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/twofactor"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestEnrollTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpsertTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.UpsertTOTPSecretParams) (db.TotpSecret, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.TotpSecret{Username: arg.Username, EncryptedSecret: arg.EncryptedSecret}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp enrollTOTPResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.NotEmpty(t, rsp.Secret)
+				require.Contains(t, rsp.ProvisioningURI, "otpauth://totp/")
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpsertTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TotpSecret{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpsertTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/totp", nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestConfirmTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	secret, plainSecret := randomTOTPSecret(t, user.Username)
+	secret.IsEnabled = false
+
+	code, err := totp.GenerateCode(plainSecret, time.Now())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(secret, nil)
+				store.EXPECT().
+					EnableTOTPTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.EnableTOTPTxParams) (db.EnableTOTPTxResult, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Len(t, arg.HashedRecoveryCodes, 10)
+						return db.EnableTOTPTxResult{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp confirmTOTPResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.Len(t, rsp.RecoveryCodes, 10)
+			},
+		},
+		{
+			name: "InvalidCode",
+			body: gin.H{"code": wrongTOTPCode(code)},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(secret, nil)
+				store.EXPECT().
+					EnableTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NotEnrolled",
+			body: gin.H{"code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.TotpSecret{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "BadCodeFormat",
+			body: gin.H{"code": "abc"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/totp/confirm", getReaderFor(t, tc.body))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDisableTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	secret, plainSecret := randomTOTPSecret(t, user.Username)
+
+	code, err := totp.GenerateCode(plainSecret, time.Now())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"totp_code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().LoginAttemptTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(runLoginAttemptTx(store))
+				store.EXPECT().GetUserLoginFailures(gomock.Any(), EqStepUpFailures(user.Username)).Times(1).Return(db.GetUserLoginFailuresRow{}, nil)
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(secret, nil)
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(secret, nil)
+				store.EXPECT().FinishLoginEvent(gomock.Any(), EqLoginEvent(user.Username, db.LoginOutcomeSuccess)).Times(1)
+				store.EXPECT().DisableTOTPTx(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "CodeMissing",
+			body: gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().LoginAttemptTx(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().DisableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidCode",
+			body: gin.H{"totp_code": wrongTOTPCode(code)},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().LoginAttemptTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(runLoginAttemptTx(store))
+				store.EXPECT().GetUserLoginFailures(gomock.Any(), EqStepUpFailures(user.Username)).Times(1).Return(db.GetUserLoginFailuresRow{}, nil)
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(secret, nil)
+				store.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Any()).Times(1).Return(db.RecoveryCode{}, db.ErrRecordNotFound)
+				store.EXPECT().FinishLoginEvent(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().DisableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NotEnabled",
+			body: gin.H{"totp_code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().LoginAttemptTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(runLoginAttemptTx(store))
+				store.EXPECT().GetUserLoginFailures(gomock.Any(), EqStepUpFailures(user.Username)).Times(1).Return(db.GetUserLoginFailuresRow{}, nil)
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.TotpSecret{}, db.ErrRecordNotFound)
+				store.EXPECT().DisableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "Locked",
+			body: gin.H{"totp_code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().LoginAttemptTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(runLoginAttemptTx(store))
+				store.EXPECT().GetUserLoginFailures(gomock.Any(), EqStepUpFailures(user.Username)).Times(1).
+					Return(db.GetUserLoginFailuresRow{Failures: 5, LastFailureAt: time.Now()}, nil)
+				store.EXPECT().GetTOTPSecret(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().DisableTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/totp/disable", getReaderFor(t, tc.body))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginUserTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	secret, plainSecret := randomTOTPSecret(t, user.Username)
+	challenge := db.LoginChallenge{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		Attempts:  1,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	code, err := totp.GenerateCode(plainSecret, time.Now())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"challenge_token": challenge.ID.String(), "code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AttemptLoginChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(secret, nil)
+				store.EXPECT().
+					UseTOTPStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(secret, nil)
+				store.EXPECT().
+					CompleteLoginChallenge(gomock.Any(), gomock.Eq(challenge.ID)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					FinishLoginEvent(gomock.Any(), EqLoginEvent(user.Username, db.LoginOutcomeSuccess)).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp loginUserResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.NotEmpty(t, rsp.AccessToken)
+			},
+		},
+		{
+			name: "RecoveryCode",
+			body: gin.H{"challenge_token": challenge.ID.String(), "code": "abcd-efgh"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AttemptLoginChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(secret, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RecoveryCode{Username: user.Username, IsUsed: true}, nil)
+				store.EXPECT().
+					CompleteLoginChallenge(gomock.Any(), gomock.Eq(challenge.ID)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					FinishLoginEvent(gomock.Any(), EqLoginEvent(user.Username, db.LoginOutcomeSuccess)).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidCode",
+			body: gin.H{"challenge_token": challenge.ID.String(), "code": wrongTOTPCode(code)},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AttemptLoginChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(secret, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RecoveryCode{}, db.ErrRecordNotFound)
+				store.EXPECT().
+					FinishLoginEvent(gomock.Any(), EqLoginEvent(user.Username, db.LoginOutcomeFailure)).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidChallenge",
+			body: gin.H{"challenge_token": challenge.ID.String(), "code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AttemptLoginChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.LoginChallenge{}, db.ErrRecordNotFound)
+				store.EXPECT().
+					FinishLoginEvent(gomock.Any(), EqLoginEvent("", db.LoginOutcomeFailure)).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "BadChallengeToken",
+			body: gin.H{"challenge_token": "not-a-uuid", "code": code},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					AttemptLoginChallenge(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			allowLogin(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/login/totp", getReaderFor(t, tc.body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+// randomTOTPSecret returns an enabled TOTP secret as it is stored, and its plain secret to generate codes with
+func randomTOTPSecret(t *testing.T, username string) (db.TotpSecret, string) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      "Simple Bank",
+		AccountName: username,
+	})
+	require.NoError(t, err)
+
+	return encryptedTOTPSecret(t, username, key.Secret()), key.Secret()
+}
+
+// encryptedTOTPSecret returns the enabled secret of username, encrypted with the key of the test server
+func encryptedTOTPSecret(t *testing.T, username string, secret string) db.TotpSecret {
+	encryptedSecret, err := twofactor.EncryptSecret(testTOTPEncryptionKey, username, secret)
+	require.NoError(t, err)
+
+	return db.TotpSecret{
+		Username:        username,
+		EncryptedSecret: encryptedSecret,
+		IsEnabled:       true,
+		CreatedAt:       time.Now(),
+	}
+}
+
+// wrongTOTPCode returns a six digit code that differs from code
+func wrongTOTPCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
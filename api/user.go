@@ -1,15 +1,20 @@
 package api
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/apperror"
 	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/lockout"
+	"github.com/pakojabi/simplebank/token"
 	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/val"
+	"github.com/pakojabi/simplebank/worker"
 )
 
 type createUserRequest struct {
@@ -25,6 +30,8 @@ type userResponse struct {
 	Email             string    `json:"email"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
+	IsEmailVerified   bool      `json:"is_email_verified"`
+	Role              string    `json:"role"`
 }
 
 func newUserResponse(user db.User) *userResponse {
@@ -34,6 +41,8 @@ func newUserResponse(user db.User) *userResponse {
 		Email:             user.Email,
 		PasswordChangedAt: user.PasswordChangedAt,
 		CreatedAt:         user.CreatedAt,
+		IsEmailVerified:   user.IsEmailVerified,
+		Role:              user.Role,
 	}
 }
 
@@ -41,38 +50,44 @@ func (server *Server) createUser(ctx *gin.Context) {
 	var req createUserRequest
 	// validation - with the help of gin's validator
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, invalidRequest(err))
 		return
 	}
 
 	hashedPassword, err := util.HashPassword(req.Password)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
+		return
 	}
 
-	arg := db.CreateUserParams{
-		Username:       req.Username,
-		HashedPassword: hashedPassword,
-		FullName:       req.FullName,
-		Email:          req.Email,
+	arg := db.CreateUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+			Username:       req.Username,
+			HashedPassword: hashedPassword,
+			FullName:       req.FullName,
+			Email:          req.Email,
+		},
+		AfterCreate: func(q db.Querier, user db.User) error {
+			payload := &worker.PayloadSendVerifyEmail{
+				Username: user.Username,
+			}
+			return server.taskDistributor.DistributeTaskSendVerifyEmail(ctx, q, payload, worker.Queue(worker.QueueCritical))
+		},
 	}
 
-	user, err := server.store.CreateUser(ctx, arg)
+	txResult, err := server.store.CreateUserTx(ctx, arg)
 
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code.Name() {
-			case "unique_violation":
-				ctx.JSON(http.StatusForbidden, errorResponse(err))
-				return
-			}
+		if db.IsUniqueViolation(err) {
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodeUserAlreadyExists, "username or email already exists"))
+			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 
 	// we do not want to return the hashed password, so we use a custom response
-	rsp := newUserResponse(user)
+	rsp := newUserResponse(txResult.User)
 	ctx.JSON(http.StatusOK, rsp)
 }
 
@@ -90,41 +105,103 @@ type loginUserResponse struct {
 	User                  userResponse `json:"user"`
 }
 
+// loginChallengeResponse is returned instead of tokens when the user has two-factor authentication enabled.
+// The challenge token must then be sent to /users/login/totp along with a code.
+type loginChallengeResponse struct {
+	TwoFactorRequired  bool      `json:"two_factor_required"`
+	ChallengeToken     string    `json:"challenge_token"`
+	ChallengeExpiresAt time.Time `json:"challenge_expires_at"`
+}
+
 func (server *Server) loginUser(ctx *gin.Context) {
 	var req loginUserRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	attempt := lockout.Attempt{
+		Username:  req.Username,
+		ClientIP:  ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	}
+
+	if err := server.loginGuard.Begin(ctx, &attempt); err != nil {
+		var lockedErr *lockout.LockedError
+		if errors.As(err, &lockedErr) {
+			err = loginLockedError(lockedErr)
+		}
+		abortWithError(ctx, err)
 		return
 	}
 
 	user, err := server.store.GetUser(ctx, req.Username)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		if err == db.ErrRecordNotFound {
+			server.recordLoginFailure(ctx, attempt, apperror.Wrap(err, apperror.CodeUserNotFound, "user not found"))
 			return
 		}
 
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
+		return
 	}
 
 	if err = util.CheckPassword(req.Password, user.HashedPassword); err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		server.recordLoginFailure(ctx, attempt, apperror.Wrap(err, apperror.CodeInvalidCredentials, "incorrect password"))
 		return
 	}
 
-	accessToken, accessPayload, err := server.tokenMaker.Make(user.Username, server.config.AccessTokenDuration)
+	enabled, err := server.twoFactor.IsEnabled(ctx, user.Username)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
+	if enabled {
+		// the outcome of the login is recorded once the second factor has been checked
+		attempt.Outcome = db.LoginOutcomeChallenged
+		if err := server.loginGuard.Finish(ctx, attempt); err != nil {
+			abortWithError(ctx, err)
+			return
+		}
 
-	refreshToken, refreshPayload, err := server.tokenMaker.Make(user.Username, server.config.RefreshTokenDuration)
+		challenge, err := server.twoFactor.CreateChallenge(ctx, user.Username)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, loginChallengeResponse{
+			TwoFactorRequired:  true,
+			ChallengeToken:     challenge.ID.String(),
+			ChallengeExpiresAt: challenge.ExpiresAt,
+		})
+		return
+	}
+
+	server.completeLogin(ctx, attempt, user)
+}
+
+// completeLogin records the successful attempt, then starts a session and replies with its tokens
+func (server *Server) completeLogin(ctx *gin.Context, attempt lockout.Attempt, user db.User) {
+	attempt.Outcome = db.LoginOutcomeSuccess
+	if err := server.loginGuard.Finish(ctx, attempt); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.Make(user.Username, user.Role, server.config.AccessTokenDuration)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 
-	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
+	refreshToken, refreshPayload, err := server.tokenMaker.Make(user.Username, user.Role, server.config.RefreshTokenDuration)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	session, err := server.store.CreateSession(db.WithAuditUser(ctx, user.Username, user.Role), db.CreateSessionParams{
 		ID:           refreshPayload.ID,
 		Username:     user.Username,
 		RefreshToken: refreshToken,
@@ -135,7 +212,7 @@ func (server *Server) loginUser(ctx *gin.Context) {
 	})
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 
@@ -150,3 +227,192 @@ func (server *Server) loginUser(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, rsp)
 }
+
+// recordLoginFailure records the failed attempt, then replies with the given error
+func (server *Server) recordLoginFailure(ctx *gin.Context, attempt lockout.Attempt, loginErr error) {
+	attempt.Outcome = db.LoginOutcomeFailure
+	if err := server.loginGuard.Finish(ctx, attempt); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	abortWithError(ctx, loginErr)
+}
+
+func loginLockedError(err *lockout.LockedError) error {
+	appErr := apperror.Wrap(err, apperror.CodeLoginLocked, "too many failed login attempts")
+	appErr.RetryAfter = err.RetryAfter()
+	return appErr
+}
+
+type unlockUserRequest struct {
+	Username string `uri:"username" binding:"required,alphanum"`
+}
+
+// unlockUser clears the failed logins of a user, so they can log in again right away. Admins only.
+func (server *Server) unlockUser(ctx *gin.Context) {
+	var req unlockUserRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != util.AdminRole {
+		abortWithError(ctx, apperror.New(apperror.CodePermissionDenied, "only admins can unlock users"))
+		return
+	}
+
+	if err := server.loginGuard.Unlock(ctx, req.Username, ctx.ClientIP(), ctx.Request.UserAgent()); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+type verifyEmailRequest struct {
+	EmailID    int64  `form:"email_id" binding:"required,min=1"`
+	SecretCode string `form:"secret_code" binding:"required,min=32,max=128"`
+}
+
+type verifyEmailResponse struct {
+	IsVerified bool `json:"is_verified"`
+}
+
+func (server *Server) verifyEmail(ctx *gin.Context) {
+	var req verifyEmailRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	txResult, err := server.store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{
+		EmailId:    req.EmailID,
+		SecretCode: req.SecretCode,
+	})
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodeInvalidVerifyCode, "invalid or expired verification code"))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, verifyEmailResponse{IsVerified: txResult.User.IsEmailVerified})
+}
+
+// requireVerifiedEmail writes an error response and returns false unless the user has verified their email
+func (server *Server) requireVerifiedEmail(ctx *gin.Context, username string) bool {
+	user, err := server.store.GetUser(ctx, username)
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodeUserNotFound, "user not found"))
+			return false
+		}
+		abortWithError(ctx, err)
+		return false
+	}
+
+	if !user.IsEmailVerified {
+		abortWithError(ctx, apperror.New(apperror.CodeEmailNotVerified, "email address is not verified"))
+		return false
+	}
+	return true
+}
+
+type updateUserURI struct {
+	Username string `uri:"username" binding:"required"`
+}
+
+// updateUserRequest only updates the fields present in the body
+type updateUserRequest struct {
+	FullName *string `json:"full_name"`
+	Email    *string `json:"email"`
+}
+
+// validateUpdateUserRequest checks the request with the same rules as the gRPC API
+func validateUpdateUserRequest(uri updateUserURI, req updateUserRequest) error {
+	var violations []apperror.FieldViolation
+	if err := val.ValidateUsername(uri.Username); err != nil {
+		violations = append(violations, fieldViolation("username", err))
+	}
+	if req.FullName != nil {
+		if err := val.ValidateFullUsername(*req.FullName); err != nil {
+			violations = append(violations, fieldViolation("full_name", err))
+		}
+	}
+	if req.Email != nil {
+		if err := val.ValidateEmail(*req.Email); err != nil {
+			violations = append(violations, fieldViolation("email", err))
+		}
+	}
+
+	if len(violations) > 0 {
+		return apperror.InvalidArgument(violations...)
+	}
+	return nil
+}
+
+// updateUser changes a user's full name and/or email. Users can only update themselves, unless they are an admin.
+// Changing the email resets its verification and sends a new verification email.
+func (server *Server) updateUser(ctx *gin.Context) {
+	var uri updateUserURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+
+	var req updateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		abortWithError(ctx, invalidRequest(err))
+		return
+	}
+	if err := validateUpdateUserRequest(uri, req); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != util.AdminRole && authPayload.Username != uri.Username {
+		abortWithError(ctx, apperror.New(apperror.CodePermissionDenied, "cannot update other user's info"))
+		return
+	}
+
+	arg := db.UpdateUserTxParams{
+		UpdateUserParams: db.UpdateUserParams{
+			Username: uri.Username,
+		},
+		AfterUpdate: func(q db.Querier, user db.User) error {
+			if req.Email == nil || user.IsEmailVerified {
+				return nil
+			}
+			payload := &worker.PayloadSendVerifyEmail{
+				Username: user.Username,
+			}
+			return server.taskDistributor.DistributeTaskSendVerifyEmail(ctx, q, payload, worker.Queue(worker.QueueCritical))
+		},
+	}
+	if req.FullName != nil {
+		arg.FullName = pgtype.Text{String: *req.FullName, Valid: true}
+	}
+	if req.Email != nil {
+		arg.Email = pgtype.Text{String: *req.Email, Valid: true}
+	}
+
+	txResult, err := server.store.UpdateUserTx(ctx, arg)
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodeUserNotFound, "user not found"))
+			return
+		}
+		if db.IsUniqueViolation(err) {
+			abortWithError(ctx, apperror.Wrap(err, apperror.CodeEmailInUse, "email already in use"))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(txResult.User))
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,12 +11,15 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgtype"
 	mockdb "github.com/pakojabi/simplebank/db/mock"
 	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
 	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/worker"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
@@ -39,7 +43,7 @@ func TestCreateUser(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), EqCreateUserParams(
+					CreateUserTx(gomock.Any(), EqCreateUserTxParams(
 						db.CreateUserParams{
 							Username: user.Username,
 							Email:    user.Email,
@@ -48,7 +52,14 @@ func TestCreateUser(t *testing.T) {
 						password,
 					)).
 					Times(1).
-					Return(user, nil)
+					DoAndReturn(func(ctx context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+						// like the real store, run AfterCreate against the transaction
+						err := arg.AfterCreate(store, user)
+						return db.CreateUserTxResult{User: user}, err
+					})
+				store.EXPECT().
+					CreateTask(gomock.Any(), EqSendVerifyEmailTask(user.Username)).
+					Times(1)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -65,9 +76,9 @@ func TestCreateUser(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.User{}, sql.ErrConnDone)
+					Return(db.CreateUserTxResult{}, sql.ErrConnDone)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
@@ -83,9 +94,9 @@ func TestCreateUser(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.User{}, &pq.Error{Code: "23505"})
+					Return(db.CreateUserTxResult{}, db.ErrUniqueViolation)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusForbidden, recorder.Code)
@@ -101,7 +112,7 @@ func TestCreateUser(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -118,7 +129,7 @@ func TestCreateUser(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -169,6 +180,9 @@ func TestLoginUserAPI(t *testing.T) {
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					FinishLoginEvent(gomock.Any(), EqLoginEvent(user.Username, db.LoginOutcomeSuccess)).
+					Times(1)
 				store.EXPECT().
 					CreateSession(gomock.Any(), gomock.Any()).
 					Times(1)
@@ -187,7 +201,10 @@ func TestLoginUserAPI(t *testing.T) {
 				store.EXPECT().
 					GetUser(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.User{}, sql.ErrNoRows)
+					Return(db.User{}, db.ErrRecordNotFound)
+				store.EXPECT().
+					FinishLoginEvent(gomock.Any(), EqLoginEvent("NotFound", db.LoginOutcomeFailure)).
+					Times(1)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusNotFound, recorder.Code)
@@ -204,11 +221,77 @@ func TestLoginUserAPI(t *testing.T) {
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					FinishLoginEvent(gomock.Any(), EqLoginEvent(user.Username, db.LoginOutcomeFailure)).
+					Times(1)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
 		},
+		{
+			name: "TwoFactorRequired",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetTOTPSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.TotpSecret{Username: user.Username, IsEnabled: true}, nil)
+				store.EXPECT().
+					CreateLoginChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateLoginChallengeParams) (db.LoginChallenge, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.LoginChallenge{ID: arg.ID, Username: arg.Username, ExpiresAt: arg.ExpiresAt}, nil
+					})
+				// no tokens until the second step, which records the outcome of the login
+				store.EXPECT().
+					FinishLoginEvent(gomock.Any(), EqLoginEvent(user.Username, db.LoginOutcomeChallenged)).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp loginChallengeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.True(t, rsp.TwoFactorRequired)
+				require.NotEmpty(t, rsp.ChallengeToken)
+				require.WithinDuration(t, time.Now().Add(5*time.Minute), rsp.ChallengeExpiresAt, time.Second)
+			},
+		},
+		{
+			name: "LockedOut",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserLoginFailures(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetUserLoginFailuresRow{Failures: 5, LastFailureAt: time.Now()}, nil)
+				store.EXPECT().
+					FinishLoginEvent(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+			},
+		},
 		{
 			name: "InternalError",
 			body: gin.H{
@@ -251,6 +334,7 @@ func TestLoginUserAPI(t *testing.T) {
 
 			store := mockdb.NewMockStore(ctrl)
 			tc.buildStubs(store)
+			allowLogin(store)
 
 			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
@@ -270,6 +354,467 @@ func TestLoginUserAPI(t *testing.T) {
 }
 
 
+func TestVerifyEmailAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.IsEmailVerified = true
+	secretCode := util.RandomString(32)
+
+	testCases := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recoder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: fmt.Sprintf("email_id=%d&secret_code=%s", 1, secretCode),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.VerifyEmailTxParams{
+					EmailId:    1,
+					SecretCode: secretCode,
+				}
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.VerifyEmailTxResult{User: user}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp verifyEmailResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+				require.NoError(t, err)
+				require.True(t, rsp.IsVerified)
+			},
+		},
+		{
+			name:  "InvalidCode",
+			query: fmt.Sprintf("email_id=%d&secret_code=%s", 1, secretCode),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.VerifyEmailTxResult{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: fmt.Sprintf("email_id=%d&secret_code=%s", 1, secretCode),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.VerifyEmailTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:  "SecretCodeTooShort",
+			query: fmt.Sprintf("email_id=%d&secret_code=%s", 1, "short"),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "MissingEmailID",
+			query: fmt.Sprintf("secret_code=%s", secretCode),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := "/users/verify_email?" + tc.query
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestUpdateUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	admin, _ := randomUser(t)
+	admin.Role = util.AdminRole
+	other, _ := randomUser(t)
+
+	newFullName := util.RandomString(6) + " " + util.RandomString(6)
+	newEmail := util.RandomString(6) + "@email.com"
+
+	testCases := []struct {
+		name          string
+		username      string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "FullNameOnly",
+			username: user.Username,
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.FullName = newFullName
+
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, pgtype.Text{String: newFullName, Valid: true}, arg.FullName)
+						require.False(t, arg.Email.Valid)
+						err := arg.AfterUpdate(store, updated)
+						return db.UpdateUserTxResult{User: updated}, err
+					})
+				store.EXPECT().
+					CreateTask(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				updated := user
+				updated.FullName = newFullName
+				requireBodyMatchUser(t, recorder.Body, updated)
+			},
+		},
+		{
+			name:     "EmailResetsVerification",
+			username: user.Username,
+			body: gin.H{
+				"email": newEmail,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.Email = newEmail
+				updated.IsEmailVerified = false
+
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+						require.False(t, arg.FullName.Valid)
+						require.Equal(t, pgtype.Text{String: newEmail, Valid: true}, arg.Email)
+						err := arg.AfterUpdate(store, updated)
+						return db.UpdateUserTxResult{User: updated}, err
+					})
+				store.EXPECT().
+					CreateTask(gomock.Any(), EqSendVerifyEmailTask(user.Username)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "AdminUpdatesOtherUser",
+			username: user.Username,
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserTxResult{User: user}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "OtherUserForbidden",
+			username: user.Username,
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, other.Username, other.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "NoAuthorization",
+			username: user.Username,
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidEmail",
+			username: user.Username,
+			body: gin.H{
+				"email": "invalid-email",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidFullName",
+			username: user.Username,
+			body: gin.H{
+				"full_name": "J0hn",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "full_name")
+			},
+		},
+		{
+			name:     "EmailInUse",
+			username: user.Username,
+			body: gin.H{
+				"email": newEmail,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserTxResult{}, db.ErrUniqueViolation)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:     "UserNotFound",
+			username: user.Username,
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserTxResult{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "InternalError",
+			username: user.Username,
+			body: gin.H{
+				"full_name": newFullName,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UpdateUserTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/users/%s", tc.username)
+			request, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUnlockUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	admin, _ := randomUser(t)
+	admin.Role = util.AdminRole
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateLoginEvent(gomock.Any(), EqLoginEvent(user.Username, db.LoginOutcomeUnlocked)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateLoginEvent(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateLoginEvent(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, admin.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateLoginEvent(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.LoginEvent{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/%s/unlock", user.Username)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
 func randomUser(t *testing.T) (db.User, string) {
 	password := util.RandomString(6)
 	hashedPassword, err := util.HashPassword(password)
@@ -280,6 +825,7 @@ func randomUser(t *testing.T) (db.User, string) {
 		HashedPassword: hashedPassword,
 		FullName:       util.RandomString(6) + " " + util.RandomString(6),
 		Email:          util.RandomString(6) + "@email.com",
+		Role:           util.DepositorRole,
 	}, password
 }
 
@@ -295,22 +841,23 @@ func requireBodyMatchUser(t *testing.T, body *bytes.Buffer, expectedUser db.User
 	require.Equal(t, expectedUser.Email, gotResponse.Email)
 	require.Equal(t, expectedUser.CreatedAt, gotResponse.CreatedAt)
 	require.Equal(t, expectedUser.PasswordChangedAt, gotResponse.PasswordChangedAt)
+	require.Equal(t, expectedUser.Role, gotResponse.Role)
 
 }
 
-// Custom matcher for db.CreateUserParams
+// Custom matcher for db.CreateUserTxParams
 
-type eqCreateUserParamsMatcher struct {
+type eqCreateUserTxParamsMatcher struct {
 	arg      db.CreateUserParams
 	password string
 }
 
-func EqCreateUserParams(arg db.CreateUserParams, password string) gomock.Matcher {
-	return eqCreateUserParamsMatcher{arg, password}
+func EqCreateUserTxParams(arg db.CreateUserParams, password string) gomock.Matcher {
+	return eqCreateUserTxParamsMatcher{arg, password}
 }
 
-func (e eqCreateUserParamsMatcher) Matches(x any) bool {
-	arg, ok := x.(db.CreateUserParams)
+func (e eqCreateUserTxParamsMatcher) Matches(x any) bool {
+	arg, ok := x.(db.CreateUserTxParams)
 	if !ok {
 		return false
 	}
@@ -319,9 +866,122 @@ func (e eqCreateUserParamsMatcher) Matches(x any) bool {
 		return false
 	}
 	e.arg.HashedPassword = arg.HashedPassword
-	return reflect.DeepEqual(e.arg, arg)
+	return reflect.DeepEqual(e.arg, arg.CreateUserParams) && arg.AfterCreate != nil
 }
 
-func (e eqCreateUserParamsMatcher) String() string {
+func (e eqCreateUserTxParamsMatcher) String() string {
 	return fmt.Sprintf("is equal to %v (%T)", e.arg, e.arg)
 }
+
+// Custom matcher for the task enqueued to send the verification email
+
+type eqSendVerifyEmailTaskMatcher struct {
+	username string
+}
+
+func EqSendVerifyEmailTask(username string) gomock.Matcher {
+	return eqSendVerifyEmailTaskMatcher{username}
+}
+
+func (e eqSendVerifyEmailTaskMatcher) Matches(x any) bool {
+	arg, ok := x.(db.CreateTaskParams)
+	if !ok || arg.Type != worker.TaskSendVerifyEmail {
+		return false
+	}
+
+	var payload worker.PayloadSendVerifyEmail
+	if err := json.Unmarshal(arg.Payload, &payload); err != nil {
+		return false
+	}
+	return payload.Username == e.username
+}
+
+func (e eqSendVerifyEmailTaskMatcher) String() string {
+	return fmt.Sprintf("is a %s task for %s", worker.TaskSendVerifyEmail, e.username)
+}
+
+// allowLogin stubs the login lockout and two-factor queries as if there had been no failed logins
+// and the user had no two-factor authentication. Call it after the test case stubs, so that theirs take precedence.
+func allowLogin(store *mockdb.MockStore) {
+	store.EXPECT().
+		LoginAttemptTx(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(runLoginAttemptTx(store))
+	store.EXPECT().
+		GetUserLoginFailures(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(db.GetUserLoginFailuresRow{}, nil)
+	store.EXPECT().
+		GetClientIPLoginFailures(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(db.GetClientIPLoginFailuresRow{}, nil)
+	store.EXPECT().
+		GetTOTPSecret(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(db.TotpSecret{}, db.ErrRecordNotFound)
+}
+
+// runLoginAttemptTx runs the BeforeCreate of LoginAttemptTx on the mock store, as the transaction would
+func runLoginAttemptTx(store *mockdb.MockStore) func(ctx context.Context, arg db.LoginAttemptTxParams) (db.LoginAttemptTxResult, error) {
+	return func(ctx context.Context, arg db.LoginAttemptTxParams) (db.LoginAttemptTxResult, error) {
+		outcome, err := arg.BeforeCreate(store)
+		if err != nil {
+			return db.LoginAttemptTxResult{}, err
+		}
+		event := db.LoginEvent{
+			ID:        1,
+			Username:  arg.Username,
+			ClientIp:  arg.ClientIp,
+			UserAgent: arg.UserAgent,
+			Outcome:   outcome,
+			Kind:      arg.Kind,
+		}
+		return db.LoginAttemptTxResult{LoginEvent: event}, nil
+	}
+}
+
+type eqLoginEventMatcher struct {
+	username string
+	outcome  string
+}
+
+// EqLoginEvent matches a login event recorded at once, like an unlock, or the outcome of an attempt when it finishes
+func EqLoginEvent(username string, outcome string) gomock.Matcher {
+	return eqLoginEventMatcher{username, outcome}
+}
+
+func (e eqLoginEventMatcher) Matches(x any) bool {
+	switch arg := x.(type) {
+	case db.CreateLoginEventParams:
+		return arg.Username == e.username && arg.Outcome == e.outcome
+	case db.FinishLoginEventParams:
+		return arg.Username == e.username && arg.Outcome == e.outcome
+	}
+	return false
+}
+
+func (e eqLoginEventMatcher) String() string {
+	return fmt.Sprintf("login event of %s with outcome %s", e.username, e.outcome)
+}
+
+type eqLoginFailuresMatcher struct {
+	username string
+	kind     string
+}
+
+// EqStepUpFailures matches the count of the failed step-up codes of a username, whatever the window
+func EqStepUpFailures(username string) gomock.Matcher {
+	return eqLoginFailuresMatcher{username, db.LoginKindStepUp}
+}
+
+func (e eqLoginFailuresMatcher) Matches(x any) bool {
+	arg, ok := x.(db.GetUserLoginFailuresParams)
+	if !ok {
+		return false
+	}
+	return arg.Username == e.username && arg.Kind == e.kind
+}
+
+func (e eqLoginFailuresMatcher) String() string {
+	return fmt.Sprintf("%s failures of %s", e.kind, e.username)
+}
@@ -1,6 +1,9 @@
 package api
 
 import (
+	"reflect"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/pakojabi/simplebank/util"
 )
@@ -14,3 +17,20 @@ var validCurrency validator.Func = func (fieldLevel validator.FieldLevel) bool {
 	return false
 }
 
+var validScope validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	if scope, ok := fieldLevel.Field().Interface().(string); ok {
+		return util.IsSupportedScope(scope)
+	}
+	return false
+}
+
+// fieldName reports the fields of validation errors by the name clients send them with
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "uri", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,170 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
+)
+
+const (
+	// keyMarker starts every key, so that leaked keys are easy to recognize
+	keyMarker = "sbk_"
+	// prefixLength is the length of the public prefix, marker included
+	prefixLength = len(keyMarker) + 8
+	secretLength = 32
+)
+
+var (
+	ErrInvalidKey      = apperror.New(apperror.CodeInvalidAPIKey, "api key is invalid")
+	ErrExpiredKey      = apperror.New(apperror.CodeExpiredAPIKey, "api key has expired")
+	ErrRevokedKey      = apperror.New(apperror.CodeRevokedAPIKey, "api key has been revoked")
+	ErrInvalidDuration = apperror.New(apperror.CodeInvalidDuration, "api key duration is out of range")
+	ErrNoScopes        = apperror.New(apperror.CodeScopesRequired, "api key needs at least one scope")
+)
+
+var prefixEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// CreateParams describes a new API key
+type CreateParams struct {
+	Username string
+	Name     string
+	Scopes   []string
+	// Duration defaults to the configured API key duration when zero
+	Duration time.Duration
+}
+
+// Manager issues API keys and authenticates requests made with them
+type Manager struct {
+	config util.Config
+	store  db.Store
+}
+
+// NewManager creates a new API key Manager
+func NewManager(config util.Config, store db.Store) *Manager {
+	return &Manager{
+		config: config,
+		store:  store,
+	}
+}
+
+// IsKey reports whether credential looks like an API key rather than an access token
+func IsKey(credential string) bool {
+	return strings.HasPrefix(credential, keyMarker)
+}
+
+// Create issues a new API key. The key itself is only stored hashed, so this is the only time it is returned.
+func (manager *Manager) Create(ctx context.Context, arg CreateParams) (string, db.ApiKey, error) {
+	if len(arg.Scopes) == 0 {
+		return "", db.ApiKey{}, ErrNoScopes
+	}
+
+	duration := arg.Duration
+	if duration == 0 {
+		duration = manager.config.APIKeyDuration
+	}
+	if duration < 0 || duration > manager.config.APIKeyMaxDuration {
+		return "", db.ApiKey{}, ErrInvalidDuration
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", db.ApiKey{}, err
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return "", db.ApiKey{}, err
+	}
+
+	apiKey, err := manager.store.CreateAPIKey(ctx, db.CreateAPIKeyParams{
+		ID:        id,
+		Username:  arg.Username,
+		Name:      arg.Name,
+		Prefix:    key[:prefixLength],
+		HashedKey: hashKey(key),
+		Scopes:    arg.Scopes,
+		ExpiresAt: time.Now().Add(duration),
+	})
+	if err != nil {
+		return "", db.ApiKey{}, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return key, apiKey, nil
+}
+
+// Authenticate verifies key and returns a payload standing for it, limited to the key's scopes
+func (manager *Manager) Authenticate(ctx context.Context, key string) (*token.Payload, error) {
+	if !IsKey(key) || len(key) <= prefixLength {
+		return nil, ErrInvalidKey
+	}
+
+	apiKey, err := manager.store.GetAPIKeyByPrefix(ctx, key[:prefixLength])
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil, ErrInvalidKey
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashKey(key)), []byte(apiKey.HashedKey)) != 1 {
+		return nil, ErrInvalidKey
+	}
+	if apiKey.IsRevoked {
+		return nil, ErrRevokedKey
+	}
+	if time.Now().After(apiKey.ExpiresAt) {
+		return nil, ErrExpiredKey
+	}
+
+	user, err := manager.store.GetUser(ctx, apiKey.Username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key owner: %w", err)
+	}
+
+	if err := manager.store.TouchAPIKey(ctx, apiKey.ID); err != nil {
+		return nil, fmt.Errorf("failed to record api key use: %w", err)
+	}
+
+	return &token.Payload{
+		ID:        apiKey.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		IssuedAt:  apiKey.CreatedAt,
+		ExpiredAt: apiKey.ExpiresAt,
+		Scopes:    apiKey.Scopes,
+	}, nil
+}
+
+// newKey returns a random key like "sbk_abcd2345_<secret>"
+func newKey() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key prefix: %w", err)
+	}
+
+	secret, err := util.RandomSecret(secretLength)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	return keyMarker + strings.ToLower(prefixEncoding.EncodeToString(b)) + "_" + secret, nil
+}
+
+// hashKey hashes the whole key. Keys are random enough that a plain SHA-256 is sufficient.
+func hashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
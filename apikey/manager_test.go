@@ -0,0 +1,188 @@
+package apikey
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestManager(t *testing.T) (*Manager, *mockdb.MockStore) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	config := util.Config{
+		APIKeyDuration:    30 * 24 * time.Hour,
+		APIKeyMaxDuration: 365 * 24 * time.Hour,
+	}
+	return NewManager(config, store), store
+}
+
+// createTestKey creates a key through the manager and returns it along with what got stored
+func createTestKey(t *testing.T, manager *Manager, store *mockdb.MockStore, username string) (string, db.ApiKey) {
+	store.EXPECT().
+		CreateAPIKey(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+			return db.ApiKey{
+				ID:        arg.ID,
+				Username:  arg.Username,
+				Name:      arg.Name,
+				Prefix:    arg.Prefix,
+				HashedKey: arg.HashedKey,
+				Scopes:    arg.Scopes,
+				ExpiresAt: arg.ExpiresAt,
+				CreatedAt: time.Now(),
+			}, nil
+		})
+
+	key, apiKey, err := manager.Create(context.Background(), CreateParams{
+		Username: username,
+		Name:     "reporting",
+		Scopes:   []string{util.AccountsReadScope},
+	})
+	require.NoError(t, err)
+	return key, apiKey
+}
+
+func TestCreate(t *testing.T) {
+	manager, store := newTestManager(t)
+	username := util.RandomOwner()
+
+	key, apiKey := createTestKey(t, manager, store, username)
+	require.True(t, IsKey(key))
+	require.Regexp(t, "^sbk_[a-z2-7]{8}_", key)
+	require.Equal(t, key[:prefixLength], apiKey.Prefix)
+	require.Equal(t, hashKey(key), apiKey.HashedKey)
+	require.NotContains(t, apiKey.HashedKey, key)
+	require.Equal(t, username, apiKey.Username)
+	require.WithinDuration(t, time.Now().Add(manager.config.APIKeyDuration), apiKey.ExpiresAt, time.Second)
+}
+
+func TestCreateInvalid(t *testing.T) {
+	manager, store := newTestManager(t)
+	store.EXPECT().
+		CreateAPIKey(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	_, _, err := manager.Create(context.Background(), CreateParams{
+		Username: util.RandomOwner(),
+		Name:     "reporting",
+	})
+	require.ErrorIs(t, err, ErrNoScopes)
+
+	_, _, err = manager.Create(context.Background(), CreateParams{
+		Username: util.RandomOwner(),
+		Name:     "reporting",
+		Scopes:   []string{util.AccountsReadScope},
+		Duration: 2 * manager.config.APIKeyMaxDuration,
+	})
+	require.ErrorIs(t, err, ErrInvalidDuration)
+}
+
+func TestAuthenticate(t *testing.T) {
+	manager, store := newTestManager(t)
+	user := db.User{Username: util.RandomOwner(), Role: util.DepositorRole}
+	key, apiKey := createTestKey(t, manager, store, user.Username)
+
+	store.EXPECT().
+		GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+		Times(1).
+		Return(apiKey, nil)
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		TouchAPIKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+		Times(1)
+
+	payload, err := manager.Authenticate(context.Background(), key)
+	require.NoError(t, err)
+	require.Equal(t, apiKey.ID, payload.ID)
+	require.Equal(t, user.Username, payload.Username)
+	require.Equal(t, user.Role, payload.Role)
+	require.Equal(t, apiKey.Scopes, payload.Scopes)
+	require.Equal(t, apiKey.ExpiresAt, payload.ExpiredAt)
+	require.True(t, payload.IsDelegated())
+}
+
+func TestAuthenticateRejected(t *testing.T) {
+	username := util.RandomOwner()
+
+	testCases := []struct {
+		name   string
+		modify func(key string, apiKey db.ApiKey) (string, db.ApiKey, error)
+		err    error
+	}{
+		{
+			name: "WrongSecret",
+			modify: func(key string, apiKey db.ApiKey) (string, db.ApiKey, error) {
+				return key[:prefixLength] + "_wrong", apiKey, nil
+			},
+			err: ErrInvalidKey,
+		},
+		{
+			name: "UnknownPrefix",
+			modify: func(key string, apiKey db.ApiKey) (string, db.ApiKey, error) {
+				return key, db.ApiKey{}, db.ErrRecordNotFound
+			},
+			err: ErrInvalidKey,
+		},
+		{
+			name: "Revoked",
+			modify: func(key string, apiKey db.ApiKey) (string, db.ApiKey, error) {
+				apiKey.IsRevoked = true
+				return key, apiKey, nil
+			},
+			err: ErrRevokedKey,
+		},
+		{
+			name: "Expired",
+			modify: func(key string, apiKey db.ApiKey) (string, db.ApiKey, error) {
+				apiKey.ExpiresAt = time.Now().Add(-time.Minute)
+				return key, apiKey, nil
+			},
+			err: ErrExpiredKey,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			manager, store := newTestManager(t)
+			key, apiKey := createTestKey(t, manager, store, username)
+			key, apiKey, getErr := tc.modify(key, apiKey)
+
+			store.EXPECT().
+				GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return(apiKey, getErr)
+			store.EXPECT().
+				TouchAPIKey(gomock.Any(), gomock.Any()).
+				Times(0)
+
+			payload, err := manager.Authenticate(context.Background(), key)
+			require.ErrorIs(t, err, tc.err)
+			require.Nil(t, payload)
+		})
+	}
+}
+
+func TestAuthenticateNotAKey(t *testing.T) {
+	manager, store := newTestManager(t)
+	store.EXPECT().
+		GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	for _, key := range []string{"", "sbk_", "sbk_abc", "eyJhbGciOiJIUzI1NiJ9.e30.x"} {
+		_, err := manager.Authenticate(context.Background(), key)
+		require.ErrorIs(t, err, ErrInvalidKey)
+	}
+}
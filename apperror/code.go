@@ -0,0 +1,134 @@
+package apperror
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code identifies an error for clients. Codes are part of the API: never rename one.
+type Code string
+
+const (
+	CodeInternal        Code = "INTERNAL"
+	CodeInvalidArgument Code = "INVALID_ARGUMENT"
+
+	CodeUnauthenticated  Code = "UNAUTHENTICATED"
+	CodePermissionDenied Code = "PERMISSION_DENIED"
+	CodeInvalidToken     Code = "INVALID_TOKEN"
+	CodeExpiredToken     Code = "EXPIRED_TOKEN"
+	CodeRevokedToken     Code = "REVOKED_TOKEN"
+	CodeMissingScope     Code = "MISSING_SCOPE"
+	CodeInvalidAPIKey    Code = "INVALID_API_KEY"
+	CodeExpiredAPIKey    Code = "EXPIRED_API_KEY"
+	CodeRevokedAPIKey    Code = "REVOKED_API_KEY"
+	CodeAPIKeyNotFound   Code = "API_KEY_NOT_FOUND"
+	CodeInvalidDuration  Code = "INVALID_DURATION"
+	CodeScopesRequired   Code = "SCOPES_REQUIRED"
+
+	CodeOAuthClientRevoked   Code = "OAUTH_CLIENT_REVOKED"
+	CodeOAuthConsentRevoked  Code = "OAUTH_CONSENT_REVOKED"
+	CodeOAuthConsentNotFound Code = "OAUTH_CONSENT_NOT_FOUND"
+
+	CodeInvalidCredentials   Code = "INVALID_CREDENTIALS"
+	CodeLoginLocked          Code = "LOGIN_LOCKED"
+	CodeSessionNotFound      Code = "SESSION_NOT_FOUND"
+	CodeInvalidSession       Code = "INVALID_SESSION"
+	CodeInvalidResetCode     Code = "INVALID_RESET_CODE"
+	CodeInvalidVerifyCode    Code = "INVALID_VERIFICATION_CODE"
+	CodeTwoFactorRequired    Code = "TWO_FACTOR_REQUIRED"
+	CodeInvalidTwoFactor     Code = "INVALID_TWO_FACTOR_CODE"
+	CodeInvalidChallenge     Code = "INVALID_LOGIN_CHALLENGE"
+	CodeTwoFactorNotEnabled  Code = "TWO_FACTOR_NOT_ENABLED"
+	CodeTwoFactorNotEnrolled Code = "TWO_FACTOR_NOT_ENROLLED"
+	CodeTwoFactorEnabled     Code = "TWO_FACTOR_ALREADY_ENABLED"
+	CodeTwoFactorLocked      Code = "TWO_FACTOR_LOCKED"
+	CodeTwoFactorUnavailable Code = "TWO_FACTOR_UNAVAILABLE"
+
+	CodeUserNotFound      Code = "USER_NOT_FOUND"
+	CodeUserAlreadyExists Code = "USER_ALREADY_EXISTS"
+	CodeEmailInUse        Code = "EMAIL_ALREADY_IN_USE"
+	CodeEmailNotVerified  Code = "EMAIL_NOT_VERIFIED"
+
+	CodeAccountNotFound      Code = "ACCOUNT_NOT_FOUND"
+	CodeAccountAlreadyExists Code = "ACCOUNT_ALREADY_EXISTS"
+	CodeAccountNotOwned      Code = "ACCOUNT_NOT_OWNED"
+	CodeCurrencyMismatch     Code = "CURRENCY_MISMATCH"
+	CodeInsufficientFunds    Code = "INSUFFICIENT_FUNDS"
+	CodeSlowConsumer         Code = "SLOW_CONSUMER"
+
+	CodePaymentNotFound Code = "PAYMENT_NOT_FOUND"
+)
+
+type mapping struct {
+	httpStatus int
+	grpcCode   codes.Code
+}
+
+// mappings keeps the HTTP statuses the Gin API answered with before codes were introduced
+var mappings = map[Code]mapping{
+	CodeInternal:        {http.StatusInternalServerError, codes.Internal},
+	CodeInvalidArgument: {http.StatusBadRequest, codes.InvalidArgument},
+
+	CodeUnauthenticated:  {http.StatusUnauthorized, codes.Unauthenticated},
+	CodePermissionDenied: {http.StatusForbidden, codes.PermissionDenied},
+	CodeInvalidToken:     {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeExpiredToken:     {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeRevokedToken:     {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeMissingScope:     {http.StatusForbidden, codes.PermissionDenied},
+	CodeInvalidAPIKey:    {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeExpiredAPIKey:    {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeRevokedAPIKey:    {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeAPIKeyNotFound:   {http.StatusNotFound, codes.NotFound},
+	CodeInvalidDuration:  {http.StatusBadRequest, codes.InvalidArgument},
+	CodeScopesRequired:   {http.StatusBadRequest, codes.InvalidArgument},
+
+	CodeOAuthClientRevoked:   {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeOAuthConsentRevoked:  {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeOAuthConsentNotFound: {http.StatusNotFound, codes.NotFound},
+
+	CodeInvalidCredentials:   {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeLoginLocked:          {http.StatusTooManyRequests, codes.ResourceExhausted},
+	CodeSessionNotFound:      {http.StatusNotFound, codes.NotFound},
+	CodeInvalidSession:       {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeInvalidResetCode:     {http.StatusNotFound, codes.NotFound},
+	CodeInvalidVerifyCode:    {http.StatusNotFound, codes.NotFound},
+	CodeTwoFactorRequired:    {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeInvalidTwoFactor:     {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeInvalidChallenge:     {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeTwoFactorNotEnabled:  {http.StatusForbidden, codes.FailedPrecondition},
+	CodeTwoFactorNotEnrolled: {http.StatusForbidden, codes.FailedPrecondition},
+	CodeTwoFactorEnabled:     {http.StatusConflict, codes.AlreadyExists},
+	CodeTwoFactorLocked:      {http.StatusTooManyRequests, codes.ResourceExhausted},
+	CodeTwoFactorUnavailable: {http.StatusServiceUnavailable, codes.Unavailable},
+
+	CodeUserNotFound:      {http.StatusNotFound, codes.NotFound},
+	CodeUserAlreadyExists: {http.StatusForbidden, codes.AlreadyExists},
+	CodeEmailInUse:        {http.StatusConflict, codes.AlreadyExists},
+	CodeEmailNotVerified:  {http.StatusForbidden, codes.FailedPrecondition},
+
+	CodeAccountNotFound:      {http.StatusNotFound, codes.NotFound},
+	CodeAccountAlreadyExists: {http.StatusForbidden, codes.AlreadyExists},
+	CodeAccountNotOwned:      {http.StatusForbidden, codes.PermissionDenied},
+	CodeCurrencyMismatch:     {http.StatusBadRequest, codes.FailedPrecondition},
+	CodeInsufficientFunds:    {http.StatusUnprocessableEntity, codes.FailedPrecondition},
+	CodeSlowConsumer:         {http.StatusTooManyRequests, codes.ResourceExhausted},
+
+	CodePaymentNotFound: {http.StatusNotFound, codes.NotFound},
+}
+
+// HTTPStatus is the status of the problem details sent for code
+func (code Code) HTTPStatus() int {
+	if m, ok := mappings[code]; ok {
+		return m.httpStatus
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode is the status code sent for code
+func (code Code) GRPCCode() codes.Code {
+	if m, ok := mappings[code]; ok {
+		return m.grpcCode
+	}
+	return codes.Internal
+}
@@ -0,0 +1,89 @@
+package apperror
+
+import (
+	"errors"
+	"time"
+)
+
+// Domain is the domain of the gRPC ErrorInfo details, which scopes the codes
+const Domain = "simplebank"
+
+// Error is an error meant for clients: its code and message are part of the API, and stable.
+// The cause, if any, is only logged, never sent to clients.
+type Error struct {
+	Code    Code
+	Message string
+	// Metadata gives the client more context on the error, e.g. the account that has insufficient funds
+	Metadata map[string]string
+	// Violations lists the invalid fields of an InvalidArgument error
+	Violations []FieldViolation
+	// RetryAfter is how long the client has to wait before retrying, if it is set
+	RetryAfter time.Duration
+
+	cause error
+}
+
+// FieldViolation describes an invalid field of a request
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// New creates an error with the given code, and a message clients can see
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap creates an error with the given code and message, caused by err
+func Wrap(err error, code Code, message string) *Error {
+	return &Error{Code: code, Message: message, cause: err}
+}
+
+// Internal hides err from clients behind a generic message
+func Internal(err error) *Error {
+	return Wrap(err, CodeInternal, "internal error")
+}
+
+// InvalidArgument reports the invalid fields of a request
+func InvalidArgument(violations ...FieldViolation) *Error {
+	return &Error{Code: CodeInvalidArgument, Message: "invalid parameters", Violations: violations}
+}
+
+// With returns a copy of err with the metadata key set to value.
+// Sentinel errors are shared, so they are never modified in place.
+func (err *Error) With(key string, value string) *Error {
+	copied := *err
+	copied.Metadata = make(map[string]string, len(err.Metadata)+1)
+	for k, v := range err.Metadata {
+		copied.Metadata[k] = v
+	}
+	copied.Metadata[key] = value
+	return &copied
+}
+
+// Error includes the cause, for logs
+func (err *Error) Error() string {
+	if err.cause != nil {
+		return err.Message + ": " + err.cause.Error()
+	}
+	return err.Message
+}
+
+func (err *Error) Unwrap() error {
+	return err.cause
+}
+
+// Is matches errors with the same code and message, so that copies made by With still match their sentinel
+func (err *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == err.Code && t.Message == err.Message
+}
+
+// From returns err if it is, or wraps, an *Error. Any other error is internal.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err)
+}
@@ -0,0 +1,49 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+var errTest = New(CodeAccountNotFound, "account not found")
+
+func TestWith(t *testing.T) {
+	err := errTest.With("account_id", "1")
+	require.Equal(t, map[string]string{"account_id": "1"}, err.Metadata)
+	require.Empty(t, errTest.Metadata)
+	require.ErrorIs(t, err, errTest)
+	require.NotErrorIs(t, err, New(CodeAccountNotFound, "another message"))
+}
+
+func TestFrom(t *testing.T) {
+	cause := errors.New("sql: no rows in result set")
+	wrapped := fmt.Errorf("get account: %w", Wrap(cause, CodeAccountNotFound, "account not found"))
+
+	appErr := From(wrapped)
+	require.Equal(t, CodeAccountNotFound, appErr.Code)
+	require.ErrorIs(t, appErr, cause)
+	require.Equal(t, "account not found: sql: no rows in result set", appErr.Error())
+
+	appErr = From(cause)
+	require.Equal(t, CodeInternal, appErr.Code)
+	require.Equal(t, "internal error", appErr.Message)
+	require.ErrorIs(t, appErr, cause)
+}
+
+func TestCodeMappings(t *testing.T) {
+	require.Equal(t, http.StatusUnprocessableEntity, CodeInsufficientFunds.HTTPStatus())
+	require.Equal(t, codes.FailedPrecondition, CodeInsufficientFunds.GRPCCode())
+
+	unknown := Code("UNKNOWN_CODE")
+	require.Equal(t, http.StatusInternalServerError, unknown.HTTPStatus())
+	require.Equal(t, codes.Internal, unknown.GRPCCode())
+
+	for code, m := range mappings {
+		require.NotZero(t, m.httpStatus, code)
+	}
+}
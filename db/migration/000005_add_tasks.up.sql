@@ -0,0 +1,19 @@
+CREATE TABLE "tasks" (
+  "id" bigserial PRIMARY KEY,
+  "queue" varchar NOT NULL,
+  "type" varchar NOT NULL,
+  "payload" jsonb NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "attempts" int NOT NULL DEFAULT 0,
+  "max_attempts" int NOT NULL,
+  "last_error" varchar NOT NULL DEFAULT '',
+  "run_at" timestamptz NOT NULL DEFAULT (now()),
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "tasks" ("queue", "status", "run_at");
+
+COMMENT ON COLUMN "tasks"."status" IS 'pending, completed or failed';
+
+COMMENT ON COLUMN "tasks"."run_at" IS 'not before; pushed forward while a worker holds the task';
@@ -0,0 +1,16 @@
+CREATE TABLE "login_events" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "client_ip" varchar NOT NULL,
+  "user_agent" varchar NOT NULL,
+  "outcome" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "login_events" ("username", "created_at");
+
+CREATE INDEX ON "login_events" ("client_ip", "created_at");
+
+COMMENT ON COLUMN "login_events"."username" IS 'as submitted, so it may not belong to any user';
+
+COMMENT ON COLUMN "login_events"."outcome" IS 'success, failure, locked, unlocked or challenged. An attempt is recorded as a failure until its outcome is known';
@@ -0,0 +1,9 @@
+DELETE FROM "login_events" WHERE "kind" <> 'login';
+
+ALTER TABLE "login_events" DROP COLUMN IF EXISTS "kind";
+
+DROP TABLE IF EXISTS "login_challenges";
+
+DROP TABLE IF EXISTS "recovery_codes";
+
+DROP TABLE IF EXISTS "totp_secrets";
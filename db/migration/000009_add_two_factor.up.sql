@@ -0,0 +1,40 @@
+CREATE TABLE "totp_secrets" (
+  "username" varchar PRIMARY KEY,
+  "encrypted_secret" bytea NOT NULL,
+  "is_enabled" bool NOT NULL DEFAULT false,
+  "last_used_step" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "hashed_code" varchar NOT NULL,
+  "is_used" bool NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "login_challenges" (
+  "id" uuid PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "attempts" int NOT NULL DEFAULT 0,
+  "is_used" bool NOT NULL DEFAULT false,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "login_events" ADD COLUMN "kind" varchar NOT NULL DEFAULT 'login';
+
+CREATE UNIQUE INDEX ON "recovery_codes" ("username", "hashed_code");
+
+COMMENT ON COLUMN "totp_secrets"."encrypted_secret" IS 'AES-256-GCM with TOTP_ENCRYPTION_KEY and the username as additional data, after its nonce';
+
+COMMENT ON COLUMN "totp_secrets"."last_used_step" IS 'time step of the last accepted code, so a code cannot be replayed';
+
+COMMENT ON COLUMN "login_events"."kind" IS 'login, or step_up for the two-factor codes sent with high-value operations';
+
+ALTER TABLE "totp_secrets" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "login_challenges" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
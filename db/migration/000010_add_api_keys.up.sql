@@ -0,0 +1,20 @@
+CREATE TABLE "api_keys" (
+  "id" uuid PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "name" varchar NOT NULL,
+  "prefix" varchar UNIQUE NOT NULL,
+  "hashed_key" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "is_revoked" bool NOT NULL DEFAULT false,
+  "expires_at" timestamptz NOT NULL,
+  "last_used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "api_keys" ("username");
+
+COMMENT ON COLUMN "api_keys"."prefix" IS 'public start of the key, to tell keys apart and look them up';
+
+COMMENT ON COLUMN "api_keys"."hashed_key" IS 'sha256 of the whole key, which is only shown once';
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "oauth_consents";
+
+DROP TABLE IF EXISTS "oauth_authorization_codes";
+
+DROP TABLE IF EXISTS "oauth_clients";
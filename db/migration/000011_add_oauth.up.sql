@@ -0,0 +1,48 @@
+CREATE TABLE "oauth_clients" (
+  "id" uuid PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "name" varchar NOT NULL,
+  "hashed_secret" varchar,
+  "redirect_uris" varchar[] NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "oauth_authorization_codes" (
+  "hashed_code" varchar PRIMARY KEY,
+  "client_id" uuid NOT NULL,
+  "username" varchar NOT NULL,
+  "redirect_uri" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "code_challenge" varchar NOT NULL,
+  "is_used" bool NOT NULL DEFAULT false,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "oauth_consents" (
+  "username" varchar NOT NULL,
+  "client_id" uuid NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "granted_at" timestamptz NOT NULL DEFAULT (now()),
+  "revoked_at" timestamptz,
+  PRIMARY KEY ("username", "client_id")
+);
+
+CREATE INDEX ON "oauth_clients" ("owner");
+
+COMMENT ON COLUMN "oauth_clients"."hashed_secret" IS 'sha256 of the client secret; NULL for public clients, which must use PKCE';
+
+COMMENT ON COLUMN "oauth_authorization_codes"."code_challenge" IS 'PKCE S256 challenge the token request has to answer';
+
+COMMENT ON COLUMN "oauth_consents"."granted_at" IS 'tokens issued before are not valid, so granting again after a revocation does not revive them';
+
+ALTER TABLE "oauth_clients" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "oauth_authorization_codes" ADD FOREIGN KEY ("client_id") REFERENCES "oauth_clients" ("id");
+
+ALTER TABLE "oauth_authorization_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "oauth_consents" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "oauth_consents" ADD FOREIGN KEY ("client_id") REFERENCES "oauth_clients" ("id");
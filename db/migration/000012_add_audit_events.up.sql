@@ -0,0 +1,29 @@
+CREATE TABLE "audit_events" (
+  "id" bigserial PRIMARY KEY,
+  "actor" varchar NOT NULL,
+  "actor_role" varchar NOT NULL,
+  "client_ip" varchar NOT NULL,
+  "user_agent" varchar NOT NULL,
+  "action" varchar NOT NULL,
+  "resource_type" varchar NOT NULL,
+  "resource_id" varchar NOT NULL,
+  "before" jsonb,
+  "after" jsonb,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "audit_events" ("actor", "created_at");
+
+CREATE INDEX ON "audit_events" ("resource_type", "resource_id", "created_at");
+
+CREATE INDEX ON "audit_events" ("action", "created_at");
+
+CREATE INDEX ON "audit_events" ("created_at");
+
+COMMENT ON COLUMN "audit_events"."actor" IS 'username of the authenticated user, empty for anonymous requests such as signing up';
+
+COMMENT ON COLUMN "audit_events"."action" IS 'e.g. account.create or session.block';
+
+COMMENT ON COLUMN "audit_events"."before" IS 'state of the resource before the change, NULL when it is created';
+
+COMMENT ON COLUMN "audit_events"."after" IS 'state of the resource after the change, NULL when it is deleted';
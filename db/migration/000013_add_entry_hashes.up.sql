@@ -0,0 +1,32 @@
+ALTER TABLE "entries" ADD COLUMN "hash" bytea;
+
+CREATE INDEX ON "entries" ("account_id", "id");
+
+COMMENT ON COLUMN "entries"."hash" IS 'sha256 of the hash of the previous entry of the account and of this entry, see db.HashEntry';
+
+-- chain the existing entries of each account, the same way db.HashEntry does
+DO $$
+DECLARE
+  entry record;
+  prev_account_id bigint;
+  prev_hash bytea;
+BEGIN
+  FOR entry IN SELECT * FROM "entries" ORDER BY "account_id", "id" LOOP
+    IF prev_account_id IS DISTINCT FROM entry.account_id THEN
+      prev_account_id := entry.account_id;
+      prev_hash := decode(repeat('00', 32), 'hex');
+    END IF;
+
+    prev_hash := sha256(
+      prev_hash
+      || int8send(entry.id)
+      || int8send(entry.account_id)
+      || int8send(entry.amount)
+      || int8send(
+        extract(epoch FROM date_trunc('second', entry.created_at))::bigint * 1000000
+        + extract(microseconds FROM entry.created_at)::bigint % 1000000
+      )
+    );
+    UPDATE "entries" SET "hash" = prev_hash WHERE "id" = entry.id;
+  END LOOP;
+END $$;
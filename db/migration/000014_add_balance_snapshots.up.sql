@@ -0,0 +1,18 @@
+CREATE TABLE "balance_snapshots" (
+  "account_id" bigint NOT NULL,
+  "snapshot_date" date NOT NULL,
+  "balance" bigint NOT NULL,
+  "is_frozen" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("account_id", "snapshot_date")
+);
+
+CREATE INDEX ON "balance_snapshots" ("snapshot_date");
+
+CREATE INDEX ON "entries" ("account_id", "created_at");
+
+COMMENT ON COLUMN "balance_snapshots"."snapshot_date" IS 'day in UTC: the balance is the one at the end of that day';
+
+COMMENT ON COLUMN "balance_snapshots"."is_frozen" IS 'frozen by the end of day job, for reporting: it is never taken again';
+
+ALTER TABLE "balance_snapshots" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id") ON DELETE CASCADE;
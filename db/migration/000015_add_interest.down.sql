@@ -0,0 +1,9 @@
+-- the system user stays, as its accounts may have entries
+
+DROP TABLE IF EXISTS "interest_payouts";
+
+DROP TABLE IF EXISTS "interest_accruals";
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "product";
+
+DROP TABLE IF EXISTS "account_products";
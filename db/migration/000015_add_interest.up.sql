@@ -0,0 +1,66 @@
+CREATE TABLE "account_products" (
+  "code" varchar PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "annual_rate_ppm" bigint NOT NULL DEFAULT 0,
+  "day_count" varchar NOT NULL DEFAULT 'ACT/365',
+  "compounding" varchar NOT NULL DEFAULT 'monthly',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "interest_accruals" (
+  "account_id" bigint NOT NULL,
+  "accrual_date" date NOT NULL,
+  "balance" bigint NOT NULL,
+  "annual_rate_ppm" bigint NOT NULL,
+  "day_count" varchar NOT NULL,
+  "amount_micros" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("account_id", "accrual_date")
+);
+
+CREATE TABLE "interest_payouts" (
+  "account_id" bigint NOT NULL,
+  "payout_date" date NOT NULL,
+  "accrued_micros" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "carry_micros" bigint NOT NULL,
+  "transfer_id" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("account_id", "payout_date")
+);
+
+ALTER TABLE "accounts" ADD COLUMN "product" varchar NOT NULL DEFAULT 'checking';
+
+COMMENT ON COLUMN "account_products"."annual_rate_ppm" IS 'annual rate in parts per million: 20000 is 2%';
+
+COMMENT ON COLUMN "account_products"."day_count" IS 'ACT/365 or 30/360';
+
+COMMENT ON COLUMN "account_products"."compounding" IS 'daily, monthly, quarterly or annually: interest is paid out at the end of each period';
+
+COMMENT ON COLUMN "interest_accruals"."accrual_date" IS 'day in UTC: interest accrues on the balance at the end of that day';
+
+COMMENT ON COLUMN "interest_accruals"."amount_micros" IS 'in millionths of the minor unit, rounded down';
+
+COMMENT ON COLUMN "interest_payouts"."accrued_micros" IS 'accrued since the last payout, with its carry';
+
+COMMENT ON COLUMN "interest_payouts"."carry_micros" IS 'less than a minor unit, left for the next payout';
+
+COMMENT ON COLUMN "interest_payouts"."transfer_id" IS 'from the interest expense account, unless nothing was paid out';
+
+ALTER TABLE "interest_accruals" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "interest_payouts" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "interest_payouts" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+INSERT INTO "account_products" ("code", "name", "annual_rate_ppm", "day_count", "compounding") VALUES
+  ('checking', 'Checking', 0, 'ACT/365', 'monthly'),
+  ('savings', 'Savings', 20000, 'ACT/365', 'monthly');
+
+ALTER TABLE "accounts" ADD FOREIGN KEY ("product") REFERENCES "account_products" ("code");
+
+-- system users own the accounts of the bank itself. They cannot log in, and get their username as email, which is
+-- not a valid address, so no user can sign up with it or take it with an update.
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email", "role") VALUES
+  ('system:interest_expense', '', 'Simple Bank Interest expense', 'system:interest_expense', 'system')
+ON CONFLICT ("username") DO NOTHING;
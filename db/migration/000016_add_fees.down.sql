@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "transfer_fees";
+
+DROP TABLE IF EXISTS "fee_rules";
+
+DROP TABLE IF EXISTS "fee_schedules";
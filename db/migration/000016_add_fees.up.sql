@@ -0,0 +1,57 @@
+CREATE TABLE "fee_schedules" (
+  "code" varchar PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "fee_rules" (
+  "id" bigserial PRIMARY KEY,
+  "schedule" varchar NOT NULL,
+  "currency" varchar NOT NULL,
+  "min_amount" bigint NOT NULL DEFAULT 0,
+  "flat_fee" bigint NOT NULL DEFAULT 0,
+  "rate_ppm" bigint NOT NULL DEFAULT 0,
+  "min_fee" bigint NOT NULL DEFAULT 0,
+  "max_fee" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "transfer_fees" (
+  "transfer_id" bigint PRIMARY KEY,
+  "fee_transfer_id" bigint UNIQUE NOT NULL,
+  "schedule" varchar NOT NULL,
+  "rule_id" bigint NOT NULL,
+  "flat" bigint NOT NULL,
+  "percentage" bigint NOT NULL,
+  "adjustment" bigint NOT NULL,
+  "total" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE UNIQUE INDEX ON "fee_rules" ("schedule", "currency", "min_amount");
+
+COMMENT ON COLUMN "fee_rules"."min_amount" IS 'tier: the rule applies to transfers of at least min_amount, up to the next tier';
+
+COMMENT ON COLUMN "fee_rules"."rate_ppm" IS 'percentage of the amount, in parts per million: 1000 is 0.1%';
+
+COMMENT ON COLUMN "fee_rules"."max_fee" IS '0 for no maximum';
+
+COMMENT ON COLUMN "transfer_fees"."fee_transfer_id" IS 'from the sender to the fee revenue account';
+
+ALTER TABLE "fee_rules" ADD FOREIGN KEY ("schedule") REFERENCES "fee_schedules" ("code");
+
+ALTER TABLE "transfer_fees" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "transfer_fees" ADD FOREIGN KEY ("fee_transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "transfer_fees" ADD FOREIGN KEY ("schedule") REFERENCES "fee_schedules" ("code");
+
+ALTER TABLE "transfer_fees" ADD FOREIGN KEY ("rule_id") REFERENCES "fee_rules" ("id");
+
+INSERT INTO "fee_schedules" ("code", "name") VALUES ('standard', 'Standard');
+
+INSERT INTO "fee_rules" ("schedule", "currency", "min_amount", "flat_fee", "rate_ppm", "min_fee", "max_fee") VALUES
+  ('standard', 'USD', 0, 25, 0, 0, 0),
+  ('standard', 'USD', 100000, 0, 1000, 100, 1000),
+  ('standard', 'EUR', 0, 0, 2000, 20, 500),
+  ('standard', 'CAD', 0, 30, 0, 0, 0);
@@ -0,0 +1,8 @@
+-- the system accounts that were never used go, the others keep their entries
+DELETE FROM "accounts"
+WHERE "owner" LIKE 'system:%'
+  AND NOT EXISTS (SELECT 1 FROM "entries" WHERE "entries"."account_id" = "accounts"."id");
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "gl_code";
+
+DROP TABLE IF EXISTS "gl_accounts";
@@ -0,0 +1,41 @@
+CREATE TABLE "gl_accounts" (
+  "code" varchar PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "type" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  CHECK ("type" IN ('asset', 'liability', 'revenue', 'expense'))
+);
+
+ALTER TABLE "accounts" ADD COLUMN "gl_code" varchar NOT NULL DEFAULT 'customer_deposits';
+
+COMMENT ON COLUMN "gl_accounts"."type" IS 'asset, liability, revenue or expense';
+
+COMMENT ON COLUMN "accounts"."gl_code" IS 'customer_deposits for the accounts of users, the code of the GL account for system accounts';
+
+INSERT INTO "gl_accounts" ("code", "name", "type") VALUES
+  ('customer_deposits', 'Customer deposits', 'liability'),
+  ('fee_revenue', 'Fee revenue', 'revenue'),
+  ('interest_expense', 'Interest expense', 'expense');
+
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email", "role")
+SELECT 'system:' || "code", '', 'Simple Bank ' || "name", 'system:' || "code", 'system'
+FROM "gl_accounts"
+WHERE "code" <> 'customer_deposits'
+ON CONFLICT ("username") DO NOTHING;
+
+UPDATE "accounts" SET "gl_code" = "gl_accounts"."code"
+FROM "gl_accounts"
+WHERE "accounts"."owner" = 'system:' || "gl_accounts"."code";
+
+-- every GL account other than customer deposits has a system account in every currency, so transactions only
+-- have to look them up. A new currency needs a migration that creates its system accounts.
+INSERT INTO "accounts" ("owner", "balance", "currency", "gl_code")
+SELECT 'system:' || "code", 0, "currency", "code"
+FROM "gl_accounts"
+CROSS JOIN (VALUES ('USD'), ('EUR'), ('CAD')) AS "currencies" ("currency")
+WHERE "code" <> 'customer_deposits'
+ON CONFLICT ("owner", "currency") DO NOTHING;
+
+ALTER TABLE "accounts" ADD FOREIGN KEY ("gl_code") REFERENCES "gl_accounts" ("code");
+
+CREATE INDEX ON "accounts" ("gl_code", "currency");
@@ -0,0 +1,12 @@
+ALTER TABLE "entries" DROP COLUMN IF EXISTS "payment_id";
+
+DROP TABLE IF EXISTS "payments";
+
+-- the clearing and suspense accounts that were used keep their entries, and so their GL accounts
+DELETE FROM "accounts"
+WHERE "gl_code" IN ('clearing', 'suspense')
+  AND NOT EXISTS (SELECT 1 FROM "entries" WHERE "entries"."account_id" = "accounts"."id");
+
+DELETE FROM "gl_accounts"
+WHERE "code" IN ('clearing', 'suspense')
+  AND NOT EXISTS (SELECT 1 FROM "accounts" WHERE "accounts"."gl_code" = "gl_accounts"."code");
@@ -0,0 +1,64 @@
+CREATE TABLE "payments" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "direction" varchar NOT NULL,
+  "rail" varchar NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "reference" varchar NOT NULL DEFAULT '',
+  "failure_reason" varchar NOT NULL DEFAULT '',
+  "pending_transfer_id" bigint,
+  "final_transfer_id" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "completed_at" timestamptz,
+  CHECK ("direction" IN ('deposit', 'withdrawal')),
+  CHECK ("status" IN ('pending', 'settled', 'failed')),
+  CHECK ("amount" > 0)
+);
+
+CREATE INDEX ON "payments" ("account_id");
+
+COMMENT ON COLUMN "payments"."direction" IS 'deposit or withdrawal';
+
+COMMENT ON COLUMN "payments"."rail" IS 'the payment rail that moves the money, e.g. ach, sepa or card';
+
+COMMENT ON COLUMN "payments"."status" IS 'pending, settled or failed';
+
+COMMENT ON COLUMN "payments"."reference" IS 'given by the rail once it accepts the payment';
+
+COMMENT ON COLUMN "payments"."pending_transfer_id" IS 'into the suspense account, from the clearing account for deposits and from the account for withdrawals. Set right after the payment is created';
+
+COMMENT ON COLUMN "payments"."final_transfer_id" IS 'out of the suspense account once the payment settles or fails';
+
+ALTER TABLE "payments" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "payments" ADD FOREIGN KEY ("pending_transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "payments" ADD FOREIGN KEY ("final_transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "entries" ADD COLUMN "payment_id" bigint;
+
+COMMENT ON COLUMN "entries"."payment_id" IS 'the payment that moved the money, if any: its status tells whether the entry is pending, settled or failed';
+
+ALTER TABLE "entries" ADD FOREIGN KEY ("payment_id") REFERENCES "payments" ("id");
+
+CREATE INDEX ON "entries" ("payment_id");
+
+INSERT INTO "gl_accounts" ("code", "name", "type") VALUES
+  ('clearing', 'Payment rail clearing', 'asset'),
+  ('suspense', 'Payments in suspense', 'liability')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email", "role")
+SELECT 'system:' || "code", '', 'Simple Bank ' || "name", 'system:' || "code", 'system'
+FROM "gl_accounts"
+WHERE "code" IN ('clearing', 'suspense')
+ON CONFLICT ("username") DO NOTHING;
+
+INSERT INTO "accounts" ("owner", "balance", "currency", "gl_code")
+SELECT 'system:' || "code", 0, "currency", "code"
+FROM "gl_accounts"
+CROSS JOIN (VALUES ('USD'), ('EUR'), ('CAD')) AS "currencies" ("currency")
+WHERE "code" IN ('clearing', 'suspense')
+ON CONFLICT ("owner", "currency") DO NOTHING;
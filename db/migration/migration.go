@@ -0,0 +1,183 @@
+// Package migration embeds the SQL migrations of the schema, and applies them with golang-migrate
+package migration
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	// registers the pgx5:// database driver
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+)
+
+// lockID is the key of the advisory lock held while migrating on start or rolling back. It differs from the lock
+// golang-migrate takes for each operation, so that both can be held at once.
+const lockID = 7305916218234401
+
+//go:embed *.sql
+var files embed.FS
+
+// upFile matches the up migrations created by golang-migrate, e.g. 000011_add_oauth.up.sql
+var upFile = regexp.MustCompile(`^(\d+)_.*\.up\.sql$`)
+
+// Latest returns the version of the last migration embedded in the binary
+func Latest() (uint, error) {
+	return latestVersion(files)
+}
+
+func latestVersion(fsys fs.FS) (uint, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return 0, fmt.Errorf("cannot read migrations: %w", err)
+	}
+
+	var latest uint
+	for _, entry := range entries {
+		match := upFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		latest = max(latest, uint(version))
+	}
+
+	if latest == 0 {
+		return 0, errors.New("no migration found")
+	}
+	return latest, nil
+}
+
+// Migrator applies the embedded migrations to a database
+type Migrator struct {
+	m        *migrate.Migrate
+	dbSource string
+}
+
+// New creates a migrator for the database at dbSource, a postgresql:// URL
+func New(dbSource string) (*Migrator, error) {
+	source, err := iofs.New(files, ".")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read migrations: %w", err)
+	}
+
+	databaseURL, err := driverURL(dbSource)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", source, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create migrator: %w", err)
+	}
+	return &Migrator{m: m, dbSource: dbSource}, nil
+}
+
+// driverURL swaps the scheme of dbSource for the one of the golang-migrate pgx/v5 driver
+func driverURL(dbSource string) (string, error) {
+	for _, scheme := range []string{"postgresql://", "postgres://"} {
+		if rest, ok := strings.CutPrefix(dbSource, scheme); ok {
+			return "pgx5://" + rest, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported database url scheme: %s", dbSource)
+}
+
+// Up applies n migrations, or all the pending ones if n is zero
+func (migrator *Migrator) Up(n int) error {
+	if n > 0 {
+		return ignoreNoChange(migrator.m.Steps(n))
+	}
+	return ignoreNoChange(migrator.m.Up())
+}
+
+// Down rolls back the last n migrations. It takes the same advisory lock as UpOnStart, so that a replica
+// starting meanwhile does not migrate the schema back up halfway through.
+func (migrator *Migrator) Down(ctx context.Context, n int) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid number of migrations to roll back: %d", n)
+	}
+	return migrator.withLock(ctx, func() error {
+		return ignoreNoChange(migrator.m.Steps(-n))
+	})
+}
+
+// Version returns the version of the schema, which is zero if no migration was applied.
+// dirty is true if the last migration failed.
+func (migrator *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = migrator.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// CheckVersion fails if the schema is newer than the embedded migrations,
+// i.e. if it was migrated by a more recent binary
+func (migrator *Migrator) CheckVersion() error {
+	latest, err := Latest()
+	if err != nil {
+		return err
+	}
+
+	version, _, err := migrator.Version()
+	if err != nil {
+		return fmt.Errorf("cannot get schema version: %w", err)
+	}
+	if version > latest {
+		return fmt.Errorf("schema is at version %d, newer than the latest migration known to this binary: %d", version, latest)
+	}
+	return nil
+}
+
+// UpOnStart applies the pending migrations when the service starts. The whole check and upgrade runs under
+// an advisory lock, so that replicas starting together wait for each other instead of racing.
+func (migrator *Migrator) UpOnStart(ctx context.Context) error {
+	return migrator.withLock(ctx, func() error {
+		if err := migrator.CheckVersion(); err != nil {
+			return err
+		}
+		return migrator.Up(0)
+	})
+}
+
+// withLock runs fn while holding the migration advisory lock, on a session of its own
+func (migrator *Migrator) withLock(ctx context.Context, fn func() error) error {
+	conn, err := pgx.Connect(ctx, migrator.dbSource)
+	if err != nil {
+		return fmt.Errorf("cannot connect to db: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("cannot take migration lock: %w", err)
+	}
+	// the lock is released with the session anyway, should unlocking fail
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+
+	return fn()
+}
+
+// Close releases the connection of the migrator
+func (migrator *Migrator) Close() error {
+	sourceErr, databaseErr := migrator.m.Close()
+	return errors.Join(sourceErr, databaseErr)
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
@@ -1,8 +1,8 @@
 -- name: CreateAccount :one
 INSERT INTO accounts (
-  owner, balance, currency
+  owner, balance, currency, product
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, COALESCE(sqlc.narg(product)::varchar, 'checking')
 )
 RETURNING *;
 
@@ -37,3 +37,13 @@ RETURNING *;
 -- name: DeleteAccount :exec
 DELETE FROM accounts
 WHERE id = $1;
+
+-- name: ListAllAccounts :many
+SELECT * FROM accounts
+WHERE id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT $1;
+
+-- name: GetAccountByOwner :one
+SELECT * FROM accounts
+WHERE owner = $1 AND currency = $2 LIMIT 1;
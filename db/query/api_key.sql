@@ -0,0 +1,41 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (
+  id,
+  username,
+  name,
+  prefix,
+  hashed_key,
+  scopes,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: GetAPIKeyByPrefix :one
+SELECT * FROM api_keys
+WHERE prefix = $1 LIMIT 1;
+
+-- name: ListAPIKeys :many
+SELECT * FROM api_keys
+WHERE username = $1
+ORDER BY created_at DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: RevokeAPIKey :one
+UPDATE api_keys
+SET
+  is_revoked = TRUE
+WHERE
+  id = @id
+  AND username = @username
+RETURNING *;
+
+-- name: TouchAPIKey :exec
+-- records that the key was used, at most once a minute to spare writes on busy keys
+UPDATE api_keys
+SET
+  last_used_at = now()
+WHERE
+  id = $1
+  AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute');
@@ -0,0 +1,47 @@
+-- name: TakeBalanceSnapshots :execrows
+-- takes the snapshots of the accounts that exist at the end of the day, from their current balance and the
+-- entries created since. Frozen snapshots are kept as they are.
+INSERT INTO balance_snapshots (
+  account_id,
+  snapshot_date,
+  balance
+)
+SELECT
+  a.id,
+  sqlc.arg(snapshot_date)::date,
+  a.balance - COALESCE((
+    SELECT SUM(e.amount) FROM entries e
+    WHERE e.account_id = a.id AND e.created_at >= sqlc.arg(day_end)
+  ), 0)::bigint
+FROM accounts a
+WHERE a.created_at < sqlc.arg(day_end)
+ON CONFLICT (account_id, snapshot_date) DO UPDATE
+SET
+  balance = EXCLUDED.balance,
+  created_at = now()
+WHERE NOT balance_snapshots.is_frozen;
+
+-- name: FreezeBalanceSnapshots :execrows
+UPDATE balance_snapshots
+SET is_frozen = true
+WHERE snapshot_date = $1 AND NOT is_frozen;
+
+-- name: GetLastFrozenSnapshotDate :one
+SELECT snapshot_date FROM balance_snapshots
+WHERE is_frozen
+ORDER BY snapshot_date DESC
+LIMIT 1;
+
+-- name: GetBalanceSnapshotBefore :one
+SELECT * FROM balance_snapshots
+WHERE account_id = $1 AND snapshot_date < sqlc.arg(before)
+ORDER BY snapshot_date DESC
+LIMIT 1;
+
+-- name: SumEntries :one
+-- sums the amounts of the entries of the account created in [since, until), or since since if until is NULL
+SELECT COALESCE(SUM(amount), 0)::bigint AS total FROM entries
+WHERE
+  account_id = $1
+  AND created_at >= sqlc.arg(since)
+  AND (sqlc.narg(until)::timestamptz IS NULL OR created_at < sqlc.narg(until));
@@ -1,10 +1,41 @@
 -- name: CreateEntry :one
+-- the entry is linked to the hash chain of its account, the same way db.HashEntry does. The account must be
+-- locked already, e.g. by AddAccountBalance, so that its entries are chained one transaction at a time.
+WITH prev AS (
+  SELECT hash FROM entries
+  WHERE account_id = sqlc.arg(account_id)
+  ORDER BY id DESC
+  LIMIT 1
+), next AS (
+  SELECT nextval(pg_get_serial_sequence('entries', 'id')) AS id, now() AS created_at
+)
 INSERT INTO entries (
+  id,
   account_id,
-  amount
-) VALUES (
-  $1, $2
-) RETURNING *;
+  amount,
+  created_at,
+  payment_id,
+  hash
+)
+SELECT
+  next.id,
+  sqlc.arg(account_id),
+  sqlc.arg(amount),
+  next.created_at,
+  sqlc.narg(payment_id),
+  sha256(
+    coalesce((SELECT hash FROM prev), decode(repeat('00', 32), 'hex'))
+    || int8send(next.id)
+    || int8send(sqlc.arg(account_id)::bigint)
+    || int8send(sqlc.arg(amount)::bigint)
+    || int8send(
+      extract(epoch FROM date_trunc('second', next.created_at))::bigint * 1000000
+      + extract(microseconds FROM next.created_at)::bigint % 1000000
+    )
+    || coalesce(int8send(sqlc.narg(payment_id)::bigint), ''::bytea)
+  )
+FROM next
+RETURNING *;
 
 -- name: GetEntry :one
 SELECT * FROM entries
@@ -16,3 +47,21 @@ WHERE account_id = $1
 ORDER BY id
 LIMIT $2
 OFFSET $3;
+
+-- name: GetLastEntry :one
+SELECT * FROM entries
+WHERE account_id = $1
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: GetLastEntryBefore :one
+SELECT * FROM entries
+WHERE account_id = $1 AND created_at < sqlc.arg(before)
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: ListEntriesAfter :many
+SELECT * FROM entries
+WHERE account_id = $1 AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT $2;
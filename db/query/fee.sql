@@ -0,0 +1,27 @@
+-- name: GetFeeSchedule :one
+SELECT * FROM fee_schedules
+WHERE code = $1 LIMIT 1;
+
+-- name: ListFeeRules :many
+SELECT * FROM fee_rules
+WHERE schedule = $1 AND currency = $2
+ORDER BY min_amount;
+
+-- name: CreateTransferFee :one
+INSERT INTO transfer_fees (
+  transfer_id,
+  fee_transfer_id,
+  schedule,
+  rule_id,
+  flat,
+  percentage,
+  adjustment,
+  total
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: GetTransferFee :one
+SELECT * FROM transfer_fees
+WHERE transfer_id = $1 LIMIT 1;
@@ -0,0 +1,21 @@
+-- name: GetGLAccount :one
+SELECT * FROM gl_accounts
+WHERE code = $1 LIMIT 1;
+
+-- name: ListGLAccounts :many
+SELECT * FROM gl_accounts
+ORDER BY code;
+
+-- name: ListGLBalances :many
+-- sums the balances of the accounts of each GL account, in each currency
+SELECT
+  gl_accounts.code,
+  gl_accounts.name,
+  gl_accounts.type,
+  accounts.currency,
+  COUNT(accounts.id) AS accounts,
+  SUM(accounts.balance)::bigint AS balance
+FROM gl_accounts
+JOIN accounts ON accounts.gl_code = gl_accounts.code
+GROUP BY gl_accounts.code, accounts.currency
+ORDER BY accounts.currency, gl_accounts.code;
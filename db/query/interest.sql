@@ -0,0 +1,59 @@
+-- name: GetAccountProduct :one
+SELECT * FROM account_products
+WHERE code = $1 LIMIT 1;
+
+-- name: ListAccountProducts :many
+SELECT * FROM account_products
+ORDER BY code;
+
+-- name: ListInterestBearingAccounts :many
+-- lists the accounts created before day_end whose product earns interest, after after_id
+SELECT * FROM accounts
+WHERE
+  accounts.id > sqlc.arg(after_id)
+  AND accounts.created_at < sqlc.arg(day_end)
+  AND accounts.product IN (SELECT code FROM account_products WHERE annual_rate_ppm > 0)
+ORDER BY accounts.id
+LIMIT $1;
+
+-- name: CreateInterestAccrual :one
+-- returns no row if the account already accrued interest on that day
+INSERT INTO interest_accruals (
+  account_id,
+  accrual_date,
+  balance,
+  annual_rate_ppm,
+  day_count,
+  amount_micros
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (account_id, accrual_date) DO NOTHING
+RETURNING *;
+
+-- name: SumInterestAccruals :one
+-- sums the interest the account accrued in (after, until]
+SELECT COALESCE(SUM(amount_micros), 0)::bigint AS total FROM interest_accruals
+WHERE
+  account_id = $1
+  AND accrual_date > sqlc.arg(after)
+  AND accrual_date <= sqlc.arg(until);
+
+-- name: GetLastInterestPayout :one
+SELECT * FROM interest_payouts
+WHERE account_id = $1 AND payout_date < sqlc.arg(before)
+ORDER BY payout_date DESC
+LIMIT 1;
+
+-- name: CreateInterestPayout :one
+INSERT INTO interest_payouts (
+  account_id,
+  payout_date,
+  accrued_micros,
+  amount,
+  carry_micros,
+  transfer_id
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
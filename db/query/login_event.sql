@@ -0,0 +1,51 @@
+-- name: CreateLoginEvent :one
+INSERT INTO login_events (
+  username,
+  client_ip,
+  user_agent,
+  outcome,
+  kind
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetUserLoginFailures :one
+-- counts failures of @kind since @since, forgetting those before the last success of the same kind or unlock
+SELECT
+  COUNT(*) AS failures,
+  COALESCE(MAX(e.created_at), '0001-01-01 00:00:00Z')::timestamptz AS last_failure_at
+FROM login_events e
+WHERE
+  e.username = sqlc.arg(username)
+  AND e.kind = sqlc.arg(kind)
+  AND e.outcome = 'failure'
+  AND e.created_at > sqlc.arg(since)
+  AND e.created_at > COALESCE((
+    SELECT MAX(r.created_at) FROM login_events r
+    WHERE r.username = sqlc.arg(username)
+      AND (r.outcome = 'unlocked' OR (r.outcome = 'success' AND r.kind = sqlc.arg(kind)))
+  ), '-infinity'::timestamptz);
+
+-- name: GetClientIPLoginFailures :one
+SELECT
+  COUNT(*) AS failures,
+  COALESCE(MAX(created_at), '0001-01-01 00:00:00Z')::timestamptz AS last_failure_at
+FROM login_events
+WHERE
+  client_ip = sqlc.arg(client_ip)
+  AND kind = sqlc.arg(kind)
+  AND outcome = 'failure'
+  AND created_at > sqlc.arg(since);
+
+
+-- name: LockLoginAttempts :exec
+-- serializes the attempts on @key, a username or client IP, until the end of the transaction
+SELECT pg_advisory_xact_lock(hashtext(sqlc.arg(key)::text));
+
+-- name: FinishLoginEvent :one
+UPDATE login_events
+SET
+  username = sqlc.arg(username),
+  outcome = sqlc.arg(outcome)
+WHERE id = sqlc.arg(id)
+RETURNING *;
@@ -0,0 +1,78 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (
+  id,
+  owner,
+  name,
+  hashed_secret,
+  redirect_uris,
+  scopes
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1 LIMIT 1;
+
+-- name: CreateOAuthAuthorizationCode :one
+INSERT INTO oauth_authorization_codes (
+  hashed_code,
+  client_id,
+  username,
+  redirect_uri,
+  scopes,
+  code_challenge,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: UseOAuthAuthorizationCode :one
+-- an authorization code can be exchanged only once, before it expires
+UPDATE oauth_authorization_codes
+SET
+  is_used = TRUE
+WHERE
+  hashed_code = $1
+  AND is_used = FALSE
+  AND expires_at > now()
+RETURNING *;
+
+-- name: UpsertOAuthConsent :one
+-- granting again keeps the tokens issued under the current consent valid, unless it had been revoked
+INSERT INTO oauth_consents (
+  username,
+  client_id,
+  scopes
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT (username, client_id) DO UPDATE
+SET
+  scopes = EXCLUDED.scopes,
+  granted_at = CASE WHEN oauth_consents.revoked_at IS NULL THEN oauth_consents.granted_at ELSE now() END,
+  revoked_at = NULL
+RETURNING *;
+
+-- name: GetOAuthConsent :one
+SELECT * FROM oauth_consents
+WHERE username = $1 AND client_id = $2 LIMIT 1;
+
+-- name: ListOAuthConsents :many
+SELECT oauth_consents.*, oauth_clients.name AS client_name
+FROM oauth_consents
+JOIN oauth_clients ON oauth_clients.id = oauth_consents.client_id
+WHERE
+  oauth_consents.username = $1
+  AND oauth_consents.revoked_at IS NULL
+ORDER BY oauth_consents.granted_at DESC;
+
+-- name: RevokeOAuthConsent :one
+UPDATE oauth_consents
+SET
+  revoked_at = now()
+WHERE
+  username = $1
+  AND client_id = $2
+  AND revoked_at IS NULL
+RETURNING *;
@@ -0,0 +1,53 @@
+-- name: CreatePayment :one
+INSERT INTO payments (
+  account_id, direction, rail, amount, currency
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: SetPaymentPendingTransfer :one
+UPDATE payments
+SET pending_transfer_id = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: GetPayment :one
+SELECT * FROM payments
+WHERE id = $1 LIMIT 1;
+
+-- name: GetPaymentForUpdate :one
+SELECT * FROM payments
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: ListPayments :many
+SELECT * FROM payments
+WHERE account_id = $1
+ORDER BY id DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: ListPendingPayments :many
+-- the payments still pending that were created before created_before, in the order of their ids from after_id
+SELECT * FROM payments
+WHERE status = 'pending' AND id > sqlc.arg(after_id) AND created_at < sqlc.arg(created_before)
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: SetPaymentReference :one
+UPDATE payments
+SET reference = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: CompletePayment :one
+-- only pending payments complete, so a payment settles or fails once
+UPDATE payments
+SET
+  status = sqlc.arg(status),
+  failure_reason = sqlc.arg(failure_reason),
+  final_transfer_id = sqlc.arg(final_transfer_id),
+  completed_at = now()
+WHERE id = sqlc.arg(id) AND status = 'pending'
+RETURNING *;
@@ -0,0 +1,18 @@
+-- name: CreateResetPassword :one
+INSERT INTO reset_passwords (
+  username,
+  hashed_code
+) VALUES (
+  $1, $2
+) RETURNING *;
+
+-- name: UpdateResetPassword :one
+UPDATE reset_passwords
+SET
+  is_used = TRUE
+WHERE
+  id = @id
+  AND hashed_code = @hashed_code
+  AND is_used = FALSE
+  AND expired_at > now()
+RETURNING *;
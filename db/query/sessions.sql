@@ -15,3 +15,11 @@ RETURNING *;
 -- name: GetSession :one
 SELECT * FROM sessions
 WHERE id = $1 LIMIT 1;
+
+-- name: BlockUserSessions :exec
+UPDATE sessions
+SET
+  is_blocked = TRUE
+WHERE
+  username = $1
+  AND is_blocked = FALSE;
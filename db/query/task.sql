@@ -0,0 +1,55 @@
+-- name: CreateTask :one
+INSERT INTO tasks (
+  queue,
+  type,
+  payload,
+  max_attempts,
+  run_at
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetTask :one
+SELECT * FROM tasks
+WHERE id = $1 LIMIT 1;
+
+-- name: ClaimTask :one
+UPDATE tasks
+SET
+  attempts = attempts + 1,
+  run_at = sqlc.arg(locked_until),
+  updated_at = now()
+WHERE id = (
+  SELECT t.id FROM tasks t
+  WHERE
+    t.queue = sqlc.arg(queue)
+    AND t.status = 'pending'
+    AND t.run_at <= now()
+  ORDER BY t.run_at
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteTask :exec
+UPDATE tasks
+SET
+  status = 'completed',
+  updated_at = now()
+WHERE id = $1;
+
+-- name: RetryTask :exec
+UPDATE tasks
+SET
+  run_at = $2,
+  last_error = $3,
+  updated_at = now()
+WHERE id = $1;
+
+-- name: FailTask :exec
+UPDATE tasks
+SET
+  status = 'failed',
+  last_error = $2,
+  updated_at = now()
+WHERE id = $1;
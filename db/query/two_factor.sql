@@ -0,0 +1,99 @@
+-- name: UpsertTOTPSecret :one
+-- replaces a pending secret, but never one that is already enabled
+INSERT INTO totp_secrets (
+  username,
+  encrypted_secret
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (username) DO UPDATE
+SET
+  encrypted_secret = EXCLUDED.encrypted_secret,
+  last_used_step = 0,
+  created_at = now()
+WHERE
+  totp_secrets.is_enabled = FALSE
+RETURNING *;
+
+-- name: GetTOTPSecret :one
+SELECT * FROM totp_secrets
+WHERE username = $1 LIMIT 1;
+
+-- name: EnableTOTPSecret :one
+UPDATE totp_secrets
+SET
+  is_enabled = TRUE,
+  last_used_step = @step
+WHERE
+  username = @username
+  AND is_enabled = FALSE
+RETURNING *;
+
+-- name: UseTOTPStep :one
+UPDATE totp_secrets
+SET
+  last_used_step = @step
+WHERE
+  username = @username
+  AND is_enabled = TRUE
+  AND last_used_step < @step
+RETURNING *;
+
+-- name: DeleteTOTPSecret :one
+DELETE FROM totp_secrets
+WHERE
+  username = $1
+  AND is_enabled = TRUE
+RETURNING *;
+
+-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (
+  username,
+  hashed_code
+) VALUES (
+  $1, $2
+) RETURNING *;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE username = $1;
+
+-- name: UseRecoveryCode :one
+UPDATE recovery_codes
+SET
+  is_used = TRUE
+WHERE
+  username = @username
+  AND hashed_code = @hashed_code
+  AND is_used = FALSE
+RETURNING *;
+
+-- name: CreateLoginChallenge :one
+INSERT INTO login_challenges (
+  id,
+  username,
+  expires_at
+) VALUES (
+  $1, $2, $3
+) RETURNING *;
+
+-- name: AttemptLoginChallenge :one
+-- counts an attempt at answering the challenge, as long as it is still open
+UPDATE login_challenges
+SET
+  attempts = attempts + 1
+WHERE
+  id = @id
+  AND is_used = FALSE
+  AND expires_at > now()
+  AND attempts < @max_attempts
+RETURNING *;
+
+-- name: CompleteLoginChallenge :one
+UPDATE login_challenges
+SET
+  is_used = TRUE
+WHERE
+  id = $1
+  AND is_used = FALSE
+RETURNING *;
@@ -10,3 +10,42 @@ RETURNING *;
 SELECT * FROM users
 WHERE username = $1 LIMIT 1;
 
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET
+  is_email_verified = TRUE
+WHERE
+  username = @username
+  AND email = @email
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1 LIMIT 1;
+
+-- name: GetUserPasswordChangedAt :one
+SELECT password_changed_at FROM users
+WHERE username = $1 LIMIT 1;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET
+  hashed_password = @hashed_password,
+  password_changed_at = @password_changed_at
+WHERE
+  username = @username
+RETURNING *;
+
+-- name: UpdateUser :one
+UPDATE users
+SET
+  full_name = COALESCE(sqlc.narg(full_name), full_name),
+  email = COALESCE(sqlc.narg(email), email),
+  is_email_verified = CASE
+    WHEN sqlc.narg(email)::varchar IS NULL OR sqlc.narg(email)::varchar = email THEN is_email_verified
+    ELSE FALSE
+  END
+WHERE
+  username = sqlc.arg(username)
+RETURNING *;
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"sync"
+)
+
+// accountEventsBuffer is how many events a watcher may fall behind before it is dropped
+const accountEventsBuffer = 16
+
+// AccountEvent describes a committed change to an account: its new state and the entry that caused it
+type AccountEvent struct {
+	Account Account `json:"account"`
+	Entry   Entry   `json:"entry"`
+}
+
+type accountWatcher struct {
+	events chan AccountEvent
+}
+
+// accountBroker fans out committed account events to the watchers of each account.
+// Publishing never blocks: a watcher whose buffer is full is dropped and its channel closed.
+//
+// The broker only lives in the process of the store, and only sees the transactions committed through it.
+// Watching accounts therefore requires a single replica of the server: behind a load balancer, a watcher
+// would miss every change committed by another replica. Serving more replicas needs the events to be fanned
+// out through Postgres, e.g. with LISTEN/NOTIFY, first.
+type accountBroker struct {
+	mu       sync.Mutex
+	watchers map[int64]map[*accountWatcher]struct{}
+}
+
+func newAccountBroker() *accountBroker {
+	return &accountBroker{
+		watchers: make(map[int64]map[*accountWatcher]struct{}),
+	}
+}
+
+// watch registers a watcher for the account until ctx is done
+func (broker *accountBroker) watch(ctx context.Context, accountID int64) <-chan AccountEvent {
+	watcher := &accountWatcher{
+		events: make(chan AccountEvent, accountEventsBuffer),
+	}
+
+	broker.mu.Lock()
+	if broker.watchers[accountID] == nil {
+		broker.watchers[accountID] = make(map[*accountWatcher]struct{})
+	}
+	broker.watchers[accountID][watcher] = struct{}{}
+	broker.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		broker.mu.Lock()
+		broker.remove(accountID, watcher)
+		broker.mu.Unlock()
+	}()
+
+	return watcher.events
+}
+
+func (broker *accountBroker) publish(events ...AccountEvent) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for _, event := range events {
+		for watcher := range broker.watchers[event.Account.ID] {
+			select {
+			case watcher.events <- event:
+			default:
+				// slow consumer: drop it rather than hold up the transfer
+				broker.remove(event.Account.ID, watcher)
+			}
+		}
+	}
+}
+
+// remove must be called with mu held. It is a no-op if the watcher is already gone.
+func (broker *accountBroker) remove(accountID int64, watcher *accountWatcher) {
+	watchers, ok := broker.watchers[accountID]
+	if !ok {
+		return
+	}
+	if _, ok := watchers[watcher]; !ok {
+		return
+	}
+
+	delete(watchers, watcher)
+	close(watcher.events)
+	if len(watchers) == 0 {
+		delete(broker.watchers, accountID)
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountBrokerPublish(t *testing.T) {
+	broker := newAccountBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := broker.watch(ctx, 1)
+	other := broker.watch(ctx, 2)
+
+	broker.publish(AccountEvent{Account: Account{ID: 1, Balance: 10}, Entry: Entry{ID: 7, AccountID: 1, Amount: 10}})
+
+	event := <-events
+	require.Equal(t, int64(1), event.Account.ID)
+	require.Equal(t, int64(10), event.Account.Balance)
+	require.Equal(t, int64(7), event.Entry.ID)
+	require.Empty(t, other)
+}
+
+func TestAccountBrokerCancel(t *testing.T) {
+	broker := newAccountBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	events := broker.watch(ctx, 1)
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("channel was not closed after cancel")
+	}
+
+	// publishing to an account nobody watches must not panic
+	broker.publish(AccountEvent{Account: Account{ID: 1}})
+}
+
+func TestAccountBrokerSlowConsumer(t *testing.T) {
+	broker := newAccountBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := broker.watch(ctx, 1)
+
+	// publishing more than the buffer holds must not block
+	for i := 0; i <= accountEventsBuffer; i++ {
+		broker.publish(AccountEvent{Account: Account{ID: 1}})
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	require.Equal(t, accountEventsBuffer, received)
+}
@@ -2,7 +2,6 @@ package db
 
 import (
 	"context"
-	"database/sql"
 	"testing"
 	"time"
 
@@ -37,13 +36,24 @@ func createRandomAccount (t *testing.T) Account{
 	return createTestAccount(t, user.Username, util.RandomCurrency(), util.RandomMoney())
 }
 
+// createFundedAccount creates an account of a random user with enough money for the transfers of a test
+func createFundedAccount(t *testing.T) Account {
+	user := createRandomUser(t)
+
+	return createTestAccount(t, user.Username, util.USD, 1000)
+}
+
 func TestCreateAccount(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	createRandomAccount(t)
 }
 
 func TestGetAccount(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	account1 := createRandomAccount(t)
@@ -59,6 +69,8 @@ func TestGetAccount(t *testing.T) {
 }
 
 func TestUpdateAccount(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	account1 := createRandomAccount(t)
@@ -79,6 +91,8 @@ func TestUpdateAccount(t *testing.T) {
 }
 
 func TestDeleteAccount(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	account1 := createRandomAccount(t)
@@ -87,12 +101,14 @@ func TestDeleteAccount(t *testing.T) {
 
 	account2, err := testQueries.GetAccount(context.Background(), account1.ID)
 	require.Error(t, err)
-	require.EqualError(t, err, sql.ErrNoRows.Error())
+	require.EqualError(t, err, ErrRecordNotFound.Error())
 	require.Empty(t, account2)
 
 }
 
 func TestListAccounts(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 	var lastAccount Account
 	for i := 0; i < 10; i++ {
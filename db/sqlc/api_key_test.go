@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomAPIKey(t *testing.T, user User) ApiKey {
+	arg := CreateAPIKeyParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		Name:      util.RandomString(8),
+		Prefix:    "sbk_" + util.RandomString(8),
+		HashedKey: util.RandomString(64),
+		Scopes:    []string{util.AccountsReadScope, util.TransfersWriteScope},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	apiKey, err := testQueries.CreateAPIKey(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, apiKey.ID)
+	require.Equal(t, arg.Username, apiKey.Username)
+	require.Equal(t, arg.Name, apiKey.Name)
+	require.Equal(t, arg.Prefix, apiKey.Prefix)
+	require.Equal(t, arg.HashedKey, apiKey.HashedKey)
+	require.Equal(t, arg.Scopes, apiKey.Scopes)
+	require.WithinDuration(t, arg.ExpiresAt, apiKey.ExpiresAt, time.Second)
+	require.False(t, apiKey.IsRevoked)
+	require.False(t, apiKey.LastUsedAt.Valid)
+	require.NotZero(t, apiKey.CreatedAt)
+
+	return apiKey
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+	createRandomAPIKey(t, createRandomUser(t))
+}
+
+func TestGetAPIKeyByPrefix(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	apiKey1 := createRandomAPIKey(t, createRandomUser(t))
+	apiKey2, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey1.Prefix)
+	require.NoError(t, err)
+	require.Equal(t, apiKey1.ID, apiKey2.ID)
+	require.Equal(t, apiKey1.Scopes, apiKey2.Scopes)
+
+	_, err = testQueries.GetAPIKeyByPrefix(context.Background(), "sbk_unknown")
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestListAPIKeys(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	user := createRandomUser(t)
+	for i := 0; i < 3; i++ {
+		createRandomAPIKey(t, user)
+	}
+	createRandomAPIKey(t, createRandomUser(t))
+
+	apiKeys, err := testQueries.ListAPIKeys(context.Background(), ListAPIKeysParams{
+		Username: user.Username,
+		Limit:    5,
+		Offset:   0,
+	})
+	require.NoError(t, err)
+	require.Len(t, apiKeys, 3)
+	for _, apiKey := range apiKeys {
+		require.Equal(t, user.Username, apiKey.Username)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	apiKey := createRandomAPIKey(t, createRandomUser(t))
+
+	// only the owner can revoke it
+	_, err := testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{ID: apiKey.ID, Username: util.RandomOwner()})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	revoked, err := testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{ID: apiKey.ID, Username: apiKey.Username})
+	require.NoError(t, err)
+	require.True(t, revoked.IsRevoked)
+}
+
+func TestTouchAPIKey(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	apiKey := createRandomAPIKey(t, createRandomUser(t))
+
+	err := testQueries.TouchAPIKey(context.Background(), apiKey.ID)
+	require.NoError(t, err)
+
+	touched, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.True(t, touched.LastUsedAt.Valid)
+	require.WithinDuration(t, time.Now(), touched.LastUsedAt.Time, time.Second)
+
+	// further uses within the minute are not written
+	err = testQueries.TouchAPIKey(context.Background(), apiKey.ID)
+	require.NoError(t, err)
+
+	touchedAgain, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.Equal(t, touched.LastUsedAt.Time, touchedAgain.LastUsedAt.Time)
+}
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditAccountCreate  = "account.create"
+	AuditAccountUpdate  = "account.update"
+	AuditAccountDelete  = "account.delete"
+	AuditTransferCreate = "transfer.create"
+	AuditInterestPay    = "interest.pay"
+	AuditPaymentCreate  = "payment.create"
+	AuditPaymentSettle  = "payment.settle"
+	AuditPaymentFail    = "payment.fail"
+	AuditSessionCreate  = "session.create"
+	AuditSessionBlock   = "session.block"
+	AuditUserCreate     = "user.create"
+	AuditUserUpdate     = "user.update"
+	AuditUserVerify     = "user.verify_email"
+	AuditUserPassword   = "user.change_password"
+	AuditUserReset      = "user.reset_password"
+	AuditUserTOTP       = "user.enable_totp"
+	AuditUserTOTPOff    = "user.disable_totp"
+)
+
+// Types of the resources in the audit log
+const (
+	AuditResourceAccount  = "account"
+	AuditResourceTransfer = "transfer"
+	AuditResourcePayment  = "payment"
+	AuditResourceSession  = "session"
+	AuditResourceUser     = "user"
+)
+
+// AuditActor is who makes a change, as recorded in the audit log
+type AuditActor struct {
+	// Username and Role are empty for anonymous requests, such as signing up
+	Username  string
+	Role      string
+	ClientIP  string
+	UserAgent string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor returns a copy of ctx whose changes are recorded as made by actor
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// WithAuditUser returns a copy of ctx whose changes are recorded as made by the user,
+// from the client of the actor already in ctx
+func WithAuditUser(ctx context.Context, username string, role string) context.Context {
+	actor := AuditActorFrom(ctx)
+	actor.Username = username
+	actor.Role = role
+	return WithAuditActor(ctx, actor)
+}
+
+// AuditActorFrom returns the actor of ctx, which is empty for changes made by the server itself
+func AuditActorFrom(ctx context.Context) AuditActor {
+	actor, _ := ctx.Value(auditActorKey{}).(AuditActor)
+	return actor
+}
+
+// auditChange describes a change to record in the audit log
+type auditChange struct {
+	action       string
+	resourceType string
+	resourceID   string
+	// before and after are encoded to JSON; nil stands for a resource that does not exist
+	before any
+	after  any
+}
+
+// recordAudit adds change to the audit log with q, so it is committed or rolled back with the change itself
+func recordAudit(ctx context.Context, q Querier, change auditChange) error {
+	before, err := auditState(change.before)
+	if err != nil {
+		return err
+	}
+	after, err := auditState(change.after)
+	if err != nil {
+		return err
+	}
+
+	actor := AuditActorFrom(ctx)
+	_, err = q.CreateAuditEvent(ctx, CreateAuditEventParams{
+		Actor:        actor.Username,
+		ActorRole:    actor.Role,
+		ClientIp:     actor.ClientIP,
+		UserAgent:    actor.UserAgent,
+		Action:       change.action,
+		ResourceType: change.resourceType,
+		ResourceID:   change.resourceID,
+		Before:       before,
+		After:        after,
+	})
+	return err
+}
+
+func auditState(state any) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+func accountChange(action string, before, after *Account) auditChange {
+	change := auditChange{action: action, resourceType: AuditResourceAccount}
+	if before != nil {
+		change.resourceID = strconv.FormatInt(before.ID, 10)
+		change.before = before
+	}
+	if after != nil {
+		change.resourceID = strconv.FormatInt(after.ID, 10)
+		change.after = after
+	}
+	return change
+}
+
+// auditedUser is what the audit log keeps of a user: never the password hash
+type auditedUser struct {
+	Username          string    `json:"username"`
+	FullName          string    `json:"full_name"`
+	Email             string    `json:"email"`
+	Role              string    `json:"role"`
+	IsEmailVerified   bool      `json:"is_email_verified"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+func userChange(action string, before, after *User) auditChange {
+	change := auditChange{action: action, resourceType: AuditResourceUser}
+	if before != nil {
+		change.resourceID = before.Username
+		change.before = newAuditedUser(*before)
+	}
+	if after != nil {
+		change.resourceID = after.Username
+		change.after = newAuditedUser(*after)
+	}
+	return change
+}
+
+func newAuditedUser(user User) auditedUser {
+	return auditedUser{
+		Username:          user.Username,
+		FullName:          user.FullName,
+		Email:             user.Email,
+		Role:              user.Role,
+		IsEmailVerified:   user.IsEmailVerified,
+		PasswordChangedAt: user.PasswordChangedAt,
+		CreatedAt:         user.CreatedAt,
+	}
+}
+
+// auditedSession is what the audit log keeps of a session: never the refresh token
+type auditedSession struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	UserAgent string    `json:"user_agent"`
+	ClientIp  string    `json:"client_ip"`
+	IsBlocked bool      `json:"is_blocked"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func sessionCreated(session Session) auditChange {
+	return auditChange{
+		action:       AuditSessionCreate,
+		resourceType: AuditResourceSession,
+		resourceID:   session.ID.String(),
+		after: auditedSession{
+			ID:        session.ID,
+			Username:  session.Username,
+			UserAgent: session.UserAgent,
+			ClientIp:  session.ClientIp,
+			IsBlocked: session.IsBlocked,
+			ExpiresAt: session.ExpiresAt,
+			CreatedAt: session.CreatedAt,
+		},
+	}
+}
+
+// sessionsBlocked records that all the sessions of a user were blocked, e.g. when the password changes
+func sessionsBlocked(username string) auditChange {
+	return auditChange{
+		action:       AuditSessionBlock,
+		resourceType: AuditResourceUser,
+		resourceID:   username,
+		after:        map[string]bool{"sessions_blocked": true},
+	}
+}
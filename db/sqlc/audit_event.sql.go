@@ -0,0 +1,136 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: audit_event.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+  actor,
+  actor_role,
+  client_ip,
+  user_agent,
+  action,
+  resource_type,
+  resource_id,
+  before,
+  after
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING id, actor, actor_role, client_ip, user_agent, action, resource_type, resource_id, before, after, created_at
+`
+
+type CreateAuditEventParams struct {
+	Actor        string `json:"actor"`
+	ActorRole    string `json:"actor_role"`
+	ClientIp     string `json:"client_ip"`
+	UserAgent    string `json:"user_agent"`
+	Action       string `json:"action"`
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+	Before       []byte `json:"before"`
+	After        []byte `json:"after"`
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error) {
+	row := q.db.QueryRow(ctx, createAuditEvent,
+		arg.Actor,
+		arg.ActorRole,
+		arg.ClientIp,
+		arg.UserAgent,
+		arg.Action,
+		arg.ResourceType,
+		arg.ResourceID,
+		arg.Before,
+		arg.After,
+	)
+	var i AuditEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Actor,
+		&i.ActorRole,
+		&i.ClientIp,
+		&i.UserAgent,
+		&i.Action,
+		&i.ResourceType,
+		&i.ResourceID,
+		&i.Before,
+		&i.After,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, actor, actor_role, client_ip, user_agent, action, resource_type, resource_id, before, after, created_at FROM audit_events
+WHERE
+  ($1::varchar IS NULL OR actor = $1)
+  AND ($2::varchar IS NULL OR action = $2)
+  AND ($3::varchar IS NULL OR resource_type = $3)
+  AND ($4::varchar IS NULL OR resource_id = $4)
+  AND ($5::timestamptz IS NULL OR created_at >= $5)
+  AND ($6::timestamptz IS NULL OR created_at < $6)
+ORDER BY id DESC
+LIMIT $8
+OFFSET $7
+`
+
+type ListAuditEventsParams struct {
+	Actor        pgtype.Text        `json:"actor"`
+	Action       pgtype.Text        `json:"action"`
+	ResourceType pgtype.Text        `json:"resource_type"`
+	ResourceID   pgtype.Text        `json:"resource_id"`
+	Since        pgtype.Timestamptz `json:"since"`
+	Until        pgtype.Timestamptz `json:"until"`
+	Offset       int64              `json:"offset"`
+	Limit        int64              `json:"limit"`
+}
+
+// newest first; the filters left NULL match every event
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.Query(ctx, listAuditEvents,
+		arg.Actor,
+		arg.Action,
+		arg.ResourceType,
+		arg.ResourceID,
+		arg.Since,
+		arg.Until,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditEvent{}
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Actor,
+			&i.ActorRole,
+			&i.ClientIp,
+			&i.UserAgent,
+			&i.Action,
+			&i.ResourceType,
+			&i.ResourceID,
+			&i.Before,
+			&i.After,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,141 @@
+package db
+
+import "context"
+
+// The changes the audit log records are made in a transaction of their own, along with their audit event,
+// when they are not part of one of the Tx methods. Within those, the queries are run as they are and the
+// Tx method records the change.
+
+// audited runs change in a transaction and records the auditChange it returns
+func audited[T any](ctx context.Context, store *txStore, change func(q Querier) (T, auditChange, error)) (T, error) {
+	var result T
+
+	err := store.execTx(ctx, func(q Querier) error {
+		var audit auditChange
+		var err error
+
+		result, audit, err = change(q)
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, q, audit)
+	})
+
+	return result, err
+}
+
+// CreateAccount implements Querier and records the new account in the audit log.
+func (store *txStore) CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error) {
+	return audited(ctx, store, func(q Querier) (Account, auditChange, error) {
+		account, err := q.CreateAccount(ctx, arg)
+		return account, accountChange(AuditAccountCreate, nil, &account), err
+	})
+}
+
+// UpdateAccount implements Querier and records the change in the audit log.
+func (store *txStore) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error) {
+	return audited(ctx, store, func(q Querier) (Account, auditChange, error) {
+		before, err := q.GetAccountForUpdate(ctx, arg.ID)
+		if err != nil {
+			return Account{}, auditChange{}, err
+		}
+
+		account, err := q.UpdateAccount(ctx, arg)
+		return account, accountChange(AuditAccountUpdate, &before, &account), err
+	})
+}
+
+// AddAccountBalance implements Querier and records the change in the audit log.
+func (store *txStore) AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error) {
+	return audited(ctx, store, func(q Querier) (Account, auditChange, error) {
+		before, err := q.GetAccountForUpdate(ctx, arg.ID)
+		if err != nil {
+			return Account{}, auditChange{}, err
+		}
+
+		account, err := q.AddAccountBalance(ctx, arg)
+		return account, accountChange(AuditAccountUpdate, &before, &account), err
+	})
+}
+
+// DeleteAccount implements Querier and records the deleted account in the audit log.
+// Deleting an account that does not exist is not recorded.
+func (store *txStore) DeleteAccount(ctx context.Context, id int64) error {
+	return store.execTx(ctx, func(q Querier) error {
+		before, err := q.GetAccountForUpdate(ctx, id)
+		if err == ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = q.DeleteAccount(ctx, id); err != nil {
+			return err
+		}
+		return recordAudit(ctx, q, accountChange(AuditAccountDelete, &before, nil))
+	})
+}
+
+// CreateSession implements Querier and records the new session in the audit log.
+func (store *txStore) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	return audited(ctx, store, func(q Querier) (Session, auditChange, error) {
+		session, err := q.CreateSession(ctx, arg)
+		return session, sessionCreated(session), err
+	})
+}
+
+// BlockUserSessions implements Querier and records the change in the audit log.
+func (store *txStore) BlockUserSessions(ctx context.Context, username string) error {
+	_, err := audited(ctx, store, func(q Querier) (struct{}, auditChange, error) {
+		return struct{}{}, sessionsBlocked(username), q.BlockUserSessions(ctx, username)
+	})
+	return err
+}
+
+// CreateUser implements Querier and records the new user in the audit log.
+func (store *txStore) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	return audited(ctx, store, func(q Querier) (User, auditChange, error) {
+		user, err := q.CreateUser(ctx, arg)
+		return user, userChange(AuditUserCreate, nil, &user), err
+	})
+}
+
+// UpdateUser implements Querier and records the change in the audit log.
+func (store *txStore) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	return audited(ctx, store, func(q Querier) (User, auditChange, error) {
+		before, err := q.GetUser(ctx, arg.Username)
+		if err != nil {
+			return User{}, auditChange{}, err
+		}
+
+		user, err := q.UpdateUser(ctx, arg)
+		return user, userChange(AuditUserUpdate, &before, &user), err
+	})
+}
+
+// UpdateUserPassword implements Querier and records the change in the audit log.
+func (store *txStore) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	return audited(ctx, store, func(q Querier) (User, auditChange, error) {
+		before, err := q.GetUser(ctx, arg.Username)
+		if err != nil {
+			return User{}, auditChange{}, err
+		}
+
+		user, err := q.UpdateUserPassword(ctx, arg)
+		return user, userChange(AuditUserPassword, &before, &user), err
+	})
+}
+
+// VerifyUserEmail implements Querier and records the change in the audit log.
+func (store *txStore) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	return audited(ctx, store, func(q Querier) (User, auditChange, error) {
+		before, err := q.GetUser(ctx, arg.Username)
+		if err != nil {
+			return User{}, auditChange{}, err
+		}
+
+		user, err := q.VerifyUserEmail(ctx, arg)
+		return user, userChange(AuditUserVerify, &before, &user), err
+	})
+}
@@ -0,0 +1,125 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: balance_snapshot.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const freezeBalanceSnapshots = `-- name: FreezeBalanceSnapshots :execrows
+UPDATE balance_snapshots
+SET is_frozen = true
+WHERE snapshot_date = $1 AND NOT is_frozen
+`
+
+func (q *Queries) FreezeBalanceSnapshots(ctx context.Context, snapshotDate time.Time) (int64, error) {
+	result, err := q.db.Exec(ctx, freezeBalanceSnapshots, snapshotDate)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getBalanceSnapshotBefore = `-- name: GetBalanceSnapshotBefore :one
+SELECT account_id, snapshot_date, balance, is_frozen, created_at FROM balance_snapshots
+WHERE account_id = $1 AND snapshot_date < $2
+ORDER BY snapshot_date DESC
+LIMIT 1
+`
+
+type GetBalanceSnapshotBeforeParams struct {
+	AccountID int64     `json:"account_id"`
+	Before    time.Time `json:"before"`
+}
+
+func (q *Queries) GetBalanceSnapshotBefore(ctx context.Context, arg GetBalanceSnapshotBeforeParams) (BalanceSnapshot, error) {
+	row := q.db.QueryRow(ctx, getBalanceSnapshotBefore, arg.AccountID, arg.Before)
+	var i BalanceSnapshot
+	err := row.Scan(
+		&i.AccountID,
+		&i.SnapshotDate,
+		&i.Balance,
+		&i.IsFrozen,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLastFrozenSnapshotDate = `-- name: GetLastFrozenSnapshotDate :one
+SELECT snapshot_date FROM balance_snapshots
+WHERE is_frozen
+ORDER BY snapshot_date DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLastFrozenSnapshotDate(ctx context.Context) (time.Time, error) {
+	row := q.db.QueryRow(ctx, getLastFrozenSnapshotDate)
+	var snapshot_date time.Time
+	err := row.Scan(&snapshot_date)
+	return snapshot_date, err
+}
+
+const sumEntries = `-- name: SumEntries :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS total FROM entries
+WHERE
+  account_id = $1
+  AND created_at >= $2
+  AND ($3::timestamptz IS NULL OR created_at < $3)
+`
+
+type SumEntriesParams struct {
+	AccountID int64              `json:"account_id"`
+	Since     time.Time          `json:"since"`
+	Until     pgtype.Timestamptz `json:"until"`
+}
+
+// sums the amounts of the entries of the account created in [since, until), or since since if until is NULL
+func (q *Queries) SumEntries(ctx context.Context, arg SumEntriesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, sumEntries, arg.AccountID, arg.Since, arg.Until)
+	var total int64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const takeBalanceSnapshots = `-- name: TakeBalanceSnapshots :execrows
+INSERT INTO balance_snapshots (
+  account_id,
+  snapshot_date,
+  balance
+)
+SELECT
+  a.id,
+  $1::date,
+  a.balance - COALESCE((
+    SELECT SUM(e.amount) FROM entries e
+    WHERE e.account_id = a.id AND e.created_at >= $2
+  ), 0)::bigint
+FROM accounts a
+WHERE a.created_at < $2
+ON CONFLICT (account_id, snapshot_date) DO UPDATE
+SET
+  balance = EXCLUDED.balance,
+  created_at = now()
+WHERE NOT balance_snapshots.is_frozen
+`
+
+type TakeBalanceSnapshotsParams struct {
+	SnapshotDate time.Time `json:"snapshot_date"`
+	DayEnd       time.Time `json:"day_end"`
+}
+
+// takes the snapshots of the accounts that exist at the end of the day, from their current balance and the
+// entries created since. Frozen snapshots are kept as they are.
+func (q *Queries) TakeBalanceSnapshots(ctx context.Context, arg TakeBalanceSnapshotsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, takeBalanceSnapshots, arg.SnapshotDate, arg.DayEnd)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
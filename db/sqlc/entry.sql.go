@@ -7,53 +7,139 @@ package db
 
 import (
 	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createEntry = `-- name: CreateEntry :one
+WITH prev AS (
+  SELECT hash FROM entries
+  WHERE account_id = $1
+  ORDER BY id DESC
+  LIMIT 1
+), next AS (
+  SELECT nextval(pg_get_serial_sequence('entries', 'id')) AS id, now() AS created_at
+)
 INSERT INTO entries (
+  id,
   account_id,
-  amount
-) VALUES (
-  $1, $2
-) RETURNING id, account_id, amount, created_at
+  amount,
+  created_at,
+  payment_id,
+  hash
+)
+SELECT
+  next.id,
+  $1,
+  $2,
+  next.created_at,
+  $3,
+  sha256(
+    coalesce((SELECT hash FROM prev), decode(repeat('00', 32), 'hex'))
+    || int8send(next.id)
+    || int8send($1::bigint)
+    || int8send($2::bigint)
+    || int8send(
+      extract(epoch FROM date_trunc('second', next.created_at))::bigint * 1000000
+      + extract(microseconds FROM next.created_at)::bigint % 1000000
+    )
+    || coalesce(int8send($3::bigint), ''::bytea)
+  )
+FROM next
+RETURNING id, account_id, amount, created_at, hash, payment_id
 `
 
 type CreateEntryParams struct {
-	AccountID int64 `json:"account_id"`
-	Amount    int64 `json:"amount"`
+	AccountID int64       `json:"account_id"`
+	Amount    int64       `json:"amount"`
+	PaymentID pgtype.Int8 `json:"payment_id"`
 }
 
+// the entry is linked to the hash chain of its account, the same way db.HashEntry does. The account must be
+// locked already, e.g. by AddAccountBalance, so that its entries are chained one transaction at a time.
 func (q *Queries) CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error) {
-	row := q.db.QueryRowContext(ctx, createEntry, arg.AccountID, arg.Amount)
+	row := q.db.QueryRow(ctx, createEntry, arg.AccountID, arg.Amount, arg.PaymentID)
 	var i Entry
 	err := row.Scan(
 		&i.ID,
 		&i.AccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.Hash,
+		&i.PaymentID,
 	)
 	return i, err
 }
 
 const getEntry = `-- name: GetEntry :one
-SELECT id, account_id, amount, created_at FROM entries
+SELECT id, account_id, amount, created_at, hash, payment_id FROM entries
 WHERE id = $1 LIMIT 1
 `
 
 func (q *Queries) GetEntry(ctx context.Context, id int64) (Entry, error) {
-	row := q.db.QueryRowContext(ctx, getEntry, id)
+	row := q.db.QueryRow(ctx, getEntry, id)
+	var i Entry
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.Hash,
+		&i.PaymentID,
+	)
+	return i, err
+}
+
+const getLastEntry = `-- name: GetLastEntry :one
+SELECT id, account_id, amount, created_at, hash, payment_id FROM entries
+WHERE account_id = $1
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLastEntry(ctx context.Context, accountID int64) (Entry, error) {
+	row := q.db.QueryRow(ctx, getLastEntry, accountID)
+	var i Entry
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.Hash,
+		&i.PaymentID,
+	)
+	return i, err
+}
+
+const getLastEntryBefore = `-- name: GetLastEntryBefore :one
+SELECT id, account_id, amount, created_at, hash, payment_id FROM entries
+WHERE account_id = $1 AND created_at < $2
+ORDER BY id DESC
+LIMIT 1
+`
+
+type GetLastEntryBeforeParams struct {
+	AccountID int64     `json:"account_id"`
+	Before    time.Time `json:"before"`
+}
+
+func (q *Queries) GetLastEntryBefore(ctx context.Context, arg GetLastEntryBeforeParams) (Entry, error) {
+	row := q.db.QueryRow(ctx, getLastEntryBefore, arg.AccountID, arg.Before)
 	var i Entry
 	err := row.Scan(
 		&i.ID,
 		&i.AccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.Hash,
+		&i.PaymentID,
 	)
 	return i, err
 }
 
 const listEntries = `-- name: ListEntries :many
-SELECT id, account_id, amount, created_at FROM entries
+SELECT id, account_id, amount, created_at, hash, payment_id FROM entries
 WHERE account_id = $1
 ORDER BY id
 LIMIT $2
@@ -67,7 +153,7 @@ type ListEntriesParams struct {
 }
 
 func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error) {
-	rows, err := q.db.QueryContext(ctx, listEntries, arg.AccountID, arg.Limit, arg.Offset)
+	rows, err := q.db.Query(ctx, listEntries, arg.AccountID, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -80,14 +166,53 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 			&i.AccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.Hash,
+			&i.PaymentID,
 		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
 	}
-	if err := rows.Close(); err != nil {
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEntriesAfter = `-- name: ListEntriesAfter :many
+SELECT id, account_id, amount, created_at, hash, payment_id FROM entries
+WHERE account_id = $1 AND id > $3
+ORDER BY id
+LIMIT $2
+`
+
+type ListEntriesAfterParams struct {
+	AccountID int64 `json:"account_id"`
+	Limit     int64 `json:"limit"`
+	AfterID   int64 `json:"after_id"`
+}
+
+func (q *Queries) ListEntriesAfter(ctx context.Context, arg ListEntriesAfterParams) ([]Entry, error) {
+	rows, err := q.db.Query(ctx, listEntriesAfter, arg.AccountID, arg.Limit, arg.AfterID)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+	items := []Entry{}
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.Hash,
+			&i.PaymentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// The entries of each account form a hash chain, in the order of their ids: the hash of an entry covers
+// the hash of the previous entry of the account and the content of the entry itself. Editing, inserting
+// or deleting an entry with direct SQL breaks the chain from that entry on, which the ledger package checks.
+// CreateEntry links every entry it creates to the chain, so there is no way to create an unchained entry.
+
+// genesisHash stands for the previous hash of the first entry of an account
+var genesisHash = make([]byte, sha256.Size)
+
+// HashEntry computes the hash of entry, chained to the hash of the previous entry of the account.
+// prevHash is nil for the first entry. Migration 000013 and CreateEntry compute the same hash in SQL.
+func HashEntry(prevHash []byte, entry Entry) []byte {
+	if prevHash == nil {
+		prevHash = genesisHash
+	}
+
+	hash := sha256.New()
+	hash.Write(prevHash)
+	fields := []int64{entry.ID, entry.AccountID, entry.Amount, entry.CreatedAt.UnixMicro()}
+	// the payment is only covered when there is one, so the entries chained before it existed keep their hash
+	if entry.PaymentID.Valid {
+		fields = append(fields, entry.PaymentID.Int64)
+	}
+	for _, field := range fields {
+		hash.Write(binary.BigEndian.AppendUint64(nil, uint64(field)))
+	}
+	return hash.Sum(nil)
+}
@@ -25,6 +25,8 @@ func createTestEntry(t *testing.T, account *Account, amount int64) Entry {
 }
 
 func TestCreateEntry(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	account := createRandomAccount(t)
@@ -32,6 +34,8 @@ func TestCreateEntry(t *testing.T) {
 }
 
 func TestGetEntry(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	account := createRandomAccount(t)
@@ -46,6 +50,8 @@ func TestGetEntry(t *testing.T) {
 }
 
 func TestListEntries(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	account := createRandomAccount(t)
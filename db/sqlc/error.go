@@ -0,0 +1,50 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pakojabi/simplebank/apperror"
+)
+
+// SQLSTATE codes of the errors callers handle
+const (
+	UniqueViolation      = "23505"
+	ForeignKeyViolation  = "23503"
+	SerializationFailure = "40001"
+	DeadlockDetected     = "40P01"
+)
+
+// ErrRecordNotFound is returned by the queries that get a single row, when there is none
+var ErrRecordNotFound = pgx.ErrNoRows
+
+// ErrUniqueViolation and ErrForeignKeyViolation stand for the errors Postgres returns when a
+// constraint is violated, e.g. for mocks of Store. Check errors with IsUniqueViolation and IsForeignKeyViolation.
+var (
+	ErrUniqueViolation     = &pgconn.PgError{Code: UniqueViolation}
+	ErrForeignKeyViolation = &pgconn.PgError{Code: ForeignKeyViolation}
+)
+
+// ErrorCode returns the SQLSTATE code of err, or an empty string if Postgres did not return it
+func ErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// IsUniqueViolation tells whether err is a violation of a unique constraint, e.g. a duplicate username
+func IsUniqueViolation(err error) bool {
+	return ErrorCode(err) == UniqueViolation
+}
+
+// IsForeignKeyViolation tells whether err is a violation of a foreign key, e.g. an account of an unknown user
+func IsForeignKeyViolation(err error) bool {
+	return ErrorCode(err) == ForeignKeyViolation
+}
+
+// ErrInsufficientFunds is returned when a transaction would overdraw the account of a customer, so it rolls back.
+// The id of the account is in its metadata.
+var ErrInsufficientFunds = apperror.New(apperror.CodeInsufficientFunds, "account balance is too low")
@@ -0,0 +1,138 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: fee.sql
+
+package db
+
+import (
+	"context"
+)
+
+const createTransferFee = `-- name: CreateTransferFee :one
+INSERT INTO transfer_fees (
+  transfer_id,
+  fee_transfer_id,
+  schedule,
+  rule_id,
+  flat,
+  percentage,
+  adjustment,
+  total
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING transfer_id, fee_transfer_id, schedule, rule_id, flat, percentage, adjustment, total, created_at
+`
+
+type CreateTransferFeeParams struct {
+	TransferID    int64  `json:"transfer_id"`
+	FeeTransferID int64  `json:"fee_transfer_id"`
+	Schedule      string `json:"schedule"`
+	RuleID        int64  `json:"rule_id"`
+	Flat          int64  `json:"flat"`
+	Percentage    int64  `json:"percentage"`
+	Adjustment    int64  `json:"adjustment"`
+	Total         int64  `json:"total"`
+}
+
+func (q *Queries) CreateTransferFee(ctx context.Context, arg CreateTransferFeeParams) (TransferFee, error) {
+	row := q.db.QueryRow(ctx, createTransferFee,
+		arg.TransferID,
+		arg.FeeTransferID,
+		arg.Schedule,
+		arg.RuleID,
+		arg.Flat,
+		arg.Percentage,
+		arg.Adjustment,
+		arg.Total,
+	)
+	var i TransferFee
+	err := row.Scan(
+		&i.TransferID,
+		&i.FeeTransferID,
+		&i.Schedule,
+		&i.RuleID,
+		&i.Flat,
+		&i.Percentage,
+		&i.Adjustment,
+		&i.Total,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getFeeSchedule = `-- name: GetFeeSchedule :one
+SELECT code, name, created_at FROM fee_schedules
+WHERE code = $1 LIMIT 1
+`
+
+func (q *Queries) GetFeeSchedule(ctx context.Context, code string) (FeeSchedule, error) {
+	row := q.db.QueryRow(ctx, getFeeSchedule, code)
+	var i FeeSchedule
+	err := row.Scan(&i.Code, &i.Name, &i.CreatedAt)
+	return i, err
+}
+
+const getTransferFee = `-- name: GetTransferFee :one
+SELECT transfer_id, fee_transfer_id, schedule, rule_id, flat, percentage, adjustment, total, created_at FROM transfer_fees
+WHERE transfer_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetTransferFee(ctx context.Context, transferID int64) (TransferFee, error) {
+	row := q.db.QueryRow(ctx, getTransferFee, transferID)
+	var i TransferFee
+	err := row.Scan(
+		&i.TransferID,
+		&i.FeeTransferID,
+		&i.Schedule,
+		&i.RuleID,
+		&i.Flat,
+		&i.Percentage,
+		&i.Adjustment,
+		&i.Total,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listFeeRules = `-- name: ListFeeRules :many
+SELECT id, schedule, currency, min_amount, flat_fee, rate_ppm, min_fee, max_fee, created_at FROM fee_rules
+WHERE schedule = $1 AND currency = $2
+ORDER BY min_amount
+`
+
+type ListFeeRulesParams struct {
+	Schedule string `json:"schedule"`
+	Currency string `json:"currency"`
+}
+
+func (q *Queries) ListFeeRules(ctx context.Context, arg ListFeeRulesParams) ([]FeeRule, error) {
+	rows, err := q.db.Query(ctx, listFeeRules, arg.Schedule, arg.Currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []FeeRule{}
+	for rows.Next() {
+		var i FeeRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.Schedule,
+			&i.Currency,
+			&i.MinAmount,
+			&i.FlatFee,
+			&i.RatePpm,
+			&i.MinFee,
+			&i.MaxFee,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,108 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: gl_account.sql
+
+package db
+
+import (
+	"context"
+)
+
+const getGLAccount = `-- name: GetGLAccount :one
+SELECT code, name, type, created_at FROM gl_accounts
+WHERE code = $1 LIMIT 1
+`
+
+func (q *Queries) GetGLAccount(ctx context.Context, code string) (GlAccount, error) {
+	row := q.db.QueryRow(ctx, getGLAccount, code)
+	var i GlAccount
+	err := row.Scan(
+		&i.Code,
+		&i.Name,
+		&i.Type,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listGLAccounts = `-- name: ListGLAccounts :many
+SELECT code, name, type, created_at FROM gl_accounts
+ORDER BY code
+`
+
+func (q *Queries) ListGLAccounts(ctx context.Context) ([]GlAccount, error) {
+	rows, err := q.db.Query(ctx, listGLAccounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GlAccount{}
+	for rows.Next() {
+		var i GlAccount
+		if err := rows.Scan(
+			&i.Code,
+			&i.Name,
+			&i.Type,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGLBalances = `-- name: ListGLBalances :many
+SELECT
+  gl_accounts.code,
+  gl_accounts.name,
+  gl_accounts.type,
+  accounts.currency,
+  COUNT(accounts.id) AS accounts,
+  SUM(accounts.balance)::bigint AS balance
+FROM gl_accounts
+JOIN accounts ON accounts.gl_code = gl_accounts.code
+GROUP BY gl_accounts.code, accounts.currency
+ORDER BY accounts.currency, gl_accounts.code
+`
+
+type ListGLBalancesRow struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Currency string `json:"currency"`
+	Accounts int64  `json:"accounts"`
+	Balance  int64  `json:"balance"`
+}
+
+// sums the balances of the accounts of each GL account, in each currency
+func (q *Queries) ListGLBalances(ctx context.Context) ([]ListGLBalancesRow, error) {
+	rows, err := q.db.Query(ctx, listGLBalances)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListGLBalancesRow{}
+	for rows.Next() {
+		var i ListGLBalancesRow
+		if err := rows.Scan(
+			&i.Code,
+			&i.Name,
+			&i.Type,
+			&i.Currency,
+			&i.Accounts,
+			&i.Balance,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,250 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: interest.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createInterestAccrual = `-- name: CreateInterestAccrual :one
+INSERT INTO interest_accruals (
+  account_id,
+  accrual_date,
+  balance,
+  annual_rate_ppm,
+  day_count,
+  amount_micros
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (account_id, accrual_date) DO NOTHING
+RETURNING account_id, accrual_date, balance, annual_rate_ppm, day_count, amount_micros, created_at
+`
+
+type CreateInterestAccrualParams struct {
+	AccountID     int64     `json:"account_id"`
+	AccrualDate   time.Time `json:"accrual_date"`
+	Balance       int64     `json:"balance"`
+	AnnualRatePpm int64     `json:"annual_rate_ppm"`
+	DayCount      string    `json:"day_count"`
+	AmountMicros  int64     `json:"amount_micros"`
+}
+
+// returns no row if the account already accrued interest on that day
+func (q *Queries) CreateInterestAccrual(ctx context.Context, arg CreateInterestAccrualParams) (InterestAccrual, error) {
+	row := q.db.QueryRow(ctx, createInterestAccrual,
+		arg.AccountID,
+		arg.AccrualDate,
+		arg.Balance,
+		arg.AnnualRatePpm,
+		arg.DayCount,
+		arg.AmountMicros,
+	)
+	var i InterestAccrual
+	err := row.Scan(
+		&i.AccountID,
+		&i.AccrualDate,
+		&i.Balance,
+		&i.AnnualRatePpm,
+		&i.DayCount,
+		&i.AmountMicros,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createInterestPayout = `-- name: CreateInterestPayout :one
+INSERT INTO interest_payouts (
+  account_id,
+  payout_date,
+  accrued_micros,
+  amount,
+  carry_micros,
+  transfer_id
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING account_id, payout_date, accrued_micros, amount, carry_micros, transfer_id, created_at
+`
+
+type CreateInterestPayoutParams struct {
+	AccountID     int64       `json:"account_id"`
+	PayoutDate    time.Time   `json:"payout_date"`
+	AccruedMicros int64       `json:"accrued_micros"`
+	Amount        int64       `json:"amount"`
+	CarryMicros   int64       `json:"carry_micros"`
+	TransferID    pgtype.Int8 `json:"transfer_id"`
+}
+
+func (q *Queries) CreateInterestPayout(ctx context.Context, arg CreateInterestPayoutParams) (InterestPayout, error) {
+	row := q.db.QueryRow(ctx, createInterestPayout,
+		arg.AccountID,
+		arg.PayoutDate,
+		arg.AccruedMicros,
+		arg.Amount,
+		arg.CarryMicros,
+		arg.TransferID,
+	)
+	var i InterestPayout
+	err := row.Scan(
+		&i.AccountID,
+		&i.PayoutDate,
+		&i.AccruedMicros,
+		&i.Amount,
+		&i.CarryMicros,
+		&i.TransferID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getAccountProduct = `-- name: GetAccountProduct :one
+SELECT code, name, annual_rate_ppm, day_count, compounding, created_at FROM account_products
+WHERE code = $1 LIMIT 1
+`
+
+func (q *Queries) GetAccountProduct(ctx context.Context, code string) (AccountProduct, error) {
+	row := q.db.QueryRow(ctx, getAccountProduct, code)
+	var i AccountProduct
+	err := row.Scan(
+		&i.Code,
+		&i.Name,
+		&i.AnnualRatePpm,
+		&i.DayCount,
+		&i.Compounding,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLastInterestPayout = `-- name: GetLastInterestPayout :one
+SELECT account_id, payout_date, accrued_micros, amount, carry_micros, transfer_id, created_at FROM interest_payouts
+WHERE account_id = $1 AND payout_date < $2
+ORDER BY payout_date DESC
+LIMIT 1
+`
+
+type GetLastInterestPayoutParams struct {
+	AccountID int64     `json:"account_id"`
+	Before    time.Time `json:"before"`
+}
+
+func (q *Queries) GetLastInterestPayout(ctx context.Context, arg GetLastInterestPayoutParams) (InterestPayout, error) {
+	row := q.db.QueryRow(ctx, getLastInterestPayout, arg.AccountID, arg.Before)
+	var i InterestPayout
+	err := row.Scan(
+		&i.AccountID,
+		&i.PayoutDate,
+		&i.AccruedMicros,
+		&i.Amount,
+		&i.CarryMicros,
+		&i.TransferID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAccountProducts = `-- name: ListAccountProducts :many
+SELECT code, name, annual_rate_ppm, day_count, compounding, created_at FROM account_products
+ORDER BY code
+`
+
+func (q *Queries) ListAccountProducts(ctx context.Context) ([]AccountProduct, error) {
+	rows, err := q.db.Query(ctx, listAccountProducts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AccountProduct{}
+	for rows.Next() {
+		var i AccountProduct
+		if err := rows.Scan(
+			&i.Code,
+			&i.Name,
+			&i.AnnualRatePpm,
+			&i.DayCount,
+			&i.Compounding,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listInterestBearingAccounts = `-- name: ListInterestBearingAccounts :many
+SELECT id, owner, balance, currency, created_at, product, gl_code FROM accounts
+WHERE
+  accounts.id > $2
+  AND accounts.created_at < $3
+  AND accounts.product IN (SELECT code FROM account_products WHERE annual_rate_ppm > 0)
+ORDER BY accounts.id
+LIMIT $1
+`
+
+type ListInterestBearingAccountsParams struct {
+	Limit   int64     `json:"limit"`
+	AfterID int64     `json:"after_id"`
+	DayEnd  time.Time `json:"day_end"`
+}
+
+// lists the accounts created before day_end whose product earns interest, after after_id
+func (q *Queries) ListInterestBearingAccounts(ctx context.Context, arg ListInterestBearingAccountsParams) ([]Account, error) {
+	rows, err := q.db.Query(ctx, listInterestBearingAccounts, arg.Limit, arg.AfterID, arg.DayEnd)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.Product,
+			&i.GlCode,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const sumInterestAccruals = `-- name: SumInterestAccruals :one
+SELECT COALESCE(SUM(amount_micros), 0)::bigint AS total FROM interest_accruals
+WHERE
+  account_id = $1
+  AND accrual_date > $2
+  AND accrual_date <= $3
+`
+
+type SumInterestAccrualsParams struct {
+	AccountID int64     `json:"account_id"`
+	After     time.Time `json:"after"`
+	Until     time.Time `json:"until"`
+}
+
+// sums the interest the account accrued in (after, until]
+func (q *Queries) SumInterestAccruals(ctx context.Context, arg SumInterestAccrualsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, sumInterestAccruals, arg.AccountID, arg.After, arg.Until)
+	var total int64
+	err := row.Scan(&total)
+	return total, err
+}
@@ -0,0 +1,18 @@
+package db
+
+// Outcomes recorded in login_events.outcome
+const (
+	LoginOutcomeSuccess  = "success"
+	LoginOutcomeFailure  = "failure"
+	LoginOutcomeLocked   = "locked"
+	LoginOutcomeUnlocked = "unlocked"
+	// LoginOutcomeChallenged is a correct password, which leaves the attempt to the second factor
+	LoginOutcomeChallenged = "challenged"
+)
+
+// Kinds of attempts recorded in login_events.kind. An unlock clears the failures of both.
+const (
+	LoginKindLogin = "login"
+	// LoginKindStepUp is a two-factor code sent with a high-value operation
+	LoginKindStepUp = "step_up"
+)
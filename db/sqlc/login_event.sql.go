@@ -0,0 +1,158 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: login_event.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const createLoginEvent = `-- name: CreateLoginEvent :one
+INSERT INTO login_events (
+  username,
+  client_ip,
+  user_agent,
+  outcome,
+  kind
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING id, username, client_ip, user_agent, outcome, created_at, kind
+`
+
+type CreateLoginEventParams struct {
+	Username  string `json:"username"`
+	ClientIp  string `json:"client_ip"`
+	UserAgent string `json:"user_agent"`
+	Outcome   string `json:"outcome"`
+	Kind      string `json:"kind"`
+}
+
+func (q *Queries) CreateLoginEvent(ctx context.Context, arg CreateLoginEventParams) (LoginEvent, error) {
+	row := q.db.QueryRow(ctx, createLoginEvent,
+		arg.Username,
+		arg.ClientIp,
+		arg.UserAgent,
+		arg.Outcome,
+		arg.Kind,
+	)
+	var i LoginEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.ClientIp,
+		&i.UserAgent,
+		&i.Outcome,
+		&i.CreatedAt,
+		&i.Kind,
+	)
+	return i, err
+}
+
+const finishLoginEvent = `-- name: FinishLoginEvent :one
+UPDATE login_events
+SET
+  username = $1,
+  outcome = $2
+WHERE id = $3
+RETURNING id, username, client_ip, user_agent, outcome, created_at, kind
+`
+
+type FinishLoginEventParams struct {
+	Username string `json:"username"`
+	Outcome  string `json:"outcome"`
+	ID       int64  `json:"id"`
+}
+
+func (q *Queries) FinishLoginEvent(ctx context.Context, arg FinishLoginEventParams) (LoginEvent, error) {
+	row := q.db.QueryRow(ctx, finishLoginEvent, arg.Username, arg.Outcome, arg.ID)
+	var i LoginEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.ClientIp,
+		&i.UserAgent,
+		&i.Outcome,
+		&i.CreatedAt,
+		&i.Kind,
+	)
+	return i, err
+}
+
+const getClientIPLoginFailures = `-- name: GetClientIPLoginFailures :one
+SELECT
+  COUNT(*) AS failures,
+  COALESCE(MAX(created_at), '0001-01-01 00:00:00Z')::timestamptz AS last_failure_at
+FROM login_events
+WHERE
+  client_ip = $1
+  AND kind = $2
+  AND outcome = 'failure'
+  AND created_at > $3
+`
+
+type GetClientIPLoginFailuresParams struct {
+	ClientIp string    `json:"client_ip"`
+	Kind     string    `json:"kind"`
+	Since    time.Time `json:"since"`
+}
+
+type GetClientIPLoginFailuresRow struct {
+	Failures      int64     `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+}
+
+func (q *Queries) GetClientIPLoginFailures(ctx context.Context, arg GetClientIPLoginFailuresParams) (GetClientIPLoginFailuresRow, error) {
+	row := q.db.QueryRow(ctx, getClientIPLoginFailures, arg.ClientIp, arg.Kind, arg.Since)
+	var i GetClientIPLoginFailuresRow
+	err := row.Scan(&i.Failures, &i.LastFailureAt)
+	return i, err
+}
+
+const getUserLoginFailures = `-- name: GetUserLoginFailures :one
+SELECT
+  COUNT(*) AS failures,
+  COALESCE(MAX(e.created_at), '0001-01-01 00:00:00Z')::timestamptz AS last_failure_at
+FROM login_events e
+WHERE
+  e.username = $1
+  AND e.kind = $2
+  AND e.outcome = 'failure'
+  AND e.created_at > $3
+  AND e.created_at > COALESCE((
+    SELECT MAX(r.created_at) FROM login_events r
+    WHERE r.username = $1
+      AND (r.outcome = 'unlocked' OR (r.outcome = 'success' AND r.kind = $2))
+  ), '-infinity'::timestamptz)
+`
+
+type GetUserLoginFailuresParams struct {
+	Username string    `json:"username"`
+	Kind     string    `json:"kind"`
+	Since    time.Time `json:"since"`
+}
+
+type GetUserLoginFailuresRow struct {
+	Failures      int64     `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+}
+
+// counts failures of @kind since @since, forgetting those before the last success of the same kind or unlock
+func (q *Queries) GetUserLoginFailures(ctx context.Context, arg GetUserLoginFailuresParams) (GetUserLoginFailuresRow, error) {
+	row := q.db.QueryRow(ctx, getUserLoginFailures, arg.Username, arg.Kind, arg.Since)
+	var i GetUserLoginFailuresRow
+	err := row.Scan(&i.Failures, &i.LastFailureAt)
+	return i, err
+}
+
+const lockLoginAttempts = `-- name: LockLoginAttempts :exec
+SELECT pg_advisory_xact_lock(hashtext($1::text))
+`
+
+// serializes the attempts on @key, a username or client IP, until the end of the transaction
+func (q *Queries) LockLoginAttempts(ctx context.Context, key string) error {
+	_, err := q.db.Exec(ctx, lockLoginAttempts, key)
+	return err
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestLoginEvent(t *testing.T, username string, clientIP string, outcome string) LoginEvent {
+	arg := CreateLoginEventParams{
+		Username:  username,
+		ClientIp:  clientIP,
+		UserAgent: "test",
+		Outcome:   outcome,
+		Kind:      LoginKindLogin,
+	}
+
+	event, err := testQueries.CreateLoginEvent(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, event.ID)
+	require.Equal(t, arg.Username, event.Username)
+	require.Equal(t, arg.ClientIp, event.ClientIp)
+	require.Equal(t, arg.Outcome, event.Outcome)
+	require.NotZero(t, event.CreatedAt)
+
+	return event
+}
+
+func TestGetUserLoginFailures(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	username := util.RandomOwner()
+	since := time.Now().Add(-time.Hour)
+
+	rsp, err := testQueries.GetUserLoginFailures(context.Background(), GetUserLoginFailuresParams{Username: username, Kind: LoginKindLogin, Since: since})
+	require.NoError(t, err)
+	require.Zero(t, rsp.Failures)
+
+	createTestLoginEvent(t, username, "10.0.0.1", LoginOutcomeFailure)
+	last := createTestLoginEvent(t, username, "10.0.0.2", LoginOutcomeFailure)
+	createTestLoginEvent(t, username, "10.0.0.2", LoginOutcomeLocked)
+
+	rsp, err = testQueries.GetUserLoginFailures(context.Background(), GetUserLoginFailuresParams{Username: username, Kind: LoginKindLogin, Since: since})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rsp.Failures)
+	require.WithinDuration(t, last.CreatedAt, rsp.LastFailureAt, time.Microsecond)
+
+	// an unlock forgets earlier failures
+	createTestLoginEvent(t, username, "10.0.0.3", LoginOutcomeUnlocked)
+	createTestLoginEvent(t, username, "10.0.0.1", LoginOutcomeFailure)
+
+	rsp, err = testQueries.GetUserLoginFailures(context.Background(), GetUserLoginFailuresParams{Username: username, Kind: LoginKindLogin, Since: since})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), rsp.Failures)
+
+	// and so does a successful login
+	createTestLoginEvent(t, username, "10.0.0.1", LoginOutcomeSuccess)
+
+	rsp, err = testQueries.GetUserLoginFailures(context.Background(), GetUserLoginFailuresParams{Username: username, Kind: LoginKindLogin, Since: since})
+	require.NoError(t, err)
+	require.Zero(t, rsp.Failures)
+}
+
+func TestGetClientIPLoginFailures(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	clientIP := "10.1." + util.RandomString(3)
+	createTestLoginEvent(t, util.RandomOwner(), clientIP, LoginOutcomeFailure)
+	createTestLoginEvent(t, util.RandomOwner(), clientIP, LoginOutcomeFailure)
+	createTestLoginEvent(t, util.RandomOwner(), clientIP, LoginOutcomeSuccess)
+
+	rsp, err := testQueries.GetClientIPLoginFailures(context.Background(), GetClientIPLoginFailuresParams{
+		ClientIp: clientIP,
+		Kind:     LoginKindLogin,
+		Since:    time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rsp.Failures)
+
+	rsp, err = testQueries.GetClientIPLoginFailures(context.Background(), GetClientIPLoginFailuresParams{
+		ClientIp: clientIP,
+		Kind:     LoginKindLogin,
+		Since:    time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Zero(t, rsp.Failures)
+}
@@ -2,42 +2,85 @@ package db
 
 import (
 	"context"
-	"database/sql"
 	"log"
 	"os"
 	"testing"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pakojabi/simplebank/util"
 )
 
 var testQueries *Queries
-var testDB *sql.DB
+var testDB *pgxpool.Pool
 
 var cleanup func()
 
+// dbErr is why Postgres cannot be reached, if it cannot. The tests that need it are skipped then, and the
+// ones that run on the MemStore still run.
+var dbErr error
+
+// requireDB skips a test that needs Postgres when it cannot be reached, unless REQUIRE_DB is set, as it is in CI:
+// the test fails then, rather than the suite passing without having run
+func requireDB(t *testing.T) {
+	t.Helper()
+	if dbErr == nil {
+		return
+	}
+	if os.Getenv("REQUIRE_DB") != "" {
+		t.Fatal("postgres is unreachable:", dbErr)
+	}
+	t.Skip("postgres is unreachable:", dbErr)
+}
+
+// seedSystemAccounts creates the system users and accounts again, as the migrations do, once cleanup has
+// truncated them along with the others
+const seedSystemAccounts = `
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email", "role")
+SELECT 'system:' || "code", '', 'Simple Bank ' || "name", 'system:' || "code", 'system'
+FROM "gl_accounts"
+WHERE "code" <> 'customer_deposits'
+ON CONFLICT ("username") DO NOTHING;
+
+INSERT INTO "accounts" ("owner", "balance", "currency", "gl_code")
+SELECT 'system:' || "code", 0, "currency", "code"
+FROM "gl_accounts"
+CROSS JOIN (VALUES ('USD'), ('EUR'), ('CAD')) AS "currencies" ("currency")
+WHERE "code" <> 'customer_deposits'
+ON CONFLICT ("owner", "currency") DO NOTHING;
+`
+
 func TestMain(m *testing.M){
 	config, err := util.LoadConfig("../../")
 	if err != nil {
 		log.Fatal("cannot load configuration:", err)
 	}
-	testDB, err = sql.Open(config.DBDriver, config.TestDBSource)
+	testDB, err = pgxpool.New(context.Background(), config.TestDBSource)
 	if err != nil {
 		log.Fatal("cannot connect to db:", err)
 	}
 	testQueries = New(testDB)
+	if dbErr = testDB.Ping(context.Background()); dbErr != nil {
+		log.Println("skipping the tests that need postgres:", dbErr)
+	}
 	
 	cleanup = func() {
-		testQueries.db.ExecContext(context.Background(), "TRUNCATE TABLE transfers")
-		testQueries.db.ExecContext(context.Background(), "TRUNCATE TABLE entries")
-		_, err2 := testQueries.db.ExecContext(context.Background(), "TRUNCATE TABLE accounts CASCADE")
+		testQueries.db.Exec(context.Background(), "TRUNCATE TABLE transfers")
+		testQueries.db.Exec(context.Background(), "TRUNCATE TABLE entries")
+		testQueries.db.Exec(context.Background(), "TRUNCATE TABLE tasks")
+		testQueries.db.Exec(context.Background(), "TRUNCATE TABLE login_events")
+		testQueries.db.Exec(context.Background(), "TRUNCATE TABLE audit_events")
+		_, err2 := testQueries.db.Exec(context.Background(), "TRUNCATE TABLE accounts CASCADE")
 		if err2 != nil {
 			log.Fatal("cannot truncate accounts: ", err2)
 		}
-		_, err3 := testQueries.db.ExecContext(context.Background(), "TRUNCATE TABLE users CASCADE")
+		_, err3 := testQueries.db.Exec(context.Background(), "TRUNCATE TABLE users CASCADE")
 		if err3 != nil {
 			log.Fatal("cannot truncate users: ", err3)
 		}
+		_, err4 := testQueries.db.Exec(context.Background(), seedSystemAccounts)
+		if err4 != nil {
+			log.Fatal("cannot seed system accounts: ", err4)
+		}
 	}
 	os.Exit(m.Run())
 }
@@ -0,0 +1,341 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MemStore is a Store that keeps its data in memory, for fast tests and local demos.
+// It follows the schema of SQLStore: the same defaults, unique constraints and foreign keys,
+// with the same errors, and the queries that get a single row return ErrRecordNotFound.
+// Transactions run one at a time and roll back as a whole, so they are SERIALIZABLE whatever their options.
+type MemStore struct {
+	txStore
+	tables *memTables
+	mu     sync.Mutex
+}
+
+// NewMemStore creates an empty in-memory store
+func NewMemStore() Store {
+	store := &MemStore{tables: newMemTables()}
+	store.txStore = txStore{
+		Querier: &memQueries{tables: store.tables, mu: &store.mu},
+		execTx:  store.execTx,
+		broker:  newAccountBroker(),
+	}
+	return store
+}
+
+// execTx runs fn with the lock of the store held, and restores the tables it had on entry if fn fails
+func (store *MemStore) execTx(ctx context.Context, fn func(Querier) error, opts ...TxOption) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	snapshot := store.tables.clone()
+	err := fn(&memQueries{tables: store.tables})
+	if err != nil {
+		*store.tables = *snapshot
+	}
+	return err
+}
+
+// memQueries implements Querier on top of memTables
+type memQueries struct {
+	tables *memTables
+	// mu guards tables outside of transactions. It is nil in a transaction, which holds it already.
+	mu *sync.Mutex
+}
+
+func (q *memQueries) lock() (unlock func()) {
+	if q.mu == nil {
+		return func() {}
+	}
+	q.mu.Lock()
+	return q.mu.Unlock
+}
+
+type balanceSnapshotKey struct {
+	accountID    int64
+	snapshotDate time.Time
+}
+
+type interestKey struct {
+	accountID int64
+	date      time.Time
+}
+
+type oauthConsentKey struct {
+	username string
+	clientID uuid.UUID
+}
+
+// memTables holds the rows of every table, by primary key
+type memTables struct {
+	users           map[string]User
+	accounts        map[int64]Account
+	products        map[string]AccountProduct
+	glAccounts      map[string]GlAccount
+	entries         map[int64]Entry
+	snapshots       map[balanceSnapshotKey]BalanceSnapshot
+	accruals        map[interestKey]InterestAccrual
+	payouts         map[interestKey]InterestPayout
+	transfers       map[int64]Transfer
+	feeSchedules    map[string]FeeSchedule
+	feeRules        map[int64]FeeRule
+	transferFees    map[int64]TransferFee
+	payments        map[int64]Payment
+	sessions        map[uuid.UUID]Session
+	verifyEmails    map[int64]VerifyEmail
+	resetPasswords  map[int64]ResetPassword
+	tasks           map[int64]Task
+	loginEvents     map[int64]LoginEvent
+	totpSecrets     map[string]TotpSecret
+	recoveryCodes   map[int64]RecoveryCode
+	loginChallenges map[uuid.UUID]LoginChallenge
+	apiKeys         map[uuid.UUID]ApiKey
+	oauthClients    map[uuid.UUID]OauthClient
+	oauthCodes      map[string]OauthAuthorizationCode
+	oauthConsents   map[oauthConsentKey]OauthConsent
+	auditEvents     map[int64]AuditEvent
+	// sequences hold the last id given out for each bigserial column. Like Postgres sequences,
+	// they are shared by the snapshots of a transaction, so a rollback does not give ids out again.
+	sequences map[string]int64
+}
+
+func newMemTables() *memTables {
+	tables := &memTables{
+		users:           make(map[string]User),
+		accounts:        make(map[int64]Account),
+		products:        seedAccountProducts(),
+		glAccounts:      seedGLAccounts(),
+		entries:         make(map[int64]Entry),
+		snapshots:       make(map[balanceSnapshotKey]BalanceSnapshot),
+		accruals:        make(map[interestKey]InterestAccrual),
+		payouts:         make(map[interestKey]InterestPayout),
+		transfers:       make(map[int64]Transfer),
+		feeSchedules:    make(map[string]FeeSchedule),
+		feeRules:        make(map[int64]FeeRule),
+		transferFees:    make(map[int64]TransferFee),
+		payments:        make(map[int64]Payment),
+		sessions:        make(map[uuid.UUID]Session),
+		verifyEmails:    make(map[int64]VerifyEmail),
+		resetPasswords:  make(map[int64]ResetPassword),
+		tasks:           make(map[int64]Task),
+		loginEvents:     make(map[int64]LoginEvent),
+		totpSecrets:     make(map[string]TotpSecret),
+		recoveryCodes:   make(map[int64]RecoveryCode),
+		loginChallenges: make(map[uuid.UUID]LoginChallenge),
+		apiKeys:         make(map[uuid.UUID]ApiKey),
+		oauthClients:    make(map[uuid.UUID]OauthClient),
+		oauthCodes:      make(map[string]OauthAuthorizationCode),
+		oauthConsents:   make(map[oauthConsentKey]OauthConsent),
+		auditEvents:     make(map[int64]AuditEvent),
+		sequences:       make(map[string]int64),
+	}
+	tables.seedFees()
+	tables.seedSystemAccounts()
+	return tables
+}
+
+// clone copies the tables a transaction may change. Rows are values, and their slices are never
+// changed in place, so copying the maps is enough.
+func (tables *memTables) clone() *memTables {
+	return &memTables{
+		users:           maps.Clone(tables.users),
+		accounts:        maps.Clone(tables.accounts),
+		products:        maps.Clone(tables.products),
+		glAccounts:      maps.Clone(tables.glAccounts),
+		entries:         maps.Clone(tables.entries),
+		snapshots:       maps.Clone(tables.snapshots),
+		accruals:        maps.Clone(tables.accruals),
+		payouts:         maps.Clone(tables.payouts),
+		transfers:       maps.Clone(tables.transfers),
+		feeSchedules:    maps.Clone(tables.feeSchedules),
+		feeRules:        maps.Clone(tables.feeRules),
+		transferFees:    maps.Clone(tables.transferFees),
+		payments:        maps.Clone(tables.payments),
+		sessions:        maps.Clone(tables.sessions),
+		verifyEmails:    maps.Clone(tables.verifyEmails),
+		resetPasswords:  maps.Clone(tables.resetPasswords),
+		tasks:           maps.Clone(tables.tasks),
+		loginEvents:     maps.Clone(tables.loginEvents),
+		totpSecrets:     maps.Clone(tables.totpSecrets),
+		recoveryCodes:   maps.Clone(tables.recoveryCodes),
+		loginChallenges: maps.Clone(tables.loginChallenges),
+		apiKeys:         maps.Clone(tables.apiKeys),
+		oauthClients:    maps.Clone(tables.oauthClients),
+		oauthCodes:      maps.Clone(tables.oauthCodes),
+		oauthConsents:   maps.Clone(tables.oauthConsents),
+		auditEvents:     maps.Clone(tables.auditEvents),
+		sequences:       tables.sequences,
+	}
+}
+
+// seedAccountProducts returns the products the migrations insert
+func seedAccountProducts() map[string]AccountProduct {
+	createdAt := now()
+	return map[string]AccountProduct{
+		"checking": {Code: "checking", Name: "Checking", DayCount: "ACT/365", Compounding: "monthly", CreatedAt: createdAt},
+		"savings":  {Code: "savings", Name: "Savings", AnnualRatePpm: 20000, DayCount: "ACT/365", Compounding: "monthly", CreatedAt: createdAt},
+	}
+}
+
+// seedGLAccounts returns the chart of accounts the migrations insert
+func seedGLAccounts() map[string]GlAccount {
+	createdAt := now()
+	return map[string]GlAccount{
+		GLCustomerDeposits:    {Code: GLCustomerDeposits, Name: "Customer deposits", Type: GLLiability, CreatedAt: createdAt},
+		SystemFeeRevenue:      {Code: SystemFeeRevenue, Name: "Fee revenue", Type: GLRevenue, CreatedAt: createdAt},
+		SystemInterestExpense: {Code: SystemInterestExpense, Name: "Interest expense", Type: GLExpense, CreatedAt: createdAt},
+		SystemClearing:        {Code: SystemClearing, Name: "Payment rail clearing", Type: GLAsset, CreatedAt: createdAt},
+		SystemSuspense:        {Code: SystemSuspense, Name: "Payments in suspense", Type: GLLiability, CreatedAt: createdAt},
+	}
+}
+
+// seedSystemAccounts inserts the system users and accounts of the GL accounts the migrations insert
+func (tables *memTables) seedSystemAccounts() {
+	createdAt := now()
+	codes := make([]string, 0, len(tables.glAccounts))
+	for code := range tables.glAccounts {
+		codes = append(codes, code)
+	}
+	// in order, so the accounts get the same ids every time
+	slices.Sort(codes)
+
+	for _, code := range codes {
+		if code == GLCustomerDeposits {
+			continue
+		}
+		username := SystemUsername(code)
+		tables.users[username] = User{
+			Username:  username,
+			FullName:  "Simple Bank " + tables.glAccounts[code].Name,
+			Email:     username,
+			CreatedAt: createdAt,
+			Role:      "system",
+		}
+		for _, currency := range []string{"USD", "EUR", "CAD"} {
+			account := Account{
+				ID:        tables.nextID("accounts"),
+				Owner:     username,
+				Currency:  currency,
+				CreatedAt: createdAt,
+				Product:   "checking",
+				GlCode:    code,
+			}
+			tables.accounts[account.ID] = account
+		}
+	}
+}
+
+// seedFees inserts the fee schedule and rules the migrations insert
+func (tables *memTables) seedFees() {
+	createdAt := now()
+	tables.feeSchedules["standard"] = FeeSchedule{Code: "standard", Name: "Standard", CreatedAt: createdAt}
+
+	for _, rule := range []FeeRule{
+		{Currency: "USD", FlatFee: 25},
+		{Currency: "USD", MinAmount: 100000, RatePpm: 1000, MinFee: 100, MaxFee: 1000},
+		{Currency: "EUR", RatePpm: 2000, MinFee: 20, MaxFee: 500},
+		{Currency: "CAD", FlatFee: 30},
+	} {
+		rule.ID = tables.nextID("fee_rules")
+		rule.Schedule = "standard"
+		rule.CreatedAt = createdAt
+		tables.feeRules[rule.ID] = rule
+	}
+}
+
+// nextID returns the next value of the bigserial id of table
+func (tables *memTables) nextID(table string) int64 {
+	tables.sequences[table]++
+	return tables.sequences[table]
+}
+
+// now returns the current time with the precision of a timestamptz column
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// sortedRows returns the rows of table that match, ordered by compare
+func sortedRows[K comparable, T any](table map[K]T, match func(T) bool, compare func(a, b T) int) []T {
+	var rows []T
+	for _, row := range table {
+		if match(row) {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, compare)
+	return rows
+}
+
+// page applies LIMIT and OFFSET to rows. Like sqlc, it returns nil rather than an empty slice.
+func page[T any](rows []T, limit, offset int64) []T {
+	if offset >= int64(len(rows)) || limit <= 0 {
+		return nil
+	}
+	return rows[offset:min(offset+limit, int64(len(rows)))]
+}
+
+func byID[T any](id func(T) int64) func(a, b T) int {
+	return func(a, b T) int {
+		return cmp.Compare(id(a), id(b))
+	}
+}
+
+// uniqueViolation is the error Postgres returns when a row would break the unique constraint
+func uniqueViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           UniqueViolation,
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
+
+// foreignKeyViolation is the error Postgres returns when a row would break the foreign key constraint
+func foreignKeyViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           ForeignKeyViolation,
+		Message:        fmt.Sprintf("violates foreign key constraint %q", constraint),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
+
+// userExists checks the foreign key column of table that references users
+func (tables *memTables) userExists(table, column, username string) error {
+	if _, ok := tables.users[username]; !ok {
+		return foreignKeyViolation(table, table+"_"+column+"_fkey")
+	}
+	return nil
+}
+
+// accountExists checks the foreign key column of table that references accounts
+func (tables *memTables) accountExists(table, column string, id int64) error {
+	if _, ok := tables.accounts[id]; !ok {
+		return foreignKeyViolation(table, table+"_"+column+"_fkey")
+	}
+	return nil
+}
+
+// oauthClientExists checks the foreign key column of table that references oauth_clients
+func (tables *memTables) oauthClientExists(table string, id uuid.UUID) error {
+	if _, ok := tables.oauthClients[id]; !ok {
+		return foreignKeyViolation(table, table+"_client_id_fkey")
+	}
+	return nil
+}
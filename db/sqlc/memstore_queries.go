@@ -0,0 +1,1531 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// The methods below mirror the statements in db/query, one for one.
+
+func (q *memQueries) CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error) {
+	defer q.lock()()
+
+	return q.tables.createAccount(arg, GLCustomerDeposits)
+}
+
+func (tables *memTables) createAccount(arg CreateAccountParams, glCode string) (Account, error) {
+	product := "checking"
+	if arg.Product.Valid {
+		product = arg.Product.String
+	}
+
+	if err := tables.userExists("accounts", "owner", arg.Owner); err != nil {
+		return Account{}, err
+	}
+	if _, ok := tables.products[product]; !ok {
+		return Account{}, foreignKeyViolation("accounts", "accounts_product_fkey")
+	}
+	if _, ok := tables.glAccounts[glCode]; !ok {
+		return Account{}, foreignKeyViolation("accounts", "accounts_gl_code_fkey")
+	}
+	if _, err := tables.accountByOwner(arg.Owner, arg.Currency); err == nil {
+		return Account{}, uniqueViolation("owner_currency_key")
+	}
+
+	account := Account{
+		ID:        tables.nextID("accounts"),
+		Owner:     arg.Owner,
+		Balance:   arg.Balance,
+		Currency:  arg.Currency,
+		CreatedAt: now(),
+		Product:   product,
+		GlCode:    glCode,
+	}
+	tables.accounts[account.ID] = account
+	return account, nil
+}
+
+func (q *memQueries) GetAccountByOwner(ctx context.Context, arg GetAccountByOwnerParams) (Account, error) {
+	defer q.lock()()
+
+	return q.tables.accountByOwner(arg.Owner, arg.Currency)
+}
+
+func (tables *memTables) accountByOwner(owner, currency string) (Account, error) {
+	for _, account := range tables.accounts {
+		if account.Owner == owner && account.Currency == currency {
+			return account, nil
+		}
+	}
+	return Account{}, ErrRecordNotFound
+}
+
+func (q *memQueries) GetAccount(ctx context.Context, id int64) (Account, error) {
+	defer q.lock()()
+
+	account, ok := q.tables.accounts[id]
+	if !ok {
+		return Account{}, ErrRecordNotFound
+	}
+	return account, nil
+}
+
+// GetAccountForUpdate needs no row lock: transactions do not overlap
+func (q *memQueries) GetAccountForUpdate(ctx context.Context, id int64) (Account, error) {
+	return q.GetAccount(ctx, id)
+}
+
+func (q *memQueries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error) {
+	defer q.lock()()
+
+	accounts := sortedRows(q.tables.accounts,
+		func(account Account) bool { return account.Owner == arg.Owner },
+		byID(func(account Account) int64 { return account.ID }),
+	)
+	return page(accounts, arg.Limit, arg.Offset), nil
+}
+
+func (q *memQueries) ListAllAccounts(ctx context.Context, arg ListAllAccountsParams) ([]Account, error) {
+	defer q.lock()()
+
+	accounts := sortedRows(q.tables.accounts,
+		func(account Account) bool { return account.ID > arg.AfterID },
+		byID(func(account Account) int64 { return account.ID }),
+	)
+	return page(accounts, arg.Limit, 0), nil
+}
+
+func (q *memQueries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error) {
+	defer q.lock()()
+
+	account, ok := q.tables.accounts[arg.ID]
+	if !ok {
+		return Account{}, ErrRecordNotFound
+	}
+	account.Balance = arg.Balance
+	q.tables.accounts[account.ID] = account
+	return account, nil
+}
+
+func (q *memQueries) AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error) {
+	defer q.lock()()
+
+	account, ok := q.tables.accounts[arg.ID]
+	if !ok {
+		return Account{}, ErrRecordNotFound
+	}
+	account.Balance += arg.Amount
+	q.tables.accounts[account.ID] = account
+	return account, nil
+}
+
+func (q *memQueries) DeleteAccount(ctx context.Context, id int64) error {
+	defer q.lock()()
+
+	for _, entry := range q.tables.entries {
+		if entry.AccountID == id {
+			return foreignKeyViolation("entries", "entries_account_id_fkey")
+		}
+	}
+	for _, transfer := range q.tables.transfers {
+		if transfer.FromAccountID == id {
+			return foreignKeyViolation("transfers", "transfers_from_account_id_fkey")
+		}
+		if transfer.ToAccountID == id {
+			return foreignKeyViolation("transfers", "transfers_to_account_id_fkey")
+		}
+	}
+	for _, payment := range q.tables.payments {
+		if payment.AccountID == id {
+			return foreignKeyViolation("payments", "payments_account_id_fkey")
+		}
+	}
+	delete(q.tables.accounts, id)
+
+	// ON DELETE CASCADE
+	for key := range q.tables.snapshots {
+		if key.accountID == id {
+			delete(q.tables.snapshots, key)
+		}
+	}
+	for key := range q.tables.accruals {
+		if key.accountID == id {
+			delete(q.tables.accruals, key)
+		}
+	}
+	for key := range q.tables.payouts {
+		if key.accountID == id {
+			delete(q.tables.payouts, key)
+		}
+	}
+	return nil
+}
+
+func (q *memQueries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
+	defer q.lock()()
+
+	if err := q.tables.userExists("api_keys", "username", arg.Username); err != nil {
+		return ApiKey{}, err
+	}
+	if _, ok := q.tables.apiKeys[arg.ID]; ok {
+		return ApiKey{}, uniqueViolation("api_keys_pkey")
+	}
+	for _, key := range q.tables.apiKeys {
+		if key.Prefix == arg.Prefix {
+			return ApiKey{}, uniqueViolation("api_keys_prefix_key")
+		}
+	}
+
+	key := ApiKey{
+		ID:        arg.ID,
+		Username:  arg.Username,
+		Name:      arg.Name,
+		Prefix:    arg.Prefix,
+		HashedKey: arg.HashedKey,
+		Scopes:    slices.Clone(arg.Scopes),
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: now(),
+	}
+	q.tables.apiKeys[key.ID] = key
+	return key, nil
+}
+
+func (q *memQueries) GetAPIKeyByPrefix(ctx context.Context, prefix string) (ApiKey, error) {
+	defer q.lock()()
+
+	for _, key := range q.tables.apiKeys {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+	return ApiKey{}, ErrRecordNotFound
+}
+
+func (q *memQueries) ListAPIKeys(ctx context.Context, arg ListAPIKeysParams) ([]ApiKey, error) {
+	defer q.lock()()
+
+	keys := sortedRows(q.tables.apiKeys,
+		func(key ApiKey) bool { return key.Username == arg.Username },
+		func(a, b ApiKey) int { return b.CreatedAt.Compare(a.CreatedAt) },
+	)
+	return page(keys, arg.Limit, arg.Offset), nil
+}
+
+func (q *memQueries) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (ApiKey, error) {
+	defer q.lock()()
+
+	key, ok := q.tables.apiKeys[arg.ID]
+	if !ok || key.Username != arg.Username {
+		return ApiKey{}, ErrRecordNotFound
+	}
+	key.IsRevoked = true
+	q.tables.apiKeys[key.ID] = key
+	return key, nil
+}
+
+func (q *memQueries) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	defer q.lock()()
+
+	key, ok := q.tables.apiKeys[id]
+	current := now()
+	if ok && (!key.LastUsedAt.Valid || key.LastUsedAt.Time.Before(current.Add(-time.Minute))) {
+		key.LastUsedAt = pgtype.Timestamptz{Time: current, Valid: true}
+		q.tables.apiKeys[id] = key
+	}
+	return nil
+}
+
+func (q *memQueries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error) {
+	defer q.lock()()
+
+	event := AuditEvent{
+		ID:           q.tables.nextID("audit_events"),
+		Actor:        arg.Actor,
+		ActorRole:    arg.ActorRole,
+		ClientIp:     arg.ClientIp,
+		UserAgent:    arg.UserAgent,
+		Action:       arg.Action,
+		ResourceType: arg.ResourceType,
+		ResourceID:   arg.ResourceID,
+		Before:       slices.Clone(arg.Before),
+		After:        slices.Clone(arg.After),
+		CreatedAt:    now(),
+	}
+	q.tables.auditEvents[event.ID] = event
+	return event, nil
+}
+
+func (q *memQueries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	defer q.lock()()
+
+	matchText := func(filter pgtype.Text, value string) bool {
+		return !filter.Valid || filter.String == value
+	}
+	events := sortedRows(q.tables.auditEvents,
+		func(event AuditEvent) bool {
+			return matchText(arg.Actor, event.Actor) &&
+				matchText(arg.Action, event.Action) &&
+				matchText(arg.ResourceType, event.ResourceType) &&
+				matchText(arg.ResourceID, event.ResourceID) &&
+				(!arg.Since.Valid || !event.CreatedAt.Before(arg.Since.Time)) &&
+				(!arg.Until.Valid || event.CreatedAt.Before(arg.Until.Time))
+		},
+		func(a, b AuditEvent) int { return cmp.Compare(b.ID, a.ID) },
+	)
+	return page(events, arg.Limit, arg.Offset), nil
+}
+
+func (q *memQueries) TakeBalanceSnapshots(ctx context.Context, arg TakeBalanceSnapshotsParams) (int64, error) {
+	defer q.lock()()
+
+	var taken int64
+	for _, account := range q.tables.accounts {
+		if !account.CreatedAt.Before(arg.DayEnd) {
+			continue
+		}
+
+		key := balanceSnapshotKey{accountID: account.ID, snapshotDate: SnapshotDate(arg.SnapshotDate)}
+		snapshot, ok := q.tables.snapshots[key]
+		if ok && snapshot.IsFrozen {
+			continue
+		}
+
+		balance := account.Balance
+		for _, entry := range q.tables.entries {
+			if entry.AccountID == account.ID && !entry.CreatedAt.Before(arg.DayEnd) {
+				balance -= entry.Amount
+			}
+		}
+		q.tables.snapshots[key] = BalanceSnapshot{
+			AccountID:    key.accountID,
+			SnapshotDate: key.snapshotDate,
+			Balance:      balance,
+			CreatedAt:    now(),
+		}
+		taken++
+	}
+	return taken, nil
+}
+
+func (q *memQueries) FreezeBalanceSnapshots(ctx context.Context, snapshotDate time.Time) (int64, error) {
+	defer q.lock()()
+
+	var frozen int64
+	for key, snapshot := range q.tables.snapshots {
+		if key.snapshotDate.Equal(SnapshotDate(snapshotDate)) && !snapshot.IsFrozen {
+			snapshot.IsFrozen = true
+			q.tables.snapshots[key] = snapshot
+			frozen++
+		}
+	}
+	return frozen, nil
+}
+
+func (q *memQueries) GetLastFrozenSnapshotDate(ctx context.Context) (time.Time, error) {
+	defer q.lock()()
+
+	snapshots := sortedRows(q.tables.snapshots,
+		func(snapshot BalanceSnapshot) bool { return snapshot.IsFrozen },
+		bySnapshotDateDesc,
+	)
+	if len(snapshots) == 0 {
+		return time.Time{}, ErrRecordNotFound
+	}
+	return snapshots[0].SnapshotDate, nil
+}
+
+func (q *memQueries) GetBalanceSnapshotBefore(ctx context.Context, arg GetBalanceSnapshotBeforeParams) (BalanceSnapshot, error) {
+	defer q.lock()()
+
+	snapshots := sortedRows(q.tables.snapshots,
+		func(snapshot BalanceSnapshot) bool {
+			return snapshot.AccountID == arg.AccountID && snapshot.SnapshotDate.Before(SnapshotDate(arg.Before))
+		},
+		bySnapshotDateDesc,
+	)
+	if len(snapshots) == 0 {
+		return BalanceSnapshot{}, ErrRecordNotFound
+	}
+	return snapshots[0], nil
+}
+
+func bySnapshotDateDesc(a, b BalanceSnapshot) int {
+	return b.SnapshotDate.Compare(a.SnapshotDate)
+}
+
+func (q *memQueries) SumEntries(ctx context.Context, arg SumEntriesParams) (int64, error) {
+	defer q.lock()()
+
+	var total int64
+	for _, entry := range q.tables.entries {
+		if entry.AccountID == arg.AccountID && !entry.CreatedAt.Before(arg.Since) &&
+			(!arg.Until.Valid || entry.CreatedAt.Before(arg.Until.Time)) {
+			total += entry.Amount
+		}
+	}
+	return total, nil
+}
+
+func (q *memQueries) CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error) {
+	defer q.lock()()
+
+	if err := q.tables.accountExists("entries", "account_id", arg.AccountID); err != nil {
+		return Entry{}, err
+	}
+
+	var prevHash []byte
+	if prev, err := q.lastEntry(func(entry Entry) bool { return entry.AccountID == arg.AccountID }); err == nil {
+		prevHash = prev.Hash
+	}
+
+	if _, ok := q.tables.payments[arg.PaymentID.Int64]; arg.PaymentID.Valid && !ok {
+		return Entry{}, foreignKeyViolation("entries", "entries_payment_id_fkey")
+	}
+
+	entry := Entry{
+		ID:        q.tables.nextID("entries"),
+		AccountID: arg.AccountID,
+		Amount:    arg.Amount,
+		CreatedAt: now(),
+		PaymentID: arg.PaymentID,
+	}
+	entry.Hash = HashEntry(prevHash, entry)
+	q.tables.entries[entry.ID] = entry
+	return entry, nil
+}
+
+func (q *memQueries) GetEntry(ctx context.Context, id int64) (Entry, error) {
+	defer q.lock()()
+
+	entry, ok := q.tables.entries[id]
+	if !ok {
+		return Entry{}, ErrRecordNotFound
+	}
+	return entry, nil
+}
+
+func (q *memQueries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error) {
+	defer q.lock()()
+
+	entries := sortedRows(q.tables.entries,
+		func(entry Entry) bool { return entry.AccountID == arg.AccountID },
+		byID(func(entry Entry) int64 { return entry.ID }),
+	)
+	return page(entries, arg.Limit, arg.Offset), nil
+}
+
+func (q *memQueries) GetLastEntry(ctx context.Context, accountID int64) (Entry, error) {
+	defer q.lock()()
+
+	return q.lastEntry(func(entry Entry) bool { return entry.AccountID == accountID })
+}
+
+func (q *memQueries) GetLastEntryBefore(ctx context.Context, arg GetLastEntryBeforeParams) (Entry, error) {
+	defer q.lock()()
+
+	return q.lastEntry(func(entry Entry) bool {
+		return entry.AccountID == arg.AccountID && entry.CreatedAt.Before(arg.Before)
+	})
+}
+
+// lastEntry returns the entry with the highest id among those that match
+func (q *memQueries) lastEntry(match func(Entry) bool) (Entry, error) {
+	entries := sortedRows(q.tables.entries, match,
+		func(a, b Entry) int { return cmp.Compare(b.ID, a.ID) },
+	)
+	if len(entries) == 0 {
+		return Entry{}, ErrRecordNotFound
+	}
+	return entries[0], nil
+}
+
+func (q *memQueries) ListEntriesAfter(ctx context.Context, arg ListEntriesAfterParams) ([]Entry, error) {
+	defer q.lock()()
+
+	entries := sortedRows(q.tables.entries,
+		func(entry Entry) bool { return entry.AccountID == arg.AccountID && entry.ID > arg.AfterID },
+		byID(func(entry Entry) int64 { return entry.ID }),
+	)
+	return page(entries, arg.Limit, 0), nil
+}
+
+func (q *memQueries) GetFeeSchedule(ctx context.Context, code string) (FeeSchedule, error) {
+	defer q.lock()()
+
+	schedule, ok := q.tables.feeSchedules[code]
+	if !ok {
+		return FeeSchedule{}, ErrRecordNotFound
+	}
+	return schedule, nil
+}
+
+func (q *memQueries) ListFeeRules(ctx context.Context, arg ListFeeRulesParams) ([]FeeRule, error) {
+	defer q.lock()()
+
+	rules := sortedRows(q.tables.feeRules,
+		func(rule FeeRule) bool { return rule.Schedule == arg.Schedule && rule.Currency == arg.Currency },
+		func(a, b FeeRule) int { return cmp.Compare(a.MinAmount, b.MinAmount) },
+	)
+	return rules, nil
+}
+
+func (q *memQueries) CreateTransferFee(ctx context.Context, arg CreateTransferFeeParams) (TransferFee, error) {
+	defer q.lock()()
+
+	if _, ok := q.tables.transfers[arg.TransferID]; !ok {
+		return TransferFee{}, foreignKeyViolation("transfer_fees", "transfer_fees_transfer_id_fkey")
+	}
+	if _, ok := q.tables.transfers[arg.FeeTransferID]; !ok {
+		return TransferFee{}, foreignKeyViolation("transfer_fees", "transfer_fees_fee_transfer_id_fkey")
+	}
+	if _, ok := q.tables.feeSchedules[arg.Schedule]; !ok {
+		return TransferFee{}, foreignKeyViolation("transfer_fees", "transfer_fees_schedule_fkey")
+	}
+	if _, ok := q.tables.feeRules[arg.RuleID]; !ok {
+		return TransferFee{}, foreignKeyViolation("transfer_fees", "transfer_fees_rule_id_fkey")
+	}
+	if _, ok := q.tables.transferFees[arg.TransferID]; ok {
+		return TransferFee{}, uniqueViolation("transfer_fees_pkey")
+	}
+	for _, transferFee := range q.tables.transferFees {
+		if transferFee.FeeTransferID == arg.FeeTransferID {
+			return TransferFee{}, uniqueViolation("transfer_fees_fee_transfer_id_key")
+		}
+	}
+
+	transferFee := TransferFee{
+		TransferID:    arg.TransferID,
+		FeeTransferID: arg.FeeTransferID,
+		Schedule:      arg.Schedule,
+		RuleID:        arg.RuleID,
+		Flat:          arg.Flat,
+		Percentage:    arg.Percentage,
+		Adjustment:    arg.Adjustment,
+		Total:         arg.Total,
+		CreatedAt:     now(),
+	}
+	q.tables.transferFees[transferFee.TransferID] = transferFee
+	return transferFee, nil
+}
+
+func (q *memQueries) GetTransferFee(ctx context.Context, transferID int64) (TransferFee, error) {
+	defer q.lock()()
+
+	transferFee, ok := q.tables.transferFees[transferID]
+	if !ok {
+		return TransferFee{}, ErrRecordNotFound
+	}
+	return transferFee, nil
+}
+
+func (q *memQueries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
+	defer q.lock()()
+
+	if err := q.tables.accountExists("payments", "account_id", arg.AccountID); err != nil {
+		return Payment{}, err
+	}
+
+	payment := Payment{
+		ID:        q.tables.nextID("payments"),
+		AccountID: arg.AccountID,
+		Direction: arg.Direction,
+		Rail:      arg.Rail,
+		Amount:    arg.Amount,
+		Currency:  arg.Currency,
+		Status:    PaymentPending,
+		CreatedAt: now(),
+	}
+	q.tables.payments[payment.ID] = payment
+	return payment, nil
+}
+
+func (q *memQueries) GetPayment(ctx context.Context, id int64) (Payment, error) {
+	defer q.lock()()
+
+	payment, ok := q.tables.payments[id]
+	if !ok {
+		return Payment{}, ErrRecordNotFound
+	}
+	return payment, nil
+}
+
+// GetPaymentForUpdate needs no row lock: transactions do not overlap
+func (q *memQueries) GetPaymentForUpdate(ctx context.Context, id int64) (Payment, error) {
+	return q.GetPayment(ctx, id)
+}
+
+func (q *memQueries) ListPayments(ctx context.Context, arg ListPaymentsParams) ([]Payment, error) {
+	defer q.lock()()
+
+	payments := sortedRows(q.tables.payments,
+		func(payment Payment) bool { return payment.AccountID == arg.AccountID },
+		func(a, b Payment) int { return cmp.Compare(b.ID, a.ID) },
+	)
+	return page(payments, arg.Limit, arg.Offset), nil
+}
+
+func (q *memQueries) ListPendingPayments(ctx context.Context, arg ListPendingPaymentsParams) ([]Payment, error) {
+	defer q.lock()()
+
+	payments := sortedRows(q.tables.payments,
+		func(payment Payment) bool {
+			return payment.Status == PaymentPending && payment.ID > arg.AfterID &&
+				payment.CreatedAt.Before(arg.CreatedBefore)
+		},
+		byID(func(payment Payment) int64 { return payment.ID }),
+	)
+	return page(payments, arg.Limit, 0), nil
+}
+
+func (q *memQueries) SetPaymentPendingTransfer(ctx context.Context, arg SetPaymentPendingTransferParams) (Payment, error) {
+	defer q.lock()()
+
+	payment, ok := q.tables.payments[arg.ID]
+	if !ok {
+		return Payment{}, ErrRecordNotFound
+	}
+	if _, ok := q.tables.transfers[arg.PendingTransferID.Int64]; arg.PendingTransferID.Valid && !ok {
+		return Payment{}, foreignKeyViolation("payments", "payments_pending_transfer_id_fkey")
+	}
+	payment.PendingTransferID = arg.PendingTransferID
+	q.tables.payments[payment.ID] = payment
+	return payment, nil
+}
+
+func (q *memQueries) SetPaymentReference(ctx context.Context, arg SetPaymentReferenceParams) (Payment, error) {
+	defer q.lock()()
+
+	payment, ok := q.tables.payments[arg.ID]
+	if !ok {
+		return Payment{}, ErrRecordNotFound
+	}
+	payment.Reference = arg.Reference
+	q.tables.payments[payment.ID] = payment
+	return payment, nil
+}
+
+func (q *memQueries) CompletePayment(ctx context.Context, arg CompletePaymentParams) (Payment, error) {
+	defer q.lock()()
+
+	payment, ok := q.tables.payments[arg.ID]
+	if !ok || payment.Status != PaymentPending {
+		return Payment{}, ErrRecordNotFound
+	}
+	if arg.FinalTransferID.Valid {
+		if _, ok := q.tables.transfers[arg.FinalTransferID.Int64]; !ok {
+			return Payment{}, foreignKeyViolation("payments", "payments_final_transfer_id_fkey")
+		}
+	}
+
+	payment.Status = arg.Status
+	payment.FailureReason = arg.FailureReason
+	payment.FinalTransferID = arg.FinalTransferID
+	payment.CompletedAt = pgtype.Timestamptz{Time: now(), Valid: true}
+	q.tables.payments[payment.ID] = payment
+	return payment, nil
+}
+
+func (q *memQueries) GetAccountProduct(ctx context.Context, code string) (AccountProduct, error) {
+	defer q.lock()()
+
+	product, ok := q.tables.products[code]
+	if !ok {
+		return AccountProduct{}, ErrRecordNotFound
+	}
+	return product, nil
+}
+
+func (q *memQueries) ListAccountProducts(ctx context.Context) ([]AccountProduct, error) {
+	defer q.lock()()
+
+	products := sortedRows(q.tables.products,
+		func(AccountProduct) bool { return true },
+		func(a, b AccountProduct) int { return cmp.Compare(a.Code, b.Code) },
+	)
+	return products, nil
+}
+
+func (q *memQueries) GetGLAccount(ctx context.Context, code string) (GlAccount, error) {
+	defer q.lock()()
+
+	glAccount, ok := q.tables.glAccounts[code]
+	if !ok {
+		return GlAccount{}, ErrRecordNotFound
+	}
+	return glAccount, nil
+}
+
+func (q *memQueries) ListGLAccounts(ctx context.Context) ([]GlAccount, error) {
+	defer q.lock()()
+
+	glAccounts := sortedRows(q.tables.glAccounts,
+		func(GlAccount) bool { return true },
+		func(a, b GlAccount) int { return cmp.Compare(a.Code, b.Code) },
+	)
+	return glAccounts, nil
+}
+
+func (q *memQueries) ListGLBalances(ctx context.Context) ([]ListGLBalancesRow, error) {
+	defer q.lock()()
+
+	type balanceKey struct {
+		code     string
+		currency string
+	}
+	balances := make(map[balanceKey]ListGLBalancesRow)
+	for _, account := range q.tables.accounts {
+		key := balanceKey{account.GlCode, account.Currency}
+		row, ok := balances[key]
+		if !ok {
+			glAccount := q.tables.glAccounts[account.GlCode]
+			row = ListGLBalancesRow{Code: glAccount.Code, Name: glAccount.Name, Type: glAccount.Type, Currency: account.Currency}
+		}
+		row.Accounts++
+		row.Balance += account.Balance
+		balances[key] = row
+	}
+
+	rows := sortedRows(balances,
+		func(ListGLBalancesRow) bool { return true },
+		func(a, b ListGLBalancesRow) int {
+			if c := cmp.Compare(a.Currency, b.Currency); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.Code, b.Code)
+		},
+	)
+	return rows, nil
+}
+
+func (q *memQueries) ListInterestBearingAccounts(ctx context.Context, arg ListInterestBearingAccountsParams) ([]Account, error) {
+	defer q.lock()()
+
+	accounts := sortedRows(q.tables.accounts,
+		func(account Account) bool {
+			return account.ID > arg.AfterID && account.CreatedAt.Before(arg.DayEnd) &&
+				q.tables.products[account.Product].AnnualRatePpm > 0
+		},
+		byID(func(account Account) int64 { return account.ID }),
+	)
+	return page(accounts, arg.Limit, 0), nil
+}
+
+func (q *memQueries) CreateInterestAccrual(ctx context.Context, arg CreateInterestAccrualParams) (InterestAccrual, error) {
+	defer q.lock()()
+
+	if err := q.tables.accountExists("interest_accruals", "account_id", arg.AccountID); err != nil {
+		return InterestAccrual{}, err
+	}
+	key := interestKey{accountID: arg.AccountID, date: SnapshotDate(arg.AccrualDate)}
+	if _, ok := q.tables.accruals[key]; ok {
+		return InterestAccrual{}, ErrRecordNotFound
+	}
+
+	accrual := InterestAccrual{
+		AccountID:     key.accountID,
+		AccrualDate:   key.date,
+		Balance:       arg.Balance,
+		AnnualRatePpm: arg.AnnualRatePpm,
+		DayCount:      arg.DayCount,
+		AmountMicros:  arg.AmountMicros,
+		CreatedAt:     now(),
+	}
+	q.tables.accruals[key] = accrual
+	return accrual, nil
+}
+
+func (q *memQueries) SumInterestAccruals(ctx context.Context, arg SumInterestAccrualsParams) (int64, error) {
+	defer q.lock()()
+
+	var total int64
+	for key, accrual := range q.tables.accruals {
+		if key.accountID == arg.AccountID && key.date.After(SnapshotDate(arg.After)) && !key.date.After(SnapshotDate(arg.Until)) {
+			total += accrual.AmountMicros
+		}
+	}
+	return total, nil
+}
+
+func (q *memQueries) GetLastInterestPayout(ctx context.Context, arg GetLastInterestPayoutParams) (InterestPayout, error) {
+	defer q.lock()()
+
+	payouts := sortedRows(q.tables.payouts,
+		func(payout InterestPayout) bool {
+			return payout.AccountID == arg.AccountID && payout.PayoutDate.Before(SnapshotDate(arg.Before))
+		},
+		func(a, b InterestPayout) int { return b.PayoutDate.Compare(a.PayoutDate) },
+	)
+	if len(payouts) == 0 {
+		return InterestPayout{}, ErrRecordNotFound
+	}
+	return payouts[0], nil
+}
+
+func (q *memQueries) CreateInterestPayout(ctx context.Context, arg CreateInterestPayoutParams) (InterestPayout, error) {
+	defer q.lock()()
+
+	if err := q.tables.accountExists("interest_payouts", "account_id", arg.AccountID); err != nil {
+		return InterestPayout{}, err
+	}
+	if _, ok := q.tables.transfers[arg.TransferID.Int64]; arg.TransferID.Valid && !ok {
+		return InterestPayout{}, foreignKeyViolation("interest_payouts", "interest_payouts_transfer_id_fkey")
+	}
+	key := interestKey{accountID: arg.AccountID, date: SnapshotDate(arg.PayoutDate)}
+	if _, ok := q.tables.payouts[key]; ok {
+		return InterestPayout{}, uniqueViolation("interest_payouts_pkey")
+	}
+
+	payout := InterestPayout{
+		AccountID:     key.accountID,
+		PayoutDate:    key.date,
+		AccruedMicros: arg.AccruedMicros,
+		Amount:        arg.Amount,
+		CarryMicros:   arg.CarryMicros,
+		TransferID:    arg.TransferID,
+		CreatedAt:     now(),
+	}
+	q.tables.payouts[key] = payout
+	return payout, nil
+}
+
+func (q *memQueries) CreateLoginEvent(ctx context.Context, arg CreateLoginEventParams) (LoginEvent, error) {
+	defer q.lock()()
+
+	event := LoginEvent{
+		ID:        q.tables.nextID("login_events"),
+		Username:  arg.Username,
+		ClientIp:  arg.ClientIp,
+		UserAgent: arg.UserAgent,
+		Outcome:   arg.Outcome,
+		CreatedAt: now(),
+		Kind:      arg.Kind,
+	}
+	q.tables.loginEvents[event.ID] = event
+	return event, nil
+}
+
+func (q *memQueries) GetUserLoginFailures(ctx context.Context, arg GetUserLoginFailuresParams) (GetUserLoginFailuresRow, error) {
+	defer q.lock()()
+
+	// failures before the last success of the same kind or unlock are forgotten
+	since := arg.Since
+	for _, event := range q.tables.loginEvents {
+		if event.Username == arg.Username && event.CreatedAt.After(since) &&
+			(event.Outcome == "unlocked" || (event.Outcome == "success" && event.Kind == arg.Kind)) {
+			since = event.CreatedAt
+		}
+	}
+
+	var row GetUserLoginFailuresRow
+	for _, event := range q.tables.loginEvents {
+		if event.Username == arg.Username && event.Kind == arg.Kind && event.Outcome == "failure" &&
+			event.CreatedAt.After(since) {
+			row.Failures++
+			if event.CreatedAt.After(row.LastFailureAt) {
+				row.LastFailureAt = event.CreatedAt
+			}
+		}
+	}
+	return row, nil
+}
+
+func (q *memQueries) GetClientIPLoginFailures(ctx context.Context, arg GetClientIPLoginFailuresParams) (GetClientIPLoginFailuresRow, error) {
+	defer q.lock()()
+
+	var row GetClientIPLoginFailuresRow
+	for _, event := range q.tables.loginEvents {
+		if event.ClientIp == arg.ClientIp && event.Kind == arg.Kind && event.Outcome == "failure" &&
+			event.CreatedAt.After(arg.Since) {
+			row.Failures++
+			if event.CreatedAt.After(row.LastFailureAt) {
+				row.LastFailureAt = event.CreatedAt
+			}
+		}
+	}
+	return row, nil
+}
+
+// LockLoginAttempts has nothing to do: a MemStore transaction holds the lock of all the tables
+func (q *memQueries) LockLoginAttempts(ctx context.Context, key string) error {
+	return nil
+}
+
+func (q *memQueries) FinishLoginEvent(ctx context.Context, arg FinishLoginEventParams) (LoginEvent, error) {
+	defer q.lock()()
+
+	event, ok := q.tables.loginEvents[arg.ID]
+	if !ok {
+		return LoginEvent{}, ErrRecordNotFound
+	}
+	event.Username = arg.Username
+	event.Outcome = arg.Outcome
+	q.tables.loginEvents[event.ID] = event
+	return event, nil
+}
+
+func (q *memQueries) CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClient, error) {
+	defer q.lock()()
+
+	if err := q.tables.userExists("oauth_clients", "owner", arg.Owner); err != nil {
+		return OauthClient{}, err
+	}
+	if _, ok := q.tables.oauthClients[arg.ID]; ok {
+		return OauthClient{}, uniqueViolation("oauth_clients_pkey")
+	}
+
+	client := OauthClient{
+		ID:           arg.ID,
+		Owner:        arg.Owner,
+		Name:         arg.Name,
+		HashedSecret: arg.HashedSecret,
+		RedirectUris: slices.Clone(arg.RedirectUris),
+		Scopes:       slices.Clone(arg.Scopes),
+		CreatedAt:    now(),
+	}
+	q.tables.oauthClients[client.ID] = client
+	return client, nil
+}
+
+func (q *memQueries) GetOAuthClient(ctx context.Context, id uuid.UUID) (OauthClient, error) {
+	defer q.lock()()
+
+	client, ok := q.tables.oauthClients[id]
+	if !ok {
+		return OauthClient{}, ErrRecordNotFound
+	}
+	return client, nil
+}
+
+func (q *memQueries) CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) (OauthAuthorizationCode, error) {
+	defer q.lock()()
+
+	if err := q.tables.oauthClientExists("oauth_authorization_codes", arg.ClientID); err != nil {
+		return OauthAuthorizationCode{}, err
+	}
+	if err := q.tables.userExists("oauth_authorization_codes", "username", arg.Username); err != nil {
+		return OauthAuthorizationCode{}, err
+	}
+	if _, ok := q.tables.oauthCodes[arg.HashedCode]; ok {
+		return OauthAuthorizationCode{}, uniqueViolation("oauth_authorization_codes_pkey")
+	}
+
+	code := OauthAuthorizationCode{
+		HashedCode:    arg.HashedCode,
+		ClientID:      arg.ClientID,
+		Username:      arg.Username,
+		RedirectUri:   arg.RedirectUri,
+		Scopes:        slices.Clone(arg.Scopes),
+		CodeChallenge: arg.CodeChallenge,
+		ExpiresAt:     arg.ExpiresAt,
+		CreatedAt:     now(),
+	}
+	q.tables.oauthCodes[code.HashedCode] = code
+	return code, nil
+}
+
+func (q *memQueries) UseOAuthAuthorizationCode(ctx context.Context, hashedCode string) (OauthAuthorizationCode, error) {
+	defer q.lock()()
+
+	code, ok := q.tables.oauthCodes[hashedCode]
+	if !ok || code.IsUsed || !code.ExpiresAt.After(now()) {
+		return OauthAuthorizationCode{}, ErrRecordNotFound
+	}
+	code.IsUsed = true
+	q.tables.oauthCodes[hashedCode] = code
+	return code, nil
+}
+
+func (q *memQueries) UpsertOAuthConsent(ctx context.Context, arg UpsertOAuthConsentParams) (OauthConsent, error) {
+	defer q.lock()()
+
+	key := oauthConsentKey{username: arg.Username, clientID: arg.ClientID}
+	consent, ok := q.tables.oauthConsents[key]
+	if ok {
+		// granting again keeps the tokens issued under the current consent valid, unless it had been revoked
+		if consent.RevokedAt.Valid {
+			consent.GrantedAt = now()
+		}
+		consent.Scopes = slices.Clone(arg.Scopes)
+		consent.RevokedAt = pgtype.Timestamptz{}
+		q.tables.oauthConsents[key] = consent
+		return consent, nil
+	}
+
+	if err := q.tables.userExists("oauth_consents", "username", arg.Username); err != nil {
+		return OauthConsent{}, err
+	}
+	if err := q.tables.oauthClientExists("oauth_consents", arg.ClientID); err != nil {
+		return OauthConsent{}, err
+	}
+
+	consent = OauthConsent{
+		Username:  arg.Username,
+		ClientID:  arg.ClientID,
+		Scopes:    slices.Clone(arg.Scopes),
+		GrantedAt: now(),
+	}
+	q.tables.oauthConsents[key] = consent
+	return consent, nil
+}
+
+func (q *memQueries) GetOAuthConsent(ctx context.Context, arg GetOAuthConsentParams) (OauthConsent, error) {
+	defer q.lock()()
+
+	consent, ok := q.tables.oauthConsents[oauthConsentKey{username: arg.Username, clientID: arg.ClientID}]
+	if !ok {
+		return OauthConsent{}, ErrRecordNotFound
+	}
+	return consent, nil
+}
+
+func (q *memQueries) ListOAuthConsents(ctx context.Context, username string) ([]ListOAuthConsentsRow, error) {
+	defer q.lock()()
+
+	consents := sortedRows(q.tables.oauthConsents,
+		func(consent OauthConsent) bool { return consent.Username == username && !consent.RevokedAt.Valid },
+		func(a, b OauthConsent) int { return b.GrantedAt.Compare(a.GrantedAt) },
+	)
+
+	var rows []ListOAuthConsentsRow
+	for _, consent := range consents {
+		rows = append(rows, ListOAuthConsentsRow{
+			Username:   consent.Username,
+			ClientID:   consent.ClientID,
+			Scopes:     consent.Scopes,
+			GrantedAt:  consent.GrantedAt,
+			RevokedAt:  consent.RevokedAt,
+			ClientName: q.tables.oauthClients[consent.ClientID].Name,
+		})
+	}
+	return rows, nil
+}
+
+func (q *memQueries) RevokeOAuthConsent(ctx context.Context, arg RevokeOAuthConsentParams) (OauthConsent, error) {
+	defer q.lock()()
+
+	key := oauthConsentKey{username: arg.Username, clientID: arg.ClientID}
+	consent, ok := q.tables.oauthConsents[key]
+	if !ok || consent.RevokedAt.Valid {
+		return OauthConsent{}, ErrRecordNotFound
+	}
+	consent.RevokedAt = pgtype.Timestamptz{Time: now(), Valid: true}
+	q.tables.oauthConsents[key] = consent
+	return consent, nil
+}
+
+func (q *memQueries) CreateResetPassword(ctx context.Context, arg CreateResetPasswordParams) (ResetPassword, error) {
+	defer q.lock()()
+
+	if err := q.tables.userExists("reset_passwords", "username", arg.Username); err != nil {
+		return ResetPassword{}, err
+	}
+
+	createdAt := now()
+	resetPassword := ResetPassword{
+		ID:         q.tables.nextID("reset_passwords"),
+		Username:   arg.Username,
+		HashedCode: arg.HashedCode,
+		CreatedAt:  createdAt,
+		ExpiredAt:  createdAt.Add(15 * time.Minute),
+	}
+	q.tables.resetPasswords[resetPassword.ID] = resetPassword
+	return resetPassword, nil
+}
+
+func (q *memQueries) UpdateResetPassword(ctx context.Context, arg UpdateResetPasswordParams) (ResetPassword, error) {
+	defer q.lock()()
+
+	resetPassword, ok := q.tables.resetPasswords[arg.ID]
+	if !ok || resetPassword.HashedCode != arg.HashedCode || resetPassword.IsUsed || !resetPassword.ExpiredAt.After(now()) {
+		return ResetPassword{}, ErrRecordNotFound
+	}
+	resetPassword.IsUsed = true
+	q.tables.resetPasswords[resetPassword.ID] = resetPassword
+	return resetPassword, nil
+}
+
+func (q *memQueries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	defer q.lock()()
+
+	if err := q.tables.userExists("sessions", "username", arg.Username); err != nil {
+		return Session{}, err
+	}
+	if _, ok := q.tables.sessions[arg.ID]; ok {
+		return Session{}, uniqueViolation("sessions_pkey")
+	}
+
+	session := Session{
+		ID:           arg.ID,
+		Username:     arg.Username,
+		RefreshToken: arg.RefreshToken,
+		UserAgent:    arg.UserAgent,
+		ClientIp:     arg.ClientIp,
+		IsBlocked:    arg.IsBlocked,
+		ExpiresAt:    arg.ExpiresAt,
+		CreatedAt:    now(),
+	}
+	q.tables.sessions[session.ID] = session
+	return session, nil
+}
+
+func (q *memQueries) GetSession(ctx context.Context, id uuid.UUID) (Session, error) {
+	defer q.lock()()
+
+	session, ok := q.tables.sessions[id]
+	if !ok {
+		return Session{}, ErrRecordNotFound
+	}
+	return session, nil
+}
+
+func (q *memQueries) BlockUserSessions(ctx context.Context, username string) error {
+	defer q.lock()()
+
+	for id, session := range q.tables.sessions {
+		if session.Username == username && !session.IsBlocked {
+			session.IsBlocked = true
+			q.tables.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (q *memQueries) CreateTask(ctx context.Context, arg CreateTaskParams) (Task, error) {
+	defer q.lock()()
+
+	createdAt := now()
+	task := Task{
+		ID:          q.tables.nextID("tasks"),
+		Queue:       arg.Queue,
+		Type:        arg.Type,
+		Payload:     slices.Clone(arg.Payload),
+		Status:      "pending",
+		MaxAttempts: arg.MaxAttempts,
+		RunAt:       arg.RunAt,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+	q.tables.tasks[task.ID] = task
+	return task, nil
+}
+
+func (q *memQueries) GetTask(ctx context.Context, id int64) (Task, error) {
+	defer q.lock()()
+
+	task, ok := q.tables.tasks[id]
+	if !ok {
+		return Task{}, ErrRecordNotFound
+	}
+	return task, nil
+}
+
+func (q *memQueries) ClaimTask(ctx context.Context, arg ClaimTaskParams) (Task, error) {
+	defer q.lock()()
+
+	current := now()
+	tasks := sortedRows(q.tables.tasks,
+		func(task Task) bool {
+			return task.Queue == arg.Queue && task.Status == "pending" && !task.RunAt.After(current)
+		},
+		func(a, b Task) int { return a.RunAt.Compare(b.RunAt) },
+	)
+	if len(tasks) == 0 {
+		return Task{}, ErrRecordNotFound
+	}
+
+	task := tasks[0]
+	task.Attempts++
+	task.RunAt = arg.LockedUntil
+	task.UpdatedAt = current
+	q.tables.tasks[task.ID] = task
+	return task, nil
+}
+
+func (q *memQueries) CompleteTask(ctx context.Context, id int64) error {
+	defer q.lock()()
+
+	if task, ok := q.tables.tasks[id]; ok {
+		task.Status = "completed"
+		task.UpdatedAt = now()
+		q.tables.tasks[id] = task
+	}
+	return nil
+}
+
+func (q *memQueries) RetryTask(ctx context.Context, arg RetryTaskParams) error {
+	defer q.lock()()
+
+	if task, ok := q.tables.tasks[arg.ID]; ok {
+		task.RunAt = arg.RunAt
+		task.LastError = arg.LastError
+		task.UpdatedAt = now()
+		q.tables.tasks[arg.ID] = task
+	}
+	return nil
+}
+
+func (q *memQueries) FailTask(ctx context.Context, arg FailTaskParams) error {
+	defer q.lock()()
+
+	if task, ok := q.tables.tasks[arg.ID]; ok {
+		task.Status = "failed"
+		task.LastError = arg.LastError
+		task.UpdatedAt = now()
+		q.tables.tasks[arg.ID] = task
+	}
+	return nil
+}
+
+func (q *memQueries) CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error) {
+	defer q.lock()()
+
+	if err := q.tables.accountExists("transfers", "from_account_id", arg.FromAccountID); err != nil {
+		return Transfer{}, err
+	}
+	if err := q.tables.accountExists("transfers", "to_account_id", arg.ToAccountID); err != nil {
+		return Transfer{}, err
+	}
+
+	transfer := Transfer{
+		ID:            q.tables.nextID("transfers"),
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:   arg.ToAccountID,
+		Amount:        arg.Amount,
+		CreatedAt:     now(),
+	}
+	q.tables.transfers[transfer.ID] = transfer
+	return transfer, nil
+}
+
+func (q *memQueries) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
+	defer q.lock()()
+
+	transfer, ok := q.tables.transfers[id]
+	if !ok {
+		return Transfer{}, ErrRecordNotFound
+	}
+	return transfer, nil
+}
+
+func (q *memQueries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error) {
+	defer q.lock()()
+
+	transfers := sortedRows(q.tables.transfers,
+		func(transfer Transfer) bool {
+			return transfer.FromAccountID == arg.FromAccountID || transfer.ToAccountID == arg.ToAccountID
+		},
+		byID(func(transfer Transfer) int64 { return transfer.ID }),
+	)
+	return page(transfers, arg.Limit, arg.Offset), nil
+}
+
+func (q *memQueries) UpsertTOTPSecret(ctx context.Context, arg UpsertTOTPSecretParams) (TotpSecret, error) {
+	defer q.lock()()
+
+	secret, ok := q.tables.totpSecrets[arg.Username]
+	if ok && secret.IsEnabled {
+		// an enabled secret is never replaced
+		return TotpSecret{}, ErrRecordNotFound
+	}
+	if !ok {
+		if err := q.tables.userExists("totp_secrets", "username", arg.Username); err != nil {
+			return TotpSecret{}, err
+		}
+	}
+
+	secret = TotpSecret{
+		Username:        arg.Username,
+		EncryptedSecret: slices.Clone(arg.EncryptedSecret),
+		CreatedAt:       now(),
+	}
+	q.tables.totpSecrets[arg.Username] = secret
+	return secret, nil
+}
+
+func (q *memQueries) GetTOTPSecret(ctx context.Context, username string) (TotpSecret, error) {
+	defer q.lock()()
+
+	secret, ok := q.tables.totpSecrets[username]
+	if !ok {
+		return TotpSecret{}, ErrRecordNotFound
+	}
+	return secret, nil
+}
+
+func (q *memQueries) EnableTOTPSecret(ctx context.Context, arg EnableTOTPSecretParams) (TotpSecret, error) {
+	defer q.lock()()
+
+	secret, ok := q.tables.totpSecrets[arg.Username]
+	if !ok || secret.IsEnabled {
+		return TotpSecret{}, ErrRecordNotFound
+	}
+	secret.IsEnabled = true
+	secret.LastUsedStep = arg.Step
+	q.tables.totpSecrets[arg.Username] = secret
+	return secret, nil
+}
+
+func (q *memQueries) DeleteTOTPSecret(ctx context.Context, username string) (TotpSecret, error) {
+	defer q.lock()()
+
+	secret, ok := q.tables.totpSecrets[username]
+	if !ok || !secret.IsEnabled {
+		return TotpSecret{}, ErrRecordNotFound
+	}
+	delete(q.tables.totpSecrets, username)
+	return secret, nil
+}
+
+func (q *memQueries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (TotpSecret, error) {
+	defer q.lock()()
+
+	secret, ok := q.tables.totpSecrets[arg.Username]
+	if !ok || !secret.IsEnabled || secret.LastUsedStep >= arg.Step {
+		return TotpSecret{}, ErrRecordNotFound
+	}
+	secret.LastUsedStep = arg.Step
+	q.tables.totpSecrets[arg.Username] = secret
+	return secret, nil
+}
+
+func (q *memQueries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error) {
+	defer q.lock()()
+
+	if err := q.tables.userExists("recovery_codes", "username", arg.Username); err != nil {
+		return RecoveryCode{}, err
+	}
+	for _, code := range q.tables.recoveryCodes {
+		if code.Username == arg.Username && code.HashedCode == arg.HashedCode {
+			return RecoveryCode{}, uniqueViolation("recovery_codes_username_hashed_code_idx")
+		}
+	}
+
+	code := RecoveryCode{
+		ID:         q.tables.nextID("recovery_codes"),
+		Username:   arg.Username,
+		HashedCode: arg.HashedCode,
+		CreatedAt:  now(),
+	}
+	q.tables.recoveryCodes[code.ID] = code
+	return code, nil
+}
+
+func (q *memQueries) DeleteRecoveryCodes(ctx context.Context, username string) error {
+	defer q.lock()()
+
+	for id, code := range q.tables.recoveryCodes {
+		if code.Username == username {
+			delete(q.tables.recoveryCodes, id)
+		}
+	}
+	return nil
+}
+
+func (q *memQueries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (RecoveryCode, error) {
+	defer q.lock()()
+
+	for id, code := range q.tables.recoveryCodes {
+		if code.Username == arg.Username && code.HashedCode == arg.HashedCode && !code.IsUsed {
+			code.IsUsed = true
+			q.tables.recoveryCodes[id] = code
+			return code, nil
+		}
+	}
+	return RecoveryCode{}, ErrRecordNotFound
+}
+
+func (q *memQueries) CreateLoginChallenge(ctx context.Context, arg CreateLoginChallengeParams) (LoginChallenge, error) {
+	defer q.lock()()
+
+	if err := q.tables.userExists("login_challenges", "username", arg.Username); err != nil {
+		return LoginChallenge{}, err
+	}
+	if _, ok := q.tables.loginChallenges[arg.ID]; ok {
+		return LoginChallenge{}, uniqueViolation("login_challenges_pkey")
+	}
+
+	challenge := LoginChallenge{
+		ID:        arg.ID,
+		Username:  arg.Username,
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: now(),
+	}
+	q.tables.loginChallenges[challenge.ID] = challenge
+	return challenge, nil
+}
+
+func (q *memQueries) AttemptLoginChallenge(ctx context.Context, arg AttemptLoginChallengeParams) (LoginChallenge, error) {
+	defer q.lock()()
+
+	challenge, ok := q.tables.loginChallenges[arg.ID]
+	if !ok || challenge.IsUsed || !challenge.ExpiresAt.After(now()) || challenge.Attempts >= arg.MaxAttempts {
+		return LoginChallenge{}, ErrRecordNotFound
+	}
+	challenge.Attempts++
+	q.tables.loginChallenges[challenge.ID] = challenge
+	return challenge, nil
+}
+
+func (q *memQueries) CompleteLoginChallenge(ctx context.Context, id uuid.UUID) (LoginChallenge, error) {
+	defer q.lock()()
+
+	challenge, ok := q.tables.loginChallenges[id]
+	if !ok || challenge.IsUsed {
+		return LoginChallenge{}, ErrRecordNotFound
+	}
+	challenge.IsUsed = true
+	q.tables.loginChallenges[id] = challenge
+	return challenge, nil
+}
+
+func (q *memQueries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	defer q.lock()()
+
+	if _, ok := q.tables.users[arg.Username]; ok {
+		return User{}, uniqueViolation("users_pkey")
+	}
+	if q.tables.emailTaken(arg.Email, "") {
+		return User{}, uniqueViolation("users_email_key")
+	}
+
+	user := User{
+		Username:       arg.Username,
+		HashedPassword: arg.HashedPassword,
+		FullName:       arg.FullName,
+		Email:          arg.Email,
+		// the column defaults to '0001-01-01 00:00:00Z'
+		PasswordChangedAt: time.Time{},
+		CreatedAt:         now(),
+		Role:              "depositor",
+	}
+	q.tables.users[user.Username] = user
+	return user, nil
+}
+
+// emailTaken tells whether a user other than username has email
+func (tables *memTables) emailTaken(email, username string) bool {
+	for _, user := range tables.users {
+		if user.Email == email && user.Username != username {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *memQueries) GetUser(ctx context.Context, username string) (User, error) {
+	defer q.lock()()
+
+	user, ok := q.tables.users[username]
+	if !ok {
+		return User{}, ErrRecordNotFound
+	}
+	return user, nil
+}
+
+func (q *memQueries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	defer q.lock()()
+
+	user, ok := q.tables.users[arg.Username]
+	if !ok || user.Email != arg.Email {
+		return User{}, ErrRecordNotFound
+	}
+	user.IsEmailVerified = true
+	q.tables.users[user.Username] = user
+	return user, nil
+}
+
+func (q *memQueries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	defer q.lock()()
+
+	for _, user := range q.tables.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return User{}, ErrRecordNotFound
+}
+
+func (q *memQueries) GetUserPasswordChangedAt(ctx context.Context, username string) (time.Time, error) {
+	defer q.lock()()
+
+	user, ok := q.tables.users[username]
+	if !ok {
+		return time.Time{}, ErrRecordNotFound
+	}
+	return user.PasswordChangedAt, nil
+}
+
+func (q *memQueries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	defer q.lock()()
+
+	user, ok := q.tables.users[arg.Username]
+	if !ok {
+		return User{}, ErrRecordNotFound
+	}
+	user.HashedPassword = arg.HashedPassword
+	user.PasswordChangedAt = arg.PasswordChangedAt
+	q.tables.users[user.Username] = user
+	return user, nil
+}
+
+func (q *memQueries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	defer q.lock()()
+
+	user, ok := q.tables.users[arg.Username]
+	if !ok {
+		return User{}, ErrRecordNotFound
+	}
+	if arg.FullName.Valid {
+		user.FullName = arg.FullName.String
+	}
+	if arg.Email.Valid && arg.Email.String != user.Email {
+		if q.tables.emailTaken(arg.Email.String, user.Username) {
+			return User{}, uniqueViolation("users_email_key")
+		}
+		// a new email has to be verified again
+		user.Email = arg.Email.String
+		user.IsEmailVerified = false
+	}
+	q.tables.users[user.Username] = user
+	return user, nil
+}
+
+func (q *memQueries) CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error) {
+	defer q.lock()()
+
+	if err := q.tables.userExists("verify_emails", "username", arg.Username); err != nil {
+		return VerifyEmail{}, err
+	}
+
+	createdAt := now()
+	verifyEmail := VerifyEmail{
+		ID:         q.tables.nextID("verify_emails"),
+		Username:   arg.Username,
+		Email:      arg.Email,
+		SecretCode: arg.SecretCode,
+		CreatedAt:  createdAt,
+		ExpiredAt:  createdAt.Add(15 * time.Minute),
+	}
+	q.tables.verifyEmails[verifyEmail.ID] = verifyEmail
+	return verifyEmail, nil
+}
+
+func (q *memQueries) UpdateVerifyEmail(ctx context.Context, arg UpdateVerifyEmailParams) (VerifyEmail, error) {
+	defer q.lock()()
+
+	verifyEmail, ok := q.tables.verifyEmails[arg.ID]
+	if !ok || verifyEmail.SecretCode != arg.SecretCode || verifyEmail.IsUsed || !verifyEmail.ExpiredAt.After(now()) {
+		return VerifyEmail{}, ErrRecordNotFound
+	}
+	verifyEmail.IsUsed = true
+	q.tables.verifyEmails[verifyEmail.ID] = verifyEmail
+	return verifyEmail, nil
+}
+
+// compile-time check that the in-memory queries cover every statement
+var _ Querier = (*memQueries)(nil)
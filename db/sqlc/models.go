@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Account struct {
@@ -16,6 +17,64 @@ type Account struct {
 	Balance   int64     `json:"balance"`
 	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
+	Product   string    `json:"product"`
+	// customer_deposits for the accounts of users, the code of the GL account for system accounts
+	GlCode string `json:"gl_code"`
+}
+
+type AccountProduct struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// annual rate in parts per million: 20000 is 2%
+	AnnualRatePpm int64 `json:"annual_rate_ppm"`
+	// ACT/365 or 30/360
+	DayCount string `json:"day_count"`
+	// daily, monthly, quarterly or annually: interest is paid out at the end of each period
+	Compounding string    `json:"compounding"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ApiKey struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Name     string    `json:"name"`
+	// public start of the key, to tell keys apart and look them up
+	Prefix string `json:"prefix"`
+	// sha256 of the whole key, which is only shown once
+	HashedKey  string             `json:"hashed_key"`
+	Scopes     []string           `json:"scopes"`
+	IsRevoked  bool               `json:"is_revoked"`
+	ExpiresAt  time.Time          `json:"expires_at"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+type AuditEvent struct {
+	ID int64 `json:"id"`
+	// username of the authenticated user, empty for anonymous requests such as signing up
+	Actor     string `json:"actor"`
+	ActorRole string `json:"actor_role"`
+	ClientIp  string `json:"client_ip"`
+	UserAgent string `json:"user_agent"`
+	// e.g. account.create or session.block
+	Action       string `json:"action"`
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+	// state of the resource before the change, NULL when it is created
+	Before []byte `json:"before"`
+	// state of the resource after the change, NULL when it is deleted
+	After     []byte    `json:"after"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BalanceSnapshot struct {
+	AccountID int64 `json:"account_id"`
+	// day in UTC: the balance is the one at the end of that day
+	SnapshotDate time.Time `json:"snapshot_date"`
+	Balance      int64     `json:"balance"`
+	// frozen by the end of day job, for reporting: it is never taken again
+	IsFrozen  bool      `json:"is_frozen"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Entry struct {
@@ -24,6 +83,159 @@ type Entry struct {
 	// can be negative
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// sha256 of the hash of the previous entry of the account and of this entry, see db.HashEntry
+	Hash []byte `json:"hash"`
+	// the payment that moved the money, if any: its status tells whether the entry is pending, settled or failed
+	PaymentID pgtype.Int8 `json:"payment_id"`
+}
+
+type FeeRule struct {
+	ID       int64  `json:"id"`
+	Schedule string `json:"schedule"`
+	Currency string `json:"currency"`
+	// tier: the rule applies to transfers of at least min_amount, up to the next tier
+	MinAmount int64 `json:"min_amount"`
+	FlatFee   int64 `json:"flat_fee"`
+	// percentage of the amount, in parts per million: 1000 is 0.1%
+	RatePpm int64 `json:"rate_ppm"`
+	MinFee  int64 `json:"min_fee"`
+	// 0 for no maximum
+	MaxFee    int64     `json:"max_fee"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type FeeSchedule struct {
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GlAccount struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// asset, liability, revenue or expense
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type InterestAccrual struct {
+	AccountID int64 `json:"account_id"`
+	// day in UTC: interest accrues on the balance at the end of that day
+	AccrualDate   time.Time `json:"accrual_date"`
+	Balance       int64     `json:"balance"`
+	AnnualRatePpm int64     `json:"annual_rate_ppm"`
+	DayCount      string    `json:"day_count"`
+	// in millionths of the minor unit, rounded down
+	AmountMicros int64     `json:"amount_micros"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type InterestPayout struct {
+	AccountID  int64     `json:"account_id"`
+	PayoutDate time.Time `json:"payout_date"`
+	// accrued since the last payout, with its carry
+	AccruedMicros int64 `json:"accrued_micros"`
+	Amount        int64 `json:"amount"`
+	// less than a minor unit, left for the next payout
+	CarryMicros int64 `json:"carry_micros"`
+	// from the interest expense account, unless nothing was paid out
+	TransferID pgtype.Int8 `json:"transfer_id"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+type LoginChallenge struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Attempts  int32     `json:"attempts"`
+	IsUsed    bool      `json:"is_used"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoginEvent struct {
+	ID int64 `json:"id"`
+	// as submitted, so it may not belong to any user
+	Username  string `json:"username"`
+	ClientIp  string `json:"client_ip"`
+	UserAgent string `json:"user_agent"`
+	// success, failure, locked, unlocked or challenged. An attempt is recorded as a failure until its outcome is known
+	Outcome   string    `json:"outcome"`
+	CreatedAt time.Time `json:"created_at"`
+	// login, or step_up for the two-factor codes sent with high-value operations
+	Kind string `json:"kind"`
+}
+
+type OauthAuthorizationCode struct {
+	HashedCode  string    `json:"hashed_code"`
+	ClientID    uuid.UUID `json:"client_id"`
+	Username    string    `json:"username"`
+	RedirectUri string    `json:"redirect_uri"`
+	Scopes      []string  `json:"scopes"`
+	// PKCE S256 challenge the token request has to answer
+	CodeChallenge string    `json:"code_challenge"`
+	IsUsed        bool      `json:"is_used"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type OauthClient struct {
+	ID    uuid.UUID `json:"id"`
+	Owner string    `json:"owner"`
+	Name  string    `json:"name"`
+	// sha256 of the client secret; NULL for public clients, which must use PKCE
+	HashedSecret pgtype.Text `json:"hashed_secret"`
+	RedirectUris []string    `json:"redirect_uris"`
+	Scopes       []string    `json:"scopes"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+type OauthConsent struct {
+	Username string    `json:"username"`
+	ClientID uuid.UUID `json:"client_id"`
+	Scopes   []string  `json:"scopes"`
+	// tokens issued before are not valid, so granting again after a revocation does not revive them
+	GrantedAt time.Time          `json:"granted_at"`
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+}
+
+type Payment struct {
+	ID        int64 `json:"id"`
+	AccountID int64 `json:"account_id"`
+	// deposit or withdrawal
+	Direction string `json:"direction"`
+	// the payment rail that moves the money, e.g. ach, sepa or card
+	Rail     string `json:"rail"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	// pending, settled or failed
+	Status string `json:"status"`
+	// given by the rail once it accepts the payment
+	Reference     string `json:"reference"`
+	FailureReason string `json:"failure_reason"`
+	// into the suspense account, from the clearing account for deposits and from the account for withdrawals. Set right after the payment is created
+	PendingTransferID pgtype.Int8 `json:"pending_transfer_id"`
+	// out of the suspense account once the payment settles or fails
+	FinalTransferID pgtype.Int8        `json:"final_transfer_id"`
+	CreatedAt       time.Time          `json:"created_at"`
+	CompletedAt     pgtype.Timestamptz `json:"completed_at"`
+}
+
+type RecoveryCode struct {
+	ID         int64     `json:"id"`
+	Username   string    `json:"username"`
+	HashedCode string    `json:"hashed_code"`
+	IsUsed     bool      `json:"is_used"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ResetPassword struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// sha256 of the secret code, which is only sent by email
+	HashedCode string    `json:"hashed_code"`
+	IsUsed     bool      `json:"is_used"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiredAt  time.Time `json:"expired_at"`
 }
 
 type Session struct {
@@ -37,6 +249,32 @@ type Session struct {
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+type Task struct {
+	ID      int64  `json:"id"`
+	Queue   string `json:"queue"`
+	Type    string `json:"type"`
+	Payload []byte `json:"payload"`
+	// pending, completed or failed
+	Status      string `json:"status"`
+	Attempts    int32  `json:"attempts"`
+	MaxAttempts int32  `json:"max_attempts"`
+	LastError   string `json:"last_error"`
+	// not before; pushed forward while a worker holds the task
+	RunAt     time.Time `json:"run_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TotpSecret struct {
+	Username string `json:"username"`
+	// AES-256-GCM with TOTP_ENCRYPTION_KEY and the username as additional data, after its nonce
+	EncryptedSecret []byte `json:"encrypted_secret"`
+	IsEnabled       bool   `json:"is_enabled"`
+	// time step of the last accepted code, so a code cannot be replayed
+	LastUsedStep int64     `json:"last_used_step"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
 type Transfer struct {
 	ID            int64 `json:"id"`
 	FromAccountID int64 `json:"from_account_id"`
@@ -46,6 +284,19 @@ type Transfer struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type TransferFee struct {
+	TransferID int64 `json:"transfer_id"`
+	// from the sender to the fee revenue account
+	FeeTransferID int64     `json:"fee_transfer_id"`
+	Schedule      string    `json:"schedule"`
+	RuleID        int64     `json:"rule_id"`
+	Flat          int64     `json:"flat"`
+	Percentage    int64     `json:"percentage"`
+	Adjustment    int64     `json:"adjustment"`
+	Total         int64     `json:"total"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
 type User struct {
 	Username          string    `json:"username"`
 	HashedPassword    string    `json:"hashed_password"`
@@ -53,4 +304,16 @@ type User struct {
 	Email             string    `json:"email"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
+	IsEmailVerified   bool      `json:"is_email_verified"`
+	Role              string    `json:"role"`
+}
+
+type VerifyEmail struct {
+	ID         int64     `json:"id"`
+	Username   string    `json:"username"`
+	Email      string    `json:"email"`
+	SecretCode string    `json:"secret_code"`
+	IsUsed     bool      `json:"is_used"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiredAt  time.Time `json:"expired_at"`
 }
@@ -0,0 +1,290 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: oauth.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createOAuthAuthorizationCode = `-- name: CreateOAuthAuthorizationCode :one
+INSERT INTO oauth_authorization_codes (
+  hashed_code,
+  client_id,
+  username,
+  redirect_uri,
+  scopes,
+  code_challenge,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING hashed_code, client_id, username, redirect_uri, scopes, code_challenge, is_used, expires_at, created_at
+`
+
+type CreateOAuthAuthorizationCodeParams struct {
+	HashedCode    string    `json:"hashed_code"`
+	ClientID      uuid.UUID `json:"client_id"`
+	Username      string    `json:"username"`
+	RedirectUri   string    `json:"redirect_uri"`
+	Scopes        []string  `json:"scopes"`
+	CodeChallenge string    `json:"code_challenge"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) (OauthAuthorizationCode, error) {
+	row := q.db.QueryRow(ctx, createOAuthAuthorizationCode,
+		arg.HashedCode,
+		arg.ClientID,
+		arg.Username,
+		arg.RedirectUri,
+		arg.Scopes,
+		arg.CodeChallenge,
+		arg.ExpiresAt,
+	)
+	var i OauthAuthorizationCode
+	err := row.Scan(
+		&i.HashedCode,
+		&i.ClientID,
+		&i.Username,
+		&i.RedirectUri,
+		&i.Scopes,
+		&i.CodeChallenge,
+		&i.IsUsed,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createOAuthClient = `-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (
+  id,
+  owner,
+  name,
+  hashed_secret,
+  redirect_uris,
+  scopes
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING id, owner, name, hashed_secret, redirect_uris, scopes, created_at
+`
+
+type CreateOAuthClientParams struct {
+	ID           uuid.UUID   `json:"id"`
+	Owner        string      `json:"owner"`
+	Name         string      `json:"name"`
+	HashedSecret pgtype.Text `json:"hashed_secret"`
+	RedirectUris []string    `json:"redirect_uris"`
+	Scopes       []string    `json:"scopes"`
+}
+
+func (q *Queries) CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClient, error) {
+	row := q.db.QueryRow(ctx, createOAuthClient,
+		arg.ID,
+		arg.Owner,
+		arg.Name,
+		arg.HashedSecret,
+		arg.RedirectUris,
+		arg.Scopes,
+	)
+	var i OauthClient
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Name,
+		&i.HashedSecret,
+		&i.RedirectUris,
+		&i.Scopes,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getOAuthClient = `-- name: GetOAuthClient :one
+SELECT id, owner, name, hashed_secret, redirect_uris, scopes, created_at FROM oauth_clients
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetOAuthClient(ctx context.Context, id uuid.UUID) (OauthClient, error) {
+	row := q.db.QueryRow(ctx, getOAuthClient, id)
+	var i OauthClient
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Name,
+		&i.HashedSecret,
+		&i.RedirectUris,
+		&i.Scopes,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getOAuthConsent = `-- name: GetOAuthConsent :one
+SELECT username, client_id, scopes, granted_at, revoked_at FROM oauth_consents
+WHERE username = $1 AND client_id = $2 LIMIT 1
+`
+
+type GetOAuthConsentParams struct {
+	Username string    `json:"username"`
+	ClientID uuid.UUID `json:"client_id"`
+}
+
+func (q *Queries) GetOAuthConsent(ctx context.Context, arg GetOAuthConsentParams) (OauthConsent, error) {
+	row := q.db.QueryRow(ctx, getOAuthConsent, arg.Username, arg.ClientID)
+	var i OauthConsent
+	err := row.Scan(
+		&i.Username,
+		&i.ClientID,
+		&i.Scopes,
+		&i.GrantedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const listOAuthConsents = `-- name: ListOAuthConsents :many
+SELECT oauth_consents.username, oauth_consents.client_id, oauth_consents.scopes, oauth_consents.granted_at, oauth_consents.revoked_at, oauth_clients.name AS client_name
+FROM oauth_consents
+JOIN oauth_clients ON oauth_clients.id = oauth_consents.client_id
+WHERE
+  oauth_consents.username = $1
+  AND oauth_consents.revoked_at IS NULL
+ORDER BY oauth_consents.granted_at DESC
+`
+
+type ListOAuthConsentsRow struct {
+	Username   string             `json:"username"`
+	ClientID   uuid.UUID          `json:"client_id"`
+	Scopes     []string           `json:"scopes"`
+	GrantedAt  time.Time          `json:"granted_at"`
+	RevokedAt  pgtype.Timestamptz `json:"revoked_at"`
+	ClientName string             `json:"client_name"`
+}
+
+func (q *Queries) ListOAuthConsents(ctx context.Context, username string) ([]ListOAuthConsentsRow, error) {
+	rows, err := q.db.Query(ctx, listOAuthConsents, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListOAuthConsentsRow{}
+	for rows.Next() {
+		var i ListOAuthConsentsRow
+		if err := rows.Scan(
+			&i.Username,
+			&i.ClientID,
+			&i.Scopes,
+			&i.GrantedAt,
+			&i.RevokedAt,
+			&i.ClientName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeOAuthConsent = `-- name: RevokeOAuthConsent :one
+UPDATE oauth_consents
+SET
+  revoked_at = now()
+WHERE
+  username = $1
+  AND client_id = $2
+  AND revoked_at IS NULL
+RETURNING username, client_id, scopes, granted_at, revoked_at
+`
+
+type RevokeOAuthConsentParams struct {
+	Username string    `json:"username"`
+	ClientID uuid.UUID `json:"client_id"`
+}
+
+func (q *Queries) RevokeOAuthConsent(ctx context.Context, arg RevokeOAuthConsentParams) (OauthConsent, error) {
+	row := q.db.QueryRow(ctx, revokeOAuthConsent, arg.Username, arg.ClientID)
+	var i OauthConsent
+	err := row.Scan(
+		&i.Username,
+		&i.ClientID,
+		&i.Scopes,
+		&i.GrantedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const upsertOAuthConsent = `-- name: UpsertOAuthConsent :one
+INSERT INTO oauth_consents (
+  username,
+  client_id,
+  scopes
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT (username, client_id) DO UPDATE
+SET
+  scopes = EXCLUDED.scopes,
+  granted_at = CASE WHEN oauth_consents.revoked_at IS NULL THEN oauth_consents.granted_at ELSE now() END,
+  revoked_at = NULL
+RETURNING username, client_id, scopes, granted_at, revoked_at
+`
+
+type UpsertOAuthConsentParams struct {
+	Username string    `json:"username"`
+	ClientID uuid.UUID `json:"client_id"`
+	Scopes   []string  `json:"scopes"`
+}
+
+// granting again keeps the tokens issued under the current consent valid, unless it had been revoked
+func (q *Queries) UpsertOAuthConsent(ctx context.Context, arg UpsertOAuthConsentParams) (OauthConsent, error) {
+	row := q.db.QueryRow(ctx, upsertOAuthConsent, arg.Username, arg.ClientID, arg.Scopes)
+	var i OauthConsent
+	err := row.Scan(
+		&i.Username,
+		&i.ClientID,
+		&i.Scopes,
+		&i.GrantedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const useOAuthAuthorizationCode = `-- name: UseOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET
+  is_used = TRUE
+WHERE
+  hashed_code = $1
+  AND is_used = FALSE
+  AND expires_at > now()
+RETURNING hashed_code, client_id, username, redirect_uri, scopes, code_challenge, is_used, expires_at, created_at
+`
+
+// an authorization code can be exchanged only once, before it expires
+func (q *Queries) UseOAuthAuthorizationCode(ctx context.Context, hashedCode string) (OauthAuthorizationCode, error) {
+	row := q.db.QueryRow(ctx, useOAuthAuthorizationCode, hashedCode)
+	var i OauthAuthorizationCode
+	err := row.Scan(
+		&i.HashedCode,
+		&i.ClientID,
+		&i.Username,
+		&i.RedirectUri,
+		&i.Scopes,
+		&i.CodeChallenge,
+		&i.IsUsed,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomOAuthClient(t *testing.T, owner User) OauthClient {
+	arg := CreateOAuthClientParams{
+		ID:           uuid.New(),
+		Owner:        owner.Username,
+		Name:         util.RandomString(8),
+		HashedSecret: pgtype.Text{String: util.RandomString(64), Valid: true},
+		RedirectUris: []string{"https://client.example.com/callback"},
+		Scopes:       []string{util.AccountsReadScope, util.TransfersWriteScope},
+	}
+
+	client, err := testQueries.CreateOAuthClient(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, client.ID)
+	require.Equal(t, arg.Owner, client.Owner)
+	require.Equal(t, arg.Name, client.Name)
+	require.Equal(t, arg.HashedSecret, client.HashedSecret)
+	require.Equal(t, arg.RedirectUris, client.RedirectUris)
+	require.Equal(t, arg.Scopes, client.Scopes)
+	require.NotZero(t, client.CreatedAt)
+
+	return client
+}
+
+func TestCreateOAuthClient(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	client := createRandomOAuthClient(t, createRandomUser(t))
+
+	got, err := testQueries.GetOAuthClient(context.Background(), client.ID)
+	require.NoError(t, err)
+	require.Equal(t, client.Name, got.Name)
+	require.Equal(t, client.Scopes, got.Scopes)
+}
+
+func TestUseOAuthAuthorizationCode(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	user := createRandomUser(t)
+	client := createRandomOAuthClient(t, createRandomUser(t))
+
+	arg := CreateOAuthAuthorizationCodeParams{
+		HashedCode:    util.RandomString(64),
+		ClientID:      client.ID,
+		Username:      user.Username,
+		RedirectUri:   client.RedirectUris[0],
+		Scopes:        []string{util.AccountsReadScope},
+		CodeChallenge: util.RandomString(43),
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	_, err := testQueries.CreateOAuthAuthorizationCode(context.Background(), arg)
+	require.NoError(t, err)
+
+	code, err := testQueries.UseOAuthAuthorizationCode(context.Background(), arg.HashedCode)
+	require.NoError(t, err)
+	require.True(t, code.IsUsed)
+	require.Equal(t, arg.Scopes, code.Scopes)
+
+	// codes are single use
+	_, err = testQueries.UseOAuthAuthorizationCode(context.Background(), arg.HashedCode)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	arg.HashedCode = util.RandomString(64)
+	arg.ExpiresAt = time.Now().Add(-time.Minute)
+	_, err = testQueries.CreateOAuthAuthorizationCode(context.Background(), arg)
+	require.NoError(t, err)
+
+	_, err = testQueries.UseOAuthAuthorizationCode(context.Background(), arg.HashedCode)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestOAuthConsent(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	user := createRandomUser(t)
+	client := createRandomOAuthClient(t, createRandomUser(t))
+
+	arg := UpsertOAuthConsentParams{
+		Username: user.Username,
+		ClientID: client.ID,
+		Scopes:   []string{util.AccountsReadScope},
+	}
+	consent, err := testQueries.UpsertOAuthConsent(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Scopes, consent.Scopes)
+	require.False(t, consent.RevokedAt.Valid)
+
+	// granting again keeps the original grant time
+	arg.Scopes = client.Scopes
+	consent2, err := testQueries.UpsertOAuthConsent(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, client.Scopes, consent2.Scopes)
+	require.WithinDuration(t, consent.GrantedAt, consent2.GrantedAt, time.Microsecond)
+
+	consents, err := testQueries.ListOAuthConsents(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, consents, 1)
+	require.Equal(t, client.Name, consents[0].ClientName)
+
+	revoked, err := testQueries.RevokeOAuthConsent(context.Background(), RevokeOAuthConsentParams{
+		Username: user.Username,
+		ClientID: client.ID,
+	})
+	require.NoError(t, err)
+	require.True(t, revoked.RevokedAt.Valid)
+
+	consents, err = testQueries.ListOAuthConsents(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Empty(t, consents)
+
+	_, err = testQueries.RevokeOAuthConsent(context.Background(), RevokeOAuthConsentParams{
+		Username: user.Username,
+		ClientID: client.ID,
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	// granting after a revocation starts over, invalidating the tokens issued before
+	consent3, err := testQueries.UpsertOAuthConsent(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, consent3.RevokedAt.Valid)
+	require.True(t, consent3.GrantedAt.After(consent.GrantedAt))
+
+	got, err := testQueries.GetOAuthConsent(context.Background(), GetOAuthConsentParams{
+		Username: user.Username,
+		ClientID: client.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, consent3.GrantedAt, got.GrantedAt)
+}
@@ -0,0 +1,317 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: payment.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const completePayment = `-- name: CompletePayment :one
+UPDATE payments
+SET
+  status = $1,
+  failure_reason = $2,
+  final_transfer_id = $3,
+  completed_at = now()
+WHERE id = $4 AND status = 'pending'
+RETURNING id, account_id, direction, rail, amount, currency, status, reference, failure_reason, pending_transfer_id, final_transfer_id, created_at, completed_at
+`
+
+type CompletePaymentParams struct {
+	Status          string      `json:"status"`
+	FailureReason   string      `json:"failure_reason"`
+	FinalTransferID pgtype.Int8 `json:"final_transfer_id"`
+	ID              int64       `json:"id"`
+}
+
+// only pending payments complete, so a payment settles or fails once
+func (q *Queries) CompletePayment(ctx context.Context, arg CompletePaymentParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, completePayment,
+		arg.Status,
+		arg.FailureReason,
+		arg.FinalTransferID,
+		arg.ID,
+	)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Direction,
+		&i.Rail,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.Reference,
+		&i.FailureReason,
+		&i.PendingTransferID,
+		&i.FinalTransferID,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const createPayment = `-- name: CreatePayment :one
+INSERT INTO payments (
+  account_id, direction, rail, amount, currency
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+RETURNING id, account_id, direction, rail, amount, currency, status, reference, failure_reason, pending_transfer_id, final_transfer_id, created_at, completed_at
+`
+
+type CreatePaymentParams struct {
+	AccountID int64  `json:"account_id"`
+	Direction string `json:"direction"`
+	Rail      string `json:"rail"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+}
+
+func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, createPayment,
+		arg.AccountID,
+		arg.Direction,
+		arg.Rail,
+		arg.Amount,
+		arg.Currency,
+	)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Direction,
+		&i.Rail,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.Reference,
+		&i.FailureReason,
+		&i.PendingTransferID,
+		&i.FinalTransferID,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const getPayment = `-- name: GetPayment :one
+SELECT id, account_id, direction, rail, amount, currency, status, reference, failure_reason, pending_transfer_id, final_transfer_id, created_at, completed_at FROM payments
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetPayment(ctx context.Context, id int64) (Payment, error) {
+	row := q.db.QueryRow(ctx, getPayment, id)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Direction,
+		&i.Rail,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.Reference,
+		&i.FailureReason,
+		&i.PendingTransferID,
+		&i.FinalTransferID,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const getPaymentForUpdate = `-- name: GetPaymentForUpdate :one
+SELECT id, account_id, direction, rail, amount, currency, status, reference, failure_reason, pending_transfer_id, final_transfer_id, created_at, completed_at FROM payments
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetPaymentForUpdate(ctx context.Context, id int64) (Payment, error) {
+	row := q.db.QueryRow(ctx, getPaymentForUpdate, id)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Direction,
+		&i.Rail,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.Reference,
+		&i.FailureReason,
+		&i.PendingTransferID,
+		&i.FinalTransferID,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const listPayments = `-- name: ListPayments :many
+SELECT id, account_id, direction, rail, amount, currency, status, reference, failure_reason, pending_transfer_id, final_transfer_id, created_at, completed_at FROM payments
+WHERE account_id = $1
+ORDER BY id DESC
+LIMIT $2
+OFFSET $3
+`
+
+type ListPaymentsParams struct {
+	AccountID int64 `json:"account_id"`
+	Limit     int64 `json:"limit"`
+	Offset    int64 `json:"offset"`
+}
+
+func (q *Queries) ListPayments(ctx context.Context, arg ListPaymentsParams) ([]Payment, error) {
+	rows, err := q.db.Query(ctx, listPayments, arg.AccountID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Payment{}
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Direction,
+			&i.Rail,
+			&i.Amount,
+			&i.Currency,
+			&i.Status,
+			&i.Reference,
+			&i.FailureReason,
+			&i.PendingTransferID,
+			&i.FinalTransferID,
+			&i.CreatedAt,
+			&i.CompletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingPayments = `-- name: ListPendingPayments :many
+SELECT id, account_id, direction, rail, amount, currency, status, reference, failure_reason, pending_transfer_id, final_transfer_id, created_at, completed_at FROM payments
+WHERE status = 'pending' AND id > $1 AND created_at < $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListPendingPaymentsParams struct {
+	AfterID       int64     `json:"after_id"`
+	CreatedBefore time.Time `json:"created_before"`
+	Limit         int64     `json:"limit"`
+}
+
+// the payments still pending that were created before created_before, in the order of their ids from after_id
+func (q *Queries) ListPendingPayments(ctx context.Context, arg ListPendingPaymentsParams) ([]Payment, error) {
+	rows, err := q.db.Query(ctx, listPendingPayments, arg.AfterID, arg.CreatedBefore, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Payment{}
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Direction,
+			&i.Rail,
+			&i.Amount,
+			&i.Currency,
+			&i.Status,
+			&i.Reference,
+			&i.FailureReason,
+			&i.PendingTransferID,
+			&i.FinalTransferID,
+			&i.CreatedAt,
+			&i.CompletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setPaymentPendingTransfer = `-- name: SetPaymentPendingTransfer :one
+UPDATE payments
+SET pending_transfer_id = $2
+WHERE id = $1
+RETURNING id, account_id, direction, rail, amount, currency, status, reference, failure_reason, pending_transfer_id, final_transfer_id, created_at, completed_at
+`
+
+type SetPaymentPendingTransferParams struct {
+	ID                int64       `json:"id"`
+	PendingTransferID pgtype.Int8 `json:"pending_transfer_id"`
+}
+
+func (q *Queries) SetPaymentPendingTransfer(ctx context.Context, arg SetPaymentPendingTransferParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, setPaymentPendingTransfer, arg.ID, arg.PendingTransferID)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Direction,
+		&i.Rail,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.Reference,
+		&i.FailureReason,
+		&i.PendingTransferID,
+		&i.FinalTransferID,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
+
+const setPaymentReference = `-- name: SetPaymentReference :one
+UPDATE payments
+SET reference = $2
+WHERE id = $1
+RETURNING id, account_id, direction, rail, amount, currency, status, reference, failure_reason, pending_transfer_id, final_transfer_id, created_at, completed_at
+`
+
+type SetPaymentReferenceParams struct {
+	ID        int64  `json:"id"`
+	Reference string `json:"reference"`
+}
+
+func (q *Queries) SetPaymentReference(ctx context.Context, arg SetPaymentReferenceParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, setPaymentReference, arg.ID, arg.Reference)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Direction,
+		&i.Rail,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.Reference,
+		&i.FailureReason,
+		&i.PendingTransferID,
+		&i.FinalTransferID,
+		&i.CreatedAt,
+		&i.CompletedAt,
+	)
+	return i, err
+}
@@ -6,28 +6,127 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Querier interface {
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	// counts an attempt at answering the challenge, as long as it is still open
+	AttemptLoginChallenge(ctx context.Context, arg AttemptLoginChallengeParams) (LoginChallenge, error)
+	BlockUserSessions(ctx context.Context, username string) error
+	ClaimTask(ctx context.Context, arg ClaimTaskParams) (Task, error)
+	CompleteLoginChallenge(ctx context.Context, id uuid.UUID) (LoginChallenge, error)
+	// only pending payments complete, so a payment settles or fails once
+	CompletePayment(ctx context.Context, arg CompletePaymentParams) (Payment, error)
+	CompleteTask(ctx context.Context, id int64) error
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error)
+	// the entry is linked to the hash chain of its account, the same way db.HashEntry does. The account must be
+	// locked already, e.g. by AddAccountBalance, so that its entries are chained one transaction at a time.
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	// returns no row if the account already accrued interest on that day
+	CreateInterestAccrual(ctx context.Context, arg CreateInterestAccrualParams) (InterestAccrual, error)
+	CreateInterestPayout(ctx context.Context, arg CreateInterestPayoutParams) (InterestPayout, error)
+	CreateLoginChallenge(ctx context.Context, arg CreateLoginChallengeParams) (LoginChallenge, error)
+	CreateLoginEvent(ctx context.Context, arg CreateLoginEventParams) (LoginEvent, error)
+	CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) (OauthAuthorizationCode, error)
+	CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClient, error)
+	CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error)
+	CreateResetPassword(ctx context.Context, arg CreateResetPasswordParams) (ResetPassword, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateTask(ctx context.Context, arg CreateTaskParams) (Task, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
+	CreateTransferFee(ctx context.Context, arg CreateTransferFeeParams) (TransferFee, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error)
 	DeleteAccount(ctx context.Context, id int64) error
+	DeleteRecoveryCodes(ctx context.Context, username string) error
+	DeleteTOTPSecret(ctx context.Context, username string) (TotpSecret, error)
+	EnableTOTPSecret(ctx context.Context, arg EnableTOTPSecretParams) (TotpSecret, error)
+	FailTask(ctx context.Context, arg FailTaskParams) error
+	FinishLoginEvent(ctx context.Context, arg FinishLoginEventParams) (LoginEvent, error)
+	FreezeBalanceSnapshots(ctx context.Context, snapshotDate time.Time) (int64, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (ApiKey, error)
 	GetAccount(ctx context.Context, id int64) (Account, error)
+	GetAccountByOwner(ctx context.Context, arg GetAccountByOwnerParams) (Account, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	GetAccountProduct(ctx context.Context, code string) (AccountProduct, error)
+	GetBalanceSnapshotBefore(ctx context.Context, arg GetBalanceSnapshotBeforeParams) (BalanceSnapshot, error)
+	GetClientIPLoginFailures(ctx context.Context, arg GetClientIPLoginFailuresParams) (GetClientIPLoginFailuresRow, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	GetFeeSchedule(ctx context.Context, code string) (FeeSchedule, error)
+	GetGLAccount(ctx context.Context, code string) (GlAccount, error)
+	GetLastEntry(ctx context.Context, accountID int64) (Entry, error)
+	GetLastEntryBefore(ctx context.Context, arg GetLastEntryBeforeParams) (Entry, error)
+	GetLastFrozenSnapshotDate(ctx context.Context) (time.Time, error)
+	GetLastInterestPayout(ctx context.Context, arg GetLastInterestPayoutParams) (InterestPayout, error)
+	GetOAuthClient(ctx context.Context, id uuid.UUID) (OauthClient, error)
+	GetOAuthConsent(ctx context.Context, arg GetOAuthConsentParams) (OauthConsent, error)
+	GetPayment(ctx context.Context, id int64) (Payment, error)
+	GetPaymentForUpdate(ctx context.Context, id int64) (Payment, error)
 	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
+	GetTOTPSecret(ctx context.Context, username string) (TotpSecret, error)
+	GetTask(ctx context.Context, id int64) (Task, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
+	GetTransferFee(ctx context.Context, transferID int64) (TransferFee, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	// counts failures of @kind since @since, forgetting those before the last success of the same kind or unlock
+	GetUserLoginFailures(ctx context.Context, arg GetUserLoginFailuresParams) (GetUserLoginFailuresRow, error)
+	GetUserPasswordChangedAt(ctx context.Context, username string) (time.Time, error)
+	ListAPIKeys(ctx context.Context, arg ListAPIKeysParams) ([]ApiKey, error)
+	ListAccountProducts(ctx context.Context) ([]AccountProduct, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	ListAllAccounts(ctx context.Context, arg ListAllAccountsParams) ([]Account, error)
+	// newest first; the filters left NULL match every event
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	ListEntriesAfter(ctx context.Context, arg ListEntriesAfterParams) ([]Entry, error)
+	ListFeeRules(ctx context.Context, arg ListFeeRulesParams) ([]FeeRule, error)
+	ListGLAccounts(ctx context.Context) ([]GlAccount, error)
+	// sums the balances of the accounts of each GL account, in each currency
+	ListGLBalances(ctx context.Context) ([]ListGLBalancesRow, error)
+	// lists the accounts created before day_end whose product earns interest, after after_id
+	ListInterestBearingAccounts(ctx context.Context, arg ListInterestBearingAccountsParams) ([]Account, error)
+	ListOAuthConsents(ctx context.Context, username string) ([]ListOAuthConsentsRow, error)
+	ListPayments(ctx context.Context, arg ListPaymentsParams) ([]Payment, error)
+	// the payments still pending that were created before created_before, in the order of their ids from after_id
+	ListPendingPayments(ctx context.Context, arg ListPendingPaymentsParams) ([]Payment, error)
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	// serializes the attempts on @key, a username or client IP, until the end of the transaction
+	LockLoginAttempts(ctx context.Context, key string) error
+	RetryTask(ctx context.Context, arg RetryTaskParams) error
+	RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (ApiKey, error)
+	RevokeOAuthConsent(ctx context.Context, arg RevokeOAuthConsentParams) (OauthConsent, error)
+	SetPaymentPendingTransfer(ctx context.Context, arg SetPaymentPendingTransferParams) (Payment, error)
+	SetPaymentReference(ctx context.Context, arg SetPaymentReferenceParams) (Payment, error)
+	// sums the amounts of the entries of the account created in [since, until), or since since if until is NULL
+	SumEntries(ctx context.Context, arg SumEntriesParams) (int64, error)
+	// sums the interest the account accrued in (after, until]
+	SumInterestAccruals(ctx context.Context, arg SumInterestAccrualsParams) (int64, error)
+	// takes the snapshots of the accounts that exist at the end of the day, from their current balance and the
+	// entries created since. Frozen snapshots are kept as they are.
+	TakeBalanceSnapshots(ctx context.Context, arg TakeBalanceSnapshotsParams) (int64, error)
+	// records that the key was used, at most once a minute to spare writes on busy keys
+	TouchAPIKey(ctx context.Context, id uuid.UUID) error
 	UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error)
+	UpdateResetPassword(ctx context.Context, arg UpdateResetPasswordParams) (ResetPassword, error)
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateVerifyEmail(ctx context.Context, arg UpdateVerifyEmailParams) (VerifyEmail, error)
+	// granting again keeps the tokens issued under the current consent valid, unless it had been revoked
+	UpsertOAuthConsent(ctx context.Context, arg UpsertOAuthConsentParams) (OauthConsent, error)
+	// replaces a pending secret, but never one that is already enabled
+	UpsertTOTPSecret(ctx context.Context, arg UpsertTOTPSecretParams) (TotpSecret, error)
+	// an authorization code can be exchanged only once, before it expires
+	UseOAuthAuthorizationCode(ctx context.Context, hashedCode string) (OauthAuthorizationCode, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (RecoveryCode, error)
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (TotpSecret, error)
+	VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error)
 }
 
 var _ Querier = (*Queries)(nil)
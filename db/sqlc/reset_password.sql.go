@@ -0,0 +1,69 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: reset_password.sql
+
+package db
+
+import (
+	"context"
+)
+
+const createResetPassword = `-- name: CreateResetPassword :one
+INSERT INTO reset_passwords (
+  username,
+  hashed_code
+) VALUES (
+  $1, $2
+) RETURNING id, username, hashed_code, is_used, created_at, expired_at
+`
+
+type CreateResetPasswordParams struct {
+	Username   string `json:"username"`
+	HashedCode string `json:"hashed_code"`
+}
+
+func (q *Queries) CreateResetPassword(ctx context.Context, arg CreateResetPasswordParams) (ResetPassword, error) {
+	row := q.db.QueryRow(ctx, createResetPassword, arg.Username, arg.HashedCode)
+	var i ResetPassword
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedCode,
+		&i.IsUsed,
+		&i.CreatedAt,
+		&i.ExpiredAt,
+	)
+	return i, err
+}
+
+const updateResetPassword = `-- name: UpdateResetPassword :one
+UPDATE reset_passwords
+SET
+  is_used = TRUE
+WHERE
+  id = $1
+  AND hashed_code = $2
+  AND is_used = FALSE
+  AND expired_at > now()
+RETURNING id, username, hashed_code, is_used, created_at, expired_at
+`
+
+type UpdateResetPasswordParams struct {
+	ID         int64  `json:"id"`
+	HashedCode string `json:"hashed_code"`
+}
+
+func (q *Queries) UpdateResetPassword(ctx context.Context, arg UpdateResetPasswordParams) (ResetPassword, error) {
+	row := q.db.QueryRow(ctx, updateResetPassword, arg.ID, arg.HashedCode)
+	var i ResetPassword
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedCode,
+		&i.IsUsed,
+		&i.CreatedAt,
+		&i.ExpiredAt,
+	)
+	return i, err
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// createRandomResetPassword returns a reset password along with its secret code, which is only stored hashed
+func createRandomResetPassword(t *testing.T, user User) (ResetPassword, string) {
+	secretCode := util.RandomString(32)
+	arg := CreateResetPasswordParams{
+		Username:   user.Username,
+		HashedCode: HashResetCode(secretCode),
+	}
+
+	resetPassword, err := testQueries.CreateResetPassword(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, resetPassword.ID)
+	require.Equal(t, arg.Username, resetPassword.Username)
+	require.Equal(t, arg.HashedCode, resetPassword.HashedCode)
+	require.NotEqual(t, secretCode, resetPassword.HashedCode)
+	require.False(t, resetPassword.IsUsed)
+	require.WithinDuration(t, resetPassword.CreatedAt.Add(15*time.Minute), resetPassword.ExpiredAt, time.Second)
+
+	return resetPassword, secretCode
+}
+
+func TestCreateResetPassword(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	createRandomResetPassword(t, createRandomUser(t))
+}
+
+func TestChangePasswordTx(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	session, err := testQueries.CreateSession(context.Background(), CreateSessionParams{
+		ID:           uuid.New(),
+		Username:     user.Username,
+		RefreshToken: util.RandomString(32),
+		ExpiresAt:    time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.False(t, session.IsBlocked)
+
+	hashedPassword, err := util.HashPassword(util.RandomString(8))
+	require.NoError(t, err)
+
+	result, err := store.ChangePasswordTx(context.Background(), ChangePasswordTxParams{
+		Username:       user.Username,
+		HashedPassword: hashedPassword,
+	})
+	require.NoError(t, err)
+	require.Equal(t, hashedPassword, result.User.HashedPassword)
+	require.WithinDuration(t, time.Now(), result.User.PasswordChangedAt, time.Second)
+
+	passwordChangedAt, err := testQueries.GetUserPasswordChangedAt(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.WithinDuration(t, result.User.PasswordChangedAt, passwordChangedAt, time.Microsecond)
+
+	session, err = testQueries.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, session.IsBlocked)
+}
+
+func TestResetPasswordTx(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	resetPassword, secretCode := createRandomResetPassword(t, user)
+
+	hashedPassword, err := util.HashPassword(util.RandomString(8))
+	require.NoError(t, err)
+
+	// wrong code
+	_, err = store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		ResetId:        resetPassword.ID,
+		SecretCode:     util.RandomString(32),
+		HashedPassword: hashedPassword,
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	// the stored hash is not the code
+	_, err = store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		ResetId:        resetPassword.ID,
+		SecretCode:     resetPassword.HashedCode,
+		HashedPassword: hashedPassword,
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	result, err := store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		ResetId:        resetPassword.ID,
+		SecretCode:     secretCode,
+		HashedPassword: hashedPassword,
+	})
+	require.NoError(t, err)
+	require.True(t, result.ResetPassword.IsUsed)
+	require.Equal(t, user.Username, result.User.Username)
+	require.Equal(t, hashedPassword, result.User.HashedPassword)
+
+	// codes are single use
+	_, err = store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		ResetId:        resetPassword.ID,
+		SecretCode:     secretCode,
+		HashedPassword: hashedPassword,
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
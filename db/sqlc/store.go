@@ -2,128 +2,264 @@ package db
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pakojabi/simplebank/fee"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // SQLStore includes functions to execute SQL queries and transactions. 
 // this extends the Queries object defined by sqlc, to allow more complex queries
 type SQLStore struct {
-	*Queries
-	db *sql.DB
+	txStore
+	connPool *pgxpool.Pool
+	// txOptions apply to every transaction of the store
+	txOptions []TxOption
 }
 
+// Store provides all functions to execute db queries and transactions.
+// Callers customise the transactions of the Tx methods with WithTxOptions, e.g. to make them SERIALIZABLE.
 type Store interface {
 	Querier
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	QuoteTransferFee(ctx context.Context, arg QuoteTransferFeeParams) (fee.Breakdown, error)
+	CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error)
+	UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error)
+	EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (EnableTOTPTxResult, error)
+	DisableTOTPTx(ctx context.Context, username string) error
+	VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error)
+	ChangePasswordTx(ctx context.Context, arg ChangePasswordTxParams) (ChangePasswordTxResult, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error)
+	LoginAttemptTx(ctx context.Context, arg LoginAttemptTxParams) (LoginAttemptTxResult, error)
+	GetBalanceAsOf(ctx context.Context, arg GetBalanceAsOfParams) (GetBalanceAsOfResult, error)
+	EndOfDayTx(ctx context.Context, arg EndOfDayTxParams) (EndOfDayTxResult, error)
+	AccrueInterestTx(ctx context.Context, arg AccrueInterestTxParams) (AccrueInterestTxResult, error)
+	GetTrialBalance(ctx context.Context) (TrialBalance, error)
+	CreatePaymentTx(ctx context.Context, arg CreatePaymentTxParams) (PaymentTxResult, error)
+	CompletePaymentTx(ctx context.Context, arg CompletePaymentTxParams) (PaymentTxResult, error)
+	// WatchAccount streams the events committed on an account until ctx is done.
+	// The channel is also closed if the caller falls too far behind. Only the events committed through this
+	// store are seen, so watching requires a single replica of the server.
+	WatchAccount(ctx context.Context, accountID int64) <-chan AccountEvent
+}
+
+// NewStore creates a new store. opts apply to all its transactions, e.g. MaxRetries.
+func NewStore(connPool *pgxpool.Pool, opts ...TxOption) Store {
+	store := &SQLStore{
+		connPool:  connPool,
+		txOptions: opts,
+	}
+	store.txStore = txStore{
+		Querier: New(newTracedDBTX(connPool, nil)),
+		execTx:  store.execTx,
+		broker:  newAccountBroker(),
+	}
+	return store
+}
+
+// txStore implements the Tx methods of Store on top of execTx, so every Store runs the same transactions.
+// It runs the other queries with Querier, except those recorded in the audit log: see audited.go.
+type txStore struct {
+	Querier
+	// execTx runs fn in a transaction, with the queries of the transaction
+	execTx func(ctx context.Context, fn func(Querier) error, opts ...TxOption) error
+	broker *accountBroker
+}
+
+// WatchAccount implements Store.
+func (store *txStore) WatchAccount(ctx context.Context, accountID int64) <-chan AccountEvent {
+	return store.broker.watch(ctx, accountID)
 }
 
-// NewStore creates a new store
-func NewStore(db *sql.DB) Store {
-	return &SQLStore{
-		db:      db,
-		Queries: New(db),
+// execTx executes a function within a database transaction.
+// Transactions aborted by a deadlock or a serialization failure are retried after a jittered backoff, so fn
+// runs again from scratch: it must only have side effects through q, and reset whatever it computes.
+func (store *SQLStore) execTx(ctx context.Context, fn func(Querier) error, opts ...TxOption) error {
+	options := txOptionsFrom(ctx, store.txOptions, opts)
+
+	for retry := 0; ; retry++ {
+		err := store.runTx(ctx, options, retry, fn)
+		if err == nil || retry >= options.maxRetries || TxConflict(err) == "" {
+			return err
+		}
+
+		if options.onRetry != nil {
+			options.onRetry(ctx, err)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryBackoff(retry + 1)):
+		}
 	}
 }
 
-// execTx executes a function within a database transaction
-func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
-	tx, err := store.db.BeginTx(ctx, nil)
+// runTx runs a single attempt of a transaction
+func (store *SQLStore) runTx(ctx context.Context, options txOptions, retry int, fn func(Querier) error) (err error) {
+	ctx, span := startTxSpan(ctx)
+	defer func() {
+		span.SetAttributes(txAttributes(err), attribute.Int("db.tx.retry", retry))
+		recordError(span, err)
+		span.End()
+	}()
+
+	tx, err := store.connPool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   options.isoLevel,
+		AccessMode: options.accessMode,
+	})
 	if err != nil {
 		return err
 	}
 
-	q := New(tx)
+	// fn runs its queries with the caller's context, so they are parented to the tx span explicitly
+	q := New(newTracedDBTX(tx, span))
 	err = fn(q)
 	if err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
-			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			return fmt.Errorf("tx err: %w, rb err: %v", err, rbErr)
 		}
 		return err
 	}
 
-	return tx.Commit()
+	return tx.Commit(ctx)
 }
 
 type TransferTxParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
 	Amount        int64 `json:"amount"`
+	// FeeSchedule charges the sender the fee it sets for the transfer, on top of the amount, if not empty
+	FeeSchedule string `json:"fee_schedule,omitempty"`
+	// paymentID links the entries of the transfer to the payment that moves the money, if any
+	paymentID pgtype.Int8
 }
 
 type TransferTxResult struct {
 	Transfer    Transfer `json:"transfer"`
+	// FromAccount is the sender once the fee is charged, if any
 	FromAccount Account  `json:"from_account"`
 	ToAccount   Account  `json:"to_account"`
 	FromEntry   Entry    `json:"from_entry"`
 	ToEntry     Entry    `json:"to_entry"`
+	// Fee is set when the transfer has a fee schedule
+	Fee *FeeCharge `json:"fee,omitempty"`
 }
 
-// TransferTx executes a transfer within a transaction.
-func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
+// TransferTx executes a transfer within a transaction, and charges its fee, if any, in the same transaction.
+func (store *txStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
 	var result TransferTxResult
+	var events []AccountEvent
 
-	err := store.execTx(ctx, func(q *Queries) error {
-	
+	err := store.execTx(ctx, func(q Querier) error {
 		var err error
-
-		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
-			FromAccountID: arg.FromAccountID,
-			ToAccountID:  arg.ToAccountID,
-			Amount: arg.Amount,
-		})
+		result, err = transfer(ctx, q, arg)
 		if err != nil {
 			return err
 		}
+		events = []AccountEvent{
+			{Account: result.FromAccount, Entry: result.FromEntry},
+			{Account: result.ToAccount, Entry: result.ToEntry},
+		}
 
-		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount: -arg.Amount,
-		})
-		if err != nil {
-			return err
+		if arg.FeeSchedule != "" {
+			if err = chargeFee(ctx, q, arg.FeeSchedule, &result); err != nil {
+				return err
+			}
+			if charged := result.Fee.Transfer; charged != nil {
+				result.FromAccount = charged.FromAccount
+				events = append(events,
+					AccountEvent{Account: charged.FromAccount, Entry: charged.FromEntry},
+					AccountEvent{Account: charged.ToAccount, Entry: charged.ToEntry},
+				)
+			}
 		}
 
-		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount: arg.Amount,
+		return recordAudit(ctx, q, auditChange{
+			action:       AuditTransferCreate,
+			resourceType: AuditResourceTransfer,
+			resourceID:   strconv.FormatInt(result.Transfer.ID, 10),
+			after:        result,
 		})
-		if err != nil {
-			return err
-		}
+	})
+	if err != nil {
+		return result, err
+	}
 
-		// if we did not have AddAccountBalance
-		// account1, err := q.GetAccountForUpdate(ctx, arg.FromAccountID) // blocks selects until transactions are complete.
-		// if err != nil {
-		// 	return err
-		// }
-
-		// result.FromAccount, err = q.UpdateAccount(ctx, UpdateAccountParams{
-		// 	ID: arg.FromAccountID,
-		// 	Balance: account1.Balance - arg.Amount,
-		// })
-		// if err != nil {
-		// 	return err
-		// }
-		
-		if arg.FromAccountID < arg.ToAccountID {
-			result.FromAccount, result.ToAccount, err = addMoney(ctx, q, arg.FromAccountID, arg.ToAccountID, -arg.Amount, arg.Amount)
-		} else {
-			result.ToAccount, result.FromAccount, err = addMoney(ctx, q, arg.ToAccountID, arg.FromAccountID, arg.Amount, -arg.Amount)
-		}
-		if err != nil {
-			return err
-		}
+	// only notify watchers once the transaction is committed
+	store.broker.publish(events...)
+
+	return result, nil
+}
 
-		return nil
+// transfer moves money between two accounts with q, so it is part of the transaction of its caller
+func transfer(ctx context.Context, q Querier, arg TransferTxParams) (TransferTxResult, error) {
+	var result TransferTxResult
+	var err error
+
+	result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:  arg.ToAccountID,
+		Amount: arg.Amount,
 	})
+	if err != nil {
+		return result, err
+	}
 
+	// if we did not have AddAccountBalance
+	// account1, err := q.GetAccountForUpdate(ctx, arg.FromAccountID) // blocks selects until transactions are complete.
+	// if err != nil {
+	// 	return err
+	// }
+
+	// result.FromAccount, err = q.UpdateAccount(ctx, UpdateAccountParams{
+	// 	ID: arg.FromAccountID,
+	// 	Balance: account1.Balance - arg.Amount,
+	// })
+	// if err != nil {
+	// 	return err
+	// }
+	
+	if arg.FromAccountID < arg.ToAccountID {
+		result.FromAccount, result.ToAccount, err = addMoney(ctx, q, arg.FromAccountID, arg.ToAccountID, -arg.Amount, arg.Amount)
+	} else {
+		result.ToAccount, result.FromAccount, err = addMoney(ctx, q, arg.ToAccountID, arg.FromAccountID, arg.Amount, -arg.Amount)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	// the balance is checked once addMoney holds the lock of the sender, so concurrent transfers cannot overdraw
+	// it, and the whole transaction rolls back when they would. Only the accounts of customers must stay in funds.
+	if result.FromAccount.GlCode == GLCustomerDeposits && result.FromAccount.Balance < 0 {
+		return result, ErrInsufficientFunds.With("account_id", strconv.FormatInt(result.FromAccount.ID, 10))
+	}
+
+	// the entries are chained once addMoney holds the locks of both accounts
+	result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID: arg.FromAccountID,
+		Amount: -arg.Amount,
+		PaymentID: arg.paymentID,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID: arg.ToAccountID,
+		Amount: arg.Amount,
+		PaymentID: arg.paymentID,
+	})
 	return result, err
 }
 
 func addMoney(
 	ctx context.Context,
-	q *Queries,
+	q Querier,
 	accountId1, accountId2 int64,
 	amount1, amount2 int64,
 ) (account1, account2 Account, err error) {
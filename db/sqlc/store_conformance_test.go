@@ -0,0 +1,963 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// missingID is never given to a row: bigserial ids start at 1
+const missingID = -1
+
+func TestMemStoreConformance(t *testing.T) {
+	testStoreConformance(t, NewMemStore())
+}
+
+func TestSQLStoreConformance(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	testStoreConformance(t, NewStore(testDB))
+}
+
+// testStoreConformance checks the semantics the rest of the code relies on, so that every Store can stand in for
+// another: defaults, constraints and their errors, ErrRecordNotFound, and atomic transactions
+func testStoreConformance(t *testing.T, store Store) {
+	t.Run("Users", func(t *testing.T) { testConformanceUsers(t, store) })
+	t.Run("Accounts", func(t *testing.T) { testConformanceAccounts(t, store) })
+	t.Run("ForeignKeys", func(t *testing.T) { testConformanceForeignKeys(t, store) })
+	t.Run("ConditionalUpdates", func(t *testing.T) { testConformanceConditionalUpdates(t, store) })
+	t.Run("Tasks", func(t *testing.T) { testConformanceTasks(t, store) })
+	t.Run("TransferTx", func(t *testing.T) { testConformanceTransferTx(t, store) })
+	t.Run("InsufficientFunds", func(t *testing.T) { testConformanceInsufficientFunds(t, store) })
+	t.Run("LoginAttempts", func(t *testing.T) { testConformanceLoginAttempts(t, store) })
+	t.Run("Rollback", func(t *testing.T) { testConformanceRollback(t, store) })
+	t.Run("Audit", func(t *testing.T) { testConformanceAudit(t, store) })
+	t.Run("BalanceAsOf", func(t *testing.T) { testConformanceBalanceAsOf(t, store) })
+	t.Run("Interest", func(t *testing.T) { testConformanceInterest(t, store) })
+	t.Run("Fees", func(t *testing.T) { testConformanceFees(t, store) })
+	t.Run("ChartOfAccounts", func(t *testing.T) { testConformanceChartOfAccounts(t, store) })
+	t.Run("Payments", func(t *testing.T) { testConformancePayments(t, store) })
+}
+
+func conformanceUser(t *testing.T, store Store) User {
+	username := util.RandomOwner()
+	user, err := store.CreateUser(context.Background(), CreateUserParams{
+		Username:       username,
+		HashedPassword: util.RandomString(32),
+		FullName:       util.RandomOwner(),
+		Email:          username + "@" + util.RandomString(6) + ".com",
+	})
+	require.NoError(t, err)
+	return user
+}
+
+func conformanceAccount(t *testing.T, store Store, owner User, currency string, balance int64) Account {
+	account, err := store.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    owner.Username,
+		Balance:  balance,
+		Currency: currency,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func testConformanceUsers(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	user := conformanceUser(t, store)
+	require.Equal(t, "depositor", user.Role)
+	require.True(t, user.PasswordChangedAt.IsZero())
+	require.False(t, user.IsEmailVerified)
+	require.WithinDuration(t, time.Now(), user.CreatedAt, time.Minute)
+
+	_, err := store.CreateUser(ctx, CreateUserParams{Username: user.Username, Email: util.RandomString(10)})
+	require.True(t, IsUniqueViolation(err))
+
+	_, err = store.CreateUser(ctx, CreateUserParams{Username: util.RandomOwner(), Email: user.Email})
+	require.True(t, IsUniqueViolation(err))
+
+	_, err = store.GetUser(ctx, util.RandomOwner())
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	_, err = store.GetUserByEmail(ctx, util.RandomString(10))
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	user, err = store.VerifyUserEmail(ctx, VerifyUserEmailParams{Username: user.Username, Email: user.Email})
+	require.NoError(t, err)
+	require.True(t, user.IsEmailVerified)
+
+	// a new email has to be verified again, and cannot be another user's
+	other := conformanceUser(t, store)
+	_, err = store.UpdateUser(ctx, UpdateUserParams{Username: user.Username, Email: pgtype.Text{String: other.Email, Valid: true}})
+	require.True(t, IsUniqueViolation(err))
+
+	updated, err := store.UpdateUser(ctx, UpdateUserParams{Username: user.Username, Email: pgtype.Text{String: util.RandomString(10), Valid: true}})
+	require.NoError(t, err)
+	require.False(t, updated.IsEmailVerified)
+	require.Equal(t, user.FullName, updated.FullName)
+
+	_, err = store.UpdateUser(ctx, UpdateUserParams{Username: util.RandomOwner(), FullName: pgtype.Text{String: "nobody", Valid: true}})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func testConformanceAccounts(t *testing.T, store Store) {
+	ctx := context.Background()
+	owner := conformanceUser(t, store)
+
+	usd := conformanceAccount(t, store, owner, util.USD, 100)
+	eur := conformanceAccount(t, store, owner, util.EUR, 0)
+
+	_, err := store.CreateAccount(ctx, CreateAccountParams{Owner: owner.Username, Currency: util.USD})
+	require.True(t, IsUniqueViolation(err))
+
+	got, err := store.GetAccount(ctx, usd.ID)
+	require.NoError(t, err)
+	require.Equal(t, usd, got)
+
+	_, err = store.GetAccount(ctx, missingID)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	_, err = store.AddAccountBalance(ctx, AddAccountBalanceParams{ID: missingID, Amount: 10})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	accounts, err := store.ListAccounts(ctx, ListAccountsParams{Owner: owner.Username, Limit: 5})
+	require.NoError(t, err)
+	require.Equal(t, []Account{usd, eur}, accounts)
+
+	accounts, err = store.ListAccounts(ctx, ListAccountsParams{Owner: owner.Username, Limit: 5, Offset: 1})
+	require.NoError(t, err)
+	require.Equal(t, []Account{eur}, accounts)
+
+	accounts, err = store.ListAccounts(ctx, ListAccountsParams{Owner: owner.Username, Limit: 5, Offset: 2})
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+
+	// an account with entries cannot be deleted, an unused one can. Every entry is linked to the hash chain of
+	// its account, even one created outside of a transfer.
+	first, err := store.CreateEntry(ctx, CreateEntryParams{AccountID: usd.ID, Amount: 10})
+	require.NoError(t, err)
+	require.Equal(t, HashEntry(nil, first), first.Hash)
+	second, err := store.CreateEntry(ctx, CreateEntryParams{AccountID: usd.ID, Amount: -5})
+	require.NoError(t, err)
+	require.Equal(t, HashEntry(first.Hash, second), second.Hash)
+	require.True(t, IsForeignKeyViolation(store.DeleteAccount(ctx, usd.ID)))
+
+	require.NoError(t, store.DeleteAccount(ctx, eur.ID))
+	_, err = store.GetAccount(ctx, eur.ID)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func testConformanceForeignKeys(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	_, err := store.CreateAccount(ctx, CreateAccountParams{Owner: util.RandomOwner(), Currency: util.USD})
+	require.True(t, IsForeignKeyViolation(err))
+
+	_, err = store.CreateEntry(ctx, CreateEntryParams{AccountID: missingID, Amount: 10})
+	require.True(t, IsForeignKeyViolation(err))
+
+	owner := conformanceUser(t, store)
+	account := conformanceAccount(t, store, owner, util.USD, 0)
+	_, err = store.CreateTransfer(ctx, CreateTransferParams{FromAccountID: account.ID, ToAccountID: missingID, Amount: 10})
+	require.True(t, IsForeignKeyViolation(err))
+
+	_, err = store.CreateVerifyEmail(ctx, CreateVerifyEmailParams{Username: util.RandomOwner(), Email: util.RandomString(10)})
+	require.True(t, IsForeignKeyViolation(err))
+
+	_, err = store.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{Username: util.RandomOwner(), HashedCode: util.RandomString(10)})
+	require.True(t, IsForeignKeyViolation(err))
+}
+
+func testConformanceConditionalUpdates(t *testing.T, store Store) {
+	ctx := context.Background()
+	user := conformanceUser(t, store)
+
+	verifyEmail, err := store.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+		Username:   user.Username,
+		Email:      user.Email,
+		SecretCode: util.RandomString(32),
+	})
+	require.NoError(t, err)
+	require.WithinDuration(t, verifyEmail.CreatedAt.Add(15*time.Minute), verifyEmail.ExpiredAt, time.Second)
+
+	arg := UpdateVerifyEmailParams{ID: verifyEmail.ID, SecretCode: verifyEmail.SecretCode}
+	verifyEmail, err = store.UpdateVerifyEmail(ctx, arg)
+	require.NoError(t, err)
+	require.True(t, verifyEmail.IsUsed)
+
+	// a secret code is only good once
+	_, err = store.UpdateVerifyEmail(ctx, arg)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	_, err = store.UpsertTOTPSecret(ctx, UpsertTOTPSecretParams{Username: user.Username, EncryptedSecret: []byte(util.RandomString(16))})
+	require.NoError(t, err)
+	_, err = store.EnableTOTPSecret(ctx, EnableTOTPSecretParams{Username: user.Username, Step: 10})
+	require.NoError(t, err)
+
+	// an enabled secret is never replaced, and a step is never replayed
+	_, err = store.UpsertTOTPSecret(ctx, UpsertTOTPSecretParams{Username: user.Username, EncryptedSecret: []byte(util.RandomString(16))})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	_, err = store.UseTOTPStep(ctx, UseTOTPStepParams{Username: user.Username, Step: 10})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	secret, err := store.UseTOTPStep(ctx, UseTOTPStepParams{Username: user.Username, Step: 11})
+	require.NoError(t, err)
+	require.Equal(t, int64(11), secret.LastUsedStep)
+
+	// disabling removes the secret, so that the user can enroll again, but only once
+	require.NoError(t, store.DisableTOTPTx(ctx, user.Username))
+	_, err = store.GetTOTPSecret(ctx, user.Username)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+	require.ErrorIs(t, store.DisableTOTPTx(ctx, user.Username), ErrRecordNotFound)
+
+	_, err = store.UpsertTOTPSecret(ctx, UpsertTOTPSecretParams{Username: user.Username, EncryptedSecret: []byte(util.RandomString(16))})
+	require.NoError(t, err)
+	// a pending secret is not enabled, so there is nothing to disable yet
+	require.ErrorIs(t, store.DisableTOTPTx(ctx, user.Username), ErrRecordNotFound)
+}
+
+func testConformanceTasks(t *testing.T, store Store) {
+	ctx := context.Background()
+	queue := util.RandomString(10)
+
+	_, err := store.ClaimTask(ctx, ClaimTaskParams{Queue: queue, LockedUntil: time.Now().Add(time.Minute)})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	later, err := store.CreateTask(ctx, CreateTaskParams{Queue: queue, Type: "test", Payload: []byte("{}"), MaxAttempts: 3, RunAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	require.Equal(t, "pending", later.Status)
+
+	earlier, err := store.CreateTask(ctx, CreateTaskParams{Queue: queue, Type: "test", Payload: []byte("{}"), MaxAttempts: 3, RunAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+
+	_, err = store.CreateTask(ctx, CreateTaskParams{Queue: queue, Type: "test", Payload: []byte("{}"), MaxAttempts: 3, RunAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	lockedUntil := time.Now().Add(time.Minute)
+	for _, want := range []Task{earlier, later} {
+		task, err := store.ClaimTask(ctx, ClaimTaskParams{Queue: queue, LockedUntil: lockedUntil})
+		require.NoError(t, err)
+		require.Equal(t, want.ID, task.ID)
+		require.Equal(t, int32(1), task.Attempts)
+		require.WithinDuration(t, lockedUntil, task.RunAt, time.Millisecond)
+	}
+
+	// the last task is not due yet
+	_, err = store.ClaimTask(ctx, ClaimTaskParams{Queue: queue, LockedUntil: lockedUntil})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+// testConformanceTransferTx runs transfers in both directions at once, and checks the balances add up
+func testConformanceTransferTx(t *testing.T, store Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	account1 := conformanceAccount(t, store, conformanceUser(t, store), util.USD, 1000)
+	account2 := conformanceAccount(t, store, conformanceUser(t, store), util.USD, 1000)
+	events := store.WatchAccount(ctx, account1.ID)
+
+	n := 10
+	amount := int64(10)
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		from, to := account1.ID, account2.ID
+		if i%2 == 1 {
+			from, to = to, from
+		}
+		go func() {
+			_, err := store.TransferTx(ctx, TransferTxParams{FromAccountID: from, ToAccountID: to, Amount: amount})
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	updated1, err := store.GetAccount(ctx, account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updated1.Balance)
+
+	entries, err := store.ListEntries(ctx, ListEntriesParams{AccountID: account1.ID, Limit: int64(n + 1)})
+	require.NoError(t, err)
+	require.Len(t, entries, n)
+
+	// concurrent transfers still chain the entries of an account one after the other
+	var prevHash []byte
+	for _, entry := range entries {
+		require.Equal(t, HashEntry(prevHash, entry), entry.Hash)
+		prevHash = entry.Hash
+	}
+
+	transfers, err := store.ListTransfers(ctx, ListTransfersParams{FromAccountID: account1.ID, ToAccountID: account1.ID, Limit: int64(n + 1)})
+	require.NoError(t, err)
+	require.Len(t, transfers, n)
+
+	// watchers see every committed change to the account
+	for i := 0; i < n; i++ {
+		event := <-events
+		require.Equal(t, account1.ID, event.Account.ID)
+		require.Equal(t, account1.ID, event.Entry.AccountID)
+	}
+}
+
+// testConformanceInsufficientFunds checks concurrent transfers never overdraw an account: the balance is checked
+// under the lock of the account, and the transfers that would overdraw it roll back
+func testConformanceInsufficientFunds(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	account1 := conformanceAccount(t, store, conformanceUser(t, store), util.USD, 100)
+	account2 := conformanceAccount(t, store, conformanceUser(t, store), util.USD, 0)
+
+	n := 10
+	amount := int64(30)
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.TransferTx(ctx, TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: amount})
+			errs <- err
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.ErrorIs(t, err, ErrInsufficientFunds)
+	}
+	require.Equal(t, 3, succeeded)
+
+	updated1, err := store.GetAccount(ctx, account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-int64(succeeded)*amount, updated1.Balance)
+	require.GreaterOrEqual(t, updated1.Balance, int64(0))
+
+	// the transfers that rolled back left no entries behind
+	entries, err := store.ListEntries(ctx, ListEntriesParams{AccountID: account1.ID, Limit: int64(n)})
+	require.NoError(t, err)
+	require.Len(t, entries, succeeded)
+}
+
+// testConformanceLoginAttempts checks concurrent attempts each count the failures recorded before them
+func testConformanceLoginAttempts(t *testing.T, store Store) {
+	ctx := context.Background()
+	username := util.RandomOwner()
+	maxFailures := int64(3)
+
+	n := 10
+	results := make(chan LoginAttemptTxResult)
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			result, err := store.LoginAttemptTx(ctx, LoginAttemptTxParams{
+				CreateLoginEventParams: CreateLoginEventParams{
+					Username: username,
+					ClientIp: "10.0.0.1",
+					Kind:     LoginKindLogin,
+				},
+				BeforeCreate: func(q Querier) (string, error) {
+					failures, err := q.GetUserLoginFailures(ctx, GetUserLoginFailuresParams{
+						Username: username,
+						Kind:     LoginKindLogin,
+						Since:    time.Now().Add(-time.Hour),
+					})
+					if err != nil {
+						return "", err
+					}
+					if failures.Failures >= maxFailures {
+						return LoginOutcomeLocked, nil
+					}
+					return LoginOutcomeFailure, nil
+				},
+			})
+			errs <- err
+			results <- result
+		}()
+	}
+
+	var failed []LoginEvent
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+		result := <-results
+		require.Equal(t, username, result.LoginEvent.Username)
+		if result.LoginEvent.Outcome == LoginOutcomeFailure {
+			failed = append(failed, result.LoginEvent)
+		} else {
+			require.Equal(t, LoginOutcomeLocked, result.LoginEvent.Outcome)
+		}
+	}
+	require.Len(t, failed, int(maxFailures))
+
+	// finishing the last failed attempt as a success forgets the failures before it
+	last := failed[0]
+	for _, event := range failed {
+		if !event.CreatedAt.Before(last.CreatedAt) {
+			last = event
+		}
+	}
+	finished, err := store.FinishLoginEvent(ctx, FinishLoginEventParams{ID: last.ID, Username: username, Outcome: LoginOutcomeSuccess})
+	require.NoError(t, err)
+	require.Equal(t, LoginOutcomeSuccess, finished.Outcome)
+
+	failures, err := store.GetUserLoginFailures(ctx, GetUserLoginFailuresParams{Username: username, Kind: LoginKindLogin, Since: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	require.Zero(t, failures.Failures)
+
+	_, err = store.FinishLoginEvent(ctx, FinishLoginEventParams{ID: last.ID + 1_000_000, Outcome: LoginOutcomeSuccess})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+// testConformanceRollback checks a failed transaction leaves nothing behind
+func testConformanceRollback(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	account := conformanceAccount(t, store, conformanceUser(t, store), util.USD, 100)
+	_, err := store.TransferTx(ctx, TransferTxParams{FromAccountID: account.ID, ToAccountID: missingID, Amount: 10})
+	require.True(t, IsForeignKeyViolation(err))
+
+	got, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance, got.Balance)
+
+	transfers, err := store.ListTransfers(ctx, ListTransfersParams{FromAccountID: account.ID, ToAccountID: account.ID, Limit: 5})
+	require.NoError(t, err)
+	require.Empty(t, transfers)
+
+	username := util.RandomOwner()
+	errAfterCreate := errors.New("after create")
+	_, err = store.CreateUserTx(ctx, CreateUserTxParams{
+		CreateUserParams: CreateUserParams{Username: username, Email: username + "@example.com"},
+		AfterCreate: func(q Querier, user User) error {
+			_, err := q.CreateAccount(ctx, CreateAccountParams{Owner: user.Username, Currency: util.USD})
+			require.NoError(t, err)
+			return errAfterCreate
+		},
+	})
+	require.ErrorIs(t, err, errAfterCreate)
+
+	_, err = store.GetUser(ctx, username)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	accounts, err := store.ListAccounts(ctx, ListAccountsParams{Owner: username, Limit: 5})
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+}
+
+// testConformanceAudit checks changes are recorded in the audit log along with their actor, and only if they commit
+func testConformanceAudit(t *testing.T, store Store) {
+	actor := AuditActor{
+		Username:  util.RandomOwner(),
+		Role:      util.DepositorRole,
+		ClientIP:  "10.0.0.1",
+		UserAgent: "conformance",
+	}
+	ctx := WithAuditActor(context.Background(), actor)
+
+	listEvents := func(arg ListAuditEventsParams) []AuditEvent {
+		arg.Actor = pgtype.Text{String: actor.Username, Valid: true}
+		arg.Limit = 10
+		events, err := store.ListAuditEvents(ctx, arg)
+		require.NoError(t, err)
+		return events
+	}
+
+	owner := conformanceUser(t, store)
+	account, err := store.CreateAccount(ctx, CreateAccountParams{Owner: owner.Username, Currency: util.USD})
+	require.NoError(t, err)
+	_, err = store.UpdateAccount(ctx, UpdateAccountParams{ID: account.ID, Balance: 50})
+	require.NoError(t, err)
+
+	events := listEvents(ListAuditEventsParams{
+		ResourceType: pgtype.Text{String: AuditResourceAccount, Valid: true},
+		ResourceID:   pgtype.Text{String: strconv.FormatInt(account.ID, 10), Valid: true},
+	})
+	require.Len(t, events, 2)
+
+	// newest first
+	update, create := events[0], events[1]
+	require.Equal(t, AuditAccountUpdate, update.Action)
+	require.Equal(t, actor.Role, update.ActorRole)
+	require.Equal(t, actor.ClientIP, update.ClientIp)
+	require.Equal(t, actor.UserAgent, update.UserAgent)
+	require.JSONEq(t, `0`, jsonField(t, update.Before, "balance"))
+	require.JSONEq(t, `50`, jsonField(t, update.After, "balance"))
+
+	require.Equal(t, AuditAccountCreate, create.Action)
+	require.Nil(t, create.Before)
+	require.NotNil(t, create.After)
+
+	// a failed transfer leaves no event behind
+	_, err = store.TransferTx(ctx, TransferTxParams{FromAccountID: account.ID, ToAccountID: missingID, Amount: 10})
+	require.Error(t, err)
+	require.Empty(t, listEvents(ListAuditEventsParams{Action: pgtype.Text{String: AuditTransferCreate, Valid: true}}))
+
+	// secrets are kept out of the log
+	_, err = store.ChangePasswordTx(ctx, ChangePasswordTxParams{Username: owner.Username, HashedPassword: util.RandomString(32)})
+	require.NoError(t, err)
+
+	events = listEvents(ListAuditEventsParams{
+		ResourceType: pgtype.Text{String: AuditResourceUser, Valid: true},
+		ResourceID:   pgtype.Text{String: owner.Username, Valid: true},
+	})
+	require.Len(t, events, 2)
+	require.Equal(t, AuditSessionBlock, events[0].Action)
+	require.Equal(t, AuditUserPassword, events[1].Action)
+	require.NotContains(t, string(events[1].After), owner.HashedPassword)
+	require.NotContains(t, string(events[1].After), "hashed_password")
+}
+
+func jsonField(t *testing.T, state []byte, field string) string {
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(state, &fields))
+	return string(fields[field])
+}
+
+func testConformanceBalanceAsOf(t *testing.T, store Store) {
+	ctx := context.Background()
+	account := conformanceAccount(t, store, conformanceUser(t, store), util.USD, 100)
+	other := conformanceAccount(t, store, conformanceUser(t, store), util.USD, 100)
+
+	balanceAsOf := func(asOf time.Time) int64 {
+		result, err := store.GetBalanceAsOf(ctx, GetBalanceAsOfParams{AccountID: account.ID, AsOf: asOf})
+		require.NoError(t, err)
+		require.Equal(t, account.ID, result.Account.ID)
+		return result.Balance
+	}
+
+	created := time.Now().Truncate(time.Microsecond)
+	_, err := store.TransferTx(ctx, TransferTxParams{FromAccountID: other.ID, ToAccountID: account.ID, Amount: 30})
+	require.NoError(t, err)
+	afterFirst := time.Now().Truncate(time.Microsecond)
+	_, err = store.TransferTx(ctx, TransferTxParams{FromAccountID: account.ID, ToAccountID: other.ID, Amount: 10})
+	require.NoError(t, err)
+
+	// without snapshots, the entries made since are taken back from the current balance
+	require.Equal(t, int64(100), balanceAsOf(created))
+	require.Equal(t, int64(130), balanceAsOf(afterFirst))
+	require.Equal(t, int64(120), balanceAsOf(time.Now()))
+
+	_, err = store.GetBalanceAsOf(ctx, GetBalanceAsOfParams{AccountID: missingID, AsOf: created})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	result, err := store.EndOfDayTx(ctx, EndOfDayTxParams{Day: time.Now()})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, result.Snapshots, int64(2))
+	require.Equal(t, result.Snapshots, result.Frozen)
+
+	// a balance changed without entries is only seen by the snapshots taken after the change
+	_, err = store.UpdateAccount(ctx, UpdateAccountParams{ID: account.ID, Balance: 1000})
+	require.NoError(t, err)
+	tomorrow := SnapshotDate(time.Now()).AddDate(0, 0, 1)
+	require.Equal(t, int64(120), balanceAsOf(tomorrow.Add(time.Hour)))
+	require.Equal(t, int64(1000), balanceAsOf(time.Now()))
+
+	// frozen snapshots are not taken again
+	result, err = store.EndOfDayTx(ctx, EndOfDayTxParams{Day: time.Now()})
+	require.NoError(t, err)
+	require.Zero(t, result.Frozen)
+	require.Equal(t, int64(120), balanceAsOf(tomorrow.Add(time.Hour)))
+
+	lastClosed, err := store.GetLastFrozenSnapshotDate(ctx)
+	require.NoError(t, err)
+	require.True(t, SnapshotDate(time.Now()).Equal(lastClosed))
+}
+
+func testConformanceInterest(t *testing.T, store Store) {
+	ctx := context.Background()
+	owner := conformanceUser(t, store)
+
+	// 3650.00 at 2% earns 0.20 a day, 1.00 earns 5479 millionths of a cent
+	savings, err := store.CreateAccount(ctx, CreateAccountParams{
+		Owner:    owner.Username,
+		Balance:  365_000,
+		Currency: util.USD,
+		Product:  pgtype.Text{String: "savings", Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "savings", savings.Product)
+
+	small, err := store.CreateAccount(ctx, CreateAccountParams{
+		Owner:    owner.Username,
+		Balance:  100,
+		Currency: util.EUR,
+		Product:  pgtype.Text{String: "savings", Valid: true},
+	})
+	require.NoError(t, err)
+
+	checking := conformanceAccount(t, store, owner, util.CAD, 0)
+	require.Equal(t, "checking", checking.Product)
+
+	_, err = store.CreateAccount(ctx, CreateAccountParams{
+		Owner:    conformanceUser(t, store).Username,
+		Currency: util.USD,
+		Product:  pgtype.Text{String: "gold", Valid: true},
+	})
+	require.True(t, IsForeignKeyViolation(err))
+
+	accrue := func(account Account, day time.Time) AccrueInterestTxResult {
+		result, err := store.AccrueInterestTx(ctx, AccrueInterestTxParams{AccountID: account.ID, Day: day})
+		require.NoError(t, err)
+		return result
+	}
+
+	// the monthly payout pays what accrued since the last one
+	result := accrue(savings, time.Date(2024, time.January, 30, 0, 0, 0, 0, time.UTC))
+	require.True(t, result.Accrued)
+	require.Equal(t, int64(365_000), result.Accrual.Balance)
+	require.Equal(t, int64(20_000_000), result.Accrual.AmountMicros)
+	require.Nil(t, result.Payout)
+
+	result = accrue(savings, time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC))
+	require.True(t, result.Accrued)
+	require.NotNil(t, result.Payout)
+	require.Equal(t, int64(40), result.Payout.Amount)
+	require.Zero(t, result.Payout.CarryMicros)
+	require.NotNil(t, result.Transfer)
+	require.Equal(t, result.Transfer.Transfer.ID, result.Payout.TransferID.Int64)
+	require.Equal(t, int64(365_040), result.Transfer.ToAccount.Balance)
+	require.Equal(t, SystemUsername(SystemInterestExpense), result.Transfer.FromAccount.Owner)
+	require.Equal(t, util.USD, result.Transfer.FromAccount.Currency)
+
+	expense, err := store.GetAccount(ctx, result.Transfer.FromAccount.ID)
+	require.NoError(t, err)
+	expenseOwner, err := store.GetUser(ctx, expense.Owner)
+	require.NoError(t, err)
+	require.Equal(t, "system", expenseOwner.Role)
+
+	// a day accrues once
+	result = accrue(savings, time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC))
+	require.False(t, result.Accrued)
+	require.Nil(t, result.Payout)
+	got, err := store.GetAccount(ctx, savings.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(365_040), got.Balance)
+
+	// less than a cent is carried to the next payout
+	accrue(small, time.Date(2024, time.January, 30, 0, 0, 0, 0, time.UTC))
+	result = accrue(small, time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC))
+	require.NotNil(t, result.Payout)
+	require.Zero(t, result.Payout.Amount)
+	require.Equal(t, int64(2*5479), result.Payout.CarryMicros)
+	require.False(t, result.Payout.TransferID.Valid)
+	require.Nil(t, result.Transfer)
+
+	result = accrue(small, time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC))
+	require.Equal(t, int64(3*5479), result.Payout.AccruedMicros)
+	require.Equal(t, int64(3*5479), result.Payout.CarryMicros)
+
+	// accounts without interest accrue nothing, and days accrue once they are over
+	result = accrue(checking, time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC))
+	require.True(t, result.Accrued)
+	require.Zero(t, result.Accrual.AmountMicros)
+	require.Zero(t, result.Payout.Amount)
+
+	_, err = store.AccrueInterestTx(ctx, AccrueInterestTxParams{AccountID: savings.ID, Day: time.Now()})
+	require.ErrorIs(t, err, ErrDayNotOver)
+
+	_, err = store.AccrueInterestTx(ctx, AccrueInterestTxParams{AccountID: missingID, Day: time.Now().AddDate(0, 0, -1)})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	accounts, err := store.ListInterestBearingAccounts(ctx, ListInterestBearingAccountsParams{
+		AfterID: savings.ID - 1,
+		DayEnd:  time.Now().Add(time.Minute),
+		Limit:   10,
+	})
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	require.Equal(t, savings.ID, accounts[0].ID)
+	require.Equal(t, small.ID, accounts[1].ID)
+}
+
+func testConformanceFees(t *testing.T, store Store) {
+	ctx := context.Background()
+	from := conformanceAccount(t, store, conformanceUser(t, store), util.EUR, 1_000_000)
+	to := conformanceAccount(t, store, conformanceUser(t, store), util.EUR, 0)
+
+	// the standard schedule charges 0.2% of transfers in EUR, of at least 0.20 and at most 5.00
+	quote, err := store.QuoteTransferFee(ctx, QuoteTransferFeeParams{Schedule: "standard", Currency: util.EUR, Amount: 50_000})
+	require.NoError(t, err)
+	require.Equal(t, int64(100), quote.Percentage)
+	require.Equal(t, int64(100), quote.Total)
+
+	_, err = store.QuoteTransferFee(ctx, QuoteTransferFeeParams{Schedule: "missing", Currency: util.EUR, Amount: 50_000})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	free, err := store.QuoteTransferFee(ctx, QuoteTransferFeeParams{Schedule: "standard", Currency: "GBP", Amount: 50_000})
+	require.NoError(t, err)
+	require.Zero(t, free.Total)
+
+	result, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        50_000,
+		FeeSchedule:   "standard",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result.Fee)
+	require.Equal(t, quote, result.Fee.Breakdown)
+	require.Equal(t, int64(1_000_000-50_000-100), result.FromAccount.Balance)
+	require.Equal(t, int64(50_000), result.ToAccount.Balance)
+
+	charged := result.Fee.Transfer
+	require.NotNil(t, charged)
+	require.Equal(t, int64(100), charged.Transfer.Amount)
+	require.Equal(t, SystemUsername(SystemFeeRevenue), charged.ToAccount.Owner)
+	require.Equal(t, util.EUR, charged.ToAccount.Currency)
+	require.Equal(t, int64(-100), charged.FromEntry.Amount)
+
+	// the email of a system user is its username, which no user can take
+	systemUser, err := store.GetUser(ctx, charged.ToAccount.Owner)
+	require.NoError(t, err)
+	require.Equal(t, "system", systemUser.Role)
+	require.Equal(t, systemUser.Username, systemUser.Email)
+
+	_, err = store.CreateUser(ctx, CreateUserParams{
+		Username:       util.RandomOwner(),
+		HashedPassword: util.RandomString(32),
+		FullName:       util.RandomOwner(),
+		Email:          systemUser.Email,
+	})
+	require.True(t, IsUniqueViolation(err))
+
+	transferFee, err := store.GetTransferFee(ctx, result.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, charged.Transfer.ID, transferFee.FeeTransferID)
+	require.Equal(t, quote.RuleID, transferFee.RuleID)
+	require.Equal(t, int64(100), transferFee.Total)
+
+	// the fee revenue account is shared by every transfer in the currency
+	result, err = store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: to.ID,
+		ToAccountID:   from.ID,
+		Amount:        5_000,
+		FeeSchedule:   "standard",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(20), result.Fee.Breakdown.Total)
+	require.Equal(t, int64(10), result.Fee.Breakdown.Percentage)
+	require.Equal(t, int64(10), result.Fee.Breakdown.Adjustment)
+	require.Equal(t, charged.ToAccount.ID, result.Fee.Transfer.ToAccount.ID)
+
+	revenue, err := store.GetAccount(ctx, charged.ToAccount.ID)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, revenue.Balance, int64(120))
+
+	// without a schedule, nothing is charged
+	result, err = store.TransferTx(ctx, TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: 10})
+	require.NoError(t, err)
+	require.Nil(t, result.Fee)
+
+	_, err = store.GetTransferFee(ctx, result.Transfer.ID)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	// a fee that cannot be charged rolls the transfer back
+	_, err = store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        10,
+		FeeSchedule:   "missing",
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	got, err := store.GetAccount(ctx, from.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1_000_000-50_000-100+5_000-10), got.Balance)
+
+	// the sender has to cover the fee too: a transfer of its whole balance leaves nothing to pay the fee with
+	poor := conformanceAccount(t, store, conformanceUser(t, store), util.EUR, 5_000)
+	_, err = store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: poor.ID,
+		ToAccountID:   to.ID,
+		Amount:        5_000,
+		FeeSchedule:   "standard",
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	got, err = store.GetAccount(ctx, poor.ID)
+	require.NoError(t, err)
+	require.Equal(t, poor.Balance, got.Balance)
+}
+
+func testConformanceChartOfAccounts(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	glAccounts, err := store.ListGLAccounts(ctx)
+	require.NoError(t, err)
+	types := make(map[string]string)
+	for _, glAccount := range glAccounts {
+		types[glAccount.Code] = glAccount.Type
+	}
+	require.Equal(t, GLLiability, types[GLCustomerDeposits])
+	require.Equal(t, GLRevenue, types[SystemFeeRevenue])
+	require.Equal(t, GLExpense, types[SystemInterestExpense])
+
+	_, err = store.GetGLAccount(ctx, "missing")
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	// the accounts of users are customer deposits
+	from := conformanceAccount(t, store, conformanceUser(t, store), util.CAD, 1_000)
+	to := conformanceAccount(t, store, conformanceUser(t, store), util.CAD, 0)
+	require.Equal(t, GLCustomerDeposits, from.GlCode)
+
+	// other tests leave balances set without transfers behind, so only the change of the difference counts
+	outOfBalance := func() int64 {
+		trialBalance, err := store.GetTrialBalance(ctx)
+		require.NoError(t, err)
+		for _, currency := range trialBalance.Currencies {
+			if currency.Currency == util.CAD {
+				require.Equal(t, currency.TotalDebits == currency.TotalCredits, currency.Balanced)
+				return currency.TotalCredits - currency.TotalDebits
+			}
+		}
+		t.Fatal("no trial balance in CAD")
+		return 0
+	}
+	before := outOfBalance()
+
+	// the standard schedule charges 0.30 for transfers in CAD, to the fee revenue account
+	result, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        500,
+		FeeSchedule:   "standard",
+	})
+	require.NoError(t, err)
+	require.Equal(t, SystemFeeRevenue, result.Fee.Transfer.ToAccount.GlCode)
+	require.Equal(t, before, outOfBalance())
+
+	// an opening balance has no other side
+	conformanceAccount(t, store, conformanceUser(t, store), util.CAD, 250)
+	require.Equal(t, before+250, outOfBalance())
+}
+
+func testConformancePayments(t *testing.T, store Store) {
+	ctx := context.Background()
+	account := conformanceAccount(t, store, conformanceUser(t, store), util.USD, 1_000)
+
+	create := func(direction string, amount int64) PaymentTxResult {
+		result, err := store.CreatePaymentTx(ctx, CreatePaymentTxParams{
+			AccountID: account.ID,
+			Direction: direction,
+			Rail:      "ach",
+			Amount:    amount,
+		})
+		require.NoError(t, err)
+		require.Equal(t, PaymentPending, result.Payment.Status)
+		require.Equal(t, util.USD, result.Payment.Currency)
+		require.Equal(t, result.Transfer.Transfer.ID, result.Payment.PendingTransferID.Int64)
+		require.Equal(t, SystemSuspense, result.Transfer.ToAccount.GlCode)
+		requirePaymentEntries(t, result)
+		return result
+	}
+	complete := func(payment Payment, settled bool) PaymentTxResult {
+		result, err := store.CompletePaymentTx(ctx, CompletePaymentTxParams{
+			PaymentID:     payment.ID,
+			Settled:       settled,
+			FailureReason: "R01: insufficient funds",
+		})
+		require.NoError(t, err)
+		require.Equal(t, SystemSuspense, result.Transfer.FromAccount.GlCode)
+		require.Equal(t, result.Transfer.Transfer.ID, result.Payment.FinalTransferID.Int64)
+		require.True(t, result.Payment.CompletedAt.Valid)
+		requirePaymentEntries(t, result)
+		return result
+	}
+	balance := func() int64 {
+		got, err := store.GetAccount(ctx, account.ID)
+		require.NoError(t, err)
+		return got.Balance
+	}
+
+	// a deposit comes from the clearing account, and reaches the account once it settles
+	deposit := create(PaymentDeposit, 300)
+	require.Equal(t, SystemClearing, deposit.Transfer.FromAccount.GlCode)
+	require.Equal(t, int64(1_000), balance())
+
+	settled := complete(deposit.Payment, true)
+	require.Equal(t, PaymentSettled, settled.Payment.Status)
+	require.Empty(t, settled.Payment.FailureReason)
+	require.Equal(t, account.ID, settled.Transfer.ToAccount.ID)
+	require.Equal(t, int64(300), settled.Transfer.ToEntry.Amount)
+	require.Equal(t, int64(1_300), balance())
+
+	// a payment completes once
+	_, err := store.CompletePaymentTx(ctx, CompletePaymentTxParams{PaymentID: deposit.Payment.ID})
+	require.ErrorIs(t, err, ErrPaymentCompleted)
+
+	// a failed deposit goes back to the clearing account
+	deposit = create(PaymentDeposit, 50)
+	failed := complete(deposit.Payment, false)
+	require.Equal(t, PaymentFailed, failed.Payment.Status)
+	require.Equal(t, "R01: insufficient funds", failed.Payment.FailureReason)
+	require.Equal(t, SystemClearing, failed.Transfer.ToAccount.GlCode)
+	require.Equal(t, int64(1_300), balance())
+
+	// a withdrawal is debited at once, and goes out through the clearing account once it settles
+	withdrawal := create(PaymentWithdrawal, 200)
+	require.Equal(t, account.ID, withdrawal.Transfer.FromAccount.ID)
+	require.Equal(t, int64(-200), withdrawal.Transfer.FromEntry.Amount)
+	require.Equal(t, int64(1_100), balance())
+
+	settled = complete(withdrawal.Payment, true)
+	require.Equal(t, SystemClearing, settled.Transfer.ToAccount.GlCode)
+	require.Equal(t, int64(1_100), balance())
+
+	// a withdrawal cannot overdraw the account
+	_, err = store.CreatePaymentTx(ctx, CreatePaymentTxParams{
+		AccountID: account.ID,
+		Direction: PaymentWithdrawal,
+		Rail:      "ach",
+		Amount:    1_101,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+	require.Equal(t, int64(1_100), balance())
+
+	// a failed withdrawal is credited back
+	withdrawal = create(PaymentWithdrawal, 100)
+	require.Equal(t, int64(1_000), balance())
+	failed = complete(withdrawal.Payment, false)
+	require.Equal(t, account.ID, failed.Transfer.ToAccount.ID)
+	require.Equal(t, int64(1_100), balance())
+
+	// the pending payments of other tests may be held in suspense too, so only what these left there counts
+	create(PaymentDeposit, 70)
+	suspense, err := store.GetAccount(ctx, failed.Transfer.FromAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, failed.Transfer.FromAccount.Balance+70, suspense.Balance)
+
+	payments, err := store.ListPayments(ctx, ListPaymentsParams{AccountID: account.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, payments, 5)
+	require.Equal(t, PaymentPending, payments[0].Status)
+	require.Equal(t, withdrawal.Payment.ID, payments[1].ID)
+
+	_, err = store.CreatePaymentTx(ctx, CreatePaymentTxParams{AccountID: account.ID, Direction: "refund", Rail: "ach", Amount: 10})
+	require.Error(t, err)
+
+	_, err = store.CompletePaymentTx(ctx, CompletePaymentTxParams{PaymentID: missingID})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+// requirePaymentEntries checks the entries of the transfer of a payment refer to it, and that the payment is
+// part of their hash
+func requirePaymentEntries(t *testing.T, result PaymentTxResult) {
+	for _, entry := range []Entry{result.Transfer.FromEntry, result.Transfer.ToEntry} {
+		require.Equal(t, pgtype.Int8{Int64: result.Payment.ID, Valid: true}, entry.PaymentID)
+
+		unlinked := entry
+		unlinked.PaymentID = pgtype.Int8{}
+		require.NotEqual(t, HashEntry(nil, unlinked), HashEntry(nil, entry))
+	}
+}
@@ -2,18 +2,23 @@ package db
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestTransferTx(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	account1 := createFundedAccount(t)
+	account2 := createFundedAccount(t)
 
 	// run n concurrent transfer transactions
 	n := 5
@@ -111,35 +116,85 @@ func TestTransferTx(t *testing.T) {
 
 }
 
+// TestTransferTxDeadlock moves money both ways between two accounts at once. Two transactions that lock the
+// accounts in the order of their transfer deadlock: Postgres aborts one of them and execTx retries it.
+// TransferTx itself locks them by ID, so its transfers in both directions all go through.
 func TestTransferTxDeadlock(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
-	store := NewStore(testDB)
+	store := NewStore(testDB).(*SQLStore)
 
 	user1 := createTestUser(t, "fulano", "xyz", "fulano test", "fulano@email.com")
 	user2 := createTestUser(t, "menagno", "xyz", "mengano test", "mengano@email.com")
 	account1 := createTestAccount(t, user1.Username, "USD", 100)
 	account2 := createTestAccount(t, user2.Username, "USD", 100)
 
-	// run n concurrent transfer transactions
-	n := 10
 	amount := int64(10)
-
 	errs := make(chan error)
 
+	var deadlocks atomic.Int32
+	onRetry := OnRetry(func(ctx context.Context, err error) {
+		if TxConflict(err) == ConflictDeadlock {
+			deadlocks.Add(1)
+		}
+	})
+
+	// both transactions hold the lock of their sender before either of them asks for the one of its recipient
+	var locked sync.WaitGroup
+	locked.Add(2)
+
+	for i := 0; i < 2; i++ {
+		fromAccountID, toAccountID := account1.ID, account2.ID
+		if i%2 != 0 {
+			fromAccountID, toAccountID = account2.ID, account1.ID
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			ctx = WithTxOptions(ctx, onRetry)
+
+			attempts := 0
+			errs <- store.execTx(ctx, func(q Querier) error {
+				attempts++
+				_, err := q.AddAccountBalance(ctx, AddAccountBalanceParams{ID: fromAccountID, Amount: -amount})
+				if err != nil {
+					return err
+				}
+				if attempts == 1 {
+					locked.Done()
+					locked.Wait()
+				}
+				_, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{ID: toAccountID, Amount: amount})
+				return err
+			})
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-errs)
+	}
+	require.EqualValues(t, 1, deadlocks.Load())
+
+	// run n concurrent transfer transactions, half of them with the ids reversed
+	n := 10
+
 	for i := 0; i < n; i++ {
-		fromAccountId := account1.ID
-		toAccountId := account2.ID
-		if i % 2 != 0 {
-			fromAccountId = account2.ID
-			toAccountId = account1.ID
-		}	
-		go func() { 
-			ctx := context.Background()
-			_, err := store.TransferTx(ctx, TransferTxParams{
-				FromAccountID: fromAccountId,
-				ToAccountID: toAccountId,
-				Amount: amount,
+		fromAccountID, toAccountID := account1.ID, account2.ID
+		if i%2 != 0 {
+			fromAccountID, toAccountID = account2.ID, account1.ID
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			_, err := store.TransferTx(WithTxOptions(ctx, onRetry), TransferTxParams{
+				FromAccountID: fromAccountID,
+				ToAccountID:   toAccountID,
+				Amount:        amount,
 			})
 			errs <- err
 		}()
@@ -147,9 +202,9 @@ func TestTransferTxDeadlock(t *testing.T) {
 
 	// check results
 	for i := 0; i < n; i++ {
-		err := <-errs
-		require.NoError(t, err)
+		require.NoError(t, <-errs)
 	}
+	require.EqualValues(t, 1, deadlocks.Load())
 
 	// check final balance
 	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
@@ -160,5 +215,38 @@ func TestTransferTxDeadlock(t *testing.T) {
 
 	require.Equal(t, account1.Balance, updatedAccount1.Balance)
 	require.Equal(t, account2.Balance, updatedAccount2.Balance)
+}
+
+func TestTransferTxWatchAccount(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	store := NewStore(testDB)
+
+	account1 := createFundedAccount(t)
+	account2 := createFundedAccount(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events1 := store.WatchAccount(ctx, account1.ID)
+	events2 := store.WatchAccount(ctx, account2.ID)
+
+	amount := int64(10)
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        amount,
+	})
+	require.NoError(t, err)
+
+	event1 := <-events1
+	require.Equal(t, result.FromAccount, event1.Account)
+	require.Equal(t, result.FromEntry, event1.Entry)
+	require.Equal(t, account1.Balance-amount, event1.Account.Balance)
 
+	event2 := <-events2
+	require.Equal(t, result.ToAccount, event2.Account)
+	require.Equal(t, result.ToEntry, event2.Entry)
+	require.Equal(t, account2.Balance+amount, event2.Account.Balance)
 }
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// Every account belongs to a GL account of the chart of accounts, which gives it its type. The accounts of users
+// are all customer deposits, owed by the bank. System accounts are the accounts of the bank itself, such as the
+// one interest is paid from: each GL account other than customer deposits has one in every currency.
+// They are owned by a system user of their GL account, which cannot log in. The migrations create the system
+// users and their accounts, so transactions only look them up.
+// System usernames have a colon, and are also the emails of system users, so no user can sign up or update their
+// email to take one.
+
+// Types of GL accounts
+const (
+	GLAsset     = "asset"
+	GLLiability = "liability"
+	GLRevenue   = "revenue"
+	GLExpense   = "expense"
+)
+
+// Codes of the GL accounts
+const (
+	GLCustomerDeposits    = "customer_deposits"
+	SystemInterestExpense = "interest_expense"
+	SystemFeeRevenue      = "fee_revenue"
+	SystemClearing        = "clearing"
+	SystemSuspense        = "suspense"
+)
+
+// SystemUsername returns the username of the system user that owns the accounts of the GL account of code
+func SystemUsername(code string) string {
+	return "system:" + code
+}
+
+// systemAccount returns the system account of the GL account of code in currency
+func systemAccount(ctx context.Context, q Querier, code, currency string) (Account, error) {
+	account, err := q.GetAccountByOwner(ctx, GetAccountByOwnerParams{
+		Owner:    SystemUsername(code),
+		Currency: currency,
+	})
+	if err != nil {
+		return Account{}, fmt.Errorf("cannot get %s account in %s: %w", code, currency, err)
+	}
+	return account, nil
+}
@@ -0,0 +1,175 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: task.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const claimTask = `-- name: ClaimTask :one
+UPDATE tasks
+SET
+  attempts = attempts + 1,
+  run_at = $1,
+  updated_at = now()
+WHERE id = (
+  SELECT t.id FROM tasks t
+  WHERE
+    t.queue = $2
+    AND t.status = 'pending'
+    AND t.run_at <= now()
+  ORDER BY t.run_at
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, queue, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, updated_at
+`
+
+type ClaimTaskParams struct {
+	LockedUntil time.Time `json:"locked_until"`
+	Queue       string    `json:"queue"`
+}
+
+func (q *Queries) ClaimTask(ctx context.Context, arg ClaimTaskParams) (Task, error) {
+	row := q.db.QueryRow(ctx, claimTask, arg.LockedUntil, arg.Queue)
+	var i Task
+	err := row.Scan(
+		&i.ID,
+		&i.Queue,
+		&i.Type,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.LastError,
+		&i.RunAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const completeTask = `-- name: CompleteTask :exec
+UPDATE tasks
+SET
+  status = 'completed',
+  updated_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) CompleteTask(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, completeTask, id)
+	return err
+}
+
+const createTask = `-- name: CreateTask :one
+INSERT INTO tasks (
+  queue,
+  type,
+  payload,
+  max_attempts,
+  run_at
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING id, queue, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, updated_at
+`
+
+type CreateTaskParams struct {
+	Queue       string    `json:"queue"`
+	Type        string    `json:"type"`
+	Payload     []byte    `json:"payload"`
+	MaxAttempts int32     `json:"max_attempts"`
+	RunAt       time.Time `json:"run_at"`
+}
+
+func (q *Queries) CreateTask(ctx context.Context, arg CreateTaskParams) (Task, error) {
+	row := q.db.QueryRow(ctx, createTask,
+		arg.Queue,
+		arg.Type,
+		arg.Payload,
+		arg.MaxAttempts,
+		arg.RunAt,
+	)
+	var i Task
+	err := row.Scan(
+		&i.ID,
+		&i.Queue,
+		&i.Type,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.LastError,
+		&i.RunAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const failTask = `-- name: FailTask :exec
+UPDATE tasks
+SET
+  status = 'failed',
+  last_error = $2,
+  updated_at = now()
+WHERE id = $1
+`
+
+type FailTaskParams struct {
+	ID        int64  `json:"id"`
+	LastError string `json:"last_error"`
+}
+
+func (q *Queries) FailTask(ctx context.Context, arg FailTaskParams) error {
+	_, err := q.db.Exec(ctx, failTask, arg.ID, arg.LastError)
+	return err
+}
+
+const getTask = `-- name: GetTask :one
+SELECT id, queue, type, payload, status, attempts, max_attempts, last_error, run_at, created_at, updated_at FROM tasks
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetTask(ctx context.Context, id int64) (Task, error) {
+	row := q.db.QueryRow(ctx, getTask, id)
+	var i Task
+	err := row.Scan(
+		&i.ID,
+		&i.Queue,
+		&i.Type,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.LastError,
+		&i.RunAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const retryTask = `-- name: RetryTask :exec
+UPDATE tasks
+SET
+  run_at = $2,
+  last_error = $3,
+  updated_at = now()
+WHERE id = $1
+`
+
+type RetryTaskParams struct {
+	ID        int64     `json:"id"`
+	RunAt     time.Time `json:"run_at"`
+	LastError string    `json:"last_error"`
+}
+
+func (q *Queries) RetryTask(ctx context.Context, arg RetryTaskParams) error {
+	_, err := q.db.Exec(ctx, retryTask, arg.ID, arg.RunAt, arg.LastError)
+	return err
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomTask(t *testing.T, queue string, runAt time.Time) Task {
+	arg := CreateTaskParams{
+		Queue:       queue,
+		Type:        "task:" + util.RandomString(6),
+		Payload:     json.RawMessage(`{"username":"` + util.RandomOwner() + `"}`),
+		MaxAttempts: 3,
+		RunAt:       runAt,
+	}
+
+	task, err := testQueries.CreateTask(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, task.ID)
+	require.Equal(t, arg.Queue, task.Queue)
+	require.Equal(t, arg.Type, task.Type)
+	require.JSONEq(t, string(arg.Payload), string(task.Payload))
+	require.Equal(t, "pending", task.Status)
+	require.Zero(t, task.Attempts)
+
+	return task
+}
+
+func TestClaimTask(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	queue := util.RandomString(8)
+	task := createRandomTask(t, queue, time.Now().Add(-time.Second))
+	createRandomTask(t, queue, time.Now().Add(time.Hour))
+
+	lockedUntil := time.Now().Add(time.Minute)
+	claimed, err := testQueries.ClaimTask(context.Background(), ClaimTaskParams{
+		Queue:       queue,
+		LockedUntil: lockedUntil,
+	})
+	require.NoError(t, err)
+	require.Equal(t, task.ID, claimed.ID)
+	require.Equal(t, int32(1), claimed.Attempts)
+	require.WithinDuration(t, lockedUntil, claimed.RunAt, time.Second)
+
+	// the claimed task is leased and the other one is not due yet
+	_, err = testQueries.ClaimTask(context.Background(), ClaimTaskParams{
+		Queue:       queue,
+		LockedUntil: lockedUntil,
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestCompleteAndFailTask(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	queue := util.RandomString(8)
+	task1 := createRandomTask(t, queue, time.Now())
+	task2 := createRandomTask(t, queue, time.Now())
+
+	err := testQueries.CompleteTask(context.Background(), task1.ID)
+	require.NoError(t, err)
+
+	err = testQueries.FailTask(context.Background(), FailTaskParams{ID: task2.ID, LastError: "boom"})
+	require.NoError(t, err)
+
+	completed, err := testQueries.GetTask(context.Background(), task1.ID)
+	require.NoError(t, err)
+	require.Equal(t, "completed", completed.Status)
+
+	failed, err := testQueries.GetTask(context.Background(), task2.ID)
+	require.NoError(t, err)
+	require.Equal(t, "failed", failed.Status)
+	require.Equal(t, "boom", failed.LastError)
+}
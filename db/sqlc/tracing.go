@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/pakojabi/simplebank/db/sqlc"
+
+// tracer uses the global provider, a no-op until main installs one
+var tracer = otel.Tracer(tracerName)
+
+// tracedDBTX records a span for every query run through it. The span is named after
+// the sqlc query, taken from the "-- name: GetAccount :one" header of the statement.
+type tracedDBTX struct {
+	DBTX
+	// parent, when set, is the transaction span the queries are recorded under
+	parent trace.Span
+}
+
+func newTracedDBTX(db DBTX, parent trace.Span) DBTX {
+	return &tracedDBTX{DBTX: db, parent: parent}
+}
+
+func (db *tracedDBTX) Exec(ctx context.Context, query string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span := db.startSpan(ctx, query)
+	defer span.End()
+
+	tag, err := db.DBTX.Exec(ctx, query, args...)
+	recordError(span, err)
+	return tag, err
+}
+
+func (db *tracedDBTX) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span := db.startSpan(ctx, query)
+	defer span.End()
+
+	rows, err := db.DBTX.Query(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (db *tracedDBTX) QueryRow(ctx context.Context, query string, args ...interface{}) pgx.Row {
+	ctx, span := db.startSpan(ctx, query)
+	return &tracedRow{Row: db.DBTX.QueryRow(ctx, query, args...), span: span}
+}
+
+// tracedRow ends the span of a QueryRow once the row is scanned: pgx only runs the query then
+type tracedRow struct {
+	pgx.Row
+	span trace.Span
+}
+
+func (row *tracedRow) Scan(dest ...any) error {
+	defer row.span.End()
+
+	err := row.Row.Scan(dest...)
+	// pgx.ErrNoRows is an answer, not a failure
+	if !errors.Is(err, pgx.ErrNoRows) {
+		recordError(row.span, err)
+	}
+	return err
+}
+
+func (db *tracedDBTX) startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	if db.parent != nil {
+		ctx = trace.ContextWithSpan(ctx, db.parent)
+	}
+
+	name := queryName(query)
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(name),
+		),
+	)
+}
+
+// startTxSpan starts the span of a transaction run by execTx
+func startTxSpan(ctx context.Context) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "execTx",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL),
+	)
+}
+
+// queryName returns the name sqlc gives to query, or "query" for hand-written statements
+func queryName(query string) string {
+	header, _, _ := strings.Cut(strings.TrimSpace(query), "\n")
+	fields := strings.Fields(header)
+	if len(fields) >= 3 && fields[0] == "--" && fields[1] == "name:" {
+		return fields[2]
+	}
+	return "query"
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// txAttributes describes how a transaction ended
+func txAttributes(err error) attribute.KeyValue {
+	if err != nil {
+		return attribute.String("db.tx.outcome", "rollback")
+	}
+	return attribute.String("db.tx.outcome", "commit")
+}
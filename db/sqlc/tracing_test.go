@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryName(t *testing.T) {
+	require.Equal(t, "GetAccount", queryName(getAccount))
+	require.Equal(t, "CreateTransfer", queryName(createTransfer))
+	require.Equal(t, "query", queryName("TRUNCATE TABLE transfers"))
+	require.Equal(t, "query", queryName(""))
+}
+
+func TestTransferTxSpans(t *testing.T) {
+	requireDB(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	store := NewStore(testDB)
+	account1 := createFundedAccount(t)
+	account2 := createFundedAccount(t)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "transfer")
+	_, err := store.TransferTx(ctx, TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	parent.End()
+	require.NoError(t, err)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	txSpan, ok := spans["execTx"]
+	require.True(t, ok)
+	require.Equal(t, parent.SpanContext().SpanID(), txSpan.Parent().SpanID())
+
+	// the queries of the transaction are children of its span, even though they run with the caller's context
+	transferSpan, ok := spans["CreateTransfer"]
+	require.True(t, ok)
+	require.Equal(t, txSpan.SpanContext().SpanID(), transferSpan.Parent().SpanID())
+	require.Equal(t, parent.SpanContext().TraceID(), transferSpan.SpanContext().TraceID())
+}
@@ -31,6 +31,8 @@ func createRandomTransfer(t *testing.T, account1, account2 Account) Transfer {
 }
 
 func TestCreateTransfer(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	account1 := createRandomAccount(t)
@@ -39,6 +41,8 @@ func TestCreateTransfer(t *testing.T) {
 }
 
 func TestGetTransfer(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	account1 := createRandomAccount(t)
@@ -57,6 +61,8 @@ func TestGetTransfer(t *testing.T) {
 }
 
 func TestListTransfer(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	account1 := createRandomAccount(t)
@@ -0,0 +1,86 @@
+package db
+
+import "context"
+
+// The balance of an account is what it was credited minus what it was debited, as the bank books it: the
+// balances of users, owed by the bank, are credits, and the interest the bank paid out is a debit of the interest
+// expense account. Every transfer debits one account and credits another with the same amount, so in each
+// currency the debits of all the accounts add up to their credits. Balances set without a transfer, by
+// CreateAccount or UpdateAccount, have no other side and leave the trial balance out of balance.
+
+// TrialBalanceLine is the balance of the accounts of a GL account in a currency, on the normal side of its type:
+// debit for assets and expenses, credit for liabilities and revenue. A contra balance, which falls on the other
+// side, is negative.
+type TrialBalanceLine struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Accounts int64  `json:"accounts"`
+	Debit    int64  `json:"debit"`
+	Credit   int64  `json:"credit"`
+}
+
+// CurrencyTrialBalance is the trial balance of the accounts in a currency
+type CurrencyTrialBalance struct {
+	Currency     string             `json:"currency"`
+	Lines        []TrialBalanceLine `json:"lines"`
+	TotalDebits  int64              `json:"total_debits"`
+	TotalCredits int64              `json:"total_credits"`
+	Balanced     bool               `json:"balanced"`
+}
+
+// TrialBalance lists the balances of every GL account, by currency, and whether debits equal credits in all of them
+type TrialBalance struct {
+	Currencies []CurrencyTrialBalance `json:"currencies"`
+	Balanced   bool                   `json:"balanced"`
+}
+
+// GetTrialBalance adds up the balances of all the accounts into a trial balance
+func (store *txStore) GetTrialBalance(ctx context.Context) (TrialBalance, error) {
+	rows, err := store.ListGLBalances(ctx)
+	if err != nil {
+		return TrialBalance{}, err
+	}
+	return NewTrialBalance(rows), nil
+}
+
+// NewTrialBalance builds a trial balance from the balances of the GL accounts, sorted by currency
+func NewTrialBalance(rows []ListGLBalancesRow) TrialBalance {
+	trialBalance := TrialBalance{Currencies: []CurrencyTrialBalance{}, Balanced: true}
+
+	for _, row := range rows {
+		n := len(trialBalance.Currencies)
+		if n == 0 || trialBalance.Currencies[n-1].Currency != row.Currency {
+			trialBalance.Currencies = append(trialBalance.Currencies, CurrencyTrialBalance{Currency: row.Currency})
+			n++
+		}
+		currency := &trialBalance.Currencies[n-1]
+
+		line := TrialBalanceLine{
+			Code:     row.Code,
+			Name:     row.Name,
+			Type:     row.Type,
+			Accounts: row.Accounts,
+		}
+		if isDebitNormal(row.Type) {
+			line.Debit = -row.Balance
+		} else {
+			line.Credit = row.Balance
+		}
+		currency.Lines = append(currency.Lines, line)
+		currency.TotalDebits += line.Debit
+		currency.TotalCredits += line.Credit
+	}
+
+	for i := range trialBalance.Currencies {
+		currency := &trialBalance.Currencies[i]
+		currency.Balanced = currency.TotalDebits == currency.TotalCredits
+		trialBalance.Balanced = trialBalance.Balanced && currency.Balanced
+	}
+	return trialBalance
+}
+
+// isDebitNormal tells whether the balances of a GL account of glType are normally debits
+func isDebitNormal(glType string) bool {
+	return glType == GLAsset || glType == GLExpense
+}
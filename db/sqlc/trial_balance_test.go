@@ -0,0 +1,56 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTrialBalance(t *testing.T) {
+	trialBalance := NewTrialBalance([]ListGLBalancesRow{
+		{Code: "clearing", Type: GLAsset, Currency: util.EUR, Accounts: 1, Balance: -500},
+		{Code: GLCustomerDeposits, Type: GLLiability, Currency: util.EUR, Accounts: 2, Balance: 500},
+		{Code: GLCustomerDeposits, Type: GLLiability, Currency: util.USD, Accounts: 2, Balance: 120},
+		{Code: SystemFeeRevenue, Type: GLRevenue, Currency: util.USD, Accounts: 1, Balance: 30},
+		{Code: SystemInterestExpense, Type: GLExpense, Currency: util.USD, Accounts: 1, Balance: -150},
+	})
+
+	require.True(t, trialBalance.Balanced)
+	require.Len(t, trialBalance.Currencies, 2)
+
+	eur := trialBalance.Currencies[0]
+	require.Equal(t, util.EUR, eur.Currency)
+	require.Equal(t, TrialBalanceLine{Code: "clearing", Type: GLAsset, Accounts: 1, Debit: 500}, eur.Lines[0])
+	require.Equal(t, TrialBalanceLine{Code: GLCustomerDeposits, Type: GLLiability, Accounts: 2, Credit: 500}, eur.Lines[1])
+
+	usd := trialBalance.Currencies[1]
+	require.Equal(t, util.USD, usd.Currency)
+	require.Equal(t, int64(150), usd.TotalDebits)
+	require.Equal(t, int64(150), usd.TotalCredits)
+	require.True(t, usd.Balanced)
+}
+
+// a contra balance stays on the normal side of the type of its account, as a negative amount
+func TestNewTrialBalanceContra(t *testing.T) {
+	trialBalance := NewTrialBalance([]ListGLBalancesRow{
+		// an expense refunded for more than was spent has a credit balance
+		{Code: SystemInterestExpense, Type: GLExpense, Currency: util.USD, Accounts: 1, Balance: 40},
+		// a customer account overdrawn has a debit balance
+		{Code: GLCustomerDeposits, Type: GLLiability, Currency: util.USD, Accounts: 1, Balance: -40},
+	})
+
+	require.True(t, trialBalance.Balanced)
+	usd := trialBalance.Currencies[0]
+	require.Equal(t, TrialBalanceLine{Code: SystemInterestExpense, Type: GLExpense, Accounts: 1, Debit: -40}, usd.Lines[0])
+	require.Equal(t, TrialBalanceLine{Code: GLCustomerDeposits, Type: GLLiability, Accounts: 1, Credit: -40}, usd.Lines[1])
+	require.Equal(t, int64(-40), usd.TotalDebits)
+	require.Equal(t, int64(-40), usd.TotalCredits)
+
+	// out of balance when the other side is missing
+	trialBalance = NewTrialBalance([]ListGLBalancesRow{
+		{Code: SystemInterestExpense, Type: GLExpense, Currency: util.USD, Accounts: 1, Balance: 40},
+	})
+	require.False(t, trialBalance.Balanced)
+	require.False(t, trialBalance.Currencies[0].Balanced)
+}
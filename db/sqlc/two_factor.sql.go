@@ -0,0 +1,298 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: two_factor.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const attemptLoginChallenge = `-- name: AttemptLoginChallenge :one
+UPDATE login_challenges
+SET
+  attempts = attempts + 1
+WHERE
+  id = $1
+  AND is_used = FALSE
+  AND expires_at > now()
+  AND attempts < $2
+RETURNING id, username, attempts, is_used, expires_at, created_at
+`
+
+type AttemptLoginChallengeParams struct {
+	ID          uuid.UUID `json:"id"`
+	MaxAttempts int32     `json:"max_attempts"`
+}
+
+// counts an attempt at answering the challenge, as long as it is still open
+func (q *Queries) AttemptLoginChallenge(ctx context.Context, arg AttemptLoginChallengeParams) (LoginChallenge, error) {
+	row := q.db.QueryRow(ctx, attemptLoginChallenge, arg.ID, arg.MaxAttempts)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Attempts,
+		&i.IsUsed,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const completeLoginChallenge = `-- name: CompleteLoginChallenge :one
+UPDATE login_challenges
+SET
+  is_used = TRUE
+WHERE
+  id = $1
+  AND is_used = FALSE
+RETURNING id, username, attempts, is_used, expires_at, created_at
+`
+
+func (q *Queries) CompleteLoginChallenge(ctx context.Context, id uuid.UUID) (LoginChallenge, error) {
+	row := q.db.QueryRow(ctx, completeLoginChallenge, id)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Attempts,
+		&i.IsUsed,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createLoginChallenge = `-- name: CreateLoginChallenge :one
+INSERT INTO login_challenges (
+  id,
+  username,
+  expires_at
+) VALUES (
+  $1, $2, $3
+) RETURNING id, username, attempts, is_used, expires_at, created_at
+`
+
+type CreateLoginChallengeParams struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateLoginChallenge(ctx context.Context, arg CreateLoginChallengeParams) (LoginChallenge, error) {
+	row := q.db.QueryRow(ctx, createLoginChallenge, arg.ID, arg.Username, arg.ExpiresAt)
+	var i LoginChallenge
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Attempts,
+		&i.IsUsed,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (
+  username,
+  hashed_code
+) VALUES (
+  $1, $2
+) RETURNING id, username, hashed_code, is_used, created_at
+`
+
+type CreateRecoveryCodeParams struct {
+	Username   string `json:"username"`
+	HashedCode string `json:"hashed_code"`
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error) {
+	row := q.db.QueryRow(ctx, createRecoveryCode, arg.Username, arg.HashedCode)
+	var i RecoveryCode
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedCode,
+		&i.IsUsed,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE username = $1
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, username string) error {
+	_, err := q.db.Exec(ctx, deleteRecoveryCodes, username)
+	return err
+}
+
+const deleteTOTPSecret = `-- name: DeleteTOTPSecret :one
+DELETE FROM totp_secrets
+WHERE
+  username = $1
+  AND is_enabled = TRUE
+RETURNING username, encrypted_secret, is_enabled, last_used_step, created_at
+`
+
+func (q *Queries) DeleteTOTPSecret(ctx context.Context, username string) (TotpSecret, error) {
+	row := q.db.QueryRow(ctx, deleteTOTPSecret, username)
+	var i TotpSecret
+	err := row.Scan(
+		&i.Username,
+		&i.EncryptedSecret,
+		&i.IsEnabled,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const enableTOTPSecret = `-- name: EnableTOTPSecret :one
+UPDATE totp_secrets
+SET
+  is_enabled = TRUE,
+  last_used_step = $1
+WHERE
+  username = $2
+  AND is_enabled = FALSE
+RETURNING username, encrypted_secret, is_enabled, last_used_step, created_at
+`
+
+type EnableTOTPSecretParams struct {
+	Step     int64  `json:"step"`
+	Username string `json:"username"`
+}
+
+func (q *Queries) EnableTOTPSecret(ctx context.Context, arg EnableTOTPSecretParams) (TotpSecret, error) {
+	row := q.db.QueryRow(ctx, enableTOTPSecret, arg.Step, arg.Username)
+	var i TotpSecret
+	err := row.Scan(
+		&i.Username,
+		&i.EncryptedSecret,
+		&i.IsEnabled,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getTOTPSecret = `-- name: GetTOTPSecret :one
+SELECT username, encrypted_secret, is_enabled, last_used_step, created_at FROM totp_secrets
+WHERE username = $1 LIMIT 1
+`
+
+func (q *Queries) GetTOTPSecret(ctx context.Context, username string) (TotpSecret, error) {
+	row := q.db.QueryRow(ctx, getTOTPSecret, username)
+	var i TotpSecret
+	err := row.Scan(
+		&i.Username,
+		&i.EncryptedSecret,
+		&i.IsEnabled,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const upsertTOTPSecret = `-- name: UpsertTOTPSecret :one
+INSERT INTO totp_secrets (
+  username,
+  encrypted_secret
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (username) DO UPDATE
+SET
+  encrypted_secret = EXCLUDED.encrypted_secret,
+  last_used_step = 0,
+  created_at = now()
+WHERE
+  totp_secrets.is_enabled = FALSE
+RETURNING username, encrypted_secret, is_enabled, last_used_step, created_at
+`
+
+type UpsertTOTPSecretParams struct {
+	Username        string `json:"username"`
+	EncryptedSecret []byte `json:"encrypted_secret"`
+}
+
+// replaces a pending secret, but never one that is already enabled
+func (q *Queries) UpsertTOTPSecret(ctx context.Context, arg UpsertTOTPSecretParams) (TotpSecret, error) {
+	row := q.db.QueryRow(ctx, upsertTOTPSecret, arg.Username, arg.EncryptedSecret)
+	var i TotpSecret
+	err := row.Scan(
+		&i.Username,
+		&i.EncryptedSecret,
+		&i.IsEnabled,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :one
+UPDATE recovery_codes
+SET
+  is_used = TRUE
+WHERE
+  username = $1
+  AND hashed_code = $2
+  AND is_used = FALSE
+RETURNING id, username, hashed_code, is_used, created_at
+`
+
+type UseRecoveryCodeParams struct {
+	Username   string `json:"username"`
+	HashedCode string `json:"hashed_code"`
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (RecoveryCode, error) {
+	row := q.db.QueryRow(ctx, useRecoveryCode, arg.Username, arg.HashedCode)
+	var i RecoveryCode
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.HashedCode,
+		&i.IsUsed,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :one
+UPDATE totp_secrets
+SET
+  last_used_step = $1
+WHERE
+  username = $2
+  AND is_enabled = TRUE
+  AND last_used_step < $1
+RETURNING username, encrypted_secret, is_enabled, last_used_step, created_at
+`
+
+type UseTOTPStepParams struct {
+	Step     int64  `json:"step"`
+	Username string `json:"username"`
+}
+
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (TotpSecret, error) {
+	row := q.db.QueryRow(ctx, useTOTPStep, arg.Step, arg.Username)
+	var i TotpSecret
+	err := row.Scan(
+		&i.Username,
+		&i.EncryptedSecret,
+		&i.IsEnabled,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
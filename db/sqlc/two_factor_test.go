@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomTOTPSecret(t *testing.T, user User) TotpSecret {
+	arg := UpsertTOTPSecretParams{
+		Username:        user.Username,
+		EncryptedSecret: []byte(util.RandomString(32)),
+	}
+
+	secret, err := testQueries.UpsertTOTPSecret(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Username, secret.Username)
+	require.Equal(t, arg.EncryptedSecret, secret.EncryptedSecret)
+	require.False(t, secret.IsEnabled)
+	require.Zero(t, secret.LastUsedStep)
+	require.NotZero(t, secret.CreatedAt)
+
+	return secret
+}
+
+func TestUpsertTOTPSecret(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	user := createRandomUser(t)
+	createRandomTOTPSecret(t, user)
+
+	// a pending secret can be replaced
+	secret2 := createRandomTOTPSecret(t, user)
+
+	got, err := testQueries.GetTOTPSecret(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Equal(t, secret2.EncryptedSecret, got.EncryptedSecret)
+
+	_, err = testQueries.EnableTOTPSecret(context.Background(), EnableTOTPSecretParams{Username: user.Username, Step: 10})
+	require.NoError(t, err)
+
+	// an enabled one cannot
+	_, err = testQueries.UpsertTOTPSecret(context.Background(), UpsertTOTPSecretParams{
+		Username:        user.Username,
+		EncryptedSecret: []byte(util.RandomString(32)),
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	user := createRandomUser(t)
+	createRandomTOTPSecret(t, user)
+
+	secret, err := testQueries.EnableTOTPSecret(context.Background(), EnableTOTPSecretParams{Username: user.Username, Step: 10})
+	require.NoError(t, err)
+	require.True(t, secret.IsEnabled)
+	require.Equal(t, int64(10), secret.LastUsedStep)
+
+	// the code that confirmed the enrollment cannot be used again
+	_, err = testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Username: user.Username, Step: 10})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	secret, err = testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Username: user.Username, Step: 11})
+	require.NoError(t, err)
+	require.Equal(t, int64(11), secret.LastUsedStep)
+
+	_, err = testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Username: user.Username, Step: 11})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestEnableTOTPTx(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	createRandomTOTPSecret(t, user)
+
+	arg := EnableTOTPTxParams{
+		Username:            user.Username,
+		Step:                20,
+		HashedRecoveryCodes: []string{util.RandomString(64), util.RandomString(64)},
+	}
+
+	result, err := store.EnableTOTPTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, result.TOTPSecret.IsEnabled)
+	require.Len(t, result.RecoveryCodes, 2)
+
+	code, err := testQueries.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{
+		Username:   user.Username,
+		HashedCode: arg.HashedRecoveryCodes[0],
+	})
+	require.NoError(t, err)
+	require.True(t, code.IsUsed)
+
+	// recovery codes are single use
+	_, err = testQueries.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{
+		Username:   user.Username,
+		HashedCode: arg.HashedRecoveryCodes[0],
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	// a second confirmation fails, as there is no pending secret left
+	_, err = store.EnableTOTPTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestLoginChallenge(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	user := createRandomUser(t)
+	arg := CreateLoginChallengeParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	challenge, err := testQueries.CreateLoginChallenge(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, challenge.ID)
+	require.Equal(t, arg.Username, challenge.Username)
+	require.Zero(t, challenge.Attempts)
+	require.False(t, challenge.IsUsed)
+
+	for i := 1; i <= 2; i++ {
+		challenge, err = testQueries.AttemptLoginChallenge(context.Background(), AttemptLoginChallengeParams{ID: arg.ID, MaxAttempts: 2})
+		require.NoError(t, err)
+		require.Equal(t, int32(i), challenge.Attempts)
+	}
+
+	// out of attempts
+	_, err = testQueries.AttemptLoginChallenge(context.Background(), AttemptLoginChallengeParams{ID: arg.ID, MaxAttempts: 2})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	challenge, err = testQueries.CompleteLoginChallenge(context.Background(), arg.ID)
+	require.NoError(t, err)
+	require.True(t, challenge.IsUsed)
+
+	_, err = testQueries.CompleteLoginChallenge(context.Background(), arg.ID)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestExpiredLoginChallenge(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	user := createRandomUser(t)
+	challenge, err := testQueries.CreateLoginChallenge(context.Background(), CreateLoginChallengeParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.AttemptLoginChallenge(context.Background(), AttemptLoginChallengeParams{ID: challenge.ID, MaxAttempts: 5})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Balance snapshots hold the balance of every account at the end of each day, in UTC. They are taken by
+// EndOfDayTx, so that GetBalanceAsOf only adds up the entries of part of a day rather than all of them.
+
+// SnapshotDate returns the day, in UTC, of t
+func SnapshotDate(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// snapshotEnd returns when the day of a snapshot ends, which is when its balance holds
+func snapshotEnd(snapshotDate time.Time) time.Time {
+	return SnapshotDate(snapshotDate).AddDate(0, 0, 1)
+}
+
+type GetBalanceAsOfParams struct {
+	AccountID int64
+	AsOf      time.Time
+}
+
+type GetBalanceAsOfResult struct {
+	// Account is the account as it is now
+	Account Account
+	Balance int64
+	AsOf    time.Time
+}
+
+// GetBalanceAsOf computes the balance of an account at a point in time, from the nearest snapshot taken before
+// and the entries created since. Without such a snapshot, the entries created since then are taken back from the
+// current balance. Balances changed without entries, by UpdateAccount, are only seen by the snapshots after them.
+func (store *txStore) GetBalanceAsOf(ctx context.Context, arg GetBalanceAsOfParams) (GetBalanceAsOfResult, error) {
+	var result GetBalanceAsOfResult
+
+	// the account, the snapshot and the entries are all read as of the start of the transaction
+	err := store.execTx(ctx, func(q Querier) error {
+		result = GetBalanceAsOfResult{AsOf: arg.AsOf}
+		var err error
+
+		result.Account, err = q.GetAccount(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		result.Balance, err = balanceAsOf(ctx, q, result.Account, arg.AsOf)
+		return err
+	}, Isolation(pgx.RepeatableRead), ReadOnly())
+
+	return result, err
+}
+
+// balanceAsOf computes the balance of account at asOf with q, as GetBalanceAsOf does
+func balanceAsOf(ctx context.Context, q Querier, account Account, asOf time.Time) (int64, error) {
+	snapshot, err := q.GetBalanceSnapshotBefore(ctx, GetBalanceSnapshotBeforeParams{
+		AccountID: account.ID,
+		Before:    SnapshotDate(asOf),
+	})
+	if errors.Is(err, ErrRecordNotFound) {
+		since, err := q.SumEntries(ctx, SumEntriesParams{
+			AccountID: account.ID,
+			Since:     asOf,
+		})
+		return account.Balance - since, err
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	since, err := q.SumEntries(ctx, SumEntriesParams{
+		AccountID: account.ID,
+		Since:     snapshotEnd(snapshot.SnapshotDate),
+		Until:     pgtype.Timestamptz{Time: asOf, Valid: true},
+	})
+	return snapshot.Balance + since, err
+}
+
+type EndOfDayTxParams struct {
+	// Day is the day to close, in UTC
+	Day time.Time
+}
+
+type EndOfDayTxResult struct {
+	// Snapshots is the number of snapshots taken, Frozen the number of those frozen
+	Snapshots int64
+	Frozen    int64
+}
+
+// EndOfDayTx takes the balance snapshots of every account at the end of a day, and freezes them for reporting.
+// Frozen snapshots are not taken again, so closing a day twice leaves it as it was closed the first time.
+func (store *txStore) EndOfDayTx(ctx context.Context, arg EndOfDayTxParams) (EndOfDayTxResult, error) {
+	var result EndOfDayTxResult
+
+	err := store.execTx(ctx, func(q Querier) error {
+		result = EndOfDayTxResult{}
+		var err error
+
+		day := SnapshotDate(arg.Day)
+		result.Snapshots, err = q.TakeBalanceSnapshots(ctx, TakeBalanceSnapshotsParams{
+			SnapshotDate: day,
+			DayEnd:       snapshotEnd(day),
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Frozen, err = q.FreezeBalanceSnapshots(ctx, day)
+		return err
+	})
+
+	return result, err
+}
@@ -0,0 +1,40 @@
+package db
+
+import "context"
+
+type CreateUserTxParams struct {
+	CreateUserParams
+	// AfterCreate runs inside the transaction, after the user is inserted.
+	// Returning an error rolls back the user creation.
+	AfterCreate func(q Querier, user User) error
+}
+
+type CreateUserTxResult struct {
+	User User
+}
+
+// CreateUserTx creates a user and runs AfterCreate within the same transaction
+func (store *txStore) CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error) {
+	var result CreateUserTxResult
+
+	err := store.execTx(ctx, func(q Querier) error {
+		result = CreateUserTxResult{}
+		var err error
+
+		result.User, err = q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
+			return err
+		}
+
+		if err = recordAudit(ctx, q, userChange(AuditUserCreate, nil, &result.User)); err != nil {
+			return err
+		}
+
+		if arg.AfterCreate == nil {
+			return nil
+		}
+		return arg.AfterCreate(q, result.User)
+	})
+
+	return result, err
+}
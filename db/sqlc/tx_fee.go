@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pakojabi/simplebank/fee"
+)
+
+type QuoteTransferFeeParams struct {
+	Schedule string `json:"schedule"`
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+}
+
+// FeeCharge is the fee charged for a transfer
+type FeeCharge struct {
+	Schedule  string        `json:"schedule"`
+	Breakdown fee.Breakdown `json:"breakdown"`
+	// Transfer moves the fee from the sender to the fee revenue account. It is nil when the fee is zero.
+	Transfer *TransferTxResult `json:"transfer,omitempty"`
+}
+
+// QuoteTransferFee computes the fee TransferTx charges for a transfer, without moving any money
+func (store *txStore) QuoteTransferFee(ctx context.Context, arg QuoteTransferFeeParams) (fee.Breakdown, error) {
+	return quoteFee(ctx, store.Querier, arg)
+}
+
+// quoteFee computes the fee of a transfer with the rules of its schedule in its currency.
+// A currency the schedule has no rules for is free.
+func quoteFee(ctx context.Context, q Querier, arg QuoteTransferFeeParams) (fee.Breakdown, error) {
+	if _, err := q.GetFeeSchedule(ctx, arg.Schedule); err != nil {
+		return fee.Breakdown{}, fmt.Errorf("cannot get fee schedule %s: %w", arg.Schedule, err)
+	}
+
+	rows, err := q.ListFeeRules(ctx, ListFeeRulesParams{
+		Schedule: arg.Schedule,
+		Currency: arg.Currency,
+	})
+	if err != nil {
+		return fee.Breakdown{}, err
+	}
+
+	rules := make([]fee.Rule, len(rows))
+	for i, row := range rows {
+		rules[i] = fee.Rule{
+			ID:        row.ID,
+			MinAmount: row.MinAmount,
+			FlatFee:   row.FlatFee,
+			RatePPM:   row.RatePpm,
+			MinFee:    row.MinFee,
+			MaxFee:    row.MaxFee,
+		}
+	}
+	return fee.Compute(rules, arg.Amount), nil
+}
+
+// chargeFee charges the sender of the transfer in result the fee schedule sets for it, with q. The fee is moved
+// to the fee revenue account of the currency once the transfer holds the lock of the sender, so every transfer
+// locks that account last. Like any transfer, it fails with ErrInsufficientFunds if the sender cannot cover the fee
+// on top of the amount, which rolls back the whole transaction.
+func chargeFee(ctx context.Context, q Querier, schedule string, result *TransferTxResult) error {
+	currency := result.FromAccount.Currency
+	breakdown, err := quoteFee(ctx, q, QuoteTransferFeeParams{
+		Schedule: schedule,
+		Currency: currency,
+		Amount:   result.Transfer.Amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	result.Fee = &FeeCharge{Schedule: schedule, Breakdown: breakdown}
+	if breakdown.Total == 0 {
+		return nil
+	}
+
+	revenue, err := systemAccount(ctx, q, SystemFeeRevenue, currency)
+	if err != nil {
+		return err
+	}
+
+	charged, err := transfer(ctx, q, TransferTxParams{
+		FromAccountID: result.Transfer.FromAccountID,
+		ToAccountID:   revenue.ID,
+		Amount:        breakdown.Total,
+	})
+	if err != nil {
+		return err
+	}
+	result.Fee.Transfer = &charged
+
+	_, err = q.CreateTransferFee(ctx, CreateTransferFeeParams{
+		TransferID:    result.Transfer.ID,
+		FeeTransferID: charged.Transfer.ID,
+		Schedule:      schedule,
+		RuleID:        breakdown.RuleID,
+		Flat:          breakdown.Flat,
+		Percentage:    breakdown.Percentage,
+		Adjustment:    breakdown.Adjustment,
+		Total:         breakdown.Total,
+	})
+	return err
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/interest"
+)
+
+// ErrDayNotOver is returned when interest would accrue on a day that is not over yet
+var ErrDayNotOver = errors.New("day is not over yet")
+
+type AccrueInterestTxParams struct {
+	AccountID int64
+	// Day is the day interest accrues on, in UTC
+	Day time.Time
+}
+
+type AccrueInterestTxResult struct {
+	Accrual InterestAccrual
+	// Accrued is false if the account had already accrued interest on that day, which is then left as it was
+	Accrued bool
+	// Payout is set on the payout days of the product of the account
+	Payout *InterestPayout
+	// Transfer is set when the payout is at least a minor unit: it is paid from the interest expense account
+	Transfer *TransferTxResult
+}
+
+// AccrueInterestTx accrues the interest an account earns on a day, on its balance at the end of the day, and pays
+// out what it accrued since the last payout when the day ends a compounding period of its product.
+// Accruing interest twice on the same day does nothing, so a day can safely be accrued again after a failure.
+func (store *txStore) AccrueInterestTx(ctx context.Context, arg AccrueInterestTxParams) (AccrueInterestTxResult, error) {
+	var result AccrueInterestTxResult
+
+	day := SnapshotDate(arg.Day)
+	if snapshotEnd(day).After(time.Now()) {
+		return result, ErrDayNotOver
+	}
+
+	err := store.execTx(ctx, func(q Querier) error {
+		result = AccrueInterestTxResult{}
+
+		// the lock of the account makes concurrent accruals of the same day wait for each other
+		account, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		product, err := q.GetAccountProduct(ctx, account.Product)
+		if err != nil {
+			return fmt.Errorf("cannot get product %s: %w", account.Product, err)
+		}
+		dayCount, err := interest.ParseDayCount(product.DayCount)
+		if err != nil {
+			return err
+		}
+		compounding, err := interest.ParseCompounding(product.Compounding)
+		if err != nil {
+			return err
+		}
+
+		balance, err := balanceAsOf(ctx, q, account, snapshotEnd(day))
+		if err != nil {
+			return err
+		}
+
+		result.Accrual, err = q.CreateInterestAccrual(ctx, CreateInterestAccrualParams{
+			AccountID:     account.ID,
+			AccrualDate:   day,
+			Balance:       balance,
+			AnnualRatePpm: product.AnnualRatePpm,
+			DayCount:      product.DayCount,
+			AmountMicros:  interest.DailyAccrual(balance, product.AnnualRatePpm, dayCount, day),
+		})
+		if errors.Is(err, ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result.Accrued = true
+
+		if !compounding.IsPayoutDay(day) {
+			return nil
+		}
+		return payInterest(ctx, q, account, day, &result)
+	})
+	if err != nil || result.Transfer == nil {
+		return result, err
+	}
+
+	store.broker.publish(
+		AccountEvent{Account: result.Transfer.FromAccount, Entry: result.Transfer.FromEntry},
+		AccountEvent{Account: result.Transfer.ToAccount, Entry: result.Transfer.ToEntry},
+	)
+	return result, nil
+}
+
+// payInterest pays out the interest account accrued after its last payout up to day, with the carry of that
+// payout, and carries what is left over to the next one
+func payInterest(ctx context.Context, q Querier, account Account, day time.Time, result *AccrueInterestTxResult) error {
+	var after time.Time
+	var carry int64
+
+	last, err := q.GetLastInterestPayout(ctx, GetLastInterestPayoutParams{
+		AccountID: account.ID,
+		Before:    day,
+	})
+	switch {
+	case err == nil:
+		after, carry = last.PayoutDate, last.CarryMicros
+	case !errors.Is(err, ErrRecordNotFound):
+		return err
+	}
+
+	accrued, err := q.SumInterestAccruals(ctx, SumInterestAccrualsParams{
+		AccountID: account.ID,
+		After:     after,
+		Until:     day,
+	})
+	if err != nil {
+		return err
+	}
+
+	arg := CreateInterestPayoutParams{
+		AccountID:     account.ID,
+		PayoutDate:    day,
+		AccruedMicros: accrued + carry,
+	}
+	arg.Amount, arg.CarryMicros = interest.Split(arg.AccruedMicros)
+
+	if arg.Amount > 0 {
+		expense, err := systemAccount(ctx, q, SystemInterestExpense, account.Currency)
+		if err != nil {
+			return err
+		}
+
+		paid, err := transfer(ctx, q, TransferTxParams{
+			FromAccountID: expense.ID,
+			ToAccountID:   account.ID,
+			Amount:        arg.Amount,
+		})
+		if err != nil {
+			return err
+		}
+		result.Transfer = &paid
+		arg.TransferID = pgtype.Int8{Int64: paid.Transfer.ID, Valid: true}
+	}
+
+	payout, err := q.CreateInterestPayout(ctx, arg)
+	if err != nil {
+		return err
+	}
+	result.Payout = &payout
+
+	if result.Transfer == nil {
+		return nil
+	}
+	return recordAudit(ctx, q, auditChange{
+		action:       AuditInterestPay,
+		resourceType: AuditResourceTransfer,
+		resourceID:   strconv.FormatInt(result.Transfer.Transfer.ID, 10),
+		after:        result,
+	})
+}
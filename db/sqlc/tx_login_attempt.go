@@ -0,0 +1,50 @@
+package db
+
+import "context"
+
+type LoginAttemptTxParams struct {
+	CreateLoginEventParams
+	// BeforeCreate runs inside the transaction, once the attempts of the username and client IP are locked,
+	// to count their failures. It returns the outcome to record the attempt with.
+	BeforeCreate func(q Querier) (outcome string, err error)
+}
+
+type LoginAttemptTxResult struct {
+	LoginEvent LoginEvent
+}
+
+// LoginAttemptTx records a login attempt after BeforeCreate has counted the failures of its username and client IP.
+// Concurrent attempts on the same username or client IP are serialized, so each one counts those recorded before it.
+// An empty username or client IP is not locked.
+func (store *txStore) LoginAttemptTx(ctx context.Context, arg LoginAttemptTxParams) (LoginAttemptTxResult, error) {
+	var result LoginAttemptTxResult
+
+	err := store.execTx(ctx, func(q Querier) error {
+		result = LoginAttemptTxResult{}
+		var err error
+
+		// always the username first, so that two attempts cannot wait for each other
+		if arg.Username != "" {
+			if err = q.LockLoginAttempts(ctx, "login_user:"+arg.Username); err != nil {
+				return err
+			}
+		}
+		if arg.ClientIp != "" {
+			if err = q.LockLoginAttempts(ctx, "login_ip:"+arg.ClientIp); err != nil {
+				return err
+			}
+		}
+
+		event := arg.CreateLoginEventParams
+		if arg.BeforeCreate != nil {
+			if event.Outcome, err = arg.BeforeCreate(q); err != nil {
+				return err
+			}
+		}
+
+		result.LoginEvent, err = q.CreateLoginEvent(ctx, event)
+		return err
+	})
+
+	return result, err
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// DefaultMaxTxRetries is how many times a transaction aborted by a conflict is retried, unless MaxRetries says otherwise
+	DefaultMaxTxRetries = 3
+
+	minRetryBackoff = 10 * time.Millisecond
+	maxRetryBackoff = time.Second
+)
+
+// Reasons Postgres aborts a transaction that conflicts with concurrent ones.
+// Such transactions can be retried as is.
+const (
+	ConflictDeadlock             = "deadlock"
+	ConflictSerializationFailure = "serialization_failure"
+)
+
+type txOptions struct {
+	isoLevel   pgx.TxIsoLevel
+	accessMode pgx.TxAccessMode
+	maxRetries int
+	onRetry    func(ctx context.Context, err error)
+}
+
+// TxOption customises the transactions run by a store
+type TxOption func(*txOptions)
+
+// Isolation sets the isolation level of the transaction. Postgres defaults to READ COMMITTED.
+func Isolation(level pgx.TxIsoLevel) TxOption {
+	return func(opts *txOptions) {
+		opts.isoLevel = level
+	}
+}
+
+// Serializable runs the transaction at the SERIALIZABLE isolation level, for flows that must not
+// observe any anomaly. Conflicting transactions are aborted by Postgres, then retried.
+func Serializable() TxOption {
+	return Isolation(pgx.Serializable)
+}
+
+// ReadOnly rejects any write made by the transaction
+func ReadOnly() TxOption {
+	return func(opts *txOptions) {
+		opts.accessMode = pgx.ReadOnly
+	}
+}
+
+// MaxRetries sets how many times a transaction aborted by a deadlock or a serialization failure is retried.
+// Zero disables retries.
+func MaxRetries(n int) TxOption {
+	return func(opts *txOptions) {
+		opts.maxRetries = n
+	}
+}
+
+// OnRetry calls fn with the conflict error each time a transaction is retried
+func OnRetry(fn func(ctx context.Context, err error)) TxOption {
+	return func(opts *txOptions) {
+		opts.onRetry = fn
+	}
+}
+
+type txOptionsKey struct{}
+
+// WithTxOptions returns a copy of ctx in which the transactions of the store methods called with it apply opts,
+// on top of the options of the store. This is how callers opt into SERIALIZABLE for sensitive flows:
+//
+//	store.TransferTx(db.WithTxOptions(ctx, db.Serializable()), arg)
+func WithTxOptions(ctx context.Context, opts ...TxOption) context.Context {
+	previous, _ := ctx.Value(txOptionsKey{}).([]TxOption)
+	merged := make([]TxOption, 0, len(previous)+len(opts))
+	merged = append(merged, previous...)
+	merged = append(merged, opts...)
+	return context.WithValue(ctx, txOptionsKey{}, merged)
+}
+
+// txOptionsFrom applies the options of the store, then those of the call, then those of ctx
+func txOptionsFrom(ctx context.Context, defaults []TxOption, opts []TxOption) txOptions {
+	options := txOptions{maxRetries: DefaultMaxTxRetries}
+	for _, opt := range defaults {
+		opt(&options)
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if fromCtx, ok := ctx.Value(txOptionsKey{}).([]TxOption); ok {
+		for _, opt := range fromCtx {
+			opt(&options)
+		}
+	}
+	return options
+}
+
+// TxConflict returns why Postgres aborted the transaction that failed with err,
+// or an empty string if err is not a conflict with a concurrent transaction
+func TxConflict(err error) string {
+	switch ErrorCode(err) {
+	case DeadlockDetected:
+		return ConflictDeadlock
+	case SerializationFailure:
+		return ConflictSerializationFailure
+	default:
+		return ""
+	}
+}
+
+// retryBackoff is the jittered, exponential delay before the given retry, starting at 1
+func retryBackoff(retry int) time.Duration {
+	backoff := min(minRetryBackoff<<min(retry-1, 10), maxRetryBackoff)
+	// full jitter spreads the retries of transactions that conflicted with each other
+	return time.Duration(rand.Int63n(int64(backoff))) + 1
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxConflict(t *testing.T) {
+	require.Equal(t, ConflictDeadlock, TxConflict(&pgconn.PgError{Code: DeadlockDetected}))
+	require.Equal(t, ConflictSerializationFailure, TxConflict(&pgconn.PgError{Code: SerializationFailure}))
+	require.Empty(t, TxConflict(ErrUniqueViolation))
+	require.Equal(t, ConflictSerializationFailure, TxConflict(fmt.Errorf("tx err: %w", &pgconn.PgError{Code: SerializationFailure})))
+	require.Empty(t, TxConflict(errors.New("connection refused")))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for retry := 1; retry <= 20; retry++ {
+		backoff := retryBackoff(retry)
+		require.Positive(t, backoff)
+		require.LessOrEqual(t, backoff, maxRetryBackoff)
+	}
+}
+
+func TestTxOptionsFrom(t *testing.T) {
+	ctx := WithTxOptions(context.Background(), Serializable())
+	ctx = WithTxOptions(ctx, ReadOnly())
+
+	options := txOptionsFrom(ctx, []TxOption{MaxRetries(5), Isolation(pgx.RepeatableRead)}, nil)
+	require.Equal(t, pgx.Serializable, options.isoLevel)
+	require.Equal(t, pgx.ReadOnly, options.accessMode)
+	require.Equal(t, 5, options.maxRetries)
+
+	options = txOptionsFrom(context.Background(), nil, nil)
+	require.Empty(t, options.isoLevel)
+	require.Equal(t, DefaultMaxTxRetries, options.maxRetries)
+}
+
+func TestExecTxRetry(t *testing.T) {
+	requireDB(t)
+
+	store := NewStore(testDB, MaxRetries(2)).(*SQLStore)
+
+	var retried []error
+	ctx := WithTxOptions(context.Background(), OnRetry(func(ctx context.Context, err error) {
+		retried = append(retried, err)
+	}))
+
+	attempts := 0
+	err := store.execTx(ctx, func(q Querier) error {
+		attempts++
+		if attempts < 3 {
+			return &pgconn.PgError{Code: SerializationFailure}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+	require.Len(t, retried, 2)
+
+	// the retries are exhausted
+	attempts = 0
+	err = store.execTx(ctx, func(q Querier) error {
+		attempts++
+		return &pgconn.PgError{Code: DeadlockDetected}
+	})
+	require.Equal(t, ConflictDeadlock, TxConflict(err))
+	require.Equal(t, 3, attempts)
+
+	// other errors are not retried
+	attempts = 0
+	err = store.execTx(ctx, func(q Querier) error {
+		attempts++
+		return ErrRecordNotFound
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+	require.Equal(t, 1, attempts)
+}
+
+func TestExecTxReadOnly(t *testing.T) {
+	requireDB(t)
+
+	store := NewStore(testDB).(*SQLStore)
+
+	err := store.execTx(context.Background(), func(q Querier) error {
+		_, err := q.CreateAccount(context.Background(), CreateAccountParams{Owner: "nobody", Currency: "USD"})
+		return err
+	}, ReadOnly())
+
+	// read_only_sql_transaction
+	require.Equal(t, "25006", ErrorCode(err))
+}
+
+// TestTransferTxSerializable runs transfers in both directions at once: they conflict with each other,
+// whatever the order of the account IDs, and are retried until they all go through
+func TestTransferTxSerializable(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	store := NewStore(testDB, MaxRetries(20))
+
+	account1 := createFundedAccount(t)
+	account2 := createFundedAccount(t)
+
+	n := 10
+	amount := int64(10)
+	errs := make(chan error)
+
+	for i := 0; i < n; i++ {
+		fromAccountID, toAccountID := account1.ID, account2.ID
+		if i%2 != 0 {
+			fromAccountID, toAccountID = account2.ID, account1.ID
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			_, err := store.TransferTx(WithTxOptions(ctx, Serializable()), TransferTxParams{
+				FromAccountID: fromAccountID,
+				ToAccountID:   toAccountID,
+				Amount:        amount,
+			})
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	updatedAccount2, err := testQueries.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+
+	require.Equal(t, account1.Balance, updatedAccount1.Balance)
+	require.Equal(t, account2.Balance, updatedAccount2.Balance)
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+type ChangePasswordTxParams struct {
+	Username       string
+	HashedPassword string
+}
+
+type ChangePasswordTxResult struct {
+	User User
+}
+
+// ChangePasswordTx sets a new password and blocks every session of the user.
+// Tokens issued before the change are rejected from then on, since password_changed_at moves forward.
+func (store *txStore) ChangePasswordTx(ctx context.Context, arg ChangePasswordTxParams) (ChangePasswordTxResult, error) {
+	var result ChangePasswordTxResult
+
+	err := store.execTx(ctx, func(q Querier) error {
+		result = ChangePasswordTxResult{}
+		var err error
+		result.User, err = updatePassword(ctx, q, AuditUserPassword, arg.Username, arg.HashedPassword)
+		return err
+	})
+
+	return result, err
+}
+
+type ResetPasswordTxParams struct {
+	ResetId        int64
+	SecretCode     string
+	HashedPassword string
+}
+
+type ResetPasswordTxResult struct {
+	User          User
+	ResetPassword ResetPassword
+}
+
+// ResetPasswordTx consumes a reset code and changes the password like ChangePasswordTx.
+// It returns ErrRecordNotFound if the code is wrong, used or expired.
+func (store *txStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error) {
+	var result ResetPasswordTxResult
+
+	err := store.execTx(ctx, func(q Querier) error {
+		result = ResetPasswordTxResult{}
+		var err error
+
+		result.ResetPassword, err = q.UpdateResetPassword(ctx, UpdateResetPasswordParams{
+			ID:         arg.ResetId,
+			HashedCode: HashResetCode(arg.SecretCode),
+		})
+		if err != nil {
+			return err
+		}
+
+		result.User, err = updatePassword(ctx, q, AuditUserReset, result.ResetPassword.Username, arg.HashedPassword)
+		return err
+	})
+
+	return result, err
+}
+
+// HashResetCode hashes a reset password code, which is only stored hashed.
+// The codes are random enough that a plain SHA-256 is sufficient.
+func HashResetCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+// updatePassword changes the password and blocks the sessions of the user, recording both under action
+func updatePassword(ctx context.Context, q Querier, action string, username string, hashedPassword string) (User, error) {
+	before, err := q.GetUser(ctx, username)
+	if err != nil {
+		return User{}, err
+	}
+
+	user, err := q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+		Username:          username,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if err = recordAudit(ctx, q, userChange(action, &before, &user)); err != nil {
+		return user, err
+	}
+
+	if err = q.BlockUserSessions(ctx, username); err != nil {
+		return user, err
+	}
+	return user, recordAudit(ctx, q, sessionsBlocked(username))
+}
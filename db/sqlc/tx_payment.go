@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Payments move money into and out of the bank through an external payment rail. While the rail has not settled
+// a payment yet, its amount is held in the suspense account of its currency: a deposit moves it there from the
+// clearing account, which stands for the money of the bank at the rails, and a withdrawal from the account.
+// Once the payment settles or fails, the amount leaves the suspense account for where it ends up. So the entries
+// of a deposit reach the account only once it settles, and a withdrawal is debited at once and credited back if
+// it fails. The suspense account of a currency holds the amount of its pending payments. The entries of both
+// transfers refer to their payment, whose status tells whether they are pending, settled or failed.
+
+// Directions of payments
+const (
+	PaymentDeposit    = "deposit"
+	PaymentWithdrawal = "withdrawal"
+)
+
+// Statuses of payments
+const (
+	PaymentPending = "pending"
+	PaymentSettled = "settled"
+	PaymentFailed  = "failed"
+)
+
+// ErrPaymentCompleted is returned when a payment that already settled or failed is completed again
+var ErrPaymentCompleted = errors.New("payment is already completed")
+
+type CreatePaymentTxParams struct {
+	AccountID int64
+	// Direction is PaymentDeposit or PaymentWithdrawal
+	Direction string
+	Rail      string
+	Amount    int64
+}
+
+type PaymentTxResult struct {
+	Payment Payment `json:"payment"`
+	// Transfer moves the amount into or out of the suspense account
+	Transfer TransferTxResult `json:"transfer"`
+}
+
+// CreatePaymentTx creates a pending payment, and holds its amount in the suspense account of its currency.
+// It returns ErrInsufficientFunds for a withdrawal larger than the balance of the account.
+func (store *txStore) CreatePaymentTx(ctx context.Context, arg CreatePaymentTxParams) (PaymentTxResult, error) {
+	var result PaymentTxResult
+
+	err := store.execTx(ctx, func(q Querier) error {
+		result = PaymentTxResult{}
+
+		account, err := q.GetAccount(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		suspense, err := systemAccount(ctx, q, SystemSuspense, account.Currency)
+		if err != nil {
+			return err
+		}
+
+		from := account.ID
+		switch arg.Direction {
+		case PaymentDeposit:
+			clearing, err := systemAccount(ctx, q, SystemClearing, account.Currency)
+			if err != nil {
+				return err
+			}
+			from = clearing.ID
+		case PaymentWithdrawal:
+			// the balance is checked under the lock of the account, so concurrent withdrawals and transfers cannot
+			// overdraw it. The accounts are locked in the order of their ids, like addMoney does, to avoid deadlocks.
+			if suspense.ID < account.ID {
+				if _, err := q.GetAccountForUpdate(ctx, suspense.ID); err != nil {
+					return err
+				}
+			}
+			account, err = q.GetAccountForUpdate(ctx, account.ID)
+			if err != nil {
+				return err
+			}
+			if account.Balance < arg.Amount {
+				return ErrInsufficientFunds.With("account_id", strconv.FormatInt(account.ID, 10))
+			}
+		default:
+			return fmt.Errorf("unsupported payment direction %q", arg.Direction)
+		}
+
+		// the payment is created first, so that the entries of its transfer can refer to it
+		payment, err := q.CreatePayment(ctx, CreatePaymentParams{
+			AccountID: account.ID,
+			Direction: arg.Direction,
+			Rail:      arg.Rail,
+			Amount:    arg.Amount,
+			Currency:  account.Currency,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Transfer, err = transfer(ctx, q, TransferTxParams{
+			FromAccountID: from,
+			ToAccountID:   suspense.ID,
+			Amount:        arg.Amount,
+			paymentID:     pgtype.Int8{Int64: payment.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Payment, err = q.SetPaymentPendingTransfer(ctx, SetPaymentPendingTransferParams{
+			ID:                payment.ID,
+			PendingTransferID: pgtype.Int8{Int64: result.Transfer.Transfer.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, q, auditChange{
+			action:       AuditPaymentCreate,
+			resourceType: AuditResourcePayment,
+			resourceID:   strconv.FormatInt(result.Payment.ID, 10),
+			after:        result,
+		})
+	})
+	if err != nil {
+		return result, err
+	}
+
+	store.broker.publish(
+		AccountEvent{Account: result.Transfer.FromAccount, Entry: result.Transfer.FromEntry},
+		AccountEvent{Account: result.Transfer.ToAccount, Entry: result.Transfer.ToEntry},
+	)
+	return result, nil
+}
+
+type CompletePaymentTxParams struct {
+	PaymentID int64
+	// Settled is false if the payment failed
+	Settled       bool
+	FailureReason string
+}
+
+// CompletePaymentTx settles or fails a pending payment, and moves its amount out of the suspense account:
+// to the account for settled deposits and failed withdrawals, and to the clearing account otherwise.
+// It returns ErrPaymentCompleted if the payment is not pending anymore, so a rail can report an outcome twice.
+func (store *txStore) CompletePaymentTx(ctx context.Context, arg CompletePaymentTxParams) (PaymentTxResult, error) {
+	var result PaymentTxResult
+
+	err := store.execTx(ctx, func(q Querier) error {
+		result = PaymentTxResult{}
+
+		// the lock of the payment makes concurrent outcomes of the same payment wait for each other
+		payment, err := q.GetPaymentForUpdate(ctx, arg.PaymentID)
+		if err != nil {
+			return err
+		}
+		if payment.Status != PaymentPending {
+			result.Payment = payment
+			return ErrPaymentCompleted
+		}
+
+		suspense, err := systemAccount(ctx, q, SystemSuspense, payment.Currency)
+		if err != nil {
+			return err
+		}
+
+		to := payment.AccountID
+		if arg.Settled != (payment.Direction == PaymentDeposit) {
+			clearing, err := systemAccount(ctx, q, SystemClearing, payment.Currency)
+			if err != nil {
+				return err
+			}
+			to = clearing.ID
+		}
+
+		result.Transfer, err = transfer(ctx, q, TransferTxParams{
+			FromAccountID: suspense.ID,
+			ToAccountID:   to,
+			Amount:        payment.Amount,
+			paymentID:     pgtype.Int8{Int64: payment.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		completed := CompletePaymentParams{
+			ID:              payment.ID,
+			Status:          PaymentSettled,
+			FinalTransferID: pgtype.Int8{Int64: result.Transfer.Transfer.ID, Valid: true},
+		}
+		action := AuditPaymentSettle
+		if !arg.Settled {
+			completed.Status = PaymentFailed
+			completed.FailureReason = arg.FailureReason
+			action = AuditPaymentFail
+		}
+
+		result.Payment, err = q.CompletePayment(ctx, completed)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, q, auditChange{
+			action:       action,
+			resourceType: AuditResourcePayment,
+			resourceID:   strconv.FormatInt(payment.ID, 10),
+			before:       payment,
+			after:        result,
+		})
+	})
+	if err != nil {
+		return result, err
+	}
+
+	store.broker.publish(
+		AccountEvent{Account: result.Transfer.FromAccount, Entry: result.Transfer.FromEntry},
+		AccountEvent{Account: result.Transfer.ToAccount, Entry: result.Transfer.ToEntry},
+	)
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package db
+
+import "context"
+
+type EnableTOTPTxParams struct {
+	Username string
+	// Step is the time step of the code that confirmed the enrollment
+	Step                int64
+	HashedRecoveryCodes []string
+}
+
+type EnableTOTPTxResult struct {
+	TOTPSecret    TotpSecret
+	RecoveryCodes []RecoveryCode
+}
+
+// EnableTOTPTx enables a pending TOTP secret and replaces the user's recovery codes.
+// It returns ErrRecordNotFound if there is no pending secret.
+func (store *txStore) EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (EnableTOTPTxResult, error) {
+	var result EnableTOTPTxResult
+
+	err := store.execTx(ctx, func(q Querier) error {
+		// a retried attempt must not append to the codes of the aborted one
+		result = EnableTOTPTxResult{}
+		var err error
+
+		result.TOTPSecret, err = q.EnableTOTPSecret(ctx, EnableTOTPSecretParams{
+			Username: arg.Username,
+			Step:     arg.Step,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = q.DeleteRecoveryCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+
+		for _, hashedCode := range arg.HashedRecoveryCodes {
+			code, err := q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username:   arg.Username,
+				HashedCode: hashedCode,
+			})
+			if err != nil {
+				return err
+			}
+			result.RecoveryCodes = append(result.RecoveryCodes, code)
+		}
+
+		// neither the secret nor the recovery codes belong in the audit log
+		return recordAudit(ctx, q, auditChange{
+			action:       AuditUserTOTP,
+			resourceType: AuditResourceUser,
+			resourceID:   arg.Username,
+			after:        map[string]bool{"totp_enabled": true},
+		})
+	})
+
+	return result, err
+}
+
+// DisableTOTPTx deletes an enabled TOTP secret and the user's recovery codes, so that the user can enroll again.
+// It returns ErrRecordNotFound if two-factor authentication is not enabled.
+func (store *txStore) DisableTOTPTx(ctx context.Context, username string) error {
+	return store.execTx(ctx, func(q Querier) error {
+		if _, err := q.DeleteTOTPSecret(ctx, username); err != nil {
+			return err
+		}
+
+		if err := q.DeleteRecoveryCodes(ctx, username); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, q, auditChange{
+			action:       AuditUserTOTPOff,
+			resourceType: AuditResourceUser,
+			resourceID:   username,
+			before:       map[string]bool{"totp_enabled": true},
+			after:        map[string]bool{"totp_enabled": false},
+		})
+	})
+}
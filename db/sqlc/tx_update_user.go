@@ -0,0 +1,45 @@
+package db
+
+import "context"
+
+type UpdateUserTxParams struct {
+	UpdateUserParams
+	// AfterUpdate runs inside the transaction, after the user is updated.
+	// Returning an error rolls back the update.
+	AfterUpdate func(q Querier, user User) error
+}
+
+type UpdateUserTxResult struct {
+	User User
+}
+
+// UpdateUserTx updates the fields set in arg and runs AfterUpdate within the same transaction.
+// Changing the email resets is_email_verified.
+func (store *txStore) UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error) {
+	var result UpdateUserTxResult
+
+	err := store.execTx(ctx, func(q Querier) error {
+		result = UpdateUserTxResult{}
+
+		before, err := q.GetUser(ctx, arg.Username)
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.UpdateUser(ctx, arg.UpdateUserParams)
+		if err != nil {
+			return err
+		}
+
+		if err = recordAudit(ctx, q, userChange(AuditUserUpdate, &before, &result.User)); err != nil {
+			return err
+		}
+
+		if arg.AfterUpdate == nil {
+			return nil
+		}
+		return arg.AfterUpdate(q, result.User)
+	})
+
+	return result, err
+}
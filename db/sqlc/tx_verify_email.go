@@ -0,0 +1,49 @@
+package db
+
+import "context"
+
+type VerifyEmailTxParams struct {
+	EmailId    int64
+	SecretCode string
+}
+
+type VerifyEmailTxResult struct {
+	User        User
+	VerifyEmail VerifyEmail
+}
+
+// VerifyEmailTx consumes a verification code and marks the user's email as verified.
+// It returns ErrRecordNotFound if the code is wrong, used, expired, or the user has changed email since.
+func (store *txStore) VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error) {
+	var result VerifyEmailTxResult
+
+	err := store.execTx(ctx, func(q Querier) error {
+		result = VerifyEmailTxResult{}
+		var err error
+
+		result.VerifyEmail, err = q.UpdateVerifyEmail(ctx, UpdateVerifyEmailParams{
+			ID:         arg.EmailId,
+			SecretCode: arg.SecretCode,
+		})
+		if err != nil {
+			return err
+		}
+
+		before, err := q.GetUser(ctx, result.VerifyEmail.Username)
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.VerifyUserEmail(ctx, VerifyUserEmailParams{
+			Username: result.VerifyEmail.Username,
+			Email:    result.VerifyEmail.Email,
+		})
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, q, userChange(AuditUserVerify, &before, &result.User))
+	})
+
+	return result, err
+}
@@ -5,18 +5,23 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/pakojabi/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
 
 
 func TestCreateUser(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	createRandomUser(t)
 }
 
 func TestGetUser(t *testing.T) {
+	requireDB(t)
+
 	defer cleanup()
 
 	user1 := createRandomUser(t)
@@ -51,6 +56,7 @@ func createTestUser(t *testing.T, username string, hashed_password string, full_
 
 	require.False(t, user.CreatedAt.IsZero())
 	require.True(t, user.PasswordChangedAt.IsZero())
+	require.Equal(t, util.DepositorRole, user.Role)
 
 	return user
 }
@@ -67,3 +73,63 @@ func createRandomUser(t *testing.T) User {
 		util.RandomString(4) + "@" + util.RandomString(5) + "." + util.RandomString(3),
 	)
 }
+
+func TestUpdateUserOnlyFullName(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	oldUser := createRandomUser(t)
+	newFullName := util.RandomString(6) + " " + util.RandomString(6)
+
+	updatedUser, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: oldUser.Username,
+		FullName: pgtype.Text{String: newFullName, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newFullName, updatedUser.FullName)
+	require.Equal(t, oldUser.Email, updatedUser.Email)
+	require.Equal(t, oldUser.IsEmailVerified, updatedUser.IsEmailVerified)
+}
+
+func TestUpdateUserEmailResetsVerification(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	oldUser := createRandomUser(t)
+	verifiedUser, err := testQueries.VerifyUserEmail(context.Background(), VerifyUserEmailParams{
+		Username: oldUser.Username,
+		Email:    oldUser.Email,
+	})
+	require.NoError(t, err)
+	require.True(t, verifiedUser.IsEmailVerified)
+
+	// submitting the same email keeps it verified
+	updatedUser, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: oldUser.Username,
+		Email:    pgtype.Text{String: oldUser.Email, Valid: true},
+	})
+	require.NoError(t, err)
+	require.True(t, updatedUser.IsEmailVerified)
+
+	newEmail := util.RandomString(6) + "@email.com"
+	updatedUser, err = testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: oldUser.Username,
+		Email:    pgtype.Text{String: newEmail, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newEmail, updatedUser.Email)
+	require.Equal(t, oldUser.FullName, updatedUser.FullName)
+	require.False(t, updatedUser.IsEmailVerified)
+}
+
+func TestUpdateUserNotFound(t *testing.T) {
+	requireDB(t)
+
+	_, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: util.RandomOwner(),
+		FullName: pgtype.Text{String: util.RandomString(6), Valid: true},
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
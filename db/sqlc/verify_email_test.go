@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomVerifyEmail(t *testing.T, user User) VerifyEmail {
+	arg := CreateVerifyEmailParams{
+		Username:   user.Username,
+		Email:      user.Email,
+		SecretCode: util.RandomString(32),
+	}
+
+	verifyEmail, err := testQueries.CreateVerifyEmail(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, verifyEmail.ID)
+	require.Equal(t, arg.Username, verifyEmail.Username)
+	require.Equal(t, arg.Email, verifyEmail.Email)
+	require.Equal(t, arg.SecretCode, verifyEmail.SecretCode)
+	require.False(t, verifyEmail.IsUsed)
+	require.WithinDuration(t, verifyEmail.CreatedAt.Add(15*time.Minute), verifyEmail.ExpiredAt, time.Second)
+
+	return verifyEmail
+}
+
+func TestCreateVerifyEmail(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	createRandomVerifyEmail(t, createRandomUser(t))
+}
+
+func TestVerifyEmailTx(t *testing.T) {
+	requireDB(t)
+
+	defer cleanup()
+
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	require.False(t, user.IsEmailVerified)
+	verifyEmail := createRandomVerifyEmail(t, user)
+
+	// wrong code
+	_, err := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailId:    verifyEmail.ID,
+		SecretCode: util.RandomString(32),
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	result, err := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailId:    verifyEmail.ID,
+		SecretCode: verifyEmail.SecretCode,
+	})
+	require.NoError(t, err)
+	require.True(t, result.VerifyEmail.IsUsed)
+	require.True(t, result.User.IsEmailVerified)
+
+	// codes are single use
+	_, err = store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailId:    verifyEmail.ID,
+		SecretCode: verifyEmail.SecretCode,
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
@@ -0,0 +1,75 @@
+// Package fee computes the fees of transfers from the rules of a fee schedule.
+//
+// A schedule has rules by currency, and each rule is a tier: it applies to the transfers of at least its minimum
+// amount, up to the minimum amount of the next tier. A rule charges a flat fee, a percentage of the amount, or
+// both, which the minimum and maximum fees of the rule then bound.
+package fee
+
+import (
+	"math/big"
+)
+
+// RatePPMUnit is the rate, in parts per million, of the whole amount
+const RatePPMUnit = 1_000_000
+
+// Rule is a tier of a fee schedule in a currency
+type Rule struct {
+	ID int64
+	// MinAmount is the smallest transfer amount the rule applies to
+	MinAmount int64
+	FlatFee   int64
+	RatePPM   int64
+	MinFee    int64
+	// MaxFee is 0 for no maximum
+	MaxFee int64
+}
+
+// Breakdown is how the fee of a transfer adds up, in minor units
+type Breakdown struct {
+	// RuleID is 0 if no rule applies, and the transfer is free
+	RuleID     int64 `json:"rule_id"`
+	Flat       int64 `json:"flat"`
+	Percentage int64 `json:"percentage"`
+	// Adjustment brings the flat fee and the percentage up to the minimum fee, or down to the maximum
+	Adjustment int64 `json:"adjustment"`
+	Total      int64 `json:"total"`
+}
+
+// Compute returns the fee of a transfer of amount, with the tier of rules it falls in
+func Compute(rules []Rule, amount int64) Breakdown {
+	var rule *Rule
+	for i := range rules {
+		if rules[i].MinAmount <= amount && (rule == nil || rules[i].MinAmount > rule.MinAmount) {
+			rule = &rules[i]
+		}
+	}
+	if rule == nil {
+		return Breakdown{}
+	}
+
+	breakdown := Breakdown{
+		RuleID:     rule.ID,
+		Flat:       rule.FlatFee,
+		Percentage: percentage(amount, rule.RatePPM),
+	}
+	fee := breakdown.Flat + breakdown.Percentage
+	total := max(fee, rule.MinFee)
+	if rule.MaxFee > 0 {
+		total = min(total, rule.MaxFee)
+	}
+	breakdown.Adjustment = total - fee
+	breakdown.Total = total
+	return breakdown
+}
+
+// percentage returns ratePPM of amount, rounded to the nearest minor unit and halves up
+func percentage(amount, ratePPM int64) int64 {
+	if amount <= 0 || ratePPM <= 0 {
+		return 0
+	}
+
+	fee := new(big.Int).Mul(big.NewInt(amount), big.NewInt(ratePPM))
+	fee.Add(fee, big.NewInt(RatePPMUnit/2))
+	fee.Quo(fee, big.NewInt(RatePPMUnit))
+	return fee.Int64()
+}
@@ -0,0 +1,50 @@
+package fee
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompute(t *testing.T) {
+	// 0.25 below 1000.00, then 0.1% of at least 1.50 and at most 10.00
+	rules := []Rule{
+		{ID: 2, MinAmount: 100_000, RatePPM: 1_000, MinFee: 150, MaxFee: 1_000},
+		{ID: 1, MinAmount: 1, FlatFee: 25},
+	}
+
+	testCases := []struct {
+		name      string
+		amount    int64
+		breakdown Breakdown
+	}{
+		{"BelowFirstTier", 0, Breakdown{}},
+		{"Flat", 50_000, Breakdown{RuleID: 1, Flat: 25, Total: 25}},
+		{"LastOfTier", 99_999, Breakdown{RuleID: 1, Flat: 25, Total: 25}},
+		{"RaisedToMinimum", 100_000, Breakdown{RuleID: 2, Percentage: 100, Adjustment: 50, Total: 150}},
+		{"Percentage", 500_000, Breakdown{RuleID: 2, Percentage: 500, Total: 500}},
+		{"RoundedHalfUp", 500_500, Breakdown{RuleID: 2, Percentage: 501, Total: 501}},
+		{"Maximum", 5_000_000, Breakdown{RuleID: 2, Percentage: 5_000, Adjustment: -4_000, Total: 1_000}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.breakdown, Compute(rules, tc.amount))
+		})
+	}
+}
+
+func TestComputeMinimumFee(t *testing.T) {
+	rules := []Rule{{ID: 1, FlatFee: 10, RatePPM: 2_000, MinFee: 50}}
+
+	require.Equal(t, Breakdown{RuleID: 1, Flat: 10, Percentage: 2, Adjustment: 38, Total: 50}, Compute(rules, 1_000))
+	require.Equal(t, Breakdown{RuleID: 1, Flat: 10, Percentage: 200, Total: 210}, Compute(rules, 100_000))
+}
+
+func TestComputeWithoutRules(t *testing.T) {
+	require.Equal(t, Breakdown{}, Compute(nil, 100_000))
+}
+
+func TestPercentageDoesNotOverflow(t *testing.T) {
+	require.Equal(t, int64(4_611_686_018_427_387_904), percentage(1<<62, 1_000_000))
+}
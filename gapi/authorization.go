@@ -0,0 +1,102 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/logger"
+	"github.com/pakojabi/simplebank/token"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	authorizationHeader = "authorization"
+	authorizationBearer = "bearer"
+	authorizationAPIKey = "apikey"
+)
+
+// authorizeUser verifies the bearer access token sent in the request metadata.
+// API keys are rejected: the RPCs using it manage the user's credentials, which no scope grants.
+func (server *Server) authorizeUser(ctx context.Context) (*token.Payload, error) {
+	payload, err := server.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := payload.CheckSession(); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// authorizeScope verifies the bearer access token or the API key sent in the request metadata.
+// API keys are only accepted if they grant scope.
+func (server *Server) authorizeScope(ctx context.Context, scope string) (*token.Payload, error) {
+	payload, err := server.authorize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := payload.CheckScope(scope); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// authorize returns domain errors, which RPCs convert with statusError
+func (server *Server) authorize(ctx context.Context) (*token.Payload, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, apperror.New(apperror.CodeUnauthenticated, "missing metadata")
+	}
+
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 {
+		return nil, apperror.New(apperror.CodeUnauthenticated, "authorization header is not provided")
+	}
+
+	fields := strings.Fields(values[0])
+	if len(fields) < 2 {
+		return nil, apperror.New(apperror.CodeUnauthenticated, "authorization header format not supported")
+	}
+
+	var payload *token.Payload
+	var err error
+
+	authType := strings.ToLower(fields[0])
+	switch authType {
+	case authorizationBearer:
+		payload, err = server.tokenMaker.Verify(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		// tokens issued to OAuth clients are only valid as long as the user's consent
+		if err := server.oauthProvider.CheckToken(ctx, payload); err != nil {
+			return nil, err
+		}
+	case authorizationAPIKey:
+		payload, err = server.apiKeys.Authenticate(ctx, fields[1])
+		if err != nil {
+			return nil, err
+		}
+	default:
+		err := apperror.New(apperror.CodeUnauthenticated, "unsupported authorization type")
+		return nil, err.With("authorization_type", authType)
+	}
+
+	// tokens and API keys issued before the last password change are no longer valid
+	passwordChangedAt, err := server.store.GetUserPasswordChangedAt(ctx, payload.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil, token.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if err := payload.ValidSince(passwordChangedAt); err != nil {
+		return nil, err
+	}
+
+	logger.SetUsername(ctx, payload.Username)
+	return payload, nil
+}
@@ -1,8 +1,12 @@
 package gapi
 
 import (
+	"fmt"
+
 	db "github.com/pakojabi/simplebank/db/sqlc"
 	"github.com/pakojabi/simplebank/pb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -13,5 +17,89 @@ func convertUser(user db.User) *pb.User {
 		Email: user.Email,
 		PasswordChangedAt: timestamppb.New(user.PasswordChangedAt),
 		CreatedAt: timestamppb.New(user.CreatedAt),
+		IsEmailVerified: user.IsEmailVerified,
+		Role: user.Role,
+	}
+}
+
+func convertAccount(account db.Account) *pb.Account {
+	return &pb.Account{
+		Id:        account.ID,
+		Owner:     account.Owner,
+		Balance:   account.Balance,
+		Currency:  account.Currency,
+		CreatedAt: timestamppb.New(account.CreatedAt),
+		Product:   account.Product,
+	}
+}
+
+func convertEntry(entry db.Entry) *pb.Entry {
+	return &pb.Entry{
+		Id:        entry.ID,
+		AccountId: entry.AccountID,
+		Amount:    entry.Amount,
+		CreatedAt: timestamppb.New(entry.CreatedAt),
+		PaymentId: entry.PaymentID.Int64,
+	}
+}
+
+func convertAccountEvent(event db.AccountEvent) *pb.WatchAccountResponse {
+	return &pb.WatchAccountResponse{
+		Account: convertAccount(event.Account),
+		Entry:   convertEntry(event.Entry),
+	}
+}
+
+func convertAPIKey(apiKey db.ApiKey) *pb.ApiKey {
+	rsp := &pb.ApiKey{
+		Id:        apiKey.ID.String(),
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		IsRevoked: apiKey.IsRevoked,
+		ExpiresAt: timestamppb.New(apiKey.ExpiresAt),
+		CreatedAt: timestamppb.New(apiKey.CreatedAt),
+	}
+	if apiKey.LastUsedAt.Valid {
+		rsp.LastUsedAt = timestamppb.New(apiKey.LastUsedAt.Time)
+	}
+	return rsp
+}
+
+func convertAuditEvent(event db.AuditEvent) (*pb.AuditEvent, error) {
+	before, err := convertAuditState(event.Before)
+	if err != nil {
+		return nil, err
+	}
+	after, err := convertAuditState(event.After)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.AuditEvent{
+		Id:           event.ID,
+		Actor:        event.Actor,
+		ActorRole:    event.ActorRole,
+		ClientIp:     event.ClientIp,
+		UserAgent:    event.UserAgent,
+		Action:       event.Action,
+		ResourceType: event.ResourceType,
+		ResourceId:   event.ResourceID,
+		Before:       before,
+		After:        after,
+		CreatedAt:    timestamppb.New(event.CreatedAt),
+	}, nil
+}
+
+// convertAuditState decodes the JSON object of a state, which is unset if the resource does not exist
+func convertAuditState(state []byte) (*structpb.Struct, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	rsp := &structpb.Struct{}
+	if err := protojson.Unmarshal(state, rsp); err != nil {
+		return nil, fmt.Errorf("cannot decode audit state: %w", err)
 	}
+	return rsp, nil
 }
@@ -1,9 +1,16 @@
 package gapi
 
 import (
+	"context"
+	"log/slog"
+
+	"github.com/pakojabi/simplebank/apperror"
+	"github.com/pakojabi/simplebank/lockout"
+	"github.com/pakojabi/simplebank/logger"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 func fieldViolation(field string, err error) *errdetails.BadRequest_FieldViolation {
@@ -14,15 +21,68 @@ func fieldViolation(field string, err error) *errdetails.BadRequest_FieldViolati
 }
 
 func invalidArgumentError(violations []*errdetails.BadRequest_FieldViolation) error {
-	badRequest := &errdetails.BadRequest{
-		FieldViolations: violations,
+	fieldViolations := make([]apperror.FieldViolation, len(violations))
+	for i, violation := range violations {
+		fieldViolations[i] = apperror.FieldViolation{
+			Field:       violation.GetField(),
+			Description: violation.GetDescription(),
+		}
+	}
+
+	return newStatus(apperror.InvalidArgument(fieldViolations...)).Err()
+}
+
+// statusError converts err to a gRPC status carrying its code in an ErrorInfo.
+// Errors that are not *apperror.Error are internal: they are logged, and clients only get a generic message.
+func statusError(ctx context.Context, err error) error {
+	appErr := apperror.From(err)
+	if appErr.Code == apperror.CodeInternal {
+		logger.FromContext(ctx).Error("request failed", slog.Any("error", err))
+	}
+
+	return newStatus(appErr).Err()
+}
+
+func newStatus(appErr *apperror.Error) *status.Status {
+	st := status.New(appErr.Code.GRPCCode(), appErr.Message)
+
+	details := []protoiface.MessageV1{
+		&errdetails.ErrorInfo{
+			Reason:   string(appErr.Code),
+			Domain:   apperror.Domain,
+			Metadata: appErr.Metadata,
+		},
+	}
+	if len(appErr.Violations) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, violation := range appErr.Violations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       violation.Field,
+				Description: violation.Description,
+			})
+		}
+		details = append(details, badRequest)
+	}
+	if appErr.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(appErr.RetryAfter)})
 	}
-	statusInvalid := status.New(codes.InvalidArgument, "invalid parameters")
 
-	statusDetails, err := statusInvalid.WithDetails(badRequest)
+	statusDetails, err := st.WithDetails(details...)
 	if err != nil {
-		return statusInvalid.Err()
+		return st
 	}
 
-	return statusDetails.Err()
+	return statusDetails
+}
+
+func lockedOutError(err *lockout.LockedError) error {
+	appErr := apperror.Wrap(err, apperror.CodeLoginLocked, "too many failed login attempts")
+	appErr.RetryAfter = err.RetryAfter()
+	return appErr
+}
+
+func stepUpLockedError(err *lockout.LockedError) error {
+	appErr := apperror.Wrap(err, apperror.CodeTwoFactorLocked, "too many invalid two-factor authentication codes")
+	appErr.RetryAfter = err.RetryAfter()
+	return appErr
 }
@@ -0,0 +1,96 @@
+package gapi
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pakojabi/simplebank/apperror"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/worker"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testTOTPEncryptionKey is the TOTP encryption key of the test servers
+var testTOTPEncryptionKey = base64.StdEncoding.EncodeToString([]byte(util.RandomString(32)))
+
+func newTestServer(t *testing.T, store db.Store) *Server {
+	config := util.Config{
+		TokenSymmetricKey:       util.RandomString(32),
+		AccessTokenDuration:     time.Minute,
+		LoginMaxFailures:        3,
+		LoginMaxFailuresPerIP:   20,
+		LoginFailureWindow:      15 * time.Minute,
+		LoginLockoutDuration:    time.Minute,
+		LoginChallengeDuration:  5 * time.Minute,
+		TOTPIssuer:              "Simple Bank",
+		TOTPEncryptionKey:       testTOTPEncryptionKey,
+		TransferStepUpThreshold: 10000,
+		APIKeyDuration:          30 * 24 * time.Hour,
+		APIKeyMaxDuration:       365 * 24 * time.Hour,
+	}
+
+	server, err := NewServer(config, store, worker.NewPGTaskDistributor())
+	require.NoError(t, err)
+
+	return server
+}
+
+// newContextWithBearerToken returns the incoming context of a request authorized with an access token of username
+func newContextWithBearerToken(t *testing.T, tokenMaker token.Maker, username string, role string, duration time.Duration) context.Context {
+	accessToken, _, err := tokenMaker.Make(username, role, duration)
+	require.NoError(t, err)
+
+	md := metadata.MD{
+		authorizationHeader: []string{fmt.Sprintf("%s %s", authorizationBearer, accessToken)},
+	}
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+// allowAuthorization lets the tokens of any user through, as none has changed their password
+func allowAuthorization(store *mockdb.MockStore) {
+	store.EXPECT().
+		GetUserPasswordChangedAt(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(time.Time{}, nil)
+}
+
+// requireStatus checks that err is a gRPC status with code, carrying reason in its ErrorInfo
+func requireStatus(t *testing.T, err error, code codes.Code, reason apperror.Code) {
+	t.Helper()
+
+	st, ok := status.FromError(err)
+	require.True(t, ok, "not a status: %v", err)
+	require.Equal(t, code, st.Code(), st.Message())
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			require.Equal(t, string(reason), info.GetReason())
+			return
+		}
+	}
+	require.Fail(t, "status has no ErrorInfo")
+}
+
+// createRandomUser creates a depositor in store
+func createRandomUser(t *testing.T, store db.Store) db.User {
+	username := util.RandomOwner()
+	user, err := store.CreateUser(context.Background(), db.CreateUserParams{
+		Username:       username,
+		HashedPassword: util.RandomString(32),
+		FullName:       util.RandomOwner(),
+		Email:          username + "@email.com",
+	})
+	require.NoError(t, err)
+	return user
+}
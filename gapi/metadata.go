@@ -2,7 +2,9 @@ package gapi
 
 import (
 	"context"
+	"strings"
 
+	db "github.com/pakojabi/simplebank/db/sqlc"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 )
@@ -30,9 +32,11 @@ func (server *Server) extractMetadata(ctx context.Context) *Metadata {
 		if userAgents := md.Get(userAgentHeader); len(userAgents) > 0 {
 			mtdt.UserAgent = userAgents[0]
 		}
-		// grpc gateway
+		// grpc gateway: it appends the address the request came from to the x-forwarded-for sent by the client,
+		// which anyone can forge, so only that last hop is trusted
 		if clientIPs := md.Get(xForwardedForHeader); len(clientIPs) > 0 {
-			mtdt.ClientIP = clientIPs[0]
+			hops := strings.Split(clientIPs[len(clientIPs)-1], ",")
+			mtdt.ClientIP = strings.TrimSpace(hops[len(hops)-1])
 		}
 	}
 	
@@ -42,4 +46,16 @@ func (server *Server) extractMetadata(ctx context.Context) *Metadata {
 	}
 
 	return mtdt
+}
+
+// auditContext returns ctx with the client of the request as the actor of the changes made with it,
+// on behalf of username. Anonymous requests pass an empty username and role.
+func (server *Server) auditContext(ctx context.Context, username string, role string) context.Context {
+	mtdt := server.extractMetadata(ctx)
+	return db.WithAuditActor(ctx, db.AuditActor{
+		Username:  username,
+		Role:      role,
+		ClientIP:  mtdt.ClientIP,
+		UserAgent: mtdt.UserAgent,
+	})
 }
\ No newline at end of file
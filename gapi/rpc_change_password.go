@@ -0,0 +1,76 @@
+package gapi
+
+import (
+	"context"
+
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func (server *Server) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	if violations := validateChangePasswordRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			return nil, statusError(ctx, apperror.Wrap(err, apperror.CodeUserNotFound, "user not found"))
+		}
+		return nil, statusError(ctx, err)
+	}
+
+	if err := util.CheckPassword(req.GetCurrentPassword(), user.HashedPassword); err != nil {
+		return nil, statusError(ctx, apperror.Wrap(err, apperror.CodeInvalidCredentials, "current password is incorrect"))
+	}
+
+	hashedPassword, err := util.HashPassword(req.GetNewPassword())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	txResult, err := server.store.ChangePasswordTx(server.auditContext(ctx, authPayload.Username, authPayload.Role), db.ChangePasswordTxParams{
+		Username:       user.Username,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	rsp := &pb.ChangePasswordResponse{
+		User: convertUser(txResult.User),
+	}
+	return rsp, nil
+}
+
+func validateChangePasswordRequest(req *pb.ChangePasswordRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	validators := []validator{
+		{
+			val.ValidatePassword,
+			req.GetCurrentPassword,
+			"current_password",
+		},
+		{
+			val.ValidatePassword,
+			req.GetNewPassword,
+			"new_password",
+		},
+	}
+
+	for _, validator := range validators {
+		if err := validator.validatorFunc(validator.fieldGetter()); err != nil {
+			violations = append(violations, fieldViolation(validator.fieldName, err))
+		}
+	}
+
+	return violations
+}
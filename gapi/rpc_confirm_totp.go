@@ -0,0 +1,35 @@
+package gapi
+
+import (
+	"context"
+
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func (server *Server) ConfirmTOTP(ctx context.Context, req *pb.ConfirmTOTPRequest) (*pb.ConfirmTOTPResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	if violations := validateConfirmTOTPRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	recoveryCodes, err := server.twoFactor.Confirm(server.auditContext(ctx, authPayload.Username, authPayload.Role), authPayload.Username, req.GetCode())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return &pb.ConfirmTOTPResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func validateConfirmTOTPRequest(req *pb.ConfirmTOTPRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateTOTPCode(req.GetCode()); err != nil {
+		violations = append(violations, fieldViolation("code", err))
+	}
+
+	return violations
+}
@@ -0,0 +1,71 @@
+package gapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// enableTOTP enrolls the user of ctx and confirms the enrollment, and returns their secret and recovery codes
+func enableTOTP(t *testing.T, server *Server, ctx context.Context) (string, []string) {
+	enrollment, err := server.EnrollTOTP(ctx, &pb.EnrollTOTPRequest{})
+	require.NoError(t, err)
+
+	code, err := totp.GenerateCode(enrollment.GetSecret(), time.Now())
+	require.NoError(t, err)
+
+	confirmation, err := server.ConfirmTOTP(ctx, &pb.ConfirmTOTPRequest{Code: code})
+	require.NoError(t, err)
+	require.NotEmpty(t, confirmation.GetRecoveryCodes())
+
+	return enrollment.GetSecret(), confirmation.GetRecoveryCodes()
+}
+
+func TestConfirmTOTPAPI(t *testing.T) {
+	store := db.NewMemStore()
+	server := newTestServer(t, store)
+
+	user := createRandomUser(t, store)
+	ctx := newContextWithBearerToken(t, server.tokenMaker, user.Username, user.Role, time.Minute)
+
+	// nothing to confirm before enrolling
+	_, err := server.ConfirmTOTP(ctx, &pb.ConfirmTOTPRequest{Code: "123456"})
+	requireStatus(t, err, codes.FailedPrecondition, apperror.CodeTwoFactorNotEnrolled)
+
+	enrollment, err := server.EnrollTOTP(ctx, &pb.EnrollTOTPRequest{})
+	require.NoError(t, err)
+
+	_, err = server.ConfirmTOTP(ctx, &pb.ConfirmTOTPRequest{Code: "12345"})
+	requireStatus(t, err, codes.InvalidArgument, apperror.CodeInvalidArgument)
+
+	// a code of another secret
+	otherSecret, err := totp.Generate(totp.GenerateOpts{Issuer: "Simple Bank", AccountName: user.Username})
+	require.NoError(t, err)
+	otherCode, err := totp.GenerateCode(otherSecret.Secret(), time.Now())
+	require.NoError(t, err)
+	code, err := totp.GenerateCode(enrollment.GetSecret(), time.Now())
+	require.NoError(t, err)
+	if otherCode != code {
+		_, err = server.ConfirmTOTP(ctx, &pb.ConfirmTOTPRequest{Code: otherCode})
+		requireStatus(t, err, codes.Unauthenticated, apperror.CodeInvalidTwoFactor)
+	}
+
+	res, err := server.ConfirmTOTP(ctx, &pb.ConfirmTOTPRequest{Code: code})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.GetRecoveryCodes())
+
+	secret, err := store.GetTOTPSecret(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.True(t, secret.IsEnabled)
+	require.NotContains(t, string(secret.EncryptedSecret), enrollment.GetSecret())
+
+	_, err = server.ConfirmTOTP(ctx, &pb.ConfirmTOTPRequest{Code: code})
+	requireStatus(t, err, codes.AlreadyExists, apperror.CodeTwoFactorEnabled)
+}
@@ -0,0 +1,55 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pakojabi/simplebank/apikey"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func (server *Server) CreateApiKey(ctx context.Context, req *pb.CreateApiKeyRequest) (*pb.CreateApiKeyResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	if violations := validateCreateApiKeyRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	key, apiKey, err := server.apiKeys.Create(ctx, apikey.CreateParams{
+		Username: authPayload.Username,
+		Name:     req.GetName(),
+		Scopes:   req.GetScopes(),
+		Duration: time.Duration(req.GetExpiresInDays()) * 24 * time.Hour,
+	})
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidDuration) {
+			return nil, invalidArgumentError([]*errdetails.BadRequest_FieldViolation{fieldViolation("expires_in_days", err)})
+		}
+		return nil, statusError(ctx, err)
+	}
+
+	return &pb.CreateApiKeyResponse{
+		Key:    key,
+		ApiKey: convertAPIKey(apiKey),
+	}, nil
+}
+
+func validateCreateApiKeyRequest(req *pb.CreateApiKeyRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateString(req.GetName(), 1, 100); err != nil {
+		violations = append(violations, fieldViolation("name", err))
+	}
+	if err := val.ValidateScopes(req.GetScopes()); err != nil {
+		violations = append(violations, fieldViolation("scopes", err))
+	}
+	if req.GetExpiresInDays() < 0 {
+		violations = append(violations, fieldViolation("expires_in_days", errors.New("must not be negative")))
+	}
+
+	return violations
+}
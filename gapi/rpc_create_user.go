@@ -3,14 +3,13 @@ package gapi
 import (
 	"context"
 
-	"github.com/lib/pq"
+	"github.com/pakojabi/simplebank/apperror"
 	db "github.com/pakojabi/simplebank/db/sqlc"
 	"github.com/pakojabi/simplebank/pb"
 	"github.com/pakojabi/simplebank/util"
 	"github.com/pakojabi/simplebank/val"
+	"github.com/pakojabi/simplebank/worker"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 func (server *Server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
@@ -20,31 +19,35 @@ func (server *Server) CreateUser(ctx context.Context, req *pb.CreateUserRequest)
 
 	hashedPassword, err := util.HashPassword(req.GetPassword())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to hash password: %s", err)
+		return nil, statusError(ctx, err)
 	}
 
-	arg := db.CreateUserParams{
-		Username:       req.GetUsername(),
-		HashedPassword: hashedPassword,
-		FullName:       req.GetFullName(),
-		Email:          req.GetEmail(),
+	arg := db.CreateUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+			Username:       req.GetUsername(),
+			HashedPassword: hashedPassword,
+			FullName:       req.GetFullName(),
+			Email:          req.GetEmail(),
+		},
+		AfterCreate: func(q db.Querier, user db.User) error {
+			payload := &worker.PayloadSendVerifyEmail{
+				Username: user.Username,
+			}
+			return server.taskDistributor.DistributeTaskSendVerifyEmail(ctx, q, payload, worker.Queue(worker.QueueCritical))
+		},
 	}
 
-	user, err := server.store.CreateUser(ctx, arg)
+	txResult, err := server.store.CreateUserTx(server.auditContext(ctx, "", ""), arg)
 
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code.Name() {
-			case "unique_violation":
-				return nil, status.Errorf(codes.AlreadyExists, "already exists: %s", err)
-
-			}
+		if db.IsUniqueViolation(err) {
+			return nil, statusError(ctx, apperror.Wrap(err, apperror.CodeUserAlreadyExists, "username or email already exists"))
 		}
-		return nil, status.Errorf(codes.Internal, "failed to create user: %s", err)
+		return nil, statusError(ctx, err)
 	}
 
 	rsp := &pb.CreateUserResponse{
-		User: convertUser(user),
+		User: convertUser(txResult.User),
 	}
 	return rsp, nil
 }
@@ -0,0 +1,45 @@
+package gapi
+
+import (
+	"context"
+
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// DisableTOTP turns two-factor authentication off for the logged in user, who can then enroll again,
+// e.g. with a new device. It takes a code like any other high-value operation.
+func (server *Server) DisableTOTP(ctx context.Context, req *pb.DisableTOTPRequest) (*pb.DisableTOTPResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	if violations := validateDisableTOTPRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	if err := server.requireStepUp(ctx, authPayload.Username, req.GetTotpCode()); err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	err = server.twoFactor.Disable(server.auditContext(ctx, authPayload.Username, authPayload.Role), authPayload.Username)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return &pb.DisableTOTPResponse{}, nil
+}
+
+func validateDisableTOTPRequest(req *pb.DisableTOTPRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	// a missing code is left to the step-up check, which asks for one
+	if req.GetTotpCode() == "" {
+		return nil
+	}
+	if err := val.ValidateTwoFactorCode(req.GetTotpCode()); err != nil {
+		violations = append(violations, fieldViolation("totp_code", err))
+	}
+
+	return violations
+}
@@ -0,0 +1,107 @@
+package gapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestDisableTOTPAPI(t *testing.T) {
+	store := db.NewMemStore()
+	server := newTestServer(t, store)
+
+	user := createRandomUser(t, store)
+	ctx := newContextWithBearerToken(t, server.tokenMaker, user.Username, user.Role, time.Minute)
+
+	_, err := server.DisableTOTP(ctx, &pb.DisableTOTPRequest{TotpCode: util.RandomString(10)})
+	requireStatus(t, err, codes.FailedPrecondition, apperror.CodeTwoFactorNotEnabled)
+
+	_, recoveryCodes := enableTOTP(t, server, ctx)
+
+	testCases := []struct {
+		name  string
+		req   *pb.DisableTOTPRequest
+		check func(t *testing.T, res *pb.DisableTOTPResponse, err error)
+	}{
+		{
+			name: "MissingCode",
+			req:  &pb.DisableTOTPRequest{},
+			check: func(t *testing.T, res *pb.DisableTOTPResponse, err error) {
+				requireStatus(t, err, codes.Unauthenticated, apperror.CodeTwoFactorRequired)
+			},
+		},
+		{
+			name: "InvalidCode",
+			req:  &pb.DisableTOTPRequest{TotpCode: "123"},
+			check: func(t *testing.T, res *pb.DisableTOTPResponse, err error) {
+				requireStatus(t, err, codes.InvalidArgument, apperror.CodeInvalidArgument)
+			},
+		},
+		{
+			name: "WrongCode",
+			req:  &pb.DisableTOTPRequest{TotpCode: util.RandomString(10)},
+			check: func(t *testing.T, res *pb.DisableTOTPResponse, err error) {
+				requireStatus(t, err, codes.Unauthenticated, apperror.CodeInvalidTwoFactor)
+			},
+		},
+		{
+			name: "OK",
+			req:  &pb.DisableTOTPRequest{TotpCode: recoveryCodes[0]},
+			check: func(t *testing.T, res *pb.DisableTOTPResponse, err error) {
+				require.NoError(t, err)
+				require.NotNil(t, res)
+
+				_, err = store.GetTOTPSecret(context.Background(), user.Username)
+				require.ErrorIs(t, err, db.ErrRecordNotFound)
+			},
+		},
+		{
+			name: "NotEnabled",
+			req:  &pb.DisableTOTPRequest{TotpCode: recoveryCodes[1]},
+			check: func(t *testing.T, res *pb.DisableTOTPResponse, err error) {
+				requireStatus(t, err, codes.FailedPrecondition, apperror.CodeTwoFactorNotEnabled)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := server.DisableTOTP(ctx, tc.req)
+			tc.check(t, res, err)
+		})
+	}
+
+	// the user can enroll again, e.g. with a new device
+	enableTOTP(t, server, ctx)
+}
+
+func TestDisableTOTPLocked(t *testing.T) {
+	store := db.NewMemStore()
+	server := newTestServer(t, store)
+
+	user := createRandomUser(t, store)
+	ctx := newContextWithBearerToken(t, server.tokenMaker, user.Username, user.Role, time.Minute)
+	_, recoveryCodes := enableTOTP(t, server, ctx)
+
+	for i := 0; i < server.config.LoginMaxFailures; i++ {
+		_, err := server.DisableTOTP(ctx, &pb.DisableTOTPRequest{TotpCode: util.RandomString(10)})
+		requireStatus(t, err, codes.Unauthenticated, apperror.CodeInvalidTwoFactor)
+	}
+
+	// even a valid code is turned down until the lockout ends
+	_, err := server.DisableTOTP(ctx, &pb.DisableTOTPRequest{TotpCode: recoveryCodes[0]})
+	requireStatus(t, err, codes.ResourceExhausted, apperror.CodeTwoFactorLocked)
+
+	secret, err := store.GetTOTPSecret(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.True(t, secret.IsEnabled)
+}
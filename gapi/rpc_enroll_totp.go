@@ -0,0 +1,24 @@
+package gapi
+
+import (
+	"context"
+
+	"github.com/pakojabi/simplebank/pb"
+)
+
+func (server *Server) EnrollTOTP(ctx context.Context, req *pb.EnrollTOTPRequest) (*pb.EnrollTOTPResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	enrollment, err := server.twoFactor.Enroll(ctx, authPayload.Username)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return &pb.EnrollTOTPResponse{
+		Secret:          enrollment.Secret,
+		ProvisioningUri: enrollment.ProvisioningURI,
+	}, nil
+}
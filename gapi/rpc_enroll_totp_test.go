@@ -0,0 +1,129 @@
+package gapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pakojabi/simplebank/apperror"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+)
+
+func TestEnrollTOTPAPI(t *testing.T) {
+	username := util.RandomOwner()
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		buildContext  func(t *testing.T, tokenMaker token.Maker) context.Context
+		checkResponse func(t *testing.T, res *pb.EnrollTOTPResponse, err error)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				allowAuthorization(store)
+				store.EXPECT().
+					UpsertTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.UpsertTOTPSecretParams) (db.TotpSecret, error) {
+						require.Equal(t, username, arg.Username)
+						return db.TotpSecret{Username: arg.Username, EncryptedSecret: arg.EncryptedSecret}, nil
+					})
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.EnrollTOTPResponse, err error) {
+				require.NoError(t, err)
+				require.NotEmpty(t, res.GetSecret())
+				require.Contains(t, res.GetProvisioningUri(), "otpauth://totp/")
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			buildStubs: func(store *mockdb.MockStore) {
+				allowAuthorization(store)
+				store.EXPECT().
+					UpsertTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TotpSecret{}, db.ErrRecordNotFound)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.EnrollTOTPResponse, err error) {
+				requireStatus(t, err, codes.AlreadyExists, apperror.CodeTwoFactorEnabled)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpsertTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return context.Background()
+			},
+			checkResponse: func(t *testing.T, res *pb.EnrollTOTPResponse, err error) {
+				requireStatus(t, err, codes.Unauthenticated, apperror.CodeUnauthenticated)
+			},
+		},
+		{
+			name: "ExpiredToken",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpsertTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, username, util.DepositorRole, -time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.EnrollTOTPResponse, err error) {
+				requireStatus(t, err, codes.Unauthenticated, apperror.CodeExpiredToken)
+			},
+		},
+		{
+			name: "PasswordChanged",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserPasswordChangedAt(gomock.Any(), gomock.Eq(username)).
+					Times(1).
+					Return(time.Now().Add(time.Minute), nil)
+				store.EXPECT().
+					UpsertTOTPSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			buildContext: func(t *testing.T, tokenMaker token.Maker) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, username, util.DepositorRole, time.Minute)
+			},
+			checkResponse: func(t *testing.T, res *pb.EnrollTOTPResponse, err error) {
+				requireStatus(t, err, codes.Unauthenticated, apperror.CodeRevokedToken)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			ctx := tc.buildContext(t, server.tokenMaker)
+			res, err := server.EnrollTOTP(ctx, &pb.EnrollTOTPRequest{})
+			tc.checkResponse(t, res, err)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package gapi
+
+import (
+	"context"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/val"
+	"github.com/pakojabi/simplebank/worker"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// ForgotPassword answers the same whether or not the email belongs to a user,
+// so it can't be used to probe for accounts.
+func (server *Server) ForgotPassword(ctx context.Context, req *pb.ForgotPasswordRequest) (*pb.ForgotPasswordResponse, error) {
+	if violations := validateForgotPasswordRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	user, err := server.store.GetUserByEmail(ctx, req.GetEmail())
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			return &pb.ForgotPasswordResponse{}, nil
+		}
+		return nil, statusError(ctx, err)
+	}
+
+	payload := &worker.PayloadSendResetPassword{
+		Username: user.Username,
+	}
+	err = server.taskDistributor.DistributeTaskSendResetPassword(ctx, server.store, payload, worker.Queue(worker.QueueCritical))
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return &pb.ForgotPasswordResponse{}, nil
+}
+
+func validateForgotPasswordRequest(req *pb.ForgotPasswordRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateEmail(req.GetEmail()); err != nil {
+		violations = append(violations, fieldViolation("email", err))
+	}
+
+	return violations
+}
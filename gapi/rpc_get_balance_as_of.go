@@ -0,0 +1,70 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (server *Server) GetBalanceAsOf(ctx context.Context, req *pb.GetBalanceAsOfRequest) (*pb.GetBalanceAsOfResponse, error) {
+	authPayload, err := server.authorizeScope(ctx, util.AccountsReadScope)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	now := time.Now()
+	if violations := validateGetBalanceAsOfRequest(req, now); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	asOf := now
+	if req.AsOf != nil {
+		asOf = req.GetAsOf().AsTime()
+	}
+
+	result, err := server.store.GetBalanceAsOf(ctx, db.GetBalanceAsOfParams{
+		AccountID: req.GetAccountId(),
+		AsOf:      asOf,
+	})
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			return nil, statusError(ctx, apperror.Wrap(err, apperror.CodeAccountNotFound, "account not found").With("account_id", strconv.FormatInt(req.GetAccountId(), 10)))
+		}
+		return nil, statusError(ctx, err)
+	}
+
+	if result.Account.Owner != authPayload.Username {
+		return nil, statusError(ctx, apperror.New(apperror.CodeAccountNotOwned, "account does not belong to the authenticated user").With("account_id", strconv.FormatInt(result.Account.ID, 10)))
+	}
+
+	return &pb.GetBalanceAsOfResponse{
+		AccountId: result.Account.ID,
+		Balance:   result.Balance,
+		Currency:  result.Account.Currency,
+		AsOf:      timestamppb.New(result.AsOf),
+	}, nil
+}
+
+func validateGetBalanceAsOfRequest(req *pb.GetBalanceAsOfRequest, now time.Time) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateID(req.GetAccountId()); err != nil {
+		violations = append(violations, fieldViolation("account_id", err))
+	}
+	if req.AsOf != nil {
+		if !req.GetAsOf().IsValid() {
+			violations = append(violations, fieldViolation("as_of", fmt.Errorf("must be a valid timestamp")))
+		} else if req.GetAsOf().AsTime().After(now) {
+			violations = append(violations, fieldViolation("as_of", fmt.Errorf("must not be in the future")))
+		}
+	}
+
+	return violations
+}
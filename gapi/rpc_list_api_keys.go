@@ -0,0 +1,48 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func (server *Server) ListApiKeys(ctx context.Context, req *pb.ListApiKeysRequest) (*pb.ListApiKeysResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	if violations := validateListApiKeysRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	apiKeys, err := server.store.ListAPIKeys(ctx, db.ListAPIKeysParams{
+		Username: authPayload.Username,
+		Limit:    int64(req.GetPageSize()),
+		Offset:   int64(req.GetPageId()-1) * int64(req.GetPageSize()),
+	})
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	rsp := &pb.ListApiKeysResponse{}
+	for _, apiKey := range apiKeys {
+		rsp.ApiKeys = append(rsp.ApiKeys, convertAPIKey(apiKey))
+	}
+	return rsp, nil
+}
+
+func validateListApiKeysRequest(req *pb.ListApiKeysRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateID(int64(req.GetPageId())); err != nil {
+		violations = append(violations, fieldViolation("page_id", err))
+	}
+	if req.GetPageSize() < 5 || req.GetPageSize() > 10 {
+		violations = append(violations, fieldViolation("page_size", fmt.Errorf("must be between 5 and 10")))
+	}
+
+	return violations
+}
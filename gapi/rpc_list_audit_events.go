@@ -0,0 +1,76 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func (server *Server) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	if violations := validateListAuditEventsRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	if authPayload.Role != util.AdminRole {
+		return nil, statusError(ctx, apperror.New(apperror.CodePermissionDenied, "only admins can read the audit log"))
+	}
+
+	arg := db.ListAuditEventsParams{
+		Actor:        pgtype.Text{String: req.GetActor(), Valid: req.GetActor() != ""},
+		Action:       pgtype.Text{String: req.GetAction(), Valid: req.GetAction() != ""},
+		ResourceType: pgtype.Text{String: req.GetResourceType(), Valid: req.GetResourceType() != ""},
+		ResourceID:   pgtype.Text{String: req.GetResourceId(), Valid: req.GetResourceId() != ""},
+		Limit:        int64(req.GetPageSize()),
+		Offset:       int64(req.GetPageId()-1) * int64(req.GetPageSize()),
+	}
+	if req.Since != nil {
+		arg.Since = pgtype.Timestamptz{Time: req.GetSince().AsTime(), Valid: true}
+	}
+	if req.Until != nil {
+		arg.Until = pgtype.Timestamptz{Time: req.GetUntil().AsTime(), Valid: true}
+	}
+
+	events, err := server.store.ListAuditEvents(ctx, arg)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	rsp := &pb.ListAuditEventsResponse{}
+	for _, event := range events {
+		auditEvent, err := convertAuditEvent(event)
+		if err != nil {
+			return nil, statusError(ctx, err)
+		}
+		rsp.AuditEvents = append(rsp.AuditEvents, auditEvent)
+	}
+	return rsp, nil
+}
+
+func validateListAuditEventsRequest(req *pb.ListAuditEventsRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateID(int64(req.GetPageId())); err != nil {
+		violations = append(violations, fieldViolation("page_id", err))
+	}
+	if req.GetPageSize() < 5 || req.GetPageSize() > 50 {
+		violations = append(violations, fieldViolation("page_size", fmt.Errorf("must be between 5 and 50")))
+	}
+	if req.Since != nil && !req.GetSince().IsValid() {
+		violations = append(violations, fieldViolation("since", fmt.Errorf("must be a valid timestamp")))
+	}
+	if req.Until != nil && !req.GetUntil().IsValid() {
+		violations = append(violations, fieldViolation("until", fmt.Errorf("must be a valid timestamp")))
+	}
+
+	return violations
+}
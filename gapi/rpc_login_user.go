@@ -2,15 +2,15 @@ package gapi
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 
+	"github.com/pakojabi/simplebank/apperror"
 	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/lockout"
 	"github.com/pakojabi/simplebank/pb"
 	"github.com/pakojabi/simplebank/util"
 	"github.com/pakojabi/simplebank/val"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -19,41 +19,88 @@ func (server *Server) LoginUser(ctx context.Context, req *pb.LoginUserRequest) (
 		return nil, invalidArgumentError(violations)
 	}
 
+	mtdt := server.extractMetadata(ctx)
+	attempt := lockout.Attempt{
+		Username:  req.GetUsername(),
+		ClientIP:  mtdt.ClientIP,
+		UserAgent: mtdt.UserAgent,
+	}
+
+	if err := server.loginGuard.Begin(ctx, &attempt); err != nil {
+		var lockedErr *lockout.LockedError
+		if errors.As(err, &lockedErr) {
+			err = lockedOutError(lockedErr)
+		}
+		return nil, statusError(ctx, err)
+	}
+
 	user, err := server.store.GetUser(ctx, req.GetUsername())
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, status.Errorf(codes.NotFound, "User does not exist: %s", err)
+		if err == db.ErrRecordNotFound {
+			return nil, server.recordLoginFailure(ctx, attempt, apperror.Wrap(err, apperror.CodeUserNotFound, "user not found"))
 		}
-		return nil, status.Errorf(codes.Internal, "Error retrieving user: %s", err)
+		return nil, statusError(ctx, err)
 	}
 
 	if err = util.CheckPassword(req.GetPassword(), user.HashedPassword); err != nil {
-		return nil, status.Errorf(codes.PermissionDenied, "Authentication failed: %s", err)
+		return nil, server.recordLoginFailure(ctx, attempt, apperror.Wrap(err, apperror.CodeInvalidCredentials, "incorrect password"))
+	}
+
+	enabled, err := server.twoFactor.IsEnabled(ctx, user.Username)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	if enabled {
+		// the outcome of the login is recorded once the second factor has been checked
+		attempt.Outcome = db.LoginOutcomeChallenged
+		if err := server.loginGuard.Finish(ctx, attempt); err != nil {
+			return nil, statusError(ctx, err)
+		}
+
+		challenge, err := server.twoFactor.CreateChallenge(ctx, user.Username)
+		if err != nil {
+			return nil, statusError(ctx, err)
+		}
+
+		return &pb.LoginUserResponse{
+			TwoFactorRequired:  true,
+			ChallengeToken:     challenge.ID.String(),
+			ChallengeExpiresAt: timestamppb.New(challenge.ExpiresAt),
+		}, nil
 	}
 
-	accessToken, accessPayload, err := server.tokenMaker.Make(user.Username, server.config.AccessTokenDuration)
+	return server.completeLogin(ctx, attempt, user)
+}
+
+// completeLogin records the successful attempt, then starts a session and returns its tokens
+func (server *Server) completeLogin(ctx context.Context, attempt lockout.Attempt, user db.User) (*pb.LoginUserResponse, error) {
+	attempt.Outcome = db.LoginOutcomeSuccess
+	if err := server.loginGuard.Finish(ctx, attempt); err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.Make(user.Username, user.Role, server.config.AccessTokenDuration)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to create access token: %s", err)
+		return nil, statusError(ctx, err)
 	}
 
-	refreshToken, refreshPayload, err := server.tokenMaker.Make(user.Username, server.config.RefreshTokenDuration)
+	refreshToken, refreshPayload, err := server.tokenMaker.Make(user.Username, user.Role, server.config.RefreshTokenDuration)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to create refresh token: %s", err)
+		return nil, statusError(ctx, err)
 	}
 
-	mtdt := server.extractMetadata(ctx)
-	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
+	session, err := server.store.CreateSession(server.auditContext(ctx, user.Username, user.Role), db.CreateSessionParams{
 		ID:           refreshPayload.ID,
 		Username:     user.Username,
 		RefreshToken: refreshToken,
-		UserAgent:    mtdt.UserAgent,
-		ClientIp:     mtdt.ClientIP,
+		UserAgent:    attempt.UserAgent,
+		ClientIp:     attempt.ClientIP,
 		IsBlocked:    false,
 		ExpiresAt:    refreshPayload.ExpiredAt,
 	})
 
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to create session %s", err)
+		return nil, statusError(ctx, err)
 	}
 	rsp := &pb.LoginUserResponse{
 		User:                  convertUser(user),
@@ -67,6 +114,15 @@ func (server *Server) LoginUser(ctx context.Context, req *pb.LoginUserRequest) (
 	return rsp, nil
 }
 
+// recordLoginFailure records the failed attempt and returns the status of loginErr, unless recording fails
+func (server *Server) recordLoginFailure(ctx context.Context, attempt lockout.Attempt, loginErr error) error {
+	attempt.Outcome = db.LoginOutcomeFailure
+	if err := server.loginGuard.Finish(ctx, attempt); err != nil {
+		return statusError(ctx, err)
+	}
+	return statusError(ctx, loginErr)
+}
+
 func validateLoginUserRequest(req *pb.LoginUserRequest) (violations []*errdetails.BadRequest_FieldViolation) {
 	validators := []validator{
 		{
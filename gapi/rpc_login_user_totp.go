@@ -0,0 +1,62 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/lockout"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/twofactor"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// LoginUserTOTP is the second step of logging in with two-factor authentication enabled
+func (server *Server) LoginUserTOTP(ctx context.Context, req *pb.LoginUserTOTPRequest) (*pb.LoginUserResponse, error) {
+	if violations := validateLoginUserTOTPRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	mtdt := server.extractMetadata(ctx)
+	attempt := lockout.Attempt{
+		ClientIP:  mtdt.ClientIP,
+		UserAgent: mtdt.UserAgent,
+	}
+
+	// the username is only known from the challenge, which was issued after checking it
+	if err := server.loginGuard.Begin(ctx, &attempt); err != nil {
+		var lockedErr *lockout.LockedError
+		if errors.As(err, &lockedErr) {
+			err = lockedOutError(lockedErr)
+		}
+		return nil, statusError(ctx, err)
+	}
+
+	username, err := server.twoFactor.AnswerChallenge(ctx, uuid.MustParse(req.GetChallengeToken()), req.GetCode())
+	attempt.Username = username
+	if err != nil {
+		if errors.Is(err, twofactor.ErrInvalidCode) || errors.Is(err, twofactor.ErrInvalidChallenge) {
+			return nil, server.recordLoginFailure(ctx, attempt, err)
+		}
+		return nil, statusError(ctx, err)
+	}
+
+	user, err := server.store.GetUser(ctx, username)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return server.completeLogin(ctx, attempt, user)
+}
+
+func validateLoginUserTOTPRequest(req *pb.LoginUserTOTPRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateChallengeToken(req.GetChallengeToken()); err != nil {
+		violations = append(violations, fieldViolation("challenge_token", err))
+	}
+	if err := val.ValidateTwoFactorCode(req.GetCode()); err != nil {
+		violations = append(violations, fieldViolation("code", err))
+	}
+
+	return violations
+}
@@ -0,0 +1,56 @@
+package gapi
+
+import (
+	"context"
+
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func (server *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+	if violations := validateResetPasswordRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	hashedPassword, err := util.HashPassword(req.GetNewPassword())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	txResult, err := server.store.ResetPasswordTx(server.auditContext(ctx, "", ""), db.ResetPasswordTxParams{
+		ResetId:        req.GetResetId(),
+		SecretCode:     req.GetSecretCode(),
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			return nil, statusError(ctx, apperror.Wrap(err, apperror.CodeInvalidResetCode, "invalid or expired reset code"))
+		}
+		return nil, statusError(ctx, err)
+	}
+
+	rsp := &pb.ResetPasswordResponse{
+		User: convertUser(txResult.User),
+	}
+	return rsp, nil
+}
+
+func validateResetPasswordRequest(req *pb.ResetPasswordRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateID(req.GetResetId()); err != nil {
+		violations = append(violations, fieldViolation("reset_id", err))
+	}
+
+	if err := val.ValidateSecretCode(req.GetSecretCode()); err != nil {
+		violations = append(violations, fieldViolation("secret_code", err))
+	}
+
+	if err := val.ValidatePassword(req.GetNewPassword()); err != nil {
+		violations = append(violations, fieldViolation("new_password", err))
+	}
+
+	return violations
+}
@@ -0,0 +1,46 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func (server *Server) RevokeApiKey(ctx context.Context, req *pb.RevokeApiKeyRequest) (*pb.RevokeApiKeyResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	if violations := validateRevokeApiKeyRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	// users can only revoke their own keys; other keys look like they do not exist
+	apiKey, err := server.store.RevokeAPIKey(ctx, db.RevokeAPIKeyParams{
+		ID:       uuid.MustParse(req.GetId()),
+		Username: authPayload.Username,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil, statusError(ctx, apperror.Wrap(err, apperror.CodeAPIKeyNotFound, "api key not found"))
+		}
+		return nil, statusError(ctx, err)
+	}
+
+	return &pb.RevokeApiKeyResponse{ApiKey: convertAPIKey(apiKey)}, nil
+}
+
+func validateRevokeApiKeyRequest(req *pb.RevokeApiKeyRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateUUID(req.GetId()); err != nil {
+		violations = append(violations, fieldViolation("id", err))
+	}
+
+	return violations
+}
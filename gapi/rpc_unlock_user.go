@@ -0,0 +1,41 @@
+package gapi
+
+import (
+	"context"
+
+	"github.com/pakojabi/simplebank/apperror"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func (server *Server) UnlockUser(ctx context.Context, req *pb.UnlockUserRequest) (*pb.UnlockUserResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	if violations := validateUnlockUserRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	if authPayload.Role != util.AdminRole {
+		return nil, statusError(ctx, apperror.New(apperror.CodePermissionDenied, "only admins can unlock users"))
+	}
+
+	mtdt := server.extractMetadata(ctx)
+	if err := server.loginGuard.Unlock(ctx, req.GetUsername(), mtdt.ClientIP, mtdt.UserAgent); err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return &pb.UnlockUserResponse{}, nil
+}
+
+func validateUnlockUserRequest(req *pb.UnlockUserRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateUsername(req.GetUsername()); err != nil {
+		violations = append(violations, fieldViolation("username", err))
+	}
+
+	return violations
+}
@@ -0,0 +1,106 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/val"
+	"github.com/pakojabi/simplebank/worker"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func (server *Server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	authPayload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	if violations := validateUpdateUserRequest(req); violations != nil {
+		return nil, invalidArgumentError(violations)
+	}
+
+	if authPayload.Role != util.AdminRole && authPayload.Username != req.GetUsername() {
+		return nil, statusError(ctx, apperror.New(apperror.CodePermissionDenied, "cannot update other user's info"))
+	}
+
+	updateEmail := updatesUserField(req, "email", req.Email != nil)
+	arg := db.UpdateUserTxParams{
+		UpdateUserParams: db.UpdateUserParams{
+			Username: req.GetUsername(),
+			FullName: pgtype.Text{
+				String: req.GetFullName(),
+				Valid:  updatesUserField(req, "full_name", req.FullName != nil),
+			},
+			Email: pgtype.Text{
+				String: req.GetEmail(),
+				Valid:  updateEmail,
+			},
+		},
+		AfterUpdate: func(q db.Querier, user db.User) error {
+			if !updateEmail || user.IsEmailVerified {
+				return nil
+			}
+			payload := &worker.PayloadSendVerifyEmail{
+				Username: user.Username,
+			}
+			return server.taskDistributor.DistributeTaskSendVerifyEmail(ctx, q, payload, worker.Queue(worker.QueueCritical))
+		},
+	}
+
+	txResult, err := server.store.UpdateUserTx(server.auditContext(ctx, authPayload.Username, authPayload.Role), arg)
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			return nil, statusError(ctx, apperror.Wrap(err, apperror.CodeUserNotFound, "user not found"))
+		}
+		if db.IsUniqueViolation(err) {
+			return nil, statusError(ctx, apperror.Wrap(err, apperror.CodeEmailInUse, "email already in use"))
+		}
+		return nil, statusError(ctx, err)
+	}
+
+	rsp := &pb.UpdateUserResponse{
+		User: convertUser(txResult.User),
+	}
+	return rsp, nil
+}
+
+// updatesUserField reports whether the request changes the field: it must be listed
+// in update_mask when one is given, and be present in the request otherwise.
+func updatesUserField(req *pb.UpdateUserRequest, field string, present bool) bool {
+	if mask := req.GetUpdateMask(); mask != nil {
+		return slices.Contains(mask.GetPaths(), field)
+	}
+	return present
+}
+
+func validateUpdateUserRequest(req *pb.UpdateUserRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateUsername(req.GetUsername()); err != nil {
+		violations = append(violations, fieldViolation("username", err))
+	}
+
+	for _, path := range req.GetUpdateMask().GetPaths() {
+		if path != "full_name" && path != "email" {
+			violations = append(violations, fieldViolation("update_mask", fmt.Errorf("%s cannot be updated", path)))
+		}
+	}
+
+	if updatesUserField(req, "full_name", req.FullName != nil) {
+		if err := val.ValidateFullUsername(req.GetFullName()); err != nil {
+			violations = append(violations, fieldViolation("full_name", err))
+		}
+	}
+
+	if updatesUserField(req, "email", req.Email != nil) {
+		if err := val.ValidateEmail(req.GetEmail()); err != nil {
+			violations = append(violations, fieldViolation("email", err))
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,69 @@
+package gapi
+
+import (
+	"strconv"
+
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/val"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func (server *Server) WatchAccount(req *pb.WatchAccountRequest, stream pb.SimpleBank_WatchAccountServer) error {
+	ctx := stream.Context()
+
+	authPayload, err := server.authorizeScope(ctx, util.AccountsReadScope)
+	if err != nil {
+		return statusError(ctx, err)
+	}
+
+	if violations := validateWatchAccountRequest(req); violations != nil {
+		return invalidArgumentError(violations)
+	}
+
+	// subscribe before reading the snapshot so that no commit falls in between
+	events := server.store.WatchAccount(ctx, req.GetAccountId())
+
+	account, err := server.store.GetAccount(ctx, req.GetAccountId())
+	if err != nil {
+		if err == db.ErrRecordNotFound {
+			return statusError(ctx, apperror.Wrap(err, apperror.CodeAccountNotFound, "account not found").With("account_id", strconv.FormatInt(req.GetAccountId(), 10)))
+		}
+		return statusError(ctx, err)
+	}
+
+	if account.Owner != authPayload.Username {
+		return statusError(ctx, apperror.New(apperror.CodeAccountNotOwned, "account does not belong to the authenticated user").With("account_id", strconv.FormatInt(account.ID, 10)))
+	}
+
+	if err := stream.Send(&pb.WatchAccountResponse{Account: convertAccount(account)}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return statusError(ctx, apperror.New(apperror.CodeSlowConsumer, "client is not keeping up with account events"))
+			}
+			if err := stream.Send(convertAccountEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func validateWatchAccountRequest(req *pb.WatchAccountRequest) (violations []*errdetails.BadRequest_FieldViolation) {
+	if err := val.ValidateID(req.GetAccountId()); err != nil {
+		violations = append(violations, fieldViolation("account_id", err))
+	}
+
+	return violations
+}
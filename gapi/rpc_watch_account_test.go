@@ -0,0 +1,129 @@
+package gapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// testWatchAccountStream hands the messages sent on the WatchAccount stream over to the test
+type testWatchAccountStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	messages chan *pb.WatchAccountResponse
+}
+
+func (stream *testWatchAccountStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *testWatchAccountStream) Send(rsp *pb.WatchAccountResponse) error {
+	stream.messages <- rsp
+	return nil
+}
+
+func createRandomAccount(t *testing.T, store db.Store, owner db.User) db.Account {
+	account, err := store.CreateAccount(context.Background(), db.CreateAccountParams{
+		Owner:    owner.Username,
+		Balance:  100,
+		Currency: util.USD,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func TestWatchAccountAPI(t *testing.T) {
+	store := db.NewMemStore()
+	server := newTestServer(t, store)
+
+	user := createRandomUser(t, store)
+	account := createRandomAccount(t, store, user)
+	otherAccount := createRandomAccount(t, store, createRandomUser(t, store))
+
+	ctx, cancel := context.WithCancel(newContextWithBearerToken(t, server.tokenMaker, user.Username, user.Role, time.Minute))
+	defer cancel()
+
+	stream := &testWatchAccountStream{ctx: ctx, messages: make(chan *pb.WatchAccountResponse)}
+	errs := make(chan error)
+	go func() {
+		errs <- server.WatchAccount(&pb.WatchAccountRequest{AccountId: account.ID}, stream)
+	}()
+
+	// the account as it is first, then with every entry posted to it
+	snapshot := <-stream.messages
+	require.Equal(t, account.ID, snapshot.GetAccount().GetId())
+	require.Equal(t, int64(100), snapshot.GetAccount().GetBalance())
+	require.Nil(t, snapshot.GetEntry())
+
+	_, err := store.TransferTx(context.Background(), db.TransferTxParams{
+		FromAccountID: account.ID,
+		ToAccountID:   otherAccount.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	event := <-stream.messages
+	require.Equal(t, int64(90), event.GetAccount().GetBalance())
+	require.Equal(t, int64(-10), event.GetEntry().GetAmount())
+
+	// the watch ends when the client goes away
+	cancel()
+	require.NoError(t, <-errs)
+}
+
+func TestWatchAccountAPIErrors(t *testing.T) {
+	store := db.NewMemStore()
+	server := newTestServer(t, store)
+
+	user := createRandomUser(t, store)
+	otherAccount := createRandomAccount(t, store, createRandomUser(t, store))
+
+	testCases := []struct {
+		name      string
+		accountID int64
+		check     func(t *testing.T, err error)
+	}{
+		{
+			name:      "NotOwned",
+			accountID: otherAccount.ID,
+			check: func(t *testing.T, err error) {
+				requireStatus(t, err, codes.PermissionDenied, apperror.CodeAccountNotOwned)
+			},
+		},
+		{
+			name:      "NotFound",
+			accountID: otherAccount.ID + 1000,
+			check: func(t *testing.T, err error) {
+				requireStatus(t, err, codes.NotFound, apperror.CodeAccountNotFound)
+			},
+		},
+		{
+			name:      "InvalidID",
+			accountID: 0,
+			check: func(t *testing.T, err error) {
+				requireStatus(t, err, codes.InvalidArgument, apperror.CodeInvalidArgument)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := newContextWithBearerToken(t, server.tokenMaker, user.Username, user.Role, time.Minute)
+			stream := &testWatchAccountStream{ctx: ctx, messages: make(chan *pb.WatchAccountResponse, 1)}
+
+			err := server.WatchAccount(&pb.WatchAccountRequest{AccountId: tc.accountID}, stream)
+			tc.check(t, err)
+			require.Empty(t, stream.messages)
+		})
+	}
+}
@@ -3,30 +3,51 @@ package gapi
 import (
 	"fmt"
 
+	"github.com/pakojabi/simplebank/apikey"
 	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/lockout"
+	"github.com/pakojabi/simplebank/oauth"
 	"github.com/pakojabi/simplebank/pb"
 	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/twofactor"
 	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/worker"
 )
 
 // Server serves gRPC requests
 type Server struct {
 	pb.UnimplementedSimpleBankServer
-	config     util.Config
-	store      db.Store
-	tokenMaker token.Maker
+	config          util.Config
+	store           db.Store
+	tokenMaker      token.Maker
+	taskDistributor worker.TaskDistributor
+	loginGuard      *lockout.Guard
+	stepUpGuard     *lockout.Guard
+	twoFactor       *twofactor.Authenticator
+	apiKeys         *apikey.Manager
+	oauthProvider   *oauth.Provider
 }
 
 // NewServer creates a new gRPC server instance
-func NewServer(config util.Config, store db.Store) (*Server, error) {
+func NewServer(config util.Config, store db.Store, taskDistributor worker.TaskDistributor) (*Server, error) {
 	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token maker: %w", err)
 	}
+	twoFactor, err := twofactor.NewAuthenticator(config, store)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create two-factor authenticator: %w", err)
+	}
 	server := &Server{
-		config:     config,
-		store:      store,
-		tokenMaker: tokenMaker,
+		config:          config,
+		store:           store,
+		tokenMaker:      tokenMaker,
+		taskDistributor: taskDistributor,
+		loginGuard:      lockout.NewGuard(config, store),
+		stepUpGuard:     lockout.NewStepUpGuard(config, store),
+		twoFactor:       twoFactor,
+		apiKeys:         apikey.NewManager(config, store),
+		oauthProvider:   oauth.NewProvider(config, store, tokenMaker),
 	}
 
 	return server, nil
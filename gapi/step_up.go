@@ -0,0 +1,41 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/lockout"
+)
+
+// requireStepUp checks the TOTP code sent with a high-value operation, and returns a domain error if it is not valid.
+// Invalid codes are counted like failed logins, so step-up is locked out for a while after too many of them.
+func (server *Server) requireStepUp(ctx context.Context, username string, code string) error {
+	if code == "" {
+		return apperror.New(apperror.CodeTwoFactorRequired, "a two-factor authentication code is required for this operation")
+	}
+
+	mtdt := server.extractMetadata(ctx)
+	attempt := lockout.Attempt{
+		Username:  username,
+		ClientIP:  mtdt.ClientIP,
+		UserAgent: mtdt.UserAgent,
+	}
+
+	if err := server.stepUpGuard.Begin(ctx, &attempt); err != nil {
+		var lockedErr *lockout.LockedError
+		if errors.As(err, &lockedErr) {
+			return stepUpLockedError(lockedErr)
+		}
+		return err
+	}
+
+	// the attempt stays recorded as a failure unless the code is valid
+	if err := server.twoFactor.Verify(ctx, username, code); err != nil {
+		return err
+	}
+
+	attempt.Outcome = db.LoginOutcomeSuccess
+	return server.stepUpGuard.Finish(ctx, attempt)
+}
@@ -0,0 +1,104 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/pakojabi/simplebank/logger"
+	"github.com/pakojabi/simplebank/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// sseWatchAccountStream adapts an HTTP response to the WatchAccount server stream,
+// writing every message as a server-sent event.
+type sseWatchAccountStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	writer  http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (stream *sseWatchAccountStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *sseWatchAccountStream) Send(rsp *pb.WatchAccountResponse) error {
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(rsp)
+	if err != nil {
+		return err
+	}
+
+	header := stream.writer.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		stream.writer.WriteHeader(http.StatusOK)
+	}
+
+	if _, err := fmt.Fprintf(stream.writer, "event: account\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	stream.flusher.Flush()
+	return nil
+}
+
+// sendError ends the stream with an error event, which carries the status the gRPC stream would have ended with
+func (stream *sseWatchAccountStream) sendError(watchErr error) error {
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(status.Convert(watchErr).Proto())
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(stream.writer, "event: error\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	stream.flusher.Flush()
+	return nil
+}
+
+// WatchAccountSSE bridges WatchAccount to HTTP clients as server-sent events.
+// It is registered on the gateway mux for GET /v1/accounts/{account_id}/watch.
+func (server *Server) WatchAccountSSE(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	accountID, err := strconv.ParseInt(pathParams["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid account_id", http.StatusBadRequest)
+		return
+	}
+
+	// carry the headers the gRPC handler reads over as incoming metadata
+	md := metadata.Pairs(authorizationHeader, r.Header.Get(authorizationHeader))
+	stream := &sseWatchAccountStream{
+		ctx:     metadata.NewIncomingContext(r.Context(), md),
+		writer:  w,
+		flusher: flusher,
+	}
+
+	err = server.WatchAccount(&pb.WatchAccountRequest{AccountId: accountID}, stream)
+	if err == nil || r.Context().Err() != nil {
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		// nothing has been streamed yet, so we can still reply with a proper status
+		st := status.Convert(err)
+		http.Error(w, st.Message(), runtime.HTTPStatusFromCode(st.Code()))
+		return
+	}
+	// the status is already sent, so the client learns why the stream ends from a last event
+	if err := stream.sendError(err); err != nil {
+		logger.FromContext(r.Context()).Error("cannot send the error event of an account watch", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,164 @@
+package gapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pakojabi/simplebank/apperror"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// sseEvent is an event parsed from a text/event-stream body
+type sseEvent struct {
+	name string
+	data string
+}
+
+func parseSSEEvents(t *testing.T, body string) []sseEvent {
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			field, value, ok := strings.Cut(line, ": ")
+			require.True(t, ok, "invalid event line: %q", line)
+			switch field {
+			case "event":
+				event.name = value
+			case "data":
+				event.data = value
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestWatchAccountSSE(t *testing.T) {
+	username := util.RandomOwner()
+	account := db.Account{
+		ID:       util.RandomInt(1, 1000),
+		Owner:    username,
+		Balance:  100,
+		Currency: util.USD,
+	}
+
+	// closedEvents is how the store drops a watcher that does not keep up
+	closedEvents := func() <-chan db.AccountEvent {
+		events := make(chan db.AccountEvent)
+		close(events)
+		return events
+	}
+
+	testCases := []struct {
+		name          string
+		accountID     string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "SlowConsumer",
+			accountID: strconv.FormatInt(account.ID, 10),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					WatchAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(closedEvents())
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+
+				events := parseSSEEvents(t, recorder.Body.String())
+				require.Len(t, events, 2)
+
+				require.Equal(t, "account", events[0].name)
+				var snapshot pb.WatchAccountResponse
+				require.NoError(t, protojson.Unmarshal([]byte(events[0].data), &snapshot))
+				require.Equal(t, account.ID, snapshot.GetAccount().GetId())
+
+				// the stream ends with the status the gRPC stream would have ended with
+				require.Equal(t, "error", events[1].name)
+				var st spb.Status
+				require.NoError(t, protojson.Unmarshal([]byte(events[1].data), &st))
+				requireStatus(t, status.FromProto(&st).Err(), codes.ResourceExhausted, apperror.CodeSlowConsumer)
+			},
+		},
+		{
+			name:      "NotFound",
+			accountID: strconv.FormatInt(account.ID, 10),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					WatchAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(closedEvents())
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(db.Account{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				// nothing was streamed, so the error is a plain response
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.NotEqual(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+				require.NotContains(t, recorder.Body.String(), "event:")
+			},
+		},
+		{
+			name:      "InvalidAccountID",
+			accountID: "abc",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					WatchAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			allowAuthorization(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/v1/accounts/%s/watch", tc.accountID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			accessToken, _, err := server.tokenMaker.Make(username, util.DepositorRole, time.Minute)
+			require.NoError(t, err)
+			request.Header.Set(authorizationHeader, fmt.Sprintf("%s %s", authorizationBearer, accessToken))
+
+			server.WatchAccountSSE(recorder, request, map[string]string{"account_id": tc.accountID})
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// probeTimeout bounds each probe, so that a hanging dependency reports as not ready instead of hanging the check
+const probeTimeout = 2 * time.Second
+
+// Probe reports why a dependency is not ready, or nil if it is
+type Probe func(ctx context.Context) error
+
+type namedProbe struct {
+	name  string
+	probe Probe
+}
+
+// Report is the outcome of a readiness check. Failures holds the error of every failed probe, by name.
+type Report struct {
+	Ready    bool
+	Failures map[string]string
+}
+
+// Checker tells whether the service is ready to serve, by running its probes.
+// Once shutting down, it is never ready again.
+type Checker struct {
+	mu           sync.RWMutex
+	probes       []namedProbe
+	shuttingDown atomic.Bool
+	// done is closed on shutdown, to end the health watches
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewChecker creates a new Checker without any probes
+func NewChecker() *Checker {
+	return &Checker{
+		done: make(chan struct{}),
+	}
+}
+
+// AddProbe registers probe under name
+func (checker *Checker) AddProbe(name string, probe Probe) {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	checker.probes = append(checker.probes, namedProbe{name: name, probe: probe})
+}
+
+// Shutdown makes the service report as not ready, so that traffic moves away while it drains
+func (checker *Checker) Shutdown() {
+	checker.shuttingDown.Store(true)
+	checker.doneOnce.Do(func() {
+		close(checker.done)
+	})
+}
+
+// Done is closed once Shutdown is called
+func (checker *Checker) Done() <-chan struct{} {
+	return checker.done
+}
+
+// Check runs all the probes concurrently
+func (checker *Checker) Check(ctx context.Context) Report {
+	if checker.shuttingDown.Load() {
+		return Report{Failures: map[string]string{"shutdown": "service is shutting down"}}
+	}
+
+	checker.mu.RLock()
+	probes := checker.probes
+	checker.mu.RUnlock()
+
+	errs := make([]error, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func(i int, probe Probe) {
+			defer wg.Done()
+
+			probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+			defer cancel()
+			errs[i] = runProbe(probeCtx, probe)
+		}(i, probe.probe)
+	}
+	wg.Wait()
+
+	report := Report{Ready: true, Failures: map[string]string{}}
+	for i, err := range errs {
+		if err != nil {
+			report.Ready = false
+			report.Failures[probes[i].name] = err.Error()
+		}
+	}
+	return report
+}
+
+// runProbe returns as soon as ctx is done, even if the probe ignores it
+func runProbe(ctx context.Context, probe Probe) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- probe(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("probe timed out: %w", ctx.Err())
+	}
+}
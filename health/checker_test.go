@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func okProbe(ctx context.Context) error {
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	checker := NewChecker()
+	require.True(t, checker.Check(context.Background()).Ready)
+
+	checker.AddProbe("database", okProbe)
+	checker.AddProbe("migrations", func(ctx context.Context) error {
+		return errors.New("schema is at version 10, expected version 11")
+	})
+
+	report := checker.Check(context.Background())
+	require.False(t, report.Ready)
+	require.Equal(t, map[string]string{"migrations": "schema is at version 10, expected version 11"}, report.Failures)
+}
+
+func TestCheckTimeout(t *testing.T) {
+	checker := NewChecker()
+	checker.AddProbe("stuck", func(ctx context.Context) error {
+		// ignores ctx on purpose
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	report := checker.Check(ctx)
+	require.False(t, report.Ready)
+	require.Contains(t, report.Failures, "stuck")
+}
+
+func TestShutdown(t *testing.T) {
+	checker := NewChecker()
+	checker.AddProbe("database", okProbe)
+	require.True(t, checker.Check(context.Background()).Ready)
+
+	checker.Shutdown()
+	checker.Shutdown()
+	require.False(t, checker.Check(context.Background()).Ready)
+
+	select {
+	case <-checker.Done():
+	default:
+		t.Fatal("done should be closed")
+	}
+}
+
+func TestHTTPHandlers(t *testing.T) {
+	checker := NewChecker()
+	failing := false
+	checker.AddProbe("database", func(ctx context.Context) error {
+		if failing {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	serve := func(handler http.HandlerFunc) (int, response) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		var rsp response
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+		return recorder.Code, rsp
+	}
+
+	code, rsp := serve(checker.ReadinessHandler)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, statusServing, rsp.Status)
+
+	failing = true
+	code, rsp = serve(checker.ReadinessHandler)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, statusNotServing, rsp.Status)
+	require.Equal(t, map[string]string{"database": statusNotServing}, rsp.Checks)
+
+	// the process stays alive while not ready
+	code, rsp = serve(checker.LivenessHandler)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, statusServing, rsp.Status)
+
+	failing = false
+	checker.Shutdown()
+	code, _ = serve(checker.ReadinessHandler)
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	code, _ = serve(checker.LivenessHandler)
+	require.Equal(t, http.StatusOK, code)
+}
+
+func TestGRPCCheck(t *testing.T) {
+	checker := NewChecker()
+	checker.AddProbe("database", okProbe)
+	server := NewGRPCServer(checker, "pb.SimpleBank")
+
+	for _, service := range []string{"", "pb.SimpleBank"} {
+		rsp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, rsp.Status)
+	}
+
+	_, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "pb.Unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	checker.Shutdown()
+	rsp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, rsp.Status)
+}
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// watchInterval is how often Watch runs the probes to look for a change of status
+const watchInterval = 5 * time.Second
+
+// GRPCServer implements grpc.health.v1 on top of a Checker. All the known services
+// share the status of the whole server, which is also reported for the empty service name.
+type GRPCServer struct {
+	healthpb.UnimplementedHealthServer
+	checker  *Checker
+	services map[string]bool
+}
+
+// NewGRPCServer creates a new health server for the given gRPC service names
+func NewGRPCServer(checker *Checker, services ...string) *GRPCServer {
+	server := &GRPCServer{
+		checker:  checker,
+		services: map[string]bool{"": true},
+	}
+	for _, service := range services {
+		server.services[service] = true
+	}
+	return server
+}
+
+// Check implements grpc_health_v1.HealthServer
+func (server *GRPCServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if !server.services[req.GetService()] {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+
+	return &healthpb.HealthCheckResponse{Status: server.status(ctx)}, nil
+}
+
+// Watch implements grpc_health_v1.HealthServer. Unknown services are reported as SERVICE_UNKNOWN,
+// as they might get registered later. The stream ends once the server shuts down, so it does not hold up draining.
+func (server *GRPCServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx := stream.Context()
+	known := server.services[req.GetService()]
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		current := healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		if known {
+			current = server.status(ctx)
+		}
+
+		if current != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: current}); err != nil {
+				return err
+			}
+			last = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-server.checker.Done():
+			if known && last != healthpb.HealthCheckResponse_NOT_SERVING {
+				return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING})
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (server *GRPCServer) status(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	if server.checker.Check(ctx).Ready {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
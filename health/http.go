@@ -0,0 +1,47 @@
+package health
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+const (
+	statusServing    = "SERVING"
+	statusNotServing = "NOT_SERVING"
+)
+
+type response struct {
+	Status string `json:"status"`
+	// Checks holds the status of every failed check, by name. Their errors are only logged,
+	// as they can tell anyone who reaches the endpoint about the internals of the service.
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// LivenessHandler serves /healthz: it only tells that the process is up and able to answer,
+// so that the orchestrator does not restart it while it drains
+func (checker *Checker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, http.StatusOK, response{Status: statusServing})
+}
+
+// ReadinessHandler serves /readyz: it replies 503 when any probe fails, or while shutting down
+func (checker *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := checker.Check(r.Context())
+	if !report.Ready {
+		checks := make(map[string]string, len(report.Failures))
+		for name, failure := range report.Failures {
+			log.Printf("readiness check %s failed: %s", name, failure)
+			checks[name] = statusNotServing
+		}
+		writeResponse(w, http.StatusServiceUnavailable, response{Status: statusNotServing, Checks: checks})
+		return
+	}
+	writeResponse(w, http.StatusOK, response{Status: statusServing})
+}
+
+func writeResponse(w http.ResponseWriter, code int, rsp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(rsp)
+}
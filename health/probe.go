@@ -0,0 +1,51 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pakojabi/simplebank/worker"
+)
+
+// DatabaseProbe checks that the database can be reached
+func DatabaseProbe(connPool *pgxpool.Pool) Probe {
+	return func(ctx context.Context) error {
+		return connPool.Ping(ctx)
+	}
+}
+
+// MigrationProbe checks that the database schema is at the version the service was built for
+func MigrationProbe(connPool *pgxpool.Pool, expectedVersion uint) Probe {
+	return func(ctx context.Context) error {
+		var version uint
+		var dirty bool
+		err := connPool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("no migration applied, expected version %d", expectedVersion)
+			}
+			return fmt.Errorf("cannot get migration version: %w", err)
+		}
+
+		if dirty {
+			return fmt.Errorf("migration %d failed and left the schema dirty", version)
+		}
+		if version != expectedVersion {
+			return fmt.Errorf("schema is at version %d, expected version %d", version, expectedVersion)
+		}
+		return nil
+	}
+}
+
+// TaskProcessorProbe checks that the background workers are running
+func TaskProcessorProbe(taskProcessor worker.TaskProcessor) Probe {
+	return func(ctx context.Context) error {
+		if !taskProcessor.Running() {
+			return errors.New("task processor is not running")
+		}
+		return nil
+	}
+}
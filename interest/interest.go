@@ -0,0 +1,120 @@
+// Package interest computes the interest accounts earn, from the annual rate, day-count convention and
+// compounding frequency of their product.
+//
+// Interest accrues every day on the balance at the end of the day, in millionths of the minor unit of the
+// currency, so days that earn less than a cent still count. It is paid out, and so compounds, on the last day
+// of each compounding period: the whole minor units accrued are paid, and the rest is carried to the next one.
+package interest
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// MicrosPerUnit is the number of millionths of a minor unit, in which interest accrues, in a minor unit
+const MicrosPerUnit = 1_000_000
+
+// DayCount is a day-count convention: how much of a year a day is
+type DayCount string
+
+// Day-count conventions
+const (
+	// Actual365 counts the actual days, over a year of 365 days, leap years included
+	Actual365 DayCount = "ACT/365"
+	// Thirty360 counts months of 30 days, over a year of 360 days: the 31st earns nothing, and the last
+	// day of February earns up to the 30th
+	Thirty360 DayCount = "30/360"
+)
+
+// Compounding is how often interest is paid out, and so compounds
+type Compounding string
+
+// Compounding frequencies
+const (
+	Daily     Compounding = "daily"
+	Monthly   Compounding = "monthly"
+	Quarterly Compounding = "quarterly"
+	Annually  Compounding = "annually"
+)
+
+// ParseDayCount checks that value is a supported day-count convention
+func ParseDayCount(value string) (DayCount, error) {
+	switch dayCount := DayCount(value); dayCount {
+	case Actual365, Thirty360:
+		return dayCount, nil
+	default:
+		return "", fmt.Errorf("unsupported day-count convention %q", value)
+	}
+}
+
+// ParseCompounding checks that value is a supported compounding frequency
+func ParseCompounding(value string) (Compounding, error) {
+	switch compounding := Compounding(value); compounding {
+	case Daily, Monthly, Quarterly, Annually:
+		return compounding, nil
+	default:
+		return "", fmt.Errorf("unsupported compounding frequency %q", value)
+	}
+}
+
+// YearFraction returns the fraction of a year day counts for, as days over the days of a year
+func (dayCount DayCount) YearFraction(day time.Time) (days, yearDays int64) {
+	switch dayCount {
+	case Thirty360:
+		return days360(day, day.AddDate(0, 0, 1)), 360
+	default:
+		return 1, 365
+	}
+}
+
+// days360 counts the days from start to end with the 30/360 US convention, as in ISDA 2006 section 4.16(f)
+// without the adjustments for February
+func days360(start, end time.Time) int64 {
+	y1, m1, d1 := start.Date()
+	y2, m2, d2 := end.Date()
+	if d1 == 31 {
+		d1 = 30
+	}
+	if d2 == 31 && d1 == 30 {
+		d2 = 30
+	}
+	return int64(360*(y2-y1) + 30*(int(m2)-int(m1)) + (d2 - d1))
+}
+
+// DailyAccrual returns the interest, in millionths of the minor unit and rounded down, that balance earns over
+// day at annualRatePPM. Balances below zero earn nothing.
+func DailyAccrual(balance, annualRatePPM int64, dayCount DayCount, day time.Time) int64 {
+	if balance <= 0 || annualRatePPM <= 0 {
+		return 0
+	}
+
+	// a rate in parts per million of a balance in minor units is, per year, the interest in millionths
+	days, yearDays := dayCount.YearFraction(day)
+	accrual := new(big.Int).Mul(big.NewInt(balance), big.NewInt(annualRatePPM))
+	accrual.Mul(accrual, big.NewInt(days))
+	accrual.Quo(accrual, big.NewInt(yearDays))
+	return accrual.Int64()
+}
+
+// IsPayoutDay tells whether interest is paid out at the end of day: the last day of each compounding period
+func (compounding Compounding) IsPayoutDay(day time.Time) bool {
+	next := day.AddDate(0, 0, 1)
+
+	switch compounding {
+	case Daily:
+		return true
+	case Monthly:
+		return next.Day() == 1
+	case Quarterly:
+		return next.Day() == 1 && next.Month()%3 == 1
+	default:
+		return next.YearDay() == 1
+	}
+}
+
+// Split splits accrued interest, in millionths of the minor unit, into the minor units to pay out and the
+// millionths to carry over
+func Split(accruedMicros int64) (amount, carryMicros int64) {
+	return accruedMicros / MicrosPerUnit, accruedMicros % MicrosPerUnit
+}
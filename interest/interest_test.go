@@ -0,0 +1,118 @@
+package interest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestYearFraction(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dayCount DayCount
+		day      time.Time
+		days     int64
+		yearDays int64
+	}{
+		{"Actual365", Actual365, date(2024, time.March, 15), 1, 365},
+		{"Actual365LeapDay", Actual365, date(2024, time.February, 29), 1, 365},
+		{"Thirty360", Thirty360, date(2023, time.March, 15), 1, 360},
+		{"Thirty360Thirtieth", Thirty360, date(2023, time.March, 30), 0, 360},
+		{"Thirty360ThirtyFirst", Thirty360, date(2023, time.March, 31), 1, 360},
+		{"Thirty360EndOfFebruary", Thirty360, date(2023, time.February, 28), 3, 360},
+		{"Thirty360EndOfLeapFebruary", Thirty360, date(2024, time.February, 29), 2, 360},
+		{"Thirty360EndOfYear", Thirty360, date(2023, time.December, 31), 1, 360},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			days, yearDays := tc.dayCount.YearFraction(tc.day)
+			require.Equal(t, tc.days, days)
+			require.Equal(t, tc.yearDays, yearDays)
+		})
+	}
+}
+
+func TestThirty360CountsAYearOf360Days(t *testing.T) {
+	for _, year := range []int{2023, 2024} {
+		var total int64
+		for day := date(year, time.January, 1); day.Year() == year; day = day.AddDate(0, 0, 1) {
+			days, _ := Thirty360.YearFraction(day)
+			total += days
+		}
+		require.Equal(t, int64(360), total, year)
+	}
+}
+
+func TestDailyAccrual(t *testing.T) {
+	day := date(2024, time.March, 15)
+
+	// 1000.00 at 3.65% earns 0.10 a day with ACT/365
+	require.Equal(t, int64(10*MicrosPerUnit), DailyAccrual(100_000, 36_500, Actual365, day))
+	// 1.00 at 2% earns 0.0054794... of a cent a day, kept to the millionth
+	require.Equal(t, int64(5479), DailyAccrual(100, 20_000, Actual365, day))
+	// 1.00 at 3.6% earns 0.01 of a cent a day with 30/360
+	require.Equal(t, int64(10_000), DailyAccrual(100, 36_000, Thirty360, day))
+
+	require.Zero(t, DailyAccrual(0, 20_000, Actual365, day))
+	require.Zero(t, DailyAccrual(-100_000, 20_000, Actual365, day))
+	require.Zero(t, DailyAccrual(100_000, 0, Actual365, day))
+	require.Zero(t, DailyAccrual(100_000, 20_000, Thirty360, date(2024, time.March, 30)))
+
+	// large balances do not overflow
+	require.Equal(t, int64(1_000_000_000_000_000_000), DailyAccrual(3_650_000_000_000_000, 100_000, Actual365, day))
+}
+
+func TestIsPayoutDay(t *testing.T) {
+	testCases := []struct {
+		compounding Compounding
+		day         time.Time
+		payout      bool
+	}{
+		{Daily, date(2024, time.March, 15), true},
+		{Monthly, date(2024, time.March, 15), false},
+		{Monthly, date(2024, time.February, 28), false},
+		{Monthly, date(2024, time.February, 29), true},
+		{Monthly, date(2024, time.March, 31), true},
+		{Quarterly, date(2024, time.February, 29), false},
+		{Quarterly, date(2024, time.March, 31), true},
+		{Quarterly, date(2024, time.December, 31), true},
+		{Annually, date(2024, time.June, 30), false},
+		{Annually, date(2024, time.December, 31), true},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.payout, tc.compounding.IsPayoutDay(tc.day), "%s %s", tc.compounding, tc.day.Format(time.DateOnly))
+	}
+}
+
+func TestSplit(t *testing.T) {
+	amount, carry := Split(12*MicrosPerUnit + 345)
+	require.Equal(t, int64(12), amount)
+	require.Equal(t, int64(345), carry)
+
+	amount, carry = Split(999_999)
+	require.Zero(t, amount)
+	require.Equal(t, int64(999_999), carry)
+}
+
+func TestParse(t *testing.T) {
+	dayCount, err := ParseDayCount("30/360")
+	require.NoError(t, err)
+	require.Equal(t, Thirty360, dayCount)
+
+	_, err = ParseDayCount("ACT/360")
+	require.Error(t, err)
+
+	compounding, err := ParseCompounding("quarterly")
+	require.NoError(t, err)
+	require.Equal(t, Quarterly, compounding)
+
+	_, err = ParseCompounding("weekly")
+	require.Error(t, err)
+}
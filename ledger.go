@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/ledger"
+	"github.com/pakojabi/simplebank/util"
+)
+
+const ledgerUsage = "usage: main ledger verify | anchor [YYYY-MM-DD] | eod [YYYY-MM-DD] | keygen"
+
+// runLedgerCommand runs the ledger subcommand: verify walks the hash chain of every account and checks the
+// published anchors, anchor publishes the anchor of a day, or of every day not published yet up to yesterday
+// by default, eod accrues the interest of a day and closes it, or every day not closed yet up to yesterday by default,
+// and keygen prints a new anchor signing key along with its public key
+func runLedgerCommand(config util.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(ledgerUsage)
+	}
+
+	if args[0] == "keygen" {
+		if len(args) > 1 {
+			return errors.New(ledgerUsage)
+		}
+		return printAnchorKeys()
+	}
+
+	ctx := context.Background()
+	connPool, err := newDBPool(ctx, config)
+	if err != nil {
+		return fmt.Errorf("cannot connect to db: %w", err)
+	}
+	defer connPool.Close()
+	store := db.NewStore(connPool)
+
+	switch args[0] {
+	case "verify":
+		if len(args) > 1 {
+			return errors.New(ledgerUsage)
+		}
+		return verifyLedger(ctx, config, store)
+	case "anchor":
+		day, err := parseLedgerDay(args)
+		if err != nil {
+			return err
+		}
+
+		publisher, err := ledger.NewPublisher(config, store)
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			_, err = publisher.PublishUntil(ctx, day)
+			return err
+		}
+		anchor, err := publisher.Publish(ctx, day)
+		if err != nil {
+			return fmt.Errorf("cannot publish anchor: %w", err)
+		}
+		log.Printf("published ledger anchor of %s over %d accounts: %s", anchor.Date, len(anchor.Heads), anchor.Hash)
+		return nil
+	case "eod":
+		day, err := parseLedgerDay(args)
+		if err != nil {
+			return err
+		}
+
+		eod := ledger.NewEndOfDay(store)
+		if len(args) == 1 {
+			_, err = eod.CloseUntil(ctx, day)
+			return err
+		}
+		closing, err := eod.Close(ctx, day)
+		if err != nil {
+			return err
+		}
+		log.Printf("closed %s: accrued interest of %d of %d accounts, paid %d in %d payouts, took %d and froze %d balance snapshots",
+			args[1], closing.Interest.Accrued, closing.Interest.Accounts, closing.Interest.Paid, closing.Interest.Payouts,
+			closing.Snapshots.Snapshots, closing.Snapshots.Frozen)
+		return nil
+	default:
+		return errors.New(ledgerUsage)
+	}
+}
+
+// printAnchorKeys prints a new LEDGER_ANCHOR_KEY, to keep secret, and its LEDGER_ANCHOR_PUBLIC_KEY
+func printAnchorKeys() error {
+	publicKey, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return fmt.Errorf("cannot generate anchor signing key: %w", err)
+	}
+	fmt.Printf("LEDGER_ANCHOR_KEY=%s\n", base64.StdEncoding.EncodeToString(key.Seed()))
+	fmt.Printf("LEDGER_ANCHOR_PUBLIC_KEY=%s\n", base64.StdEncoding.EncodeToString(publicKey))
+	return nil
+}
+
+// parseLedgerDay parses the optional date argument of a ledger subcommand, which defaults to yesterday
+func parseLedgerDay(args []string) (time.Time, error) {
+	switch len(args) {
+	case 1:
+		return time.Now().AddDate(0, 0, -1), nil
+	case 2:
+		day, err := time.Parse(ledger.DateLayout, args[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date: %s", args[1])
+		}
+		return day, nil
+	default:
+		return time.Time{}, errors.New(ledgerUsage)
+	}
+}
+
+// verifyLedger reports the first broken link of every account, and the entries that no longer match
+// the published anchors, if their public key is set
+func verifyLedger(ctx context.Context, config util.Config, store db.Store) error {
+	report, err := ledger.Verify(ctx, store)
+	if err != nil {
+		return err
+	}
+	log.Printf("verified %d entries of %d accounts", report.Entries, report.Accounts)
+	breaks := report.Breaks
+
+	if config.LedgerAnchorPublicKey != "" {
+		auditor, err := ledger.NewAuditor(config, store)
+		if err != nil {
+			return err
+		}
+		anchors, anchorBreaks, err := auditor.Check(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("checked %d published anchors", anchors)
+		breaks = append(breaks, anchorBreaks...)
+	}
+
+	for _, brk := range breaks {
+		log.Printf("broken link: %s", brk)
+	}
+	if len(breaks) > 0 {
+		return fmt.Errorf("ledger has %d broken links", len(breaks))
+	}
+	log.Printf("ledger is intact")
+	return nil
+}
@@ -0,0 +1,178 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+)
+
+// DateLayout is the layout of the dates of anchors, which are days in UTC
+const DateLayout = "2006-01-02"
+
+var ErrInvalidSignature = errors.New("anchor signature is invalid")
+
+// Head is the last entry of the chain of an account at the end of a day
+type Head struct {
+	AccountID int64  `json:"account_id"`
+	EntryID   int64  `json:"entry_id"`
+	Hash      string `json:"hash"`
+}
+
+// Anchor commits to the state of the ledger at the end of a day: since the hash of the head of each chain
+// covers every entry before it, rehashing the chains after the anchor is published does not go unnoticed.
+type Anchor struct {
+	Date string `json:"date"`
+	// Heads are those of the accounts with entries created before the end of the day, by account id
+	Heads []Head `json:"heads"`
+	// Hash covers all the heads, so it is enough to publish it elsewhere
+	Hash string `json:"hash"`
+}
+
+// SignedAnchor is what an anchor file holds. Anchor is kept as signed, so the signature can be checked
+// without depending on how the anchor is encoded: only its whitespace may change, e.g. to indent the file.
+type SignedAnchor struct {
+	Anchor    json.RawMessage `json:"anchor"`
+	PublicKey string          `json:"public_key"`
+	Signature string          `json:"signature"`
+}
+
+// ComputeAnchor computes the anchor of day, from the heads of the chains at the end of that day (UTC)
+func ComputeAnchor(ctx context.Context, store db.Store, day time.Time) (Anchor, error) {
+	start := startOfDay(day)
+	anchor := Anchor{Date: start.Format(DateLayout), Heads: []Head{}}
+	hash := sha256.New()
+
+	err := forEachAccount(ctx, store, func(account db.Account) error {
+		entry, err := store.GetLastEntryBefore(ctx, db.GetLastEntryBeforeParams{
+			AccountID: account.ID,
+			Before:    start.AddDate(0, 0, 1),
+		})
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot get head of account %d: %w", account.ID, err)
+		}
+
+		anchor.Heads = append(anchor.Heads, Head{
+			AccountID: entry.AccountID,
+			EntryID:   entry.ID,
+			Hash:      hex.EncodeToString(entry.Hash),
+		})
+		hash.Write(binary.BigEndian.AppendUint64(nil, uint64(entry.AccountID)))
+		hash.Write(binary.BigEndian.AppendUint64(nil, uint64(entry.ID)))
+		hash.Write(entry.Hash)
+		return nil
+	})
+	if err != nil {
+		return Anchor{}, err
+	}
+
+	anchor.Hash = hex.EncodeToString(hash.Sum(nil))
+	return anchor, nil
+}
+
+// Sign encodes anchor and signs it with key
+func Sign(anchor Anchor, key ed25519.PrivateKey) (SignedAnchor, error) {
+	payload, err := json.Marshal(anchor)
+	if err != nil {
+		return SignedAnchor{}, err
+	}
+
+	return SignedAnchor{
+		Anchor:    payload,
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	}, nil
+}
+
+// Open checks the signature of signed with publicKey, and returns its anchor
+func Open(signed SignedAnchor, publicKey ed25519.PublicKey) (Anchor, error) {
+	// the anchor is signed as json.Marshal encodes it, without whitespace
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, signed.Anchor); err != nil {
+		return Anchor{}, fmt.Errorf("cannot decode anchor: %w", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil || !ed25519.Verify(publicKey, payload.Bytes(), signature) {
+		return Anchor{}, ErrInvalidSignature
+	}
+
+	var anchor Anchor
+	if err := json.Unmarshal(signed.Anchor, &anchor); err != nil {
+		return Anchor{}, fmt.Errorf("cannot decode anchor: %w", err)
+	}
+	return anchor, nil
+}
+
+// CheckAnchor compares the heads recorded in anchor with the entries they point to now
+func CheckAnchor(ctx context.Context, store db.Store, anchor Anchor) ([]Break, error) {
+	var breaks []Break
+
+	for _, head := range anchor.Heads {
+		entry, err := store.GetEntry(ctx, head.EntryID)
+		if errors.Is(err, db.ErrRecordNotFound) {
+			breaks = append(breaks, Break{AccountID: head.AccountID, EntryID: head.EntryID, Reason: "is missing, but is in the anchor of " + anchor.Date})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot get entry %d: %w", head.EntryID, err)
+		}
+
+		if entry.AccountID != head.AccountID || hex.EncodeToString(entry.Hash) != head.Hash {
+			breaks = append(breaks, Break{AccountID: head.AccountID, EntryID: head.EntryID, Reason: "does not match the anchor of " + anchor.Date})
+		}
+	}
+
+	return breaks, nil
+}
+
+// ParseSigningKey decodes an ed25519 private key from the base64 encoding of its seed
+func ParseSigningKey(encoded string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode anchor signing key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("anchor signing key must be a %d bytes seed, got %d bytes", ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ParseVerifyingKey decodes an ed25519 public key from its base64 encoding
+func ParseVerifyingKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode anchor public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("anchor public key must be %d bytes, got %d bytes", ed25519.PublicKeySize, len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// readSignedAnchor reads an anchor file
+func readSignedAnchor(path string) (SignedAnchor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SignedAnchor{}, err
+	}
+
+	var signed SignedAnchor
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return SignedAnchor{}, fmt.Errorf("cannot decode anchor file %s: %w", path, err)
+	}
+	return signed, nil
+}
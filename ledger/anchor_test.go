@@ -0,0 +1,208 @@
+package ledger
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPublisher(t *testing.T, store db.Store) *Publisher {
+	seed := make([]byte, ed25519.SeedSize)
+	copy(seed, util.RandomString(ed25519.SeedSize))
+
+	publisher, err := NewPublisher(util.Config{
+		LedgerAnchorDir:       t.TempDir(),
+		LedgerAnchorKey:       base64.StdEncoding.EncodeToString(seed),
+		LedgerAnchorPublicKey: base64.StdEncoding.EncodeToString(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)),
+	}, store)
+	require.NoError(t, err)
+	return publisher
+}
+
+// newTestAuditor creates an Auditor of the anchors of publisher, which only has its public key
+func newTestAuditor(t *testing.T, store db.Store, publisher *Publisher) *Auditor {
+	auditor, err := NewAuditor(util.Config{
+		LedgerAnchorDir:       publisher.dir,
+		LedgerAnchorPublicKey: base64.StdEncoding.EncodeToString(publisher.key.Public().(ed25519.PublicKey)),
+	}, store)
+	require.NoError(t, err)
+	return auditor
+}
+
+func TestComputeAnchor(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	account1, account2 := createTestLedger(t, store, 3)
+	createTestAccount(t, store)
+
+	anchor, err := ComputeAnchor(ctx, store, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, time.Now().UTC().Format(DateLayout), anchor.Date)
+
+	// accounts without entries have no head
+	require.Len(t, anchor.Heads, 2)
+	for i, account := range []db.Account{account1, account2} {
+		last, err := store.GetLastEntry(ctx, account.ID)
+		require.NoError(t, err)
+		require.Equal(t, Head{AccountID: account.ID, EntryID: last.ID, Hash: hex.EncodeToString(last.Hash)}, anchor.Heads[i])
+	}
+
+	// the anchor of a day leaves out the entries of the days after it
+	yesterday, err := ComputeAnchor(ctx, store, time.Now().AddDate(0, 0, -1))
+	require.NoError(t, err)
+	require.Empty(t, yesterday.Heads)
+	require.NotEqual(t, anchor.Hash, yesterday.Hash)
+
+	again, err := ComputeAnchor(ctx, store, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, anchor, again)
+}
+
+func TestSignAndOpen(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	anchor := Anchor{Date: "2024-01-31", Heads: []Head{{AccountID: 1, EntryID: 2, Hash: "00"}}, Hash: "ff"}
+
+	signed, err := Sign(anchor, key)
+	require.NoError(t, err)
+
+	opened, err := Open(signed, key.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	require.Equal(t, anchor, opened)
+
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, err = Open(signed, otherKey)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	signed.Anchor = []byte(`{"date":"2024-01-31","heads":[],"hash":"ff"}`)
+	_, err = Open(signed, key.Public().(ed25519.PublicKey))
+	require.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestParseSigningKey(t *testing.T) {
+	_, err := ParseSigningKey("not base64!")
+	require.Error(t, err)
+
+	_, err = ParseSigningKey(base64.StdEncoding.EncodeToString([]byte("too short")))
+	require.Error(t, err)
+
+	seed := make([]byte, ed25519.SeedSize)
+	key, err := ParseSigningKey(base64.StdEncoding.EncodeToString(seed))
+	require.NoError(t, err)
+	require.Equal(t, ed25519.NewKeyFromSeed(seed), key)
+}
+
+func TestParseVerifyingKey(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	key, err := ParseVerifyingKey(base64.StdEncoding.EncodeToString(publicKey))
+	require.NoError(t, err)
+	require.Equal(t, publicKey, key)
+
+	_, err = ParseVerifyingKey("not base64!")
+	require.Error(t, err)
+
+	_, err = ParseVerifyingKey(base64.StdEncoding.EncodeToString(publicKey[:16]))
+	require.Error(t, err)
+}
+
+func TestNewPublisherKeyMismatch(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	copy(seed, util.RandomString(ed25519.SeedSize))
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	_, err = NewPublisher(util.Config{
+		LedgerAnchorDir:       t.TempDir(),
+		LedgerAnchorKey:       base64.StdEncoding.EncodeToString(seed),
+		LedgerAnchorPublicKey: base64.StdEncoding.EncodeToString(otherKey),
+	}, db.NewMemStore())
+	require.Error(t, err)
+}
+
+func TestPublishAndCheck(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	account1, _ := createTestLedger(t, store, 4)
+	publisher := newTestPublisher(t, store)
+
+	anchor, err := publisher.Publish(ctx, time.Now())
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(publisher.dir, "anchor-"+anchor.Date+".json"))
+
+	// a published anchor is never replaced
+	_, err = publisher.Publish(ctx, time.Now())
+	require.ErrorIs(t, err, ErrAnchorExists)
+
+	anchors, breaks, err := newTestAuditor(t, store, publisher).Check(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, anchors)
+	require.Empty(t, breaks)
+
+	// anchors signed with another key are rejected
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	auditor, err := NewAuditor(util.Config{
+		LedgerAnchorDir:       publisher.dir,
+		LedgerAnchorPublicKey: base64.StdEncoding.EncodeToString(otherKey),
+	}, store)
+	require.NoError(t, err)
+	_, _, err = auditor.Check(ctx)
+	require.Error(t, err)
+
+	// whoever rewrites the history of an account has to rewrite its head, which the anchor still has
+	head, err := store.GetLastEntry(ctx, account1.ID)
+	require.NoError(t, err)
+	tampered := tamper(store)
+	tampered.setHash(head.ID, db.HashEntry(nil, head))
+
+	anchors, breaks, err = newTestAuditor(t, tampered, publisher).Check(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, anchors)
+	require.Equal(t, []Break{{
+		AccountID: account1.ID,
+		EntryID:   head.ID,
+		Reason:    "does not match the anchor of " + anchor.Date,
+	}}, breaks)
+}
+
+func TestPublishUntil(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	createTestLedger(t, store, 2)
+	publisher := newTestPublisher(t, store)
+	today := startOfDay(time.Now())
+
+	// with no anchor published yet, only the last day is
+	published, err := publisher.PublishUntil(ctx, today.AddDate(0, 0, -3))
+	require.NoError(t, err)
+	require.Equal(t, 1, published)
+
+	// then every day since the last published one
+	published, err = publisher.PublishUntil(ctx, today)
+	require.NoError(t, err)
+	require.Equal(t, 3, published)
+
+	published, err = publisher.PublishUntil(ctx, today)
+	require.NoError(t, err)
+	require.Zero(t, published)
+
+	for day := -3; day <= 0; day++ {
+		require.FileExists(t, publisher.path(today.AddDate(0, 0, day).Format(DateLayout)))
+	}
+
+	anchors, breaks, err := newTestAuditor(t, store, publisher).Check(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 4, anchors)
+	require.Empty(t, breaks)
+}
@@ -0,0 +1,113 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+)
+
+// pageSize is how many rows are read at once while walking the ledger
+const pageSize = 1000
+
+// Break is a link of the hash chain of an account that does not hold
+type Break struct {
+	AccountID int64
+	EntryID   int64
+	Reason    string
+}
+
+func (b Break) String() string {
+	return fmt.Sprintf("account %d: entry %d %s", b.AccountID, b.EntryID, b.Reason)
+}
+
+// Report is the outcome of the verification of the ledger
+type Report struct {
+	Accounts int
+	Entries  int
+	// Breaks holds the first broken link of each account whose chain does not hold
+	Breaks []Break
+}
+
+// Verify walks the hash chain of every account, and reports the first broken link of each
+func Verify(ctx context.Context, store db.Store) (Report, error) {
+	var report Report
+
+	err := forEachAccount(ctx, store, func(account db.Account) error {
+		entries, brk, err := VerifyAccount(ctx, store, account.ID)
+		if err != nil {
+			return err
+		}
+
+		report.Accounts++
+		report.Entries += entries
+		if brk != nil {
+			report.Breaks = append(report.Breaks, *brk)
+		}
+		return nil
+	})
+	return report, err
+}
+
+// VerifyAccount walks the hash chain of an account up to its first broken link, if any.
+// It returns the number of entries checked.
+func VerifyAccount(ctx context.Context, store db.Store, accountID int64) (int, *Break, error) {
+	var prevHash []byte
+	var afterID int64
+	checked := 0
+
+	for {
+		entries, err := store.ListEntriesAfter(ctx, db.ListEntriesAfterParams{
+			AccountID: accountID,
+			AfterID:   afterID,
+			Limit:     pageSize,
+		})
+		if err != nil {
+			return checked, nil, fmt.Errorf("cannot list entries of account %d: %w", accountID, err)
+		}
+
+		for _, entry := range entries {
+			checked++
+			if entry.Hash == nil {
+				return checked, &Break{AccountID: accountID, EntryID: entry.ID, Reason: "has no hash"}, nil
+			}
+			// an edited entry fails here, and so does the entry after a deleted or inserted one
+			if !bytes.Equal(entry.Hash, db.HashEntry(prevHash, entry)) {
+				return checked, &Break{AccountID: accountID, EntryID: entry.ID, Reason: "does not match its hash"}, nil
+			}
+			prevHash = entry.Hash
+			afterID = entry.ID
+		}
+
+		if len(entries) < pageSize {
+			return checked, nil, nil
+		}
+	}
+}
+
+// forEachAccount calls fn with every account, in the order of their ids
+func forEachAccount(ctx context.Context, store db.Store, fn func(db.Account) error) error {
+	var afterID int64
+
+	for {
+		accounts, err := store.ListAllAccounts(ctx, db.ListAllAccountsParams{
+			AfterID: afterID,
+			Limit:   pageSize,
+		})
+		if err != nil {
+			return fmt.Errorf("cannot list accounts: %w", err)
+		}
+
+		for _, account := range accounts {
+			if err := fn(account); err != nil {
+				return err
+			}
+			afterID = account.ID
+		}
+
+		if len(accounts) < pageSize {
+			return nil
+		}
+	}
+}
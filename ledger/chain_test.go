@@ -0,0 +1,149 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestAccount(t *testing.T, store db.Store) db.Account {
+	username := util.RandomOwner()
+	_, err := store.CreateUser(context.Background(), db.CreateUserParams{
+		Username:       username,
+		HashedPassword: util.RandomString(32),
+		FullName:       util.RandomOwner(),
+		Email:          username + "@example.com",
+	})
+	require.NoError(t, err)
+
+	account, err := store.CreateAccount(context.Background(), db.CreateAccountParams{
+		Owner:    username,
+		Balance:  1000,
+		Currency: util.USD,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+// createTestLedger makes n transfers back and forth between two new accounts
+func createTestLedger(t *testing.T, store db.Store, n int) (db.Account, db.Account) {
+	account1 := createTestAccount(t, store)
+	account2 := createTestAccount(t, store)
+
+	for i := 0; i < n; i++ {
+		from, to := account1.ID, account2.ID
+		if i%2 == 1 {
+			from, to = to, from
+		}
+		_, err := store.TransferTx(context.Background(), db.TransferTxParams{
+			FromAccountID: from,
+			ToAccountID:   to,
+			Amount:        util.RandomInt(1, 10),
+		})
+		require.NoError(t, err)
+	}
+	return account1, account2
+}
+
+// tamperedStore serves the entries of a store as if their hashes had been rewritten behind its back, by a raw UPDATE
+// of the entries table, since the store itself has no query that changes the hash of an entry
+type tamperedStore struct {
+	db.Store
+	hashes map[int64][]byte
+}
+
+func tamper(store db.Store) *tamperedStore {
+	return &tamperedStore{Store: store, hashes: map[int64][]byte{}}
+}
+
+// setHash overwrites the hash of the entry on every later read
+func (store *tamperedStore) setHash(entryID int64, hash []byte) {
+	store.hashes[entryID] = hash
+}
+
+func (store *tamperedStore) apply(entry db.Entry) db.Entry {
+	if hash, ok := store.hashes[entry.ID]; ok {
+		entry.Hash = hash
+	}
+	return entry
+}
+
+func (store *tamperedStore) GetEntry(ctx context.Context, id int64) (db.Entry, error) {
+	entry, err := store.Store.GetEntry(ctx, id)
+	return store.apply(entry), err
+}
+
+func (store *tamperedStore) GetLastEntryBefore(ctx context.Context, arg db.GetLastEntryBeforeParams) (db.Entry, error) {
+	entry, err := store.Store.GetLastEntryBefore(ctx, arg)
+	return store.apply(entry), err
+}
+
+func (store *tamperedStore) ListEntriesAfter(ctx context.Context, arg db.ListEntriesAfterParams) ([]db.Entry, error) {
+	entries, err := store.Store.ListEntriesAfter(ctx, arg)
+	for i := range entries {
+		entries[i] = store.apply(entries[i])
+	}
+	return entries, err
+}
+
+func TestVerify(t *testing.T) {
+	store := db.NewMemStore()
+	createTestLedger(t, store, 5)
+	createTestAccount(t, store)
+
+	report, err := Verify(context.Background(), store)
+	require.NoError(t, err)
+	// with the 12 system accounts the migrations create
+	require.Equal(t, 15, report.Accounts)
+	require.Equal(t, 10, report.Entries)
+	require.Empty(t, report.Breaks)
+}
+
+func TestVerifyReportsFirstBrokenLink(t *testing.T) {
+	ctx := context.Background()
+	store := tamper(db.NewMemStore())
+	account1, account2 := createTestLedger(t, store, 6)
+
+	entries, err := store.ListEntries(ctx, db.ListEntriesParams{AccountID: account1.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, entries, 6)
+
+	// a rewritten hash breaks its own link, and hides those after it
+	store.setHash(entries[2].ID, db.HashEntry(nil, entries[2]))
+	store.setHash(entries[4].ID, nil)
+
+	report, err := Verify(ctx, store)
+	require.NoError(t, err)
+	require.Equal(t, []Break{{AccountID: account1.ID, EntryID: entries[2].ID, Reason: "does not match its hash"}}, report.Breaks)
+
+	// an entry without a hash is a broken link too
+	entries, err = store.ListEntries(ctx, db.ListEntriesParams{AccountID: account2.ID, Limit: 10})
+	require.NoError(t, err)
+	store.setHash(entries[0].ID, nil)
+
+	checked, brk, err := VerifyAccount(ctx, store, account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, checked)
+	require.Equal(t, &Break{AccountID: account2.ID, EntryID: entries[0].ID, Reason: "has no hash"}, brk)
+}
+
+func TestVerifyIgnoresOtherAccounts(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	account1, _ := createTestLedger(t, store, 2)
+	account3, _ := createTestLedger(t, store, 2)
+
+	// the chains of the accounts are independent, although their entries interleave
+	checked, brk, err := VerifyAccount(ctx, store, account3.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, checked)
+	require.Nil(t, brk)
+
+	checked, brk, err = VerifyAccount(ctx, store, account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, checked)
+	require.Nil(t, brk)
+}
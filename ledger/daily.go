@@ -0,0 +1,39 @@
+package ledger
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	// dayEndDelay leaves the transactions running at midnight some time to commit before a day is processed
+	dayEndDelay     = 5 * time.Minute
+	dailyRetryDelay = time.Minute
+)
+
+// runDaily calls fn with the previous day, then with every day shortly after it ends, until ctx is done.
+// Days are in UTC. When fn fails, it is called again with the same day after dailyRetryDelay.
+func runDaily(ctx context.Context, job string, fn func(ctx context.Context, day time.Time) error) {
+	for {
+		today := startOfDay(time.Now().Add(-dayEndDelay))
+		yesterday := today.AddDate(0, 0, -1)
+		wait := time.Until(today.AddDate(0, 0, 1).Add(dayEndDelay))
+
+		if err := fn(ctx, yesterday); err != nil && ctx.Err() == nil {
+			log.Printf("%s of %s failed, retrying in %s: %s", job, yesterday.Format(DateLayout), dailyRetryDelay, err)
+			wait = dailyRetryDelay
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
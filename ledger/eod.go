@@ -0,0 +1,82 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+)
+
+// EndOfDay closes the days of the ledger: it accrues the interest of each day, then takes the balance
+// snapshots of every account at its end, and freezes them for reporting
+type EndOfDay struct {
+	store db.Store
+}
+
+// Closing is what closing a day did
+type Closing struct {
+	Interest  InterestReport
+	Snapshots db.EndOfDayTxResult
+}
+
+// NewEndOfDay creates an EndOfDay job
+func NewEndOfDay(store db.Store) *EndOfDay {
+	return &EndOfDay{store: store}
+}
+
+// Close closes day. A closed day is left as it is, and so is the interest already accrued on a day that
+// failed to close: closing it again accrues the rest. Interest paid out at the end of the day is only in
+// the balances of the next one.
+func (eod *EndOfDay) Close(ctx context.Context, day time.Time) (Closing, error) {
+	var closing Closing
+	var err error
+
+	closing.Interest, err = AccrueInterest(ctx, eod.store, day)
+	if err != nil {
+		return closing, fmt.Errorf("cannot close %s: %w", startOfDay(day).Format(DateLayout), err)
+	}
+
+	closing.Snapshots, err = eod.store.EndOfDayTx(ctx, db.EndOfDayTxParams{Day: day})
+	if err != nil {
+		return closing, fmt.Errorf("cannot close %s: %w", startOfDay(day).Format(DateLayout), err)
+	}
+	return closing, nil
+}
+
+// CloseUntil closes every day after the last closed one, up to until included. When no day is closed yet,
+// only until is: GetBalanceAsOf does not need snapshots, they only make it faster.
+// A day is known to be closed by its frozen snapshots, so days before the first account are closed again.
+func (eod *EndOfDay) CloseUntil(ctx context.Context, until time.Time) (closed int, err error) {
+	day := startOfDay(until)
+	lastClosed, err := eod.store.GetLastFrozenSnapshotDate(ctx)
+	switch {
+	case err == nil:
+		day = startOfDay(lastClosed).AddDate(0, 0, 1)
+	case !errors.Is(err, db.ErrRecordNotFound):
+		return 0, fmt.Errorf("cannot get last closed day: %w", err)
+	}
+
+	for ; !day.After(startOfDay(until)); day = day.AddDate(0, 0, 1) {
+		closing, err := eod.Close(ctx, day)
+		if err != nil {
+			return closed, err
+		}
+
+		log.Printf("closed %s: accrued interest of %d accounts, made %d payouts and froze %d balance snapshots",
+			day.Format(DateLayout), closing.Interest.Accrued, closing.Interest.Payouts, closing.Snapshots.Frozen)
+		closed++
+	}
+	return closed, nil
+}
+
+// Run closes the previous day, then every day shortly after it ends, until ctx is done.
+// Days missed while no server was running are closed on the next run.
+func (eod *EndOfDay) Run(ctx context.Context) {
+	runDaily(ctx, "end of day", func(ctx context.Context, day time.Time) error {
+		_, err := eod.CloseUntil(ctx, day)
+		return err
+	})
+}
@@ -0,0 +1,62 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndOfDayCloseUntil(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	account1, account2 := createTestLedger(t, store, 3)
+	eod := NewEndOfDay(store)
+	today := startOfDay(time.Now())
+
+	// with no day closed yet, only the last one is. Days without accounts leave nothing behind,
+	// so they do not count as closed.
+	closed, err := eod.CloseUntil(ctx, today.AddDate(0, 0, -3))
+	require.NoError(t, err)
+	require.Equal(t, 1, closed)
+
+	closed, err = eod.CloseUntil(ctx, today)
+	require.NoError(t, err)
+	require.Equal(t, 1, closed)
+
+	lastClosed, err := store.GetLastFrozenSnapshotDate(ctx)
+	require.NoError(t, err)
+	require.Equal(t, today, lastClosed)
+
+	// then every day since the last closed one
+	closed, err = eod.CloseUntil(ctx, today.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	require.Equal(t, 2, closed)
+
+	closed, err = eod.CloseUntil(ctx, today.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	require.Zero(t, closed)
+
+	// the last snapshot holds the current balance, and none was taken before the accounts were created
+	for _, account := range []db.Account{account1, account2} {
+		current, err := store.GetAccount(ctx, account.ID)
+		require.NoError(t, err)
+
+		snapshot, err := store.GetBalanceSnapshotBefore(ctx, db.GetBalanceSnapshotBeforeParams{
+			AccountID: account.ID,
+			Before:    today.AddDate(0, 0, 3),
+		})
+		require.NoError(t, err)
+		require.Equal(t, today.AddDate(0, 0, 2), snapshot.SnapshotDate)
+		require.Equal(t, current.Balance, snapshot.Balance)
+		require.True(t, snapshot.IsFrozen)
+
+		_, err = store.GetBalanceSnapshotBefore(ctx, db.GetBalanceSnapshotBeforeParams{
+			AccountID: account.ID,
+			Before:    today,
+		})
+		require.ErrorIs(t, err, db.ErrRecordNotFound)
+	}
+}
@@ -0,0 +1,62 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+)
+
+// InterestReport sums up the interest accrued on a day
+type InterestReport struct {
+	// Accounts is the number of interest-bearing accounts, Accrued the number of those that had not accrued yet
+	Accounts int
+	Accrued  int
+	// Payouts is the number of interest payouts, and Paid the minor units they paid, in all currencies
+	Payouts int
+	Paid    int64
+}
+
+// AccrueInterest accrues the interest of every interest-bearing account on day, and pays it out on the payout
+// days of their products. Accounts that already accrued on day are left as they are, so it can run again.
+func AccrueInterest(ctx context.Context, store db.Store, day time.Time) (InterestReport, error) {
+	var report InterestReport
+	dayEnd := startOfDay(day).AddDate(0, 0, 1)
+	var afterID int64
+
+	for {
+		accounts, err := store.ListInterestBearingAccounts(ctx, db.ListInterestBearingAccountsParams{
+			AfterID: afterID,
+			DayEnd:  dayEnd,
+			Limit:   pageSize,
+		})
+		if err != nil {
+			return report, fmt.Errorf("cannot list interest-bearing accounts: %w", err)
+		}
+
+		for _, account := range accounts {
+			result, err := store.AccrueInterestTx(ctx, db.AccrueInterestTxParams{
+				AccountID: account.ID,
+				Day:       day,
+			})
+			if err != nil {
+				return report, fmt.Errorf("cannot accrue interest of account %d: %w", account.ID, err)
+			}
+
+			report.Accounts++
+			if result.Accrued {
+				report.Accrued++
+			}
+			if result.Payout != nil {
+				report.Payouts++
+				report.Paid += result.Payout.Amount
+			}
+			afterID = account.ID
+		}
+
+		if len(accounts) < pageSize {
+			return report, nil
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// backdatedStore lists the accounts as if they had been opened before any day, so that interest accrues on
+// past days. Their balances are those they were opened with, since they have no entries before the payouts.
+type backdatedStore struct {
+	db.Store
+}
+
+func (store backdatedStore) ListInterestBearingAccounts(ctx context.Context, arg db.ListInterestBearingAccountsParams) ([]db.Account, error) {
+	arg.DayEnd = time.Now().Add(time.Hour)
+	return store.Store.ListInterestBearingAccounts(ctx, arg)
+}
+
+func TestAccrueInterest(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	checking := createTestAccount(t, store)
+
+	// 3650.00 at 2% earns 0.20 a day
+	owner := createTestAccount(t, store).Owner
+	savings, err := store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:    owner,
+		Balance:  365_000,
+		Currency: util.EUR,
+		Product:  pgtype.Text{String: "savings", Valid: true},
+	})
+	require.NoError(t, err)
+
+	// accounts only accrue from the day they are opened
+	report, err := AccrueInterest(ctx, store, time.Now().AddDate(0, 0, -1))
+	require.NoError(t, err)
+	require.Zero(t, report.Accounts)
+
+	backdated := backdatedStore{store}
+	for day := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC); day.Month() == time.January; day = day.AddDate(0, 0, 1) {
+		report, err := AccrueInterest(ctx, backdated, day)
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Accounts)
+		require.Equal(t, 1, report.Accrued)
+
+		if day.Day() < 31 {
+			require.Zero(t, report.Payouts)
+		} else {
+			require.Equal(t, 1, report.Payouts)
+			require.Equal(t, int64(31*20), report.Paid)
+		}
+	}
+
+	// accruing a day again changes nothing
+	report, err = AccrueInterest(ctx, backdated, time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Accounts)
+	require.Zero(t, report.Accrued)
+	require.Zero(t, report.Payouts)
+
+	got, err := store.GetAccount(ctx, savings.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(365_000+31*20), got.Balance)
+
+	expense, err := store.GetAccountByOwner(ctx, db.GetAccountByOwnerParams{
+		Owner:    db.SystemUsername(db.SystemInterestExpense),
+		Currency: util.EUR,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(-31*20), expense.Balance)
+
+	// the payout is chained like any other entry
+	checked, brk, err := VerifyAccount(ctx, store, savings.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, checked)
+	require.Nil(t, brk)
+
+	got, err = store.GetAccount(ctx, checking.ID)
+	require.NoError(t, err)
+	require.Equal(t, checking.Balance, got.Balance)
+}
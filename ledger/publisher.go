@@ -0,0 +1,227 @@
+package ledger
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+)
+
+var ErrAnchorExists = errors.New("anchor is already published")
+
+// Publisher publishes the daily anchors of the ledger as signed files, one per day
+type Publisher struct {
+	dir   string
+	key   ed25519.PrivateKey
+	store db.Store
+}
+
+// NewPublisher creates a Publisher that writes to the LEDGER_ANCHOR_DIR directory,
+// and signs with the LEDGER_ANCHOR_KEY key. It must match LEDGER_ANCHOR_PUBLIC_KEY, when that is set.
+func NewPublisher(config util.Config, store db.Store) (*Publisher, error) {
+	if config.LedgerAnchorDir == "" {
+		return nil, errors.New("ledger anchor dir is not set")
+	}
+
+	key, err := ParseSigningKey(config.LedgerAnchorKey)
+	if err != nil {
+		return nil, err
+	}
+	if config.LedgerAnchorPublicKey != "" {
+		publicKey, err := ParseVerifyingKey(config.LedgerAnchorPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if !publicKey.Equal(key.Public()) {
+			return nil, errors.New("ledger anchor key does not match the public key")
+		}
+	}
+
+	return &Publisher{
+		dir:   config.LedgerAnchorDir,
+		key:   key,
+		store: store,
+	}, nil
+}
+
+// path is where the anchor of date is published
+func (publisher *Publisher) path(date string) string {
+	return filepath.Join(publisher.dir, "anchor-"+date+".json")
+}
+
+// Publish computes and publishes the anchor of day. A published anchor is never replaced, as it is
+// the evidence of what the ledger was: publishing a day twice fails with ErrAnchorExists.
+func (publisher *Publisher) Publish(ctx context.Context, day time.Time) (Anchor, error) {
+	path := publisher.path(startOfDay(day).Format(DateLayout))
+	if _, err := os.Stat(path); err == nil {
+		return Anchor{}, ErrAnchorExists
+	}
+
+	anchor, err := ComputeAnchor(ctx, publisher.store, day)
+	if err != nil {
+		return Anchor{}, err
+	}
+
+	signed, err := Sign(anchor, publisher.key)
+	if err != nil {
+		return Anchor{}, err
+	}
+	data, err := json.MarshalIndent(signed, "", "  ")
+	if err != nil {
+		return Anchor{}, err
+	}
+
+	if err := writeNewFile(path, data); err != nil {
+		return Anchor{}, err
+	}
+	return anchor, nil
+}
+
+// writeNewFile writes data to path atomically, unless path exists already
+func writeNewFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".anchor-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// unlike a rename, a link does not replace path if another publisher got there first
+	err = os.Link(tmp.Name(), path)
+	if errors.Is(err, os.ErrExist) {
+		return ErrAnchorExists
+	}
+	return err
+}
+
+// PublishUntil publishes the anchor of every day since the last published one, up to until included.
+// With no anchor published yet, only the anchor of until is. Days another publisher got first are skipped.
+func (publisher *Publisher) PublishUntil(ctx context.Context, until time.Time) (published int, err error) {
+	day := startOfDay(until)
+	lastPublished, err := publisher.lastPublished()
+	if err != nil {
+		return 0, fmt.Errorf("cannot get last published day: %w", err)
+	}
+	if !lastPublished.IsZero() {
+		day = lastPublished.AddDate(0, 0, 1)
+	}
+
+	for ; !day.After(startOfDay(until)); day = day.AddDate(0, 0, 1) {
+		anchor, err := publisher.Publish(ctx, day)
+		if errors.Is(err, ErrAnchorExists) {
+			continue
+		}
+		if err != nil {
+			return published, err
+		}
+
+		log.Printf("published ledger anchor of %s: %s", anchor.Date, anchor.Hash)
+		published++
+	}
+	return published, nil
+}
+
+// lastPublished returns the day of the last published anchor, or the zero time if there is none
+func (publisher *Publisher) lastPublished() (time.Time, error) {
+	paths, err := filepath.Glob(filepath.Join(publisher.dir, "anchor-*.json"))
+	if err != nil || len(paths) == 0 {
+		return time.Time{}, err
+	}
+	sort.Strings(paths)
+
+	name := filepath.Base(paths[len(paths)-1])
+	date := strings.TrimSuffix(strings.TrimPrefix(name, "anchor-"), ".json")
+	day, err := time.Parse(DateLayout, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return day, nil
+}
+
+// Run publishes the anchor of the previous day, then of every day shortly after it ends, until ctx is done.
+// Days missed while no server was running are published on the next run. Publishing is retried until
+// it succeeds, and several servers may run it: only one writes each anchor.
+func (publisher *Publisher) Run(ctx context.Context) {
+	runDaily(ctx, "ledger anchor", func(ctx context.Context, day time.Time) error {
+		_, err := publisher.PublishUntil(ctx, day)
+		return err
+	})
+}
+
+// Auditor checks the published anchors against the ledger. It only needs the public key of the Publisher,
+// so that whoever checks the anchors cannot sign new ones.
+type Auditor struct {
+	dir       string
+	publicKey ed25519.PublicKey
+	store     db.Store
+}
+
+// NewAuditor creates an Auditor of the anchors published to the LEDGER_ANCHOR_DIR directory,
+// signed with the key whose public key is LEDGER_ANCHOR_PUBLIC_KEY
+func NewAuditor(config util.Config, store db.Store) (*Auditor, error) {
+	if config.LedgerAnchorDir == "" {
+		return nil, errors.New("ledger anchor dir is not set")
+	}
+
+	publicKey, err := ParseVerifyingKey(config.LedgerAnchorPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Auditor{
+		dir:       config.LedgerAnchorDir,
+		publicKey: publicKey,
+		store:     store,
+	}, nil
+}
+
+// Check verifies the signature of every published anchor, and compares their heads with the ledger
+func (auditor *Auditor) Check(ctx context.Context) (anchors int, breaks []Break, err error) {
+	paths, err := filepath.Glob(filepath.Join(auditor.dir, "anchor-*.json"))
+	if err != nil {
+		return 0, nil, err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		signed, err := readSignedAnchor(path)
+		if err != nil {
+			return anchors, breaks, err
+		}
+
+		anchor, err := Open(signed, auditor.publicKey)
+		if err != nil {
+			return anchors, breaks, fmt.Errorf("%s: %w", path, err)
+		}
+
+		anchorBreaks, err := CheckAnchor(ctx, auditor.store, anchor)
+		if err != nil {
+			return anchors, breaks, err
+		}
+		anchors++
+		breaks = append(breaks, anchorBreaks...)
+	}
+
+	return anchors, breaks, nil
+}
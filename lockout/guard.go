@@ -0,0 +1,200 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+)
+
+// LockedError is returned by Begin while logins are locked out
+type LockedError struct {
+	Until time.Time
+}
+
+func (err *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again after %s", err.Until.Format(time.RFC3339))
+}
+
+// RetryAfter is how long the caller has to wait before trying again
+func (err *LockedError) RetryAfter() time.Duration {
+	return time.Until(err.Until)
+}
+
+// Attempt describes a login attempt to record
+type Attempt struct {
+	// ID is set by Begin, once the attempt is recorded
+	ID        int64
+	Username  string
+	ClientIP  string
+	UserAgent string
+	Outcome   string
+}
+
+// Guard limits failed logins per username and per client IP.
+// Once the failures within the window reach the limit, logins are locked out for
+// LoginLockoutDuration after the last failure, doubling with every further failure.
+// A successful login or an admin unlock clears the failures of a username, but not of an IP.
+// A Guard counts the attempts of one kind: a step-up Guard locks out the two-factor codes sent with
+// high-value operations the same way, without locking out logins.
+type Guard struct {
+	config util.Config
+	store  db.Store
+	kind   string
+	// maxFailuresPerIP is 0 when the failures are only counted per username
+	maxFailuresPerIP int
+}
+
+// NewGuard creates a new login Guard
+func NewGuard(config util.Config, store db.Store) *Guard {
+	return &Guard{
+		config:           config,
+		store:            store,
+		kind:             db.LoginKindLogin,
+		maxFailuresPerIP: config.LoginMaxFailuresPerIP,
+	}
+}
+
+// NewStepUpGuard creates a Guard of the two-factor codes sent with high-value operations.
+// Their failures are only counted per username, as the user has logged in already.
+func NewStepUpGuard(config util.Config, store db.Store) *Guard {
+	return &Guard{
+		config: config,
+		store:  store,
+		kind:   db.LoginKindStepUp,
+	}
+}
+
+// Begin counts the failures of the username and client IP of an attempt and records the attempt as failed,
+// in a single step: concurrent attempts cannot all get past the count before any of them is recorded.
+// Finish then records its actual outcome. If the username or client IP is locked out,
+// the attempt is recorded as locked instead and Begin returns a *LockedError.
+// Either can be empty, when it is not known yet.
+func (guard *Guard) Begin(ctx context.Context, attempt *Attempt) error {
+	clientIP := normalizeClientIP(attempt.ClientIP)
+	now := time.Now()
+	since := now.Add(-guard.config.LoginFailureWindow)
+
+	var lockedErr *LockedError
+	result, err := guard.store.LoginAttemptTx(ctx, db.LoginAttemptTxParams{
+		CreateLoginEventParams: db.CreateLoginEventParams{
+			Username:  attempt.Username,
+			ClientIp:  clientIP,
+			UserAgent: attempt.UserAgent,
+			Kind:      guard.kind,
+		},
+		BeforeCreate: func(q db.Querier) (string, error) {
+			// the transaction reruns BeforeCreate when it is retried, so only its last run counts
+			lockedErr = nil
+
+			until, err := guard.lockedUntil(ctx, q, attempt.Username, clientIP, since)
+			if err != nil {
+				return "", err
+			}
+			if until.After(now) {
+				lockedErr = &LockedError{Until: until}
+				return db.LoginOutcomeLocked, nil
+			}
+			return db.LoginOutcomeFailure, nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	attempt.ID = result.LoginEvent.ID
+	attempt.Outcome = result.LoginEvent.Outcome
+	if lockedErr != nil {
+		return lockedErr
+	}
+	return nil
+}
+
+// Finish records the outcome of an attempt started with Begin, along with its username if it was not known then
+func (guard *Guard) Finish(ctx context.Context, attempt Attempt) error {
+	_, err := guard.store.FinishLoginEvent(ctx, db.FinishLoginEventParams{
+		ID:       attempt.ID,
+		Username: attempt.Username,
+		Outcome:  attempt.Outcome,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record login outcome: %w", err)
+	}
+	return nil
+}
+
+// lockedUntil returns until when the username or client IP is locked out, which may be in the past
+func (guard *Guard) lockedUntil(ctx context.Context, q db.Querier, username string, clientIP string, since time.Time) (time.Time, error) {
+	var until time.Time
+	if username != "" {
+		userFailures, err := q.GetUserLoginFailures(ctx, db.GetUserLoginFailuresParams{
+			Username: username,
+			Kind:     guard.kind,
+			Since:    since,
+		})
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to count login failures of user: %w", err)
+		}
+		until = guard.lockoutEnd(userFailures.Failures, userFailures.LastFailureAt, guard.config.LoginMaxFailures)
+	}
+
+	if clientIP != "" && guard.maxFailuresPerIP > 0 {
+		ipFailures, err := q.GetClientIPLoginFailures(ctx, db.GetClientIPLoginFailuresParams{
+			ClientIp: clientIP,
+			Kind:     guard.kind,
+			Since:    since,
+		})
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to count login failures of client ip: %w", err)
+		}
+		ipUntil := guard.lockoutEnd(ipFailures.Failures, ipFailures.LastFailureAt, guard.maxFailuresPerIP)
+		if ipUntil.After(until) {
+			until = ipUntil
+		}
+	}
+
+	return until, nil
+}
+
+func (guard *Guard) lockoutEnd(failures int64, lastFailureAt time.Time, maxFailures int) time.Time {
+	if maxFailures <= 0 || failures < int64(maxFailures) {
+		return time.Time{}
+	}
+
+	lockout := guard.config.LoginLockoutDuration
+	for i := int64(maxFailures); i < failures && lockout < guard.config.LoginFailureWindow; i++ {
+		lockout *= 2
+	}
+	if lockout > guard.config.LoginFailureWindow {
+		lockout = guard.config.LoginFailureWindow
+	}
+
+	return lastFailureAt.Add(lockout)
+}
+
+// normalizeClientIP keeps only the host of an address, so that all connections
+// from a client count together whether its address came with a port or not
+func normalizeClientIP(clientIP string) string {
+	if host, _, err := net.SplitHostPort(clientIP); err == nil {
+		return host
+	}
+	return clientIP
+}
+
+// Unlock clears the failed logins of a username, recording who asked for it from where
+func (guard *Guard) Unlock(ctx context.Context, username string, clientIP string, userAgent string) error {
+	_, err := guard.store.CreateLoginEvent(ctx, db.CreateLoginEventParams{
+		Username:  username,
+		ClientIp:  normalizeClientIP(clientIP),
+		UserAgent: userAgent,
+		Outcome:   db.LoginOutcomeUnlocked,
+		Kind:      guard.kind,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestConfig() util.Config {
+	return util.Config{
+		LoginMaxFailures:      3,
+		LoginMaxFailuresPerIP: 10,
+		LoginFailureWindow:    15 * time.Minute,
+		LoginLockoutDuration:  time.Minute,
+	}
+}
+
+// stubLoginAttemptTx runs the BeforeCreate of LoginAttemptTx on the mock store, as the transaction would
+func stubLoginAttemptTx(store *mockdb.MockStore) {
+	store.EXPECT().
+		LoginAttemptTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.LoginAttemptTxParams) (db.LoginAttemptTxResult, error) {
+			outcome, err := arg.BeforeCreate(store)
+			if err != nil {
+				return db.LoginAttemptTxResult{}, err
+			}
+			event := db.LoginEvent{
+				ID:        1,
+				Username:  arg.Username,
+				ClientIp:  arg.ClientIp,
+				UserAgent: arg.UserAgent,
+				Outcome:   outcome,
+				Kind:      arg.Kind,
+			}
+			return db.LoginAttemptTxResult{LoginEvent: event}, nil
+		})
+}
+
+func TestGuardBegin(t *testing.T) {
+	username := util.RandomOwner()
+	now := time.Now()
+
+	userFailures := func(store *mockdb.MockStore, failures int64, lastFailureAt time.Time) {
+		store.EXPECT().
+			GetUserLoginFailures(gomock.Any(), gomock.Any()).
+			Times(1).
+			DoAndReturn(func(ctx context.Context, arg db.GetUserLoginFailuresParams) (db.GetUserLoginFailuresRow, error) {
+				require.Equal(t, username, arg.Username)
+				require.WithinDuration(t, now.Add(-15*time.Minute), arg.Since, time.Second)
+				return db.GetUserLoginFailuresRow{Failures: failures, LastFailureAt: lastFailureAt}, nil
+			})
+	}
+	ipFailures := func(store *mockdb.MockStore, failures int64, lastFailureAt time.Time) {
+		store.EXPECT().
+			GetClientIPLoginFailures(gomock.Any(), gomock.Any()).
+			Times(1).
+			DoAndReturn(func(ctx context.Context, arg db.GetClientIPLoginFailuresParams) (db.GetClientIPLoginFailuresRow, error) {
+				require.Equal(t, "10.0.0.1", arg.ClientIp)
+				return db.GetClientIPLoginFailuresRow{Failures: failures, LastFailureAt: lastFailureAt}, nil
+			})
+	}
+	requireLockedUntil := func(t *testing.T, attempt Attempt, err error, until time.Time) {
+		var lockedErr *LockedError
+		require.ErrorAs(t, err, &lockedErr)
+		require.WithinDuration(t, until, lockedErr.Until, time.Millisecond)
+		require.Equal(t, db.LoginOutcomeLocked, attempt.Outcome)
+	}
+	// an attempt let through counts as failed until it finishes
+	requireFailure := func(t *testing.T, attempt Attempt, err error) {
+		require.NoError(t, err)
+		require.NotZero(t, attempt.ID)
+		require.Equal(t, db.LoginOutcomeFailure, attempt.Outcome)
+	}
+
+	testCases := []struct {
+		name       string
+		clientIP   string
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, attempt Attempt, err error)
+	}{
+		{
+			name:     "BelowLimit",
+			clientIP: "10.0.0.1",
+			buildStubs: func(store *mockdb.MockStore) {
+				userFailures(store, 2, now)
+				ipFailures(store, 9, now)
+			},
+			check: requireFailure,
+		},
+		{
+			name:     "UserLocked",
+			clientIP: "10.0.0.1:52114",
+			buildStubs: func(store *mockdb.MockStore) {
+				userFailures(store, 3, now)
+				ipFailures(store, 3, now)
+			},
+			check: func(t *testing.T, attempt Attempt, err error) {
+				requireLockedUntil(t, attempt, err, now.Add(time.Minute))
+			},
+		},
+		{
+			name:     "LockoutDoubles",
+			clientIP: "10.0.0.1",
+			buildStubs: func(store *mockdb.MockStore) {
+				userFailures(store, 5, now)
+				ipFailures(store, 5, now)
+			},
+			check: func(t *testing.T, attempt Attempt, err error) {
+				requireLockedUntil(t, attempt, err, now.Add(4*time.Minute))
+			},
+		},
+		{
+			name:     "LockoutCappedAtWindow",
+			clientIP: "10.0.0.1",
+			buildStubs: func(store *mockdb.MockStore) {
+				userFailures(store, 40, now)
+				ipFailures(store, 0, time.Time{})
+			},
+			check: func(t *testing.T, attempt Attempt, err error) {
+				requireLockedUntil(t, attempt, err, now.Add(15*time.Minute))
+			},
+		},
+		{
+			name:     "LockoutOver",
+			clientIP: "10.0.0.1",
+			buildStubs: func(store *mockdb.MockStore) {
+				userFailures(store, 3, now.Add(-2*time.Minute))
+				ipFailures(store, 3, now.Add(-2*time.Minute))
+			},
+			check: requireFailure,
+		},
+		{
+			name:     "ClientIPLocked",
+			clientIP: "10.0.0.1",
+			buildStubs: func(store *mockdb.MockStore) {
+				userFailures(store, 0, time.Time{})
+				ipFailures(store, 10, now)
+			},
+			check: func(t *testing.T, attempt Attempt, err error) {
+				requireLockedUntil(t, attempt, err, now.Add(time.Minute))
+			},
+		},
+		{
+			name:     "NoClientIP",
+			clientIP: "",
+			buildStubs: func(store *mockdb.MockStore) {
+				userFailures(store, 0, time.Time{})
+				store.EXPECT().
+					GetClientIPLoginFailures(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			check: requireFailure,
+		},
+		{
+			name:     "InternalError",
+			clientIP: "10.0.0.1",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserLoginFailures(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.GetUserLoginFailuresRow{}, sql.ErrConnDone)
+			},
+			check: func(t *testing.T, attempt Attempt, err error) {
+				require.ErrorIs(t, err, sql.ErrConnDone)
+
+				var lockedErr *LockedError
+				require.False(t, errors.As(err, &lockedErr))
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubLoginAttemptTx(store)
+			tc.buildStubs(store)
+
+			guard := NewGuard(newTestConfig(), store)
+			attempt := Attempt{Username: username, ClientIP: tc.clientIP}
+			err := guard.Begin(context.Background(), &attempt)
+			tc.check(t, attempt, err)
+		})
+	}
+}
+
+func TestGuardFinish(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	attempt := Attempt{
+		ID:       7,
+		Username: util.RandomOwner(),
+		Outcome:  db.LoginOutcomeSuccess,
+	}
+	store.EXPECT().
+		FinishLoginEvent(gomock.Any(), gomock.Eq(db.FinishLoginEventParams{
+			ID:       attempt.ID,
+			Username: attempt.Username,
+			Outcome:  db.LoginOutcomeSuccess,
+		})).
+		Times(1)
+
+	guard := NewGuard(newTestConfig(), store)
+	require.NoError(t, guard.Finish(context.Background(), attempt))
+}
+
+func TestGuardUnlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	username := util.RandomOwner()
+	store.EXPECT().
+		CreateLoginEvent(gomock.Any(), gomock.Eq(db.CreateLoginEventParams{
+			Username:  username,
+			ClientIp:  "10.0.0.2",
+			UserAgent: "admin-console",
+			Outcome:   db.LoginOutcomeUnlocked,
+			Kind:      db.LoginKindLogin,
+		})).
+		Times(1)
+
+	guard := NewGuard(newTestConfig(), store)
+	require.NoError(t, guard.Unlock(context.Background(), username, "10.0.0.2", "admin-console"))
+}
+
+func TestStepUpGuard(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	username := util.RandomOwner()
+	lastFailureAt := time.Now()
+
+	// step-up codes are only counted with each other, per username
+	stubLoginAttemptTx(store)
+	store.EXPECT().
+		GetUserLoginFailures(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.GetUserLoginFailuresParams) (db.GetUserLoginFailuresRow, error) {
+			require.Equal(t, username, arg.Username)
+			require.Equal(t, db.LoginKindStepUp, arg.Kind)
+			return db.GetUserLoginFailuresRow{Failures: 3, LastFailureAt: lastFailureAt}, nil
+		})
+	store.EXPECT().GetClientIPLoginFailures(gomock.Any(), gomock.Any()).Times(0)
+
+	guard := NewStepUpGuard(newTestConfig(), store)
+	attempt := Attempt{Username: username, ClientIP: "10.0.0.1"}
+	err := guard.Begin(context.Background(), &attempt)
+	var lockedErr *LockedError
+	require.ErrorAs(t, err, &lockedErr)
+	require.WithinDuration(t, lastFailureAt.Add(time.Minute), lockedErr.Until, time.Millisecond)
+	require.Equal(t, db.LoginOutcomeLocked, attempt.Outcome)
+}
+
+func TestGuardBeginRetried(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	username := util.RandomOwner()
+
+	// the first run of the transaction sees the user locked out, but it is aborted by a conflict and
+	// retried after the failures have aged out of the window
+	store.EXPECT().
+		LoginAttemptTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.LoginAttemptTxParams) (db.LoginAttemptTxResult, error) {
+			outcome, err := arg.BeforeCreate(store)
+			require.NoError(t, err)
+			require.Equal(t, db.LoginOutcomeLocked, outcome)
+
+			outcome, err = arg.BeforeCreate(store)
+			require.NoError(t, err)
+			return db.LoginAttemptTxResult{LoginEvent: db.LoginEvent{ID: 1, Username: arg.Username, Outcome: outcome}}, nil
+		})
+	gomock.InOrder(
+		store.EXPECT().
+			GetUserLoginFailures(gomock.Any(), gomock.Any()).
+			Return(db.GetUserLoginFailuresRow{Failures: 3, LastFailureAt: time.Now()}, nil),
+		store.EXPECT().
+			GetUserLoginFailures(gomock.Any(), gomock.Any()).
+			Return(db.GetUserLoginFailuresRow{}, nil),
+	)
+
+	guard := NewStepUpGuard(newTestConfig(), store)
+	attempt := Attempt{Username: username}
+	err := guard.Begin(context.Background(), &attempt)
+	require.NoError(t, err)
+	require.Equal(t, db.LoginOutcomeFailure, attempt.Outcome)
+}
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor logs every unary RPC
+func UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	startTime := time.Now()
+	ctx, request := startGRPCRequest(ctx)
+
+	rsp, err := handler(ctx, req)
+	request.logGRPC(ctx, info.FullMethod, err, time.Since(startTime))
+	return rsp, err
+}
+
+// StreamServerInterceptor logs every streaming RPC, once the stream ends
+func StreamServerInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	startTime := time.Now()
+	ctx, request := startGRPCRequest(stream.Context())
+
+	err := handler(srv, &requestStream{ServerStream: stream, ctx: ctx})
+	request.logGRPC(ctx, info.FullMethod, err, time.Since(startTime))
+	return err
+}
+
+func startGRPCRequest(ctx context.Context) (context.Context, *Request) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDHeader); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+
+	ctx, request := StartRequest(ctx, requestID)
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, request.ID()))
+	return ctx, request
+}
+
+// logGRPC logs the RPC once served, at the error level if it failed on the server side
+func (req *Request) logGRPC(ctx context.Context, method string, err error, latency time.Duration) {
+	code := status.Code(err)
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+
+	attrs := append(req.attrs(latency, grpcClientIP(ctx)),
+		slog.String("protocol", "grpc"),
+		slog.String("method", method),
+		slog.Int("status_code", int(code)),
+		slog.String("status_text", code.String()),
+	)
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	slog.LogAttrs(context.Background(), level, "received a gRPC request", attrs...)
+}
+
+func grpcClientIP(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// requestStream passes the context of the logged request to the stream handler
+type requestStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *requestStream) Context() context.Context {
+	return stream.ctx
+}
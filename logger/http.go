@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+)
+
+// HTTPMiddleware logs every request served by next
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, req := StartRequest(r.Context(), r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, req.ID())
+
+		// CaptureMetrics keeps the Flusher of w, needed by server-sent events
+		stats := httpsnoop.CaptureMetrics(next, w, r.WithContext(ctx))
+
+		req.LogHTTP(r.Method, r.URL.Path, stats.Code, stats.Duration, ClientIP(r))
+	})
+}
+
+// LogHTTP logs the request once served, at the error level if it failed with a server error
+func (req *Request) LogHTTP(method string, path string, code int, latency time.Duration, clientIP string) {
+	level := slog.LevelInfo
+	if code >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	attrs := append(req.attrs(latency, clientIP),
+		slog.String("protocol", "http"),
+		slog.String("method", method),
+		slog.String("path", path),
+		slog.Int("status_code", code),
+		slog.String("status_text", http.StatusText(code)),
+	)
+	slog.LogAttrs(context.Background(), level, "received an HTTP request", attrs...)
+}
+
+// ClientIP is the address the request came from. X-Forwarded-For is ignored, as any client can set it:
+// like the Gin server, the gateway trusts no proxy.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/pakojabi/simplebank/util"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New creates a logger writing to w with the level and format of config.
+// Passwords, tokens and emails are redacted from every attribute, including the message.
+func New(config util.Config, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if config.LogLevel != "" {
+		if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+			return nil, fmt.Errorf("invalid log level: %s", config.LogLevel)
+		}
+	}
+
+	options := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}
+
+	switch strings.ToLower(config.LogFormat) {
+	case "", FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unsupported log format: %s", config.LogFormat)
+	}
+}
+
+// Setup makes the logger of config the default one, to which the log package writes too
+func Setup(config util.Config) error {
+	logger, err := New(config, os.Stderr)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
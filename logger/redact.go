@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the attribute keys whose values are never logged, matched as substrings
+var sensitiveKeys = []string{
+	"password",
+	"token",
+	"secret",
+	"authorization",
+	"api_key",
+	"apikey",
+	"code_verifier",
+	"totp",
+	"email",
+}
+
+// sensitivePatterns are scrubbed from every string, as error messages may carry them.
+// pq errors for instance quote the values violating a constraint.
+var sensitivePatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// credentials in URLs, e.g. the db source
+	{regexp.MustCompile(`://([^:/@\s]+):[^@\s]+@`), "://$1:" + redacted + "@"},
+	{regexp.MustCompile(`(?i)\b(bearer|apikey)\s+\S+`), "$1 " + redacted},
+	// JWT and PASETO tokens
+	{regexp.MustCompile(`\beyJ[\w-]+\.[\w-]+\.[\w-]*`), redacted},
+	{regexp.MustCompile(`\bv2\.local\.[\w-]+`), redacted},
+	{regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`), redacted},
+}
+
+// redactAttr is the ReplaceAttr of the handlers created by New
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if isSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, Redact(err.Error()))
+		}
+	}
+	return attr
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitiveKey := range sensitiveKeys {
+		if strings.Contains(key, sensitiveKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact scrubs the credentials and emails found in s
+func Redact(s string) string {
+	for _, p := range sensitivePatterns {
+		s = p.pattern.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID, both as an HTTP header and as gRPC metadata
+const RequestIDHeader = "x-request-id"
+
+type requestKey struct{}
+
+// Request gathers what is logged about a request once it is served.
+// The username is only known once the handler has authorized the caller.
+type Request struct {
+	mu       sync.Mutex
+	id       string
+	username string
+}
+
+// StartRequest starts logging a request served with ctx, keeping the ID sent by the client if there is one
+func StartRequest(ctx context.Context, requestID string) (context.Context, *Request) {
+	if requestID == "" || len(requestID) > 128 {
+		requestID = uuid.NewString()
+	}
+	req := &Request{id: requestID}
+	return context.WithValue(ctx, requestKey{}, req), req
+}
+
+// ID returns the ID of the request
+func (req *Request) ID() string {
+	return req.id
+}
+
+// SetUsername records the user making the request served with ctx
+func SetUsername(ctx context.Context, username string) {
+	if req, ok := ctx.Value(requestKey{}).(*Request); ok {
+		req.mu.Lock()
+		req.username = username
+		req.mu.Unlock()
+	}
+}
+
+// RequestID returns the ID of the request served with ctx, if any
+func RequestID(ctx context.Context) string {
+	if req, ok := ctx.Value(requestKey{}).(*Request); ok {
+		return req.id
+	}
+	return ""
+}
+
+// FromContext returns the default logger, tagged with the ID of the request served with ctx
+func FromContext(ctx context.Context) *slog.Logger {
+	if requestID := RequestID(ctx); requestID != "" {
+		return slog.Default().With(slog.String("request_id", requestID))
+	}
+	return slog.Default()
+}
+
+// attrs describes the request once it is served
+func (req *Request) attrs(latency time.Duration, clientIP string) []slog.Attr {
+	req.mu.Lock()
+	defer req.mu.Unlock()
+
+	return []slog.Attr{
+		slog.String("request_id", req.id),
+		slog.Duration("latency", latency),
+		slog.String("username", req.username),
+		slog.String("client_ip", clientIP),
+	}
+}
@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes every email as an .eml file into a directory, for local development
+type FileMailer struct {
+	dir         string
+	fromAddress string
+	sequence    atomic.Int64
+}
+
+func NewFileMailer(dir, fromAddress string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create mail directory: %w", err)
+	}
+
+	return &FileMailer{
+		dir:         dir,
+		fromAddress: fromAddress,
+	}, nil
+}
+
+// Send implements Mailer.
+func (mailer *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := validateMessage(msg); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%d.eml", time.Now().UTC().Format("20060102T150405.000000000"), mailer.sequence.Add(1))
+	return os.WriteFile(filepath.Join(mailer.dir, name), composeMessage(mailer.fromAddress, msg), 0o644)
+}
@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pakojabi/simplebank/util"
+)
+
+const (
+	MailerTypeSMTP   = "smtp"
+	MailerTypeFile   = "file"
+	MailerTypeMemory = "memory"
+)
+
+// Message is an HTML email
+type Message struct {
+	To      []string
+	Subject string
+	Content string
+}
+
+// Mailer sends emails on behalf of the bank
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailer creates the mailer selected by config.MailerType
+func NewMailer(config util.Config) (Mailer, error) {
+	switch config.MailerType {
+	case MailerTypeSMTP:
+		return NewSMTPMailer(
+			config.SMTPServerAddress,
+			config.EmailSenderName,
+			config.EmailSenderAddress,
+			config.EmailSenderPassword,
+		), nil
+	case MailerTypeFile:
+		return NewFileMailer(config.MailerFileDir, config.EmailSenderAddress)
+	case MailerTypeMemory:
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unsupported mailer type: %q", config.MailerType)
+	}
+}
+
+func validateMessage(msg Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("message has no recipients")
+	}
+	return nil
+}
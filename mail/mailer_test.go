@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := NewMemoryMailer()
+	msg := Message{
+		To:      []string{"user@email.com"},
+		Subject: "a test email",
+		Content: "<h1>Hello</h1>",
+	}
+
+	err := mailer.Send(context.Background(), msg)
+	require.NoError(t, err)
+
+	err = mailer.Send(context.Background(), Message{Subject: "nobody"})
+	require.Error(t, err)
+
+	require.Equal(t, []Message{msg}, mailer.Messages())
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer, err := NewMailer(util.Config{
+		MailerType:         MailerTypeFile,
+		MailerFileDir:      dir,
+		EmailSenderAddress: "bank@email.com",
+	})
+	require.NoError(t, err)
+
+	err = mailer.Send(context.Background(), Message{
+		To:      []string{"user@email.com"},
+		Subject: "a test email",
+		Content: "<h1>Hello</h1>",
+	})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.Contains(t, string(data), "To: user@email.com")
+	require.Contains(t, string(data), "Subject: a test email")
+	require.Contains(t, string(data), "<h1>Hello</h1>")
+}
+
+func TestNewMailerUnsupported(t *testing.T) {
+	_, err := NewMailer(util.Config{MailerType: "pigeon"})
+	require.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPMailer sends emails through an SMTP server with PLAIN auth, e.g. smtp.gmail.com:587
+type SMTPMailer struct {
+	serverAddress string
+	fromName      string
+	fromAddress   string
+	password      string
+}
+
+func NewSMTPMailer(serverAddress, fromName, fromAddress, password string) Mailer {
+	return &SMTPMailer{
+		serverAddress: serverAddress,
+		fromName:      fromName,
+		fromAddress:   fromAddress,
+		password:      password,
+	}
+}
+
+// Send implements Mailer.
+func (mailer *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := validateMessage(msg); err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(mailer.serverAddress)
+	if err != nil {
+		return fmt.Errorf("invalid smtp server address: %w", err)
+	}
+
+	from := mail.Address{Name: mailer.fromName, Address: mailer.fromAddress}
+	auth := smtp.PlainAuth("", mailer.fromAddress, mailer.password, host)
+
+	// net/smtp has no context support, so only honour cancellation before dialing
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err = smtp.SendMail(mailer.serverAddress, auth, mailer.fromAddress, msg.To, composeMessage(from.String(), msg))
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+func composeMessage(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Subject)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(msg.Content)
+	return buf.Bytes()
+}
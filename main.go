@@ -2,81 +2,317 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/protobuf/encoding/protojson"
 
 	"github.com/pakojabi/simplebank/api"
+	"github.com/pakojabi/simplebank/db/migration"
 	db "github.com/pakojabi/simplebank/db/sqlc"
 	"github.com/pakojabi/simplebank/gapi"
+	"github.com/pakojabi/simplebank/health"
+	"github.com/pakojabi/simplebank/ledger"
+	"github.com/pakojabi/simplebank/logger"
+	"github.com/pakojabi/simplebank/mail"
+	"github.com/pakojabi/simplebank/metrics"
+	"github.com/pakojabi/simplebank/payment"
 	"github.com/pakojabi/simplebank/pb"
+	"github.com/pakojabi/simplebank/tracing"
 	"github.com/pakojabi/simplebank/util"
+	"github.com/pakojabi/simplebank/worker"
 )
 
+const defaultShutdownTimeout = 30 * time.Second
+
+var interruptSignals = []os.Signal{
+	os.Interrupt,
+	syscall.SIGTERM,
+}
+
 func main() {
 	config, err := util.LoadConfig(".")
 	if err != nil {
 		log.Fatal("cannot load config:", err)
 	}
+	if err := logger.Setup(config); err != nil {
+		log.Fatal("cannot set up logger:", err)
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(config, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "ledger" {
+		if err := runLedgerCommand(config, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := checkSchema(context.Background(), config); err != nil {
+		log.Fatal("cannot start with the current schema: ", err)
+	}
 
-	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	shutdownTracing, err := tracing.Setup(context.Background(), config)
+	if err != nil {
+		log.Fatal("cannot set up tracing:", err)
+	}
+
+	connPool, err := newDBPool(context.Background(), config)
 	if err != nil {
 		log.Fatal("cannot connect to db:", err)
 	}
-	log.Default().Printf("Connected to %s using driver %s", config.DBSource, config.DBDriver)
+	log.Default().Printf("Connected to %s", config.DBSource)
 
-	store := db.NewStore(conn)
+	if err := metrics.RegisterDB(connPool, "simplebank"); err != nil {
+		log.Fatal("cannot register db metrics:", err)
+	}
 
-	// runGinServer(config, store)
-	go runGatewayServer(config, store)
-	runGrpcServer(config, store)
+	// an unset DB_TX_MAX_RETRIES keeps the default number of retries, rather than disabling them
+	var txOptions []db.TxOption
+	if config.DBTxMaxRetries > 0 {
+		txOptions = append(txOptions, db.MaxRetries(config.DBTxMaxRetries))
+	}
+	store := metrics.InstrumentStore(db.NewStore(connPool, txOptions...))
+
+	mailer, err := mail.NewMailer(config)
+	if err != nil {
+		log.Fatal("cannot create mailer:", err)
+	}
+
+	// ctx is cancelled on the first interrupt signal, or as soon as any server fails to start or serve
+	ctx, stop := signal.NotifyContext(context.Background(), interruptSignals...)
+	defer stop()
+
+	waitGroup, ctx := errgroup.WithContext(ctx)
+
+	taskDistributor := worker.NewPGTaskDistributor()
+	taskProcessor := runTaskProcessor(ctx, waitGroup, config, store, mailer)
+
+	checker := newHealthChecker(ctx, waitGroup, connPool, taskProcessor)
+	runLedgerPublisher(ctx, waitGroup, config, store)
+	runEndOfDay(ctx, waitGroup, store)
+	runPaymentReconciler(ctx, waitGroup, config, store)
+
+	// runGinServer(ctx, waitGroup, config, store, taskDistributor)
+	runGatewayServer(ctx, waitGroup, config, store, taskDistributor, checker)
+	runGrpcServer(ctx, waitGroup, config, store, taskDistributor, checker)
+	runMetricsServer(ctx, waitGroup, config)
+
+	err = waitGroup.Wait()
+
+	// only close the pool once nothing can use it anymore
+	connPool.Close()
+
+	// flushes the spans of the requests drained above
+	tracingCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if tracingErr := shutdownTracing(tracingCtx); tracingErr != nil {
+		log.Printf("failed to shut down tracing: %s", tracingErr)
+	}
+	if err != nil {
+		log.Fatal("error from wait group: ", err)
+	}
+	log.Printf("shut down gracefully")
 }
 
-func runGinServer(config util.Config, store db.Store) {
-	server, err := api.NewServer(config, store)
+func runTaskProcessor(ctx context.Context, waitGroup *errgroup.Group, config util.Config, store db.Store, mailer mail.Mailer) worker.TaskProcessor {
+	taskProcessor := worker.NewPGTaskProcessor(config, store, mailer)
+	err := taskProcessor.Start()
 	if err != nil {
-		log.Fatal("Cannot start server", err)
+		log.Fatal("Cannot start task processor", err)
 	}
 
-	err = server.Start(config.HTTPServerAddress)
+	waitGroup.Go(func() error {
+		<-ctx.Done()
+		log.Printf("stopping task processor")
+
+		// running tasks are waited for: they are leased, and would only be retried once the lease expires
+		taskProcessor.Shutdown()
+		log.Printf("task processor stopped")
+		return nil
+	})
+
+	return taskProcessor
+}
+
+// runLedgerPublisher publishes the daily anchors of the ledger, if a signing key is configured
+func runLedgerPublisher(ctx context.Context, waitGroup *errgroup.Group, config util.Config, store db.Store) {
+	if config.LedgerAnchorKey == "" {
+		log.Printf("ledger anchor signing key is not set, not publishing anchors")
+		return
+	}
+
+	publisher, err := ledger.NewPublisher(config, store)
 	if err != nil {
-		log.Fatal("Cannot start server", err, err)
+		log.Fatal("cannot create ledger anchor publisher: ", err)
 	}
+
+	waitGroup.Go(func() error {
+		publisher.Run(ctx)
+		return nil
+	})
+}
+
+// runEndOfDay closes the days of the ledger as they end
+func runEndOfDay(ctx context.Context, waitGroup *errgroup.Group, store db.Store) {
+	eod := ledger.NewEndOfDay(store)
+
+	waitGroup.Go(func() error {
+		eod.Run(ctx)
+		return nil
+	})
+}
+
+// runPaymentReconciler completes the payments left pending, by a restart or by a rail that never reported
+// their outcome
+func runPaymentReconciler(ctx context.Context, waitGroup *errgroup.Group, config util.Config, store db.Store) {
+	processor := payment.NewSimulatedProcessor(config, store)
+
+	waitGroup.Go(func() error {
+		processor.Run(ctx, config.PaymentReconcileInterval)
+		return nil
+	})
 }
 
-func runGrpcServer(config util.Config, store db.Store) {
-	server, err := gapi.NewServer(config, store)
+// newDBPool creates the connection pool of the store. Settings left to zero keep the pgxpool defaults.
+func newDBPool(ctx context.Context, config util.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(config.DBSource)
 	if err != nil {
-		log.Fatal("Cannot start server", err)
+		return nil, err
 	}
 
-	grpcServer := grpc.NewServer()
-	pb.RegisterSimpleBankServer(grpcServer, server)
+	if config.DBMaxConns > 0 {
+		poolConfig.MaxConns = config.DBMaxConns
+	}
+	if config.DBMinConns > 0 {
+		poolConfig.MinConns = config.DBMinConns
+	}
+	if config.DBMaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = config.DBMaxConnLifetime
+	}
+	if config.DBMaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = config.DBMaxConnIdleTime
+	}
+	if config.DBHealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = config.DBHealthCheckPeriod
+	}
 
-	// optional but allows the client discover the service
-	reflection.Register(grpcServer)
+	return pgxpool.NewWithConfig(ctx, poolConfig)
+}
 
-	listener, err := net.Listen("tcp", config.GRPCServerAddress)
+// newHealthChecker creates the readiness checks shared by the gRPC health service and the gateway,
+// and reports as not ready as soon as the shutdown starts
+func newHealthChecker(ctx context.Context, waitGroup *errgroup.Group, connPool *pgxpool.Pool, taskProcessor worker.TaskProcessor) *health.Checker {
+	migrationVersion, err := migration.Latest()
 	if err != nil {
-		log.Fatal("Cannot start server", err)
+		log.Fatal("Cannot get migration version: ", err)
+	}
+
+	checker := health.NewChecker()
+	checker.AddProbe("database", health.DatabaseProbe(connPool))
+	checker.AddProbe("migrations", health.MigrationProbe(connPool, migrationVersion))
+	checker.AddProbe("task_processor", health.TaskProcessorProbe(taskProcessor))
+
+	waitGroup.Go(func() error {
+		<-ctx.Done()
+		checker.Shutdown()
+		return nil
+	})
+
+	return checker
+}
+
+func runGinServer(ctx context.Context, waitGroup *errgroup.Group, config util.Config, store db.Store, taskDistributor worker.TaskDistributor) {
+	server, err := api.NewServer(config, store, taskDistributor)
+	if err != nil {
+		log.Fatal("Cannot create server", err)
+	}
+
+	httpServer := &http.Server{
+		Addr:    config.HTTPServerAddress,
+		Handler: server.Handler(),
 	}
+	serveHTTP(ctx, waitGroup, config, "HTTP server", httpServer)
+}
 
-	log.Printf("starting grpc server at %s", listener.Addr().String())
-	err = grpcServer.Serve(listener)
+func runGrpcServer(ctx context.Context, waitGroup *errgroup.Group, config util.Config, store db.Store, taskDistributor worker.TaskDistributor, checker *health.Checker) {
+	server, err := gapi.NewServer(config, store, taskDistributor)
 	if err != nil {
-		log.Fatal("Cannot start server", err)
+		log.Fatal("Cannot create server", err)
 	}
+
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(logger.UnaryServerInterceptor, metrics.UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(logger.StreamServerInterceptor, metrics.StreamServerInterceptor),
+	)
+	pb.RegisterSimpleBankServer(grpcServer, server)
+	healthpb.RegisterHealthServer(grpcServer, health.NewGRPCServer(checker, pb.SimpleBank_ServiceDesc.ServiceName))
+
+	// optional but allows the client discover the service
+	reflection.Register(grpcServer)
+
+	waitGroup.Go(func() error {
+		listener, err := net.Listen("tcp", config.GRPCServerAddress)
+		if err != nil {
+			log.Printf("cannot create grpc listener: %s", err)
+			return err
+		}
+
+		log.Printf("starting grpc server at %s", listener.Addr().String())
+		err = grpcServer.Serve(listener)
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Printf("grpc server failed to serve: %s", err)
+			return err
+		}
+		return nil
+	})
+
+	waitGroup.Go(func() error {
+		<-ctx.Done()
+		waitDrainDelay(config, "grpc server")
+		log.Printf("shutting down grpc server")
+
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+
+		// streams such as WatchAccount never end on their own, so they are cut off after the drain timeout
+		select {
+		case <-stopped:
+		case <-time.After(config.ShutdownTimeout):
+			log.Printf("grpc server did not drain in %s, stopping it", config.ShutdownTimeout)
+			grpcServer.Stop()
+		}
+		log.Printf("grpc server stopped")
+		return nil
+	})
 }
 
-func runGatewayServer(config util.Config, store db.Store) {
-	server, err := gapi.NewServer(config, store)
+func runGatewayServer(ctx context.Context, waitGroup *errgroup.Group, config util.Config, store db.Store, taskDistributor worker.TaskDistributor, checker *health.Checker) {
+	server, err := gapi.NewServer(config, store, taskDistributor)
 	if err != nil {
 		log.Fatal("Cannot create server", err)
 	}
@@ -89,30 +325,102 @@ func runGatewayServer(config util.Config, store db.Store) {
 			DiscardUnknown: true,
 		},
 	})
-	
-	grpcMux := runtime.NewServeMux(jsonOption)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	
+
+	// the gateway calls the server in process, bypassing the gRPC interceptors, so it is instrumented as HTTP
+	grpcMux := runtime.NewServeMux(
+		jsonOption,
+		runtime.WithMetadata(metrics.GatewayAnnotator),
+		runtime.WithMetadata(tracing.GatewayAnnotator),
+	)
+
 	err = pb.RegisterSimpleBankHandlerServer(ctx, grpcMux, server)
 	if err != nil {
 		log.Fatal("Cannot register server", err)
 	}
 
+	// server streaming is not supported by the in-process gateway, so bridge it over SSE
+	watchAccountPath := "/v1/accounts/{account_id}/watch"
+	err = grpcMux.HandlePath(http.MethodGet, watchAccountPath, metrics.GatewayPath(watchAccountPath, tracing.GatewayPath(watchAccountPath, server.WatchAccountSSE)))
+	if err != nil {
+		log.Fatal("Cannot register watch account handler", err)
+	}
+
 	mux := http.NewServeMux()
-	mux.Handle("/", grpcMux)
+	mux.Handle("/", logger.HTTPMiddleware(tracing.GatewayHandler(metrics.GatewayHandler(grpcMux))))
+	mux.HandleFunc("/healthz", checker.LivenessHandler)
+	mux.HandleFunc("/readyz", checker.ReadinessHandler)
 
 	fs := http.FileServer(http.Dir("./doc/swagger"))
 	mux.Handle("/swagger/", http.StripPrefix("/swagger/", fs))
 
-	listener, err := net.Listen("tcp", config.HTTPServerAddress)
-	if err != nil {
-		log.Fatal("Cannot create listener:", err)
+	httpServer := &http.Server{
+		Addr:    config.HTTPServerAddress,
+		Handler: mux,
+	}
+	serveHTTP(ctx, waitGroup, config, "HTTP gateway server", httpServer)
+}
+
+// runMetricsServer serves the metrics on their own listener, so that they are not exposed with the public API.
+// They are not served at all if METRICS_SERVER_ADDRESS is not set.
+func runMetricsServer(ctx context.Context, waitGroup *errgroup.Group, config util.Config) {
+	if config.MetricsServerAddress == "" {
+		log.Printf("metrics server address is not set, not serving metrics")
+		return
 	}
 
-	log.Printf("starting HTTP gateway server at %s", listener.Addr().String())
-	err = http.Serve(listener, mux)
-	if err != nil {
-		log.Fatal("Cannot start server", err)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	httpServer := &http.Server{
+		Addr:    config.MetricsServerAddress,
+		Handler: mux,
 	}
+	serveHTTP(ctx, waitGroup, config, "metrics server", httpServer)
+}
+
+// waitDrainDelay keeps a server taking requests for a while after the readiness checks start failing,
+// so that load balancers see the service as not ready and stop sending it traffic before it drains
+func waitDrainDelay(config util.Config, name string) {
+	if config.ShutdownDrainDelay <= 0 {
+		return
+	}
+	log.Printf("waiting %s before shutting down %s", config.ShutdownDrainDelay, name)
+	time.Sleep(config.ShutdownDrainDelay)
+}
+
+// serveHTTP runs httpServer under waitGroup, and shuts it down once ctx is done
+func serveHTTP(ctx context.Context, waitGroup *errgroup.Group, config util.Config, name string, httpServer *http.Server) {
+	waitGroup.Go(func() error {
+		listener, err := net.Listen("tcp", httpServer.Addr)
+		if err != nil {
+			log.Printf("cannot create %s listener: %s", name, err)
+			return err
+		}
+
+		log.Printf("starting %s at %s", name, listener.Addr().String())
+		err = httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("%s failed to serve: %s", name, err)
+			return err
+		}
+		return nil
+	})
+
+	waitGroup.Go(func() error {
+		<-ctx.Done()
+		waitDrainDelay(config, name)
+		log.Printf("shutting down %s", name)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+		defer cancel()
+
+		// SSE streams of WatchAccount stay open, so whatever is left after the drain timeout is closed
+		err := httpServer.Shutdown(shutdownCtx)
+		if err != nil {
+			log.Printf("%s did not drain in %s, closing it: %s", name, config.ShutdownTimeout, err)
+			httpServer.Close()
+		}
+		log.Printf("%s stopped", name)
+		return nil
+	})
 }
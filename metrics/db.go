@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector exports the stats of a pgx connection pool, which are read on every scrape
+type poolCollector struct {
+	connPool *pgxpool.Pool
+
+	maxConns            *prometheus.Desc
+	totalConns          *prometheus.Desc
+	acquiredConns       *prometheus.Desc
+	idleConns           *prometheus.Desc
+	acquires            *prometheus.Desc
+	acquireDuration     *prometheus.Desc
+	emptyAcquires       *prometheus.Desc
+	canceledAcquires    *prometheus.Desc
+	newConns            *prometheus.Desc
+	maxLifetimeDestroys *prometheus.Desc
+	maxIdleTimeDestroys *prometheus.Desc
+}
+
+func newPoolCollector(connPool *pgxpool.Pool, name string) *poolCollector {
+	labels := prometheus.Labels{"db_name": name}
+	desc := func(metric string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", metric), help, nil, labels)
+	}
+
+	return &poolCollector{
+		connPool:            connPool,
+		maxConns:            desc("max_conns", "Maximum size of the pool."),
+		totalConns:          desc("total_conns", "Connections currently in the pool, acquired, idle or being established."),
+		acquiredConns:       desc("acquired_conns", "Connections currently acquired by queries and transactions."),
+		idleConns:           desc("idle_conns", "Connections currently idle in the pool."),
+		acquires:            desc("acquires_total", "Connections acquired from the pool."),
+		acquireDuration:     desc("acquire_duration_seconds_total", "Time spent waiting to acquire connections from the pool."),
+		emptyAcquires:       desc("empty_acquires_total", "Acquires that had to wait for a connection because the pool was empty."),
+		canceledAcquires:    desc("canceled_acquires_total", "Acquires cancelled by their context."),
+		newConns:            desc("new_conns_total", "Connections opened by the pool."),
+		maxLifetimeDestroys: desc("max_lifetime_destroys_total", "Connections closed because they reached the maximum lifetime."),
+		maxIdleTimeDestroys: desc("max_idle_time_destroys_total", "Connections closed because they stayed idle for too long."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (collector *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(collector, ch)
+}
+
+// Collect implements prometheus.Collector.
+func (collector *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := collector.connPool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(collector.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(collector.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(collector.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(collector.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(collector.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(collector.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(collector.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(collector.canceledAcquires, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(collector.newConns, prometheus.CounterValue, float64(stat.NewConnsCount()))
+	ch <- prometheus.MustNewConstMetric(collector.maxLifetimeDestroys, prometheus.CounterValue, float64(stat.MaxLifetimeDestroyCount()))
+	ch <- prometheus.MustNewConstMetric(collector.maxIdleTimeDestroys, prometheus.CounterValue, float64(stat.MaxIdleDestroyCount()))
+}
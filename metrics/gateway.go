@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/metadata"
+)
+
+// unmatchedRoute labels the requests that did not match any route, e.g. 404s
+const unmatchedRoute = "unmatched"
+
+type routeKey struct{}
+
+// GatewayHandler records the requests served by the gateway mux next. The gateway only knows
+// the route pattern once it has matched the request: GatewayAnnotator reports it back.
+func GatewayHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		route := unmatchedRoute
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), routeKey{}, &route)))
+
+		ObserveHTTPRequest(StackGateway, route, r.Method, recorder.code, time.Since(startTime))
+	})
+}
+
+// GatewayAnnotator must be registered with runtime.WithMetadata on the gateway mux wrapped by GatewayHandler
+func GatewayAnnotator(ctx context.Context, r *http.Request) metadata.MD {
+	if pattern, ok := runtime.HTTPPathPattern(ctx); ok {
+		setRoute(ctx, pattern)
+	}
+	return nil
+}
+
+// GatewayPath reports pattern as the route of the custom handler h, registered with HandlePath.
+// Such handlers bypass the annotators.
+func GatewayPath(pattern string, h runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		setRoute(r.Context(), pattern)
+		h(w, r, pathParams)
+	}
+}
+
+func setRoute(ctx context.Context, pattern string) {
+	if route, ok := ctx.Value(routeKey{}).(*string); ok {
+		*route = pattern
+	}
+}
+
+// statusRecorder keeps the status code written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (recorder *statusRecorder) WriteHeader(code int) {
+	if !recorder.wroteHeader {
+		recorder.code = code
+		recorder.wroteHeader = true
+	}
+	recorder.ResponseWriter.WriteHeader(code)
+}
+
+func (recorder *statusRecorder) Write(b []byte) (int, error) {
+	recorder.wroteHeader = true
+	return recorder.ResponseWriter.Write(b)
+}
+
+// Flush lets server-sent events through, as used by WatchAccount
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor records the calls of unary RPCs
+func UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	startTime := time.Now()
+	rsp, err := handler(ctx, req)
+	observeGRPCRequest(info.FullMethod, err, time.Since(startTime))
+	return rsp, err
+}
+
+// StreamServerInterceptor records the calls of streaming RPCs, once the stream ends
+func StreamServerInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	startTime := time.Now()
+	err := handler(srv, stream)
+	observeGRPCRequest(info.FullMethod, err, time.Since(startTime))
+	return err
+}
+
+func observeGRPCRequest(method string, err error, duration time.Duration) {
+	code := status.Code(err).String()
+	grpcRequests.WithLabelValues(method, code).Inc()
+	grpcRequestDuration.WithLabelValues(method, code).Observe(duration.Seconds())
+}
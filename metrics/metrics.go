@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "simplebank"
+
+// Stacks serving HTTP requests, used as the stack label
+const (
+	StackGin     = "gin"
+	StackGateway = "gateway"
+)
+
+// Registry holds all the metrics of the service. It is not the default registry,
+// so that nothing gets exported by accident.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	httpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by stack, route, method and status code.",
+	}, []string{"stack", "route", "method", "code"})
+
+	httpRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests, by stack, route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"stack", "route", "method", "code"})
+
+	grpcRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "gRPC calls handled, by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Latency of gRPC calls, by method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	transferTxDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "transfer_tx_duration_seconds",
+		Help:      "Duration of transfer transactions, by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	transferTxConflicts = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_tx_conflicts_total",
+		Help:      "Transfer transactions aborted by Postgres, by reason: deadlock or serialization_failure.",
+	}, []string{"reason"})
+
+	transferTxRetries = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_tx_retries_total",
+		Help:      "Transfer transactions retried after a conflict, by reason: deadlock or serialization_failure.",
+	}, []string{"reason"})
+
+	transfers = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_total",
+		Help:      "Completed transfers, by currency.",
+	}, []string{"currency"})
+
+	transferVolume = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_volume_total",
+		Help:      "Amount moved by completed transfers, in minor units of the currency.",
+	}, []string{"currency"})
+
+	loginFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Failed login attempts, by outcome: failure for a wrong password, locked for a locked out username or client.",
+	}, []string{"outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// RegisterDB exports the connection pool stats of connPool
+func RegisterDB(connPool *pgxpool.Pool, name string) error {
+	return Registry.Register(newPoolCollector(connPool, name))
+}
+
+// Handler serves the metrics in the Prometheus format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveHTTPRequest records an HTTP request. route must be the route pattern rather than
+// the actual path, to keep the number of label values bounded.
+func ObserveHTTPRequest(stack string, route string, method string, code int, duration time.Duration) {
+	labels := prometheus.Labels{
+		"stack":  stack,
+		"route":  route,
+		"method": method,
+		"code":   strconv.Itoa(code),
+	}
+	httpRequests.With(labels).Inc()
+	httpRequestDuration.With(labels).Observe(duration.Seconds())
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTransferTx(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := mockdb.NewMockStore(ctrl)
+	store := InstrumentStore(mockStore)
+
+	arg := db.TransferTxParams{FromAccountID: 1, ToAccountID: 2, Amount: 150}
+	currency := util.RandomCurrency()
+
+	transfersBefore := testutil.ToFloat64(transfers.WithLabelValues(currency))
+	volumeBefore := testutil.ToFloat64(transferVolume.WithLabelValues(currency))
+	deadlocksBefore := testutil.ToFloat64(transferTxConflicts.WithLabelValues("deadlock"))
+
+	mockStore.EXPECT().
+		TransferTx(gomock.Any(), gomock.Eq(arg)).
+		Times(1).
+		Return(db.TransferTxResult{FromAccount: db.Account{ID: 1, Currency: currency}}, nil)
+	_, err := store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	require.Equal(t, transfersBefore+1, testutil.ToFloat64(transfers.WithLabelValues(currency)))
+	require.Equal(t, volumeBefore+150, testutil.ToFloat64(transferVolume.WithLabelValues(currency)))
+
+	mockStore.EXPECT().
+		TransferTx(gomock.Any(), gomock.Eq(arg)).
+		Times(1).
+		Return(db.TransferTxResult{}, &pgconn.PgError{Code: db.DeadlockDetected})
+	_, err = store.TransferTx(context.Background(), arg)
+	require.Error(t, err)
+
+	require.Equal(t, deadlocksBefore+1, testutil.ToFloat64(transferTxConflicts.WithLabelValues("deadlock")))
+	require.Equal(t, transfersBefore+1, testutil.ToFloat64(transfers.WithLabelValues(currency)))
+
+	retriesBefore := testutil.ToFloat64(transferTxRetries.WithLabelValues(db.ConflictSerializationFailure))
+	observeTransferRetry(context.Background(), &pgconn.PgError{Code: db.SerializationFailure})
+	require.Equal(t, retriesBefore+1, testutil.ToFloat64(transferTxRetries.WithLabelValues(db.ConflictSerializationFailure)))
+}
+
+func TestCreateLoginEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStore := mockdb.NewMockStore(ctrl)
+	store := InstrumentStore(mockStore)
+
+	mockStore.EXPECT().CreateLoginEvent(gomock.Any(), gomock.Any()).AnyTimes()
+
+	failuresBefore := testutil.ToFloat64(loginFailures.WithLabelValues(db.LoginOutcomeFailure))
+	lockedBefore := testutil.ToFloat64(loginFailures.WithLabelValues(db.LoginOutcomeLocked))
+
+	for _, outcome := range []string{db.LoginOutcomeSuccess, db.LoginOutcomeFailure, db.LoginOutcomeLocked, db.LoginOutcomeUnlocked} {
+		_, err := store.CreateLoginEvent(context.Background(), db.CreateLoginEventParams{Username: "user", Outcome: outcome})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, failuresBefore+1, testutil.ToFloat64(loginFailures.WithLabelValues(db.LoginOutcomeFailure)))
+	require.Equal(t, lockedBefore+1, testutil.ToFloat64(loginFailures.WithLabelValues(db.LoginOutcomeLocked)))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	method := "/pb.SimpleBank/Test"
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+
+	_, err := UnaryServerInterceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.NotFound, "account not found")
+	})
+	require.Error(t, err)
+
+	_, err = UnaryServerInterceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, float64(1), testutil.ToFloat64(grpcRequests.WithLabelValues(method, codes.NotFound.String())))
+	require.Equal(t, float64(1), testutil.ToFloat64(grpcRequests.WithLabelValues(method, codes.OK.String())))
+}
+
+func TestGatewayHandler(t *testing.T) {
+	pattern := "/v1/test/{id}"
+	gatewayMux := runtime.NewServeMux(runtime.WithMetadata(GatewayAnnotator))
+	err := gatewayMux.HandlePath(http.MethodGet, pattern, GatewayPath(pattern, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	require.NoError(t, err)
+
+	handler := GatewayHandler(gatewayMux)
+	for _, path := range []string{"/v1/test/1", "/v1/test/2", "/v1/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// requests are counted by route, not by path
+	require.Equal(t, float64(2), testutil.ToFloat64(httpRequests.WithLabelValues(StackGateway, pattern, http.MethodGet, "418")))
+	require.Equal(t, float64(1), testutil.ToFloat64(httpRequests.WithLabelValues(StackGateway, unmatchedRoute, http.MethodGet, "404")))
+}
+
+func TestHandler(t *testing.T) {
+	// the pool only connects on demand
+	connPool, err := pgxpool.New(context.Background(), "postgresql://localhost/metrics_test")
+	require.NoError(t, err)
+	defer connPool.Close()
+	require.NoError(t, RegisterDB(connPool, "metrics_test"))
+
+	ObserveHTTPRequest(StackGin, "/accounts/:id", http.MethodGet, http.StatusOK, 0)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	body := recorder.Body.String()
+	require.Contains(t, body, `simplebank_http_requests_total{code="200",method="GET",route="/accounts/:id",stack="gin"}`)
+	require.Contains(t, body, `simplebank_db_pool_total_conns{db_name="metrics_test"} 0`)
+}
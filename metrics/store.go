@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+)
+
+// instrumentedStore records the business metrics and the transfer transactions of the Store it wraps
+type instrumentedStore struct {
+	db.Store
+}
+
+// InstrumentStore wraps store to record its metrics
+func InstrumentStore(store db.Store) db.Store {
+	return &instrumentedStore{Store: store}
+}
+
+// TransferTx implements db.Store.
+func (store *instrumentedStore) TransferTx(ctx context.Context, arg db.TransferTxParams) (db.TransferTxResult, error) {
+	startTime := time.Now()
+	result, err := store.Store.TransferTx(db.WithTxOptions(ctx, db.OnRetry(observeTransferRetry)), arg)
+	duration := time.Since(startTime)
+
+	if err != nil {
+		transferTxDuration.WithLabelValues("error").Observe(duration.Seconds())
+		// conflicts that are left once the retries are exhausted
+		if reason := db.TxConflict(err); reason != "" {
+			transferTxConflicts.WithLabelValues(reason).Inc()
+		}
+		return result, err
+	}
+
+	transferTxDuration.WithLabelValues("success").Observe(duration.Seconds())
+	currency := result.FromAccount.Currency
+	transfers.WithLabelValues(currency).Inc()
+	transferVolume.WithLabelValues(currency).Add(float64(arg.Amount))
+	return result, nil
+}
+
+func observeTransferRetry(ctx context.Context, err error) {
+	transferTxRetries.WithLabelValues(db.TxConflict(err)).Inc()
+}
+
+// CreateLoginEvent implements db.Store.
+func (store *instrumentedStore) CreateLoginEvent(ctx context.Context, arg db.CreateLoginEventParams) (db.LoginEvent, error) {
+	event, err := store.Store.CreateLoginEvent(ctx, arg)
+	if arg.Outcome == db.LoginOutcomeFailure || arg.Outcome == db.LoginOutcomeLocked {
+		// counted even if the event could not be stored: the attempt failed all the same
+		loginFailures.WithLabelValues(arg.Outcome).Inc()
+	}
+	return event, err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/pakojabi/simplebank/db/migration"
+	"github.com/pakojabi/simplebank/util"
+)
+
+const migrateUsage = "usage: main migrate up [N] | down [N] | version"
+
+// runMigrateCommand runs the migrate subcommand: up applies N or all the pending migrations,
+// down rolls back N migrations, one by default, and version prints the version of the schema
+func runMigrateCommand(config util.Config, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+
+	n := 0
+	if len(args) == 2 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n <= 0 {
+			return fmt.Errorf("invalid number of migrations: %s", args[1])
+		}
+	}
+
+	migrator, err := migration.New(config.DBSource)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		if err := migrator.CheckVersion(); err != nil {
+			return err
+		}
+		if err := migrator.Up(n); err != nil {
+			return fmt.Errorf("cannot apply migrations: %w", err)
+		}
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		if err := migrator.Down(context.Background(), n); err != nil {
+			return fmt.Errorf("cannot roll back migrations: %w", err)
+		}
+	case "version":
+		if len(args) > 1 {
+			return errors.New(migrateUsage)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return fmt.Errorf("cannot get schema version: %w", err)
+	}
+	log.Printf("schema is at version %d (dirty: %t)", version, dirty)
+	return nil
+}
+
+// checkSchema applies the pending migrations if MIGRATE_ON_START is set. Either way, it refuses
+// to go on with a schema newer than the binary, which it does not know how to use.
+func checkSchema(ctx context.Context, config util.Config) error {
+	migrator, err := migration.New(config.DBSource)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if !config.MigrateOnStart {
+		return migrator.CheckVersion()
+	}
+
+	if err := migrator.UpOnStart(ctx); err != nil {
+		return fmt.Errorf("cannot migrate db: %w", err)
+	}
+	log.Printf("db migrated successfully")
+	return nil
+}
@@ -0,0 +1,30 @@
+package oauth
+
+// Error codes of RFC 6749, section 5.2
+const (
+	ErrorInvalidRequest          = "invalid_request"
+	ErrorInvalidClient           = "invalid_client"
+	ErrorInvalidGrant            = "invalid_grant"
+	ErrorUnauthorizedClient      = "unauthorized_client"
+	ErrorUnsupportedGrantType    = "unsupported_grant_type"
+	ErrorUnsupportedResponseType = "unsupported_response_type"
+	ErrorInvalidScope            = "invalid_scope"
+)
+
+// Error is an OAuth error, reported to clients as is
+type Error struct {
+	Code        string
+	Description string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func invalidRequest(description string) *Error {
+	return &Error{Code: ErrorInvalidRequest, Description: description}
+}
+
+func invalidGrant(description string) *Error {
+	return &Error{Code: ErrorInvalidGrant, Description: description}
+}
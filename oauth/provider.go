@@ -0,0 +1,337 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
+)
+
+const (
+	// CodeChallengeMethodS256 is the only PKCE method supported, as recommended by RFC 7636
+	CodeChallengeMethodS256 = "S256"
+
+	secretLength = 32
+	codeLength   = 32
+)
+
+var (
+	ErrClientRevoked  = apperror.New(apperror.CodeOAuthClientRevoked, "oauth client no longer exists")
+	ErrConsentRevoked = apperror.New(apperror.CodeOAuthConsentRevoked, "oauth consent has been revoked")
+)
+
+// Token is an access token issued to a client
+type Token struct {
+	AccessToken string
+	Payload     *token.Payload
+}
+
+// Provider is an OAuth 2.0 authorization server: it registers clients, records users' consents
+// and issues delegated, scoped access tokens through the token maker
+type Provider struct {
+	config     util.Config
+	store      db.Store
+	tokenMaker token.Maker
+}
+
+// NewProvider creates a new OAuth Provider
+func NewProvider(config util.Config, store db.Store, tokenMaker token.Maker) *Provider {
+	return &Provider{
+		config:     config,
+		store:      store,
+		tokenMaker: tokenMaker,
+	}
+}
+
+type RegisterClientParams struct {
+	Owner        string
+	Name         string
+	RedirectURIs []string
+	Scopes       []string
+	// Confidential clients get a secret and can use the client credentials grant.
+	// Public clients, such as mobile apps, cannot keep a secret.
+	Confidential bool
+}
+
+// RegisterClient registers a new client. The secret of a confidential client is only returned here.
+func (provider *Provider) RegisterClient(ctx context.Context, arg RegisterClientParams) (db.OauthClient, string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return db.OauthClient{}, "", err
+	}
+
+	var secret string
+	var hashedSecret pgtype.Text
+	if arg.Confidential {
+		secret, err = util.RandomSecret(secretLength)
+		if err != nil {
+			return db.OauthClient{}, "", fmt.Errorf("failed to generate client secret: %w", err)
+		}
+		hashedSecret = pgtype.Text{String: hash(secret), Valid: true}
+	}
+
+	client, err := provider.store.CreateOAuthClient(ctx, db.CreateOAuthClientParams{
+		ID:           id,
+		Owner:        arg.Owner,
+		Name:         arg.Name,
+		HashedSecret: hashedSecret,
+		RedirectUris: arg.RedirectURIs,
+		Scopes:       arg.Scopes,
+	})
+	if err != nil {
+		return db.OauthClient{}, "", fmt.Errorf("failed to create oauth client: %w", err)
+	}
+
+	return client, secret, nil
+}
+
+type AuthorizeParams struct {
+	Username            string
+	ClientID            string
+	RedirectURI         string
+	Scopes              []string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// Authorize records the user's consent to the client's request and returns an authorization code for it
+func (provider *Provider) Authorize(ctx context.Context, arg AuthorizeParams) (string, error) {
+	client, err := provider.getClient(ctx, arg.ClientID)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(client.RedirectUris, arg.RedirectURI) {
+		return "", invalidRequest("redirect_uri is not registered for the client")
+	}
+	if err := checkScopes(client, arg.Scopes); err != nil {
+		return "", err
+	}
+	if arg.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return "", invalidRequest("code_challenge_method must be S256")
+	}
+	if len(arg.CodeChallenge) != base64.RawURLEncoding.EncodedLen(sha256.Size) {
+		return "", invalidRequest("code_challenge must be a base64url encoded SHA-256 hash")
+	}
+
+	_, err = provider.store.UpsertOAuthConsent(ctx, db.UpsertOAuthConsentParams{
+		Username: arg.Username,
+		ClientID: client.ID,
+		Scopes:   arg.Scopes,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to record consent: %w", err)
+	}
+
+	code, err := util.RandomSecret(codeLength)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate authorization code: %w", err)
+	}
+
+	_, err = provider.store.CreateOAuthAuthorizationCode(ctx, db.CreateOAuthAuthorizationCodeParams{
+		HashedCode:    hash(code),
+		ClientID:      client.ID,
+		Username:      arg.Username,
+		RedirectUri:   arg.RedirectURI,
+		Scopes:        arg.Scopes,
+		CodeChallenge: arg.CodeChallenge,
+		ExpiresAt:     time.Now().Add(provider.config.OAuthCodeDuration),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create authorization code: %w", err)
+	}
+
+	return code, nil
+}
+
+type ExchangeCodeParams struct {
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+// ExchangeCode implements the authorization code grant: it trades a code for an access token
+func (provider *Provider) ExchangeCode(ctx context.Context, arg ExchangeCodeParams) (Token, error) {
+	client, err := provider.authenticateClient(ctx, arg.ClientID, arg.ClientSecret)
+	if err != nil {
+		return Token{}, err
+	}
+
+	code, err := provider.store.UseOAuthAuthorizationCode(ctx, hash(arg.Code))
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return Token{}, invalidGrant("authorization code is invalid, expired or already used")
+		}
+		return Token{}, fmt.Errorf("failed to use authorization code: %w", err)
+	}
+	if code.ClientID != client.ID {
+		return Token{}, invalidGrant("authorization code was issued to another client")
+	}
+	if code.RedirectUri != arg.RedirectURI {
+		return Token{}, invalidGrant("redirect_uri does not match the authorization request")
+	}
+	if !verifyCodeChallenge(code.CodeChallenge, arg.CodeVerifier) {
+		return Token{}, invalidGrant("code_verifier does not match the code challenge")
+	}
+
+	user, err := provider.store.GetUser(ctx, code.Username)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return provider.makeToken(user, client, code.Scopes)
+}
+
+// ClientCredentials implements the client credentials grant: a confidential client gets a token
+// to act on behalf of its owner. All of the client's scopes are granted when scopes is empty.
+func (provider *Provider) ClientCredentials(ctx context.Context, clientID string, clientSecret string, scopes []string) (Token, error) {
+	client, err := provider.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return Token{}, err
+	}
+	if !client.HashedSecret.Valid {
+		return Token{}, &Error{Code: ErrorUnauthorizedClient, Description: "public clients cannot use the client credentials grant"}
+	}
+
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	if err := checkScopes(client, scopes); err != nil {
+		return Token{}, err
+	}
+
+	owner, err := provider.store.GetUser(ctx, client.Owner)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to get client owner: %w", err)
+	}
+
+	return provider.makeToken(owner, client, scopes)
+}
+
+// CheckToken verifies that the client a token was issued to still exists, and that the user has not
+// revoked their consent since the token was issued. Client credentials tokens act for the client's
+// owner, who needs no consent, but can still revoke one given through the authorization code flow.
+func (provider *Provider) CheckToken(ctx context.Context, payload *token.Payload) error {
+	if payload.ClientID == "" {
+		return nil
+	}
+
+	client, err := provider.getClient(ctx, payload.ClientID)
+	if err != nil {
+		var oauthErr *Error
+		if errors.As(err, &oauthErr) {
+			return ErrClientRevoked
+		}
+		return err
+	}
+
+	consent, err := provider.store.GetOAuthConsent(ctx, db.GetOAuthConsentParams{
+		Username: payload.Username,
+		ClientID: client.ID,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			if payload.Username == client.Owner {
+				return nil
+			}
+			return ErrConsentRevoked
+		}
+		return fmt.Errorf("failed to get consent: %w", err)
+	}
+	if consent.RevokedAt.Valid || payload.ValidSince(consent.GrantedAt) != nil {
+		return ErrConsentRevoked
+	}
+
+	return nil
+}
+
+func (provider *Provider) makeToken(user db.User, client db.OauthClient, scopes []string) (Token, error) {
+	accessToken, payload, err := provider.tokenMaker.MakeDelegated(
+		user.Username,
+		user.Role,
+		client.ID.String(),
+		scopes,
+		provider.config.OAuthAccessTokenDuration,
+	)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to create access token: %w", err)
+	}
+
+	return Token{AccessToken: accessToken, Payload: payload}, nil
+}
+
+func (provider *Provider) getClient(ctx context.Context, clientID string) (db.OauthClient, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return db.OauthClient{}, &Error{Code: ErrorInvalidClient, Description: "unknown client"}
+	}
+
+	client, err := provider.store.GetOAuthClient(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return db.OauthClient{}, &Error{Code: ErrorInvalidClient, Description: "unknown client"}
+		}
+		return db.OauthClient{}, fmt.Errorf("failed to get oauth client: %w", err)
+	}
+	return client, nil
+}
+
+// authenticateClient checks the secret of confidential clients. Public clients send none.
+func (provider *Provider) authenticateClient(ctx context.Context, clientID string, clientSecret string) (db.OauthClient, error) {
+	client, err := provider.getClient(ctx, clientID)
+	if err != nil {
+		return db.OauthClient{}, err
+	}
+
+	if client.HashedSecret.Valid {
+		if subtle.ConstantTimeCompare([]byte(hash(clientSecret)), []byte(client.HashedSecret.String)) != 1 {
+			return db.OauthClient{}, &Error{Code: ErrorInvalidClient, Description: "client authentication failed"}
+		}
+	} else if clientSecret != "" {
+		return db.OauthClient{}, &Error{Code: ErrorInvalidClient, Description: "public clients have no secret"}
+	}
+
+	return client, nil
+}
+
+func checkScopes(client db.OauthClient, scopes []string) error {
+	if len(scopes) == 0 {
+		return &Error{Code: ErrorInvalidScope, Description: "at least one scope is required"}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return &Error{Code: ErrorInvalidScope, Description: fmt.Sprintf("scope %s is not allowed for the client", scope)}
+		}
+	}
+	return nil
+}
+
+// verifyCodeChallenge checks a PKCE code verifier against its S256 challenge
+func verifyCodeChallenge(challenge string, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// hash is used for client secrets and authorization codes, which are random enough for a plain SHA-256
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,322 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/token"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const testRedirectURI = "https://client.example.com/callback"
+
+func newTestProvider(t *testing.T) (*Provider, *mockdb.MockStore) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	tokenMaker, err := token.NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	config := util.Config{
+		OAuthCodeDuration:        time.Minute,
+		OAuthAccessTokenDuration: time.Hour,
+	}
+	return NewProvider(config, store, tokenMaker), store
+}
+
+// registerTestClient registers a client through the provider and returns it along with its secret
+func registerTestClient(t *testing.T, provider *Provider, store *mockdb.MockStore, confidential bool) (db.OauthClient, string) {
+	store.EXPECT().
+		CreateOAuthClient(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateOAuthClientParams) (db.OauthClient, error) {
+			return db.OauthClient{
+				ID:           arg.ID,
+				Owner:        arg.Owner,
+				Name:         arg.Name,
+				HashedSecret: arg.HashedSecret,
+				RedirectUris: arg.RedirectUris,
+				Scopes:       arg.Scopes,
+				CreatedAt:    time.Now(),
+			}, nil
+		})
+
+	client, secret, err := provider.RegisterClient(context.Background(), RegisterClientParams{
+		Owner:        util.RandomOwner(),
+		Name:         "budgeting app",
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       []string{util.AccountsReadScope, util.TransfersWriteScope},
+		Confidential: confidential,
+	})
+	require.NoError(t, err)
+	return client, secret
+}
+
+func randomCodeVerifier(t *testing.T) (string, string) {
+	verifier, err := util.RandomSecret(32)
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestRegisterClient(t *testing.T) {
+	provider, store := newTestProvider(t)
+
+	client, secret := registerTestClient(t, provider, store, true)
+	require.NotEmpty(t, secret)
+	require.True(t, client.HashedSecret.Valid)
+	require.NotEqual(t, secret, client.HashedSecret.String)
+
+	client, secret = registerTestClient(t, provider, store, false)
+	require.Empty(t, secret)
+	require.False(t, client.HashedSecret.Valid)
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	provider, store := newTestProvider(t)
+	client, _ := registerTestClient(t, provider, store, false)
+	user := db.User{Username: util.RandomOwner(), Role: util.DepositorRole}
+	verifier, challenge := randomCodeVerifier(t)
+	scopes := []string{util.AccountsReadScope}
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).AnyTimes().Return(client, nil)
+	store.EXPECT().
+		UpsertOAuthConsent(gomock.Any(), gomock.Eq(db.UpsertOAuthConsentParams{
+			Username: user.Username,
+			ClientID: client.ID,
+			Scopes:   scopes,
+		})).
+		Times(1)
+
+	var storedCode db.OauthAuthorizationCode
+	store.EXPECT().
+		CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateOAuthAuthorizationCodeParams) (db.OauthAuthorizationCode, error) {
+			require.WithinDuration(t, time.Now().Add(time.Minute), arg.ExpiresAt, time.Second)
+			storedCode = db.OauthAuthorizationCode{
+				HashedCode:    arg.HashedCode,
+				ClientID:      arg.ClientID,
+				Username:      arg.Username,
+				RedirectUri:   arg.RedirectUri,
+				Scopes:        arg.Scopes,
+				CodeChallenge: arg.CodeChallenge,
+				ExpiresAt:     arg.ExpiresAt,
+			}
+			return storedCode, nil
+		})
+
+	code, err := provider.Authorize(context.Background(), AuthorizeParams{
+		Username:            user.Username,
+		ClientID:            client.ID.String(),
+		RedirectURI:         testRedirectURI,
+		Scopes:              scopes,
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: CodeChallengeMethodS256,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, code)
+	require.NotEqual(t, code, storedCode.HashedCode)
+
+	store.EXPECT().
+		UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, hashedCode string) (db.OauthAuthorizationCode, error) {
+			if hashedCode != storedCode.HashedCode {
+				return db.OauthAuthorizationCode{}, db.ErrRecordNotFound
+			}
+			return storedCode, nil
+		})
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(user, nil)
+
+	// a wrong verifier, as sent by someone who intercepted the code
+	otherVerifier, _ := randomCodeVerifier(t)
+	_, err = provider.ExchangeCode(context.Background(), ExchangeCodeParams{
+		ClientID:     client.ID.String(),
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: otherVerifier,
+	})
+	requireOAuthError(t, err, ErrorInvalidGrant)
+
+	_, err = provider.ExchangeCode(context.Background(), ExchangeCodeParams{
+		ClientID:     client.ID.String(),
+		Code:         code,
+		RedirectURI:  "https://evil.example.com/callback",
+		CodeVerifier: verifier,
+	})
+	requireOAuthError(t, err, ErrorInvalidGrant)
+
+	_, err = provider.ExchangeCode(context.Background(), ExchangeCodeParams{
+		ClientID:     client.ID.String(),
+		Code:         "unknown",
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: verifier,
+	})
+	requireOAuthError(t, err, ErrorInvalidGrant)
+
+	issued, err := provider.ExchangeCode(context.Background(), ExchangeCodeParams{
+		ClientID:     client.ID.String(),
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: verifier,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, issued.AccessToken)
+	require.Equal(t, user.Username, issued.Payload.Username)
+	require.Equal(t, client.ID.String(), issued.Payload.ClientID)
+	require.Equal(t, scopes, issued.Payload.Scopes)
+	require.WithinDuration(t, time.Now().Add(time.Hour), issued.Payload.ExpiredAt, time.Second)
+}
+
+func TestAuthorizeInvalidRequest(t *testing.T) {
+	provider, store := newTestProvider(t)
+	client, _ := registerTestClient(t, provider, store, false)
+	_, challenge := randomCodeVerifier(t)
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).AnyTimes().Return(client, nil)
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Any()).AnyTimes().Return(db.OauthClient{}, db.ErrRecordNotFound)
+	store.EXPECT().UpsertOAuthConsent(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).Times(0)
+
+	valid := func() AuthorizeParams {
+		return AuthorizeParams{
+			Username:            util.RandomOwner(),
+			ClientID:            client.ID.String(),
+			RedirectURI:         testRedirectURI,
+			Scopes:              []string{util.AccountsReadScope},
+			CodeChallenge:       challenge,
+			CodeChallengeMethod: CodeChallengeMethodS256,
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		modify func(arg *AuthorizeParams)
+		code   string
+	}{
+		{"UnknownClient", func(arg *AuthorizeParams) { arg.ClientID = uuid.New().String() }, ErrorInvalidClient},
+		{"MalformedClientID", func(arg *AuthorizeParams) { arg.ClientID = "client" }, ErrorInvalidClient},
+		{"UnregisteredRedirectURI", func(arg *AuthorizeParams) { arg.RedirectURI = "https://evil.example.com/callback" }, ErrorInvalidRequest},
+		{"NoScope", func(arg *AuthorizeParams) { arg.Scopes = nil }, ErrorInvalidScope},
+		{"ScopeNotAllowed", func(arg *AuthorizeParams) { arg.Scopes = []string{"users:write"} }, ErrorInvalidScope},
+		{"PlainChallenge", func(arg *AuthorizeParams) { arg.CodeChallengeMethod = "plain" }, ErrorInvalidRequest},
+		{"ShortChallenge", func(arg *AuthorizeParams) { arg.CodeChallenge = "abc" }, ErrorInvalidRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			arg := valid()
+			tc.modify(&arg)
+
+			_, err := provider.Authorize(context.Background(), arg)
+			requireOAuthError(t, err, tc.code)
+		})
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	provider, store := newTestProvider(t)
+	client, secret := registerTestClient(t, provider, store, true)
+	publicClient, _ := registerTestClient(t, provider, store, false)
+	owner := db.User{Username: client.Owner, Role: util.DepositorRole}
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).AnyTimes().Return(client, nil)
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(publicClient.ID)).AnyTimes().Return(publicClient, nil)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(owner.Username)).AnyTimes().Return(owner, nil)
+
+	issued, err := provider.ClientCredentials(context.Background(), client.ID.String(), secret, nil)
+	require.NoError(t, err)
+	require.Equal(t, owner.Username, issued.Payload.Username)
+	require.Equal(t, client.Scopes, issued.Payload.Scopes)
+
+	issued, err = provider.ClientCredentials(context.Background(), client.ID.String(), secret, []string{util.AccountsReadScope})
+	require.NoError(t, err)
+	require.Equal(t, []string{util.AccountsReadScope}, issued.Payload.Scopes)
+
+	_, err = provider.ClientCredentials(context.Background(), client.ID.String(), "wrong", nil)
+	requireOAuthError(t, err, ErrorInvalidClient)
+
+	_, err = provider.ClientCredentials(context.Background(), client.ID.String(), secret, []string{"users:write"})
+	requireOAuthError(t, err, ErrorInvalidScope)
+
+	_, err = provider.ClientCredentials(context.Background(), publicClient.ID.String(), "", nil)
+	requireOAuthError(t, err, ErrorUnauthorizedClient)
+}
+
+func TestCheckToken(t *testing.T) {
+	provider, store := newTestProvider(t)
+	client, _ := registerTestClient(t, provider, store, true)
+	username := util.RandomOwner()
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).AnyTimes().Return(client, nil)
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Any()).AnyTimes().Return(db.OauthClient{}, db.ErrRecordNotFound)
+
+	newPayload := func(username string, clientID string) *token.Payload {
+		payload, err := token.NewDelegatedPayload(username, util.DepositorRole, clientID, []string{util.AccountsReadScope}, time.Minute)
+		require.NoError(t, err)
+		return payload
+	}
+
+	// a login token does not belong to any client
+	payload, err := token.NewPayload(username, util.DepositorRole, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, provider.CheckToken(context.Background(), payload))
+
+	require.ErrorIs(t, provider.CheckToken(context.Background(), newPayload(username, uuid.New().String())), ErrClientRevoked)
+
+	consentParams := db.GetOAuthConsentParams{Username: username, ClientID: client.ID}
+
+	store.EXPECT().
+		GetOAuthConsent(gomock.Any(), gomock.Eq(consentParams)).
+		Times(1).
+		Return(db.OauthConsent{Username: username, ClientID: client.ID, GrantedAt: time.Now().Add(-time.Hour)}, nil)
+	require.NoError(t, provider.CheckToken(context.Background(), newPayload(username, client.ID.String())))
+
+	store.EXPECT().
+		GetOAuthConsent(gomock.Any(), gomock.Eq(consentParams)).
+		Times(1).
+		Return(db.OauthConsent{
+			Username:  username,
+			ClientID:  client.ID,
+			GrantedAt: time.Now().Add(-time.Hour),
+			RevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		}, nil)
+	require.ErrorIs(t, provider.CheckToken(context.Background(), newPayload(username, client.ID.String())), ErrConsentRevoked)
+
+	// consent was revoked then granted again after the token was issued
+	store.EXPECT().
+		GetOAuthConsent(gomock.Any(), gomock.Eq(consentParams)).
+		Times(1).
+		Return(db.OauthConsent{Username: username, ClientID: client.ID, GrantedAt: time.Now().Add(time.Minute)}, nil)
+	require.ErrorIs(t, provider.CheckToken(context.Background(), newPayload(username, client.ID.String())), ErrConsentRevoked)
+
+	store.EXPECT().
+		GetOAuthConsent(gomock.Any(), gomock.Eq(consentParams)).
+		Times(1).
+		Return(db.OauthConsent{}, db.ErrRecordNotFound)
+	require.ErrorIs(t, provider.CheckToken(context.Background(), newPayload(username, client.ID.String())), ErrConsentRevoked)
+
+	// the owner of the client needs no consent for client credentials tokens
+	store.EXPECT().
+		GetOAuthConsent(gomock.Any(), gomock.Eq(db.GetOAuthConsentParams{Username: client.Owner, ClientID: client.ID})).
+		Times(1).
+		Return(db.OauthConsent{}, db.ErrRecordNotFound)
+	require.NoError(t, provider.CheckToken(context.Background(), newPayload(client.Owner, client.ID.String())))
+}
+
+func requireOAuthError(t *testing.T, err error, code string) {
+	var oauthErr *Error
+	require.ErrorAs(t, err, &oauthErr)
+	require.Equal(t, code, oauthErr.Code)
+}
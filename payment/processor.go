@@ -0,0 +1,222 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+)
+
+var (
+	// ErrUnknownRail is returned for payments on a rail the processor does not have
+	ErrUnknownRail = errors.New("unknown payment rail")
+	// ErrUnsupportedCurrency is returned for payments in a currency their rail does not move money in
+	ErrUnsupportedCurrency = errors.New("payment rail does not support the currency")
+)
+
+// PaymentParams describes a payment to initiate
+type PaymentParams struct {
+	// Direction is db.PaymentDeposit or db.PaymentWithdrawal
+	Direction string
+	Account   db.Account
+	Rail      string
+	Amount    int64
+}
+
+// Processor initiates payments on their rails, and completes them with the outcomes the rails report
+type Processor struct {
+	store db.Store
+	rails map[string]Rail
+}
+
+// NewProcessor creates a Processor of payments on rails
+func NewProcessor(store db.Store, rails ...Rail) *Processor {
+	processor := &Processor{
+		store: store,
+		rails: make(map[string]Rail, len(rails)),
+	}
+	for _, rail := range rails {
+		processor.rails[rail.Name()] = rail
+	}
+	return processor
+}
+
+// Initiate creates a pending payment, which holds its amount in suspense, and submits it to its rail.
+// A payment the rail rejects fails at once.
+func (processor *Processor) Initiate(ctx context.Context, arg PaymentParams) (db.PaymentTxResult, error) {
+	rail, ok := processor.rails[arg.Rail]
+	if !ok {
+		return db.PaymentTxResult{}, fmt.Errorf("%w: %s", ErrUnknownRail, arg.Rail)
+	}
+	if !rail.Supports(arg.Account.Currency) {
+		return db.PaymentTxResult{}, fmt.Errorf("%w: %s on %s", ErrUnsupportedCurrency, arg.Account.Currency, arg.Rail)
+	}
+
+	result, err := processor.store.CreatePaymentTx(ctx, db.CreatePaymentTxParams{
+		AccountID: arg.Account.ID,
+		Direction: arg.Direction,
+		Rail:      arg.Rail,
+		Amount:    arg.Amount,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	reference, submitErr := rail.Submit(ctx, Instruction{
+		PaymentID: result.Payment.ID,
+		Direction: arg.Direction,
+		AccountID: arg.Account.ID,
+		Amount:    arg.Amount,
+		Currency:  arg.Account.Currency,
+	}, processor.report)
+	if submitErr != nil {
+		failed, err := processor.store.CompletePaymentTx(ctx, db.CompletePaymentTxParams{
+			PaymentID:     result.Payment.ID,
+			FailureReason: submitErr.Error(),
+		})
+		if err != nil {
+			return result, fmt.Errorf("cannot fail payment %d rejected by %s: %w", result.Payment.ID, arg.Rail, err)
+		}
+		result.Payment = failed.Payment
+		return result, nil
+	}
+
+	result.Payment, err = processor.store.SetPaymentReference(ctx, db.SetPaymentReferenceParams{
+		ID:        result.Payment.ID,
+		Reference: reference,
+	})
+	return result, err
+}
+
+// Complete settles or fails a payment with the outcome its rail reported. It returns db.ErrPaymentCompleted
+// if the payment was completed already.
+func (processor *Processor) Complete(ctx context.Context, outcome Outcome) (db.PaymentTxResult, error) {
+	return processor.store.CompletePaymentTx(ctx, db.CompletePaymentTxParams{
+		PaymentID:     outcome.PaymentID,
+		Settled:       outcome.Settled,
+		FailureReason: outcome.FailureReason,
+	})
+}
+
+// reconcileBatchSize is how many pending payments Reconcile lists at a time
+const reconcileBatchSize = 100
+
+// Reconcile submits the payments still pending that were created before createdBefore to their rail again, so
+// that the payments whose outcome was lost, e.g. when the process stopped, complete. Rails do not move a payment
+// twice, so one they are still processing is left alone. A payment on a rail the processor does not have anymore,
+// or that the rail rejects, fails. It returns how many payments it submitted again or failed.
+func (processor *Processor) Reconcile(ctx context.Context, createdBefore time.Time) (int, error) {
+	reconciled := 0
+	afterID := int64(0)
+	for {
+		payments, err := processor.store.ListPendingPayments(ctx, db.ListPendingPaymentsParams{
+			AfterID:       afterID,
+			CreatedBefore: createdBefore,
+			Limit:         reconcileBatchSize,
+		})
+		if err != nil {
+			return reconciled, fmt.Errorf("cannot list pending payments: %w", err)
+		}
+
+		for _, payment := range payments {
+			if err := processor.resubmit(ctx, payment); err != nil {
+				return reconciled, err
+			}
+			reconciled++
+			afterID = payment.ID
+		}
+		if len(payments) < reconcileBatchSize {
+			return reconciled, nil
+		}
+	}
+}
+
+// resubmit submits a pending payment to its rail again, or fails it if the rail cannot take it
+func (processor *Processor) resubmit(ctx context.Context, payment db.Payment) error {
+	rail, ok := processor.rails[payment.Rail]
+	if !ok {
+		return processor.fail(ctx, payment, fmt.Sprintf("%s: %s", ErrUnknownRail, payment.Rail))
+	}
+
+	reference, err := rail.Submit(ctx, Instruction{
+		PaymentID: payment.ID,
+		Direction: payment.Direction,
+		AccountID: payment.AccountID,
+		Amount:    payment.Amount,
+		Currency:  payment.Currency,
+	}, processor.report)
+	if err != nil {
+		return processor.fail(ctx, payment, err.Error())
+	}
+	if reference == payment.Reference {
+		return nil
+	}
+
+	_, err = processor.store.SetPaymentReference(ctx, db.SetPaymentReferenceParams{
+		ID:        payment.ID,
+		Reference: reference,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot set reference of payment %d: %w", payment.ID, err)
+	}
+	return nil
+}
+
+// fail fails a pending payment, unless it completed in the meantime
+func (processor *Processor) fail(ctx context.Context, payment db.Payment, reason string) error {
+	_, err := processor.store.CompletePaymentTx(ctx, db.CompletePaymentTxParams{
+		PaymentID:     payment.ID,
+		FailureReason: reason,
+	})
+	if errors.Is(err, db.ErrPaymentCompleted) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot fail payment %d: %w", payment.ID, err)
+	}
+	log.Printf("payment %d failed: %s", payment.ID, reason)
+	return nil
+}
+
+// Run reconciles every pending payment at once, as the outcomes of the payments submitted before a restart are
+// lost, and then every interval the payments pending for longer than interval, until ctx is done.
+// Without an interval, it only reconciles once.
+func (processor *Processor) Run(ctx context.Context, interval time.Duration) {
+	createdBefore := time.Now()
+	for {
+		reconciled, err := processor.Reconcile(ctx, createdBefore)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("cannot reconcile pending payments: %s", err)
+		}
+		if reconciled > 0 {
+			log.Printf("reconciled %d pending payments", reconciled)
+		}
+		if interval <= 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		createdBefore = time.Now().Add(-interval)
+	}
+}
+
+// report completes a payment with the outcome a rail reported on its own, after the request that submitted it
+func (processor *Processor) report(outcome Outcome) {
+	_, err := processor.Complete(context.Background(), outcome)
+	if errors.Is(err, db.ErrPaymentCompleted) {
+		log.Printf("payment %d (%s) was already completed", outcome.PaymentID, outcome.Reference)
+		return
+	}
+	if err != nil {
+		log.Printf("cannot complete payment %d (%s): %s", outcome.PaymentID, outcome.Reference, err)
+		return
+	}
+	log.Printf("payment %d (%s) settled: %t", outcome.PaymentID, outcome.Reference, outcome.Settled)
+}
@@ -0,0 +1,208 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+// rejectingRail refuses every payment
+type rejectingRail struct{}
+
+func (rejectingRail) Name() string                  { return "rejecting" }
+func (rejectingRail) Supports(currency string) bool { return true }
+func (rejectingRail) Submit(ctx context.Context, instruction Instruction, report ReportFunc) (string, error) {
+	return "", errors.New("account closed")
+}
+
+func createTestAccount(t *testing.T, store db.Store, currency string, balance int64) db.Account {
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, db.CreateUserParams{
+		Username:       util.RandomOwner(),
+		HashedPassword: util.RandomString(32),
+		FullName:       util.RandomOwner(),
+		Email:          util.RandomString(10) + "@example.com",
+	})
+	require.NoError(t, err)
+
+	account, err := store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  balance,
+		Currency: currency,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+// requireCompleted waits for the rail to report the outcome of payment
+func requireCompleted(t *testing.T, store db.Store, payment db.Payment) db.Payment {
+	require.Eventually(t, func() bool {
+		var err error
+		payment, err = store.GetPayment(context.Background(), payment.ID)
+		require.NoError(t, err)
+		return payment.Status != db.PaymentPending
+	}, time.Second, 10*time.Millisecond)
+	return payment
+}
+
+func requireBalance(t *testing.T, store db.Store, accountID int64, balance int64) {
+	account, err := store.GetAccount(context.Background(), accountID)
+	require.NoError(t, err)
+	require.Equal(t, balance, account.Balance)
+}
+
+func TestStyleSupports(t *testing.T) {
+	require.True(t, ACH.Supports(util.USD))
+	require.False(t, ACH.Supports(util.EUR))
+	require.True(t, SEPA.Supports(util.EUR))
+	require.False(t, SEPA.Supports(util.CAD))
+	require.True(t, Card.Supports(util.CAD))
+}
+
+func TestDepositSettles(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	account := createTestAccount(t, store, util.USD, 100)
+	processor := NewProcessor(store, SimulatedRails(SimulatorConfig{Delay: 10 * time.Millisecond})...)
+
+	result, err := processor.Initiate(ctx, PaymentParams{
+		Direction: db.PaymentDeposit,
+		Account:   account,
+		Rail:      ACH.Name,
+		Amount:    50,
+	})
+	require.NoError(t, err)
+	require.Contains(t, result.Payment.Reference, "ach_")
+
+	payment := requireCompleted(t, store, result.Payment)
+	require.Equal(t, db.PaymentSettled, payment.Status)
+	requireBalance(t, store, account.ID, 150)
+
+	// the rail reporting the outcome again changes nothing
+	_, err = processor.Complete(ctx, Outcome{PaymentID: payment.ID, Reference: payment.Reference})
+	require.ErrorIs(t, err, db.ErrPaymentCompleted)
+	requireBalance(t, store, account.ID, 150)
+}
+
+func TestWithdrawalFails(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	account := createTestAccount(t, store, util.EUR, 100)
+	processor := NewProcessor(store, NewSimulator(SEPA, SimulatorConfig{Delay: 10 * time.Millisecond, FailureRate: 1}))
+
+	result, err := processor.Initiate(ctx, PaymentParams{
+		Direction: db.PaymentWithdrawal,
+		Account:   account,
+		Rail:      SEPA.Name,
+		Amount:    40,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(60), result.Transfer.FromAccount.Balance)
+
+	payment := requireCompleted(t, store, result.Payment)
+	require.Equal(t, db.PaymentFailed, payment.Status)
+	require.Equal(t, "AM04: insufficient funds", payment.FailureReason)
+	requireBalance(t, store, account.ID, 100)
+}
+
+func TestRejectedPaymentFails(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	account := createTestAccount(t, store, util.CAD, 100)
+	processor := NewProcessor(store, rejectingRail{})
+
+	result, err := processor.Initiate(ctx, PaymentParams{
+		Direction: db.PaymentWithdrawal,
+		Account:   account,
+		Rail:      "rejecting",
+		Amount:    40,
+	})
+	require.NoError(t, err)
+	require.Equal(t, db.PaymentFailed, result.Payment.Status)
+	require.Equal(t, "account closed", result.Payment.FailureReason)
+	requireBalance(t, store, account.ID, 100)
+}
+
+func TestInitiateUnsupported(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	account := createTestAccount(t, store, util.CAD, 100)
+	processor := NewProcessor(store, SimulatedRails(SimulatorConfig{})...)
+
+	_, err := processor.Initiate(ctx, PaymentParams{Direction: db.PaymentDeposit, Account: account, Rail: "wire", Amount: 10})
+	require.ErrorIs(t, err, ErrUnknownRail)
+
+	_, err = processor.Initiate(ctx, PaymentParams{Direction: db.PaymentDeposit, Account: account, Rail: ACH.Name, Amount: 10})
+	require.ErrorIs(t, err, ErrUnsupportedCurrency)
+
+	payments, err := store.ListPayments(ctx, db.ListPaymentsParams{AccountID: account.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, payments)
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemStore()
+	account := createTestAccount(t, store, util.USD, 100)
+
+	// the outcome of the deposit is lost with the simulator of the process that stops
+	stopped := NewProcessor(store, SimulatedRails(SimulatorConfig{Delay: time.Hour})...)
+	deposit, err := stopped.Initiate(ctx, PaymentParams{
+		Direction: db.PaymentDeposit,
+		Account:   account,
+		Rail:      ACH.Name,
+		Amount:    50,
+	})
+	require.NoError(t, err)
+
+	// and the rail of the withdrawal is not configured anymore
+	withdrawal, err := store.CreatePaymentTx(ctx, db.CreatePaymentTxParams{
+		AccountID: account.ID,
+		Direction: db.PaymentWithdrawal,
+		Rail:      "wire",
+		Amount:    30,
+	})
+	require.NoError(t, err)
+	requireBalance(t, store, account.ID, 70)
+
+	processor := NewProcessor(store, SimulatedRails(SimulatorConfig{Delay: 50 * time.Millisecond})...)
+
+	// only the payments created before the given time are reconciled
+	reconciled, err := processor.Reconcile(ctx, deposit.Payment.CreatedAt)
+	require.NoError(t, err)
+	require.Zero(t, reconciled)
+
+	reconciled, err = processor.Reconcile(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 2, reconciled)
+
+	failed, err := store.GetPayment(ctx, withdrawal.Payment.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.PaymentFailed, failed.Status)
+	require.Contains(t, failed.FailureReason, ErrUnknownRail.Error())
+	requireBalance(t, store, account.ID, 100)
+
+	resubmitted, err := store.GetPayment(ctx, deposit.Payment.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.PaymentPending, resubmitted.Status)
+	require.NotEqual(t, deposit.Payment.Reference, resubmitted.Reference)
+
+	// a payment the rail is still processing keeps its reference, and is reported once
+	reconciled, err = processor.Reconcile(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1, reconciled)
+
+	settled := requireCompleted(t, store, resubmitted)
+	require.Equal(t, db.PaymentSettled, settled.Status)
+	require.Equal(t, resubmitted.Reference, settled.Reference)
+	requireBalance(t, store, account.ID, 150)
+
+	reconciled, err = processor.Reconcile(ctx, time.Now())
+	require.NoError(t, err)
+	require.Zero(t, reconciled)
+}
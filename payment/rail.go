@@ -0,0 +1,68 @@
+// Package payment moves money into and out of the bank through external payment rails, such as ACH, SEPA or
+// card networks. A payment is held in suspense while its rail processes it, and settles or fails once the rail
+// reports its outcome, which happens asynchronously.
+package payment
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pakojabi/simplebank/util"
+)
+
+// Instruction is what a rail is asked to do for a payment
+type Instruction struct {
+	PaymentID int64
+	// Direction is db.PaymentDeposit, to pull money into the account, or db.PaymentWithdrawal, to push it out
+	Direction string
+	AccountID int64
+	Amount    int64
+	Currency  string
+}
+
+// Outcome is the final status of a payment, as its rail reports it
+type Outcome struct {
+	PaymentID int64
+	Reference string
+	Settled   bool
+	// FailureReason says why the payment failed, when it did not settle
+	FailureReason string
+}
+
+// ReportFunc is called by a rail with the outcome of a payment it accepted
+type ReportFunc func(outcome Outcome)
+
+// Rail is a provider that moves money in and out of the bank
+type Rail interface {
+	// Name identifies the rail in payments and requests
+	Name() string
+	// Supports tells whether the rail moves money in currency
+	Supports(currency string) bool
+	// Submit hands a payment over to the rail. It returns the reference the rail gives the payment once it
+	// accepts it, and calls report with its outcome later, from another goroutine.
+	// The PaymentID of the instruction is an idempotency key: a payment submitted again, e.g. by Reconcile after a
+	// restart, is not moved twice, and its outcome is reported once more if the rail already knows it.
+	Submit(ctx context.Context, instruction Instruction, report ReportFunc) (string, error)
+}
+
+// Style is a kind of rail, which moves money in some currencies
+type Style struct {
+	Name string
+	// Currencies lists the currencies of the rail, or is empty if it takes any
+	Currencies []string
+}
+
+// Styles of rails
+var (
+	// ACH moves US dollars between US banks
+	ACH = Style{Name: "ach", Currencies: []string{util.USD}}
+	// SEPA moves euros between European banks
+	SEPA = Style{Name: "sepa", Currencies: []string{util.EUR}}
+	// Card pulls money from, and pushes it to, cards in any currency
+	Card = Style{Name: "card"}
+)
+
+// Supports tells whether rails of the style move money in currency
+func (style Style) Supports(currency string) bool {
+	return len(style.Currencies) == 0 || slices.Contains(style.Currencies, currency)
+}
@@ -0,0 +1,106 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+)
+
+// simulatedFailures are the reasons simulated payments fail for, in the words of each style of rail
+var simulatedFailures = map[string]string{
+	ACH.Name:  "R01: insufficient funds",
+	SEPA.Name: "AM04: insufficient funds",
+	Card.Name: "05: do not honor",
+}
+
+// SimulatorConfig sets how a simulated rail behaves
+type SimulatorConfig struct {
+	// Delay is how long the rail takes to report the outcome of a payment
+	Delay time.Duration
+	// FailureRate is the share of payments that fail, from 0 for none to 1 for all
+	FailureRate float64
+}
+
+// Simulator is a rail that runs locally: it accepts every payment in the currencies of its style, and settles
+// or fails it at random after a delay. Payments it has not reported yet are lost when the process stops, until
+// they are submitted again.
+type Simulator struct {
+	style  Style
+	config SimulatorConfig
+
+	mutex sync.Mutex
+	// inFlight holds the outcomes not reported yet, by payment id, so a payment submitted twice is reported once
+	inFlight map[int64]Outcome
+}
+
+// NewSimulator creates a simulated rail of style
+func NewSimulator(style Style, config SimulatorConfig) *Simulator {
+	return &Simulator{
+		style:    style,
+		config:   config,
+		inFlight: make(map[int64]Outcome),
+	}
+}
+
+// SimulatedRails returns a simulated rail of every style, which all behave as config sets
+func SimulatedRails(config SimulatorConfig) []Rail {
+	return []Rail{
+		NewSimulator(ACH, config),
+		NewSimulator(SEPA, config),
+		NewSimulator(Card, config),
+	}
+}
+
+// NewSimulatedProcessor creates a Processor of payments on the simulated rails, which behave as config sets
+func NewSimulatedProcessor(config util.Config, store db.Store) *Processor {
+	return NewProcessor(store, SimulatedRails(SimulatorConfig{
+		Delay:       config.PaymentRailDelay,
+		FailureRate: config.PaymentRailFailureRate,
+	})...)
+}
+
+func (simulator *Simulator) Name() string {
+	return simulator.style.Name
+}
+
+func (simulator *Simulator) Supports(currency string) bool {
+	return simulator.style.Supports(currency)
+}
+
+func (simulator *Simulator) Submit(ctx context.Context, instruction Instruction, report ReportFunc) (string, error) {
+	if !simulator.Supports(instruction.Currency) {
+		return "", fmt.Errorf("%s does not support %s", simulator.Name(), instruction.Currency)
+	}
+
+	simulator.mutex.Lock()
+	defer simulator.mutex.Unlock()
+
+	if outcome, ok := simulator.inFlight[instruction.PaymentID]; ok {
+		return outcome.Reference, nil
+	}
+
+	outcome := Outcome{
+		PaymentID: instruction.PaymentID,
+		Reference: simulator.style.Name + "_" + uuid.NewString(),
+		Settled:   rand.Float64() >= simulator.config.FailureRate,
+	}
+	if !outcome.Settled {
+		outcome.FailureReason = simulatedFailures[simulator.style.Name]
+	}
+
+	simulator.inFlight[outcome.PaymentID] = outcome
+	time.AfterFunc(simulator.config.Delay, func() {
+		simulator.mutex.Lock()
+		delete(simulator.inFlight, outcome.PaymentID)
+		simulator.mutex.Unlock()
+
+		report(outcome)
+	})
+	return outcome.Reference, nil
+}
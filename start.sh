@@ -1,8 +1,5 @@
 #!/bin/sh
 
 set -e 
-echo "run db migration"
-/app/migrate -path /app/migration --database "$DB_SOURCE" -verbose up
-
 echo "start app"
 exec "$@"
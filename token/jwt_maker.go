@@ -29,12 +29,26 @@ func NewJWTMaker(secretKey string) (Maker, error) {
 }
 
 // Make produces a new token with the given duration for the given username
-func (m *JWTMaker) Make(username string, duration time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(username, duration)
+func (m *JWTMaker) Make(username string, role string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, duration)
 	if err != nil {
 		return "", payload, err
 	}
 
+	return m.sign(payload)
+}
+
+// MakeDelegated produces a new token limited to scopes, for a client acting on behalf of the user
+func (m *JWTMaker) MakeDelegated(username string, role string, clientID string, scopes []string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewDelegatedPayload(username, role, clientID, scopes, duration)
+	if err != nil {
+		return "", payload, err
+	}
+
+	return m.sign(payload)
+}
+
+func (m *JWTMaker) sign(payload *Payload) (string, *Payload, error) {
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, NewJWTPayloadClaims(payload))
 	signedString, err := jwtToken.SignedString([]byte(m.secretKey))
 	return signedString, payload, err
@@ -14,12 +14,13 @@ func TestJWTMaker(t *testing.T) {
 	require.NoError(t, err)
 
 	username := util.RandomOwner()
+	role := util.DepositorRole
 	duration := time.Minute
 
 	issuedAt := time.Now()
 	expiredAt := issuedAt.Add(duration)
 
-	token, payload, err := maker.Make(username, duration)
+	token, payload, err := maker.Make(username, role, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -30,6 +31,7 @@ func TestJWTMaker(t *testing.T) {
 
 	require.NotZero(t, payload.ID)
 	require.Equal(t, username, payload.Username)
+	require.Equal(t, role, payload.Role)
 	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
 	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
 
@@ -40,9 +42,10 @@ func TestExpiredJWTToken(t *testing.T) {
 	require.NoError(t, err)
 
 	username := util.RandomOwner()
+	role := util.DepositorRole
 	duration := time.Minute
 
-	token, payload, err := maker.Make(username, -duration)
+	token, payload, err := maker.Make(username, role, -duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -58,7 +61,7 @@ func TestInvalidJWTTokenAlgNone(t *testing.T) {
 	maker, err := NewJWTMaker(util.RandomString(32))
 	require.NoError(t, err)
 
-	payload, err := NewPayload(util.RandomOwner(), time.Minute)
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, time.Minute)
 	require.NoError(t, err)
 
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodNone, NewJWTPayloadClaims(payload))
@@ -70,3 +73,27 @@ func TestInvalidJWTTokenAlgNone(t *testing.T) {
 	require.Equal(t, ErrInvalidToken, err)
 	require.Nil(t, payload)
 }
+
+func TestJWTMakerDelegated(t *testing.T) {
+	maker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+	clientID := util.RandomString(16)
+	scopes := []string{util.AccountsReadScope}
+
+	token, payload, err := maker.MakeDelegated(username, util.DepositorRole, clientID, scopes, time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+
+	payload, err = maker.Verify(token)
+	require.NoError(t, err)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, clientID, payload.ClientID)
+	require.Equal(t, scopes, payload.Scopes)
+	require.True(t, payload.IsDelegated())
+
+	_, _, err = maker.MakeDelegated(username, util.DepositorRole, clientID, nil, time.Minute)
+	require.Error(t, err)
+}
@@ -4,8 +4,11 @@ import "time"
 
 //Maker represents the issuer of the token, which issues and verifies tokens.
 type Maker interface {
-	// Make produces a new token with the given duration for the given username
-	Make(username string, duration time.Duration) (string, *Payload, error)
+	// Make produces a new token with the given duration for the given username and role
+	Make(username string, role string, duration time.Duration) (string, *Payload, error)
+
+	// MakeDelegated produces a token limited to scopes, for a client acting on behalf of the user
+	MakeDelegated(username string, role string, clientID string, scopes []string, duration time.Duration) (string, *Payload, error)
 
 	//Verify returns the payload of the token if its valid or an error otherwise
 	Verify(token string) (*Payload, error)
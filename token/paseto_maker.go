@@ -14,8 +14,19 @@ type PasetoMaker struct {
 }
 
 // Make implements Maker.
-func (m *PasetoMaker) Make(username string, duration time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(username, duration)
+func (m *PasetoMaker) Make(username string, role string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, duration)
+	if err != nil {
+		return "", payload, err
+	}
+
+	result, err := m.paseto.Encrypt(m.symmetricKey, payload, nil)
+	return result, payload, err
+}
+
+// MakeDelegated implements Maker.
+func (m *PasetoMaker) MakeDelegated(username string, role string, clientID string, scopes []string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewDelegatedPayload(username, role, clientID, scopes, duration)
 	if err != nil {
 		return "", payload, err
 	}
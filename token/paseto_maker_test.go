@@ -13,12 +13,13 @@ func TestPasetoMaker(t *testing.T) {
 	require.NoError(t, err)
 
 	username := util.RandomOwner()
+	role := util.DepositorRole
 	duration := time.Minute
 
 	issuedAt := time.Now()
 	expiredAt := issuedAt.Add(duration)
 
-	token, payload, err := maker.Make(username, duration)
+	token, payload, err := maker.Make(username, role, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -29,6 +30,7 @@ func TestPasetoMaker(t *testing.T) {
 
 	require.NotZero(t, payload.ID)
 	require.Equal(t, username, payload.Username)
+	require.Equal(t, role, payload.Role)
 	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
 	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
 
@@ -39,9 +41,10 @@ func TestExpiredPasetoToken(t *testing.T) {
 	require.NoError(t, err)
 
 	username := util.RandomOwner()
+	role := util.DepositorRole
 	duration := time.Minute
 
-	token, payload, err := maker.Make(username, -duration)
+	token, payload, err := maker.Make(username, role, -duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -50,3 +53,24 @@ func TestExpiredPasetoToken(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, ErrExpiredToken, err)
 }
+
+func TestPasetoMakerDelegated(t *testing.T) {
+	maker, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+	clientID := util.RandomString(16)
+	scopes := []string{util.AccountsReadScope, util.TransfersWriteScope}
+
+	token, payload, err := maker.MakeDelegated(username, util.DepositorRole, clientID, scopes, time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+
+	payload, err = maker.Verify(token)
+	require.NoError(t, err)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, clientID, payload.ClientID)
+	require.Equal(t, scopes, payload.Scopes)
+	require.ErrorIs(t, payload.CheckSession(), ErrMissingScope)
+}
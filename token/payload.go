@@ -2,26 +2,36 @@ package token
 
 import (
 	"errors"
+	"slices"
 	"time"
 
 	uuid "github.com/google/uuid"
+	"github.com/pakojabi/simplebank/apperror"
 )
 
 var (
-	ErrInvalidToken = errors.New("token is invalid")
-	ErrExpiredToken = errors.New("token has expired")
+	ErrInvalidToken = apperror.New(apperror.CodeInvalidToken, "token is invalid")
+	ErrExpiredToken = apperror.New(apperror.CodeExpiredToken, "token has expired")
+	ErrRevokedToken = apperror.New(apperror.CodeRevokedToken, "token has been revoked")
+	ErrMissingScope = apperror.New(apperror.CodeMissingScope, "token does not grant the required scope")
 )
 
 // Payload contains the token user data
 type Payload struct {
 	ID        uuid.UUID `json:"id"`
 	Username  string    `json:"username"`
+	Role      string    `json:"role"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expires_at"`
+	// Scopes limit what a delegated credential, such as an API key, can do.
+	// Tokens issued when the user logs in have no scopes and full access.
+	Scopes []string `json:"scopes,omitempty"`
+	// ClientID is the OAuth client the token was issued to, if any
+	ClientID string `json:"client_id,omitempty"`
 }
 
 // NewPayload creates a nuew token payload with a specific name and duration
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+func NewPayload(username string, role string, duration time.Duration) (*Payload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
@@ -30,6 +40,7 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 	payload := &Payload{
 		ID:        tokenID,
 		Username:  username,
+		Role:      role,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration),
 	}
@@ -37,6 +48,22 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 	return payload, nil
 }
 
+// NewDelegatedPayload creates a token payload limited to scopes, for a client acting on behalf of the user
+func NewDelegatedPayload(username string, role string, clientID string, scopes []string, duration time.Duration) (*Payload, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("a delegated token needs at least one scope")
+	}
+
+	payload, err := NewPayload(username, role, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	payload.ClientID = clientID
+	payload.Scopes = scopes
+	return payload, nil
+}
+
 // Valid checks if the token payload is valid or not
 func (payload *Payload) Valid() error {
 	if time.Now().After(payload.ExpiredAt) {
@@ -44,3 +71,35 @@ func (payload *Payload) Valid() error {
 	}
 	return nil
 }
+
+// ValidSince returns ErrRevokedToken if the token was issued before t,
+// e.g. before the user last changed their password
+func (payload *Payload) ValidSince(t time.Time) error {
+	if payload.IssuedAt.Before(t) {
+		return ErrRevokedToken
+	}
+	return nil
+}
+
+// IsDelegated reports whether the payload comes from a credential limited to scopes
+// rather than from the user logging in
+func (payload *Payload) IsDelegated() bool {
+	return len(payload.Scopes) > 0
+}
+
+// CheckScope returns ErrMissingScope if the payload is delegated and does not grant scope
+func (payload *Payload) CheckScope(scope string) error {
+	if payload.IsDelegated() && !slices.Contains(payload.Scopes, scope) {
+		return ErrMissingScope
+	}
+	return nil
+}
+
+// CheckSession returns ErrMissingScope if the payload is delegated.
+// It guards operations, such as managing credentials, that no scope grants.
+func (payload *Payload) CheckSession() error {
+	if payload.IsDelegated() {
+		return ErrMissingScope
+	}
+	return nil
+}
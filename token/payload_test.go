@@ -0,0 +1,25 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadScopes(t *testing.T) {
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, time.Minute)
+	require.NoError(t, err)
+
+	// a login token has full access
+	require.False(t, payload.IsDelegated())
+	require.NoError(t, payload.CheckScope(util.AccountsReadScope))
+	require.NoError(t, payload.CheckSession())
+
+	payload.Scopes = []string{util.AccountsReadScope}
+	require.True(t, payload.IsDelegated())
+	require.NoError(t, payload.CheckScope(util.AccountsReadScope))
+	require.ErrorIs(t, payload.CheckScope(util.TransfersWriteScope), ErrMissingScope)
+	require.ErrorIs(t, payload.CheckSession(), ErrMissingScope)
+}
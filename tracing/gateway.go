@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// GatewayHandler starts a server span for every request served by the gateway mux next,
+// continuing the trace of the caller if the request carries a traceparent header.
+// The gateway calls the gRPC handlers in process with the request context, so their
+// spans, and the spans of the queries they run, end up in the same trace.
+func GatewayHandler(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "gateway")
+}
+
+// GatewayAnnotator must be registered with runtime.WithMetadata on the gateway mux wrapped by GatewayHandler.
+// It names the span after the route, which is only known once the gateway has matched the request.
+func GatewayAnnotator(ctx context.Context, r *http.Request) metadata.MD {
+	if pattern, ok := runtime.HTTPPathPattern(ctx); ok {
+		setRoute(ctx, r.Method, pattern)
+	}
+	return nil
+}
+
+// GatewayPath names the span of the custom handler h, registered with HandlePath, after pattern.
+// Such handlers bypass the annotators.
+func GatewayPath(pattern string, h runtime.HandlerFunc) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		setRoute(r.Context(), r.Method, pattern)
+		h(w, r, pathParams)
+	}
+}
+
+func setRoute(ctx context.Context, method string, pattern string) {
+	span := trace.SpanFromContext(ctx)
+	span.SetName(method + " " + pattern)
+	span.SetAttributes(semconv.HTTPRoute(pattern))
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"github.com/pakojabi/simplebank/util"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+const defaultServiceName = "simplebank"
+
+// ShutdownFunc flushes the spans still buffered and stops the exporter
+type ShutdownFunc func(ctx context.Context) error
+
+// Setup installs the global tracer provider and W3C trace context propagator,
+// exporting the spans with the exporter selected by config.TracingExporter.
+// With no exporter, spans are still propagated but never recorded.
+func Setup(ctx context.Context, config util.Config) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+
+	serviceName := config.TracingServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(samplerRatio(config)))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, config util.Config) (sdktrace.SpanExporter, error) {
+	switch config.TracingExporter {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterOTLP:
+		options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.OTLPEndpoint)}
+		if config.OTLPInsecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create otlp exporter: %w", err)
+		}
+		return exporter, nil
+	case ExporterStdout:
+		return newWriterExporter(os.Stdout)
+	case ExporterFile:
+		file, err := openTraceFile(config.TracingFile)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := newWriterExporter(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &fileExporter{SpanExporter: exporter, file: file}, nil
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter: %s", config.TracingExporter)
+	}
+}
+
+func newWriterExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create stdout exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+func openTraceFile(path string) (*os.File, error) {
+	if path == "" {
+		return nil, fmt.Errorf("TRACING_FILE is required by the file exporter")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create trace directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open trace file: %w", err)
+	}
+	return file, nil
+}
+
+// fileExporter writes the spans as JSON lines into a file, closed on shutdown
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+// Shutdown implements sdktrace.SpanExporter.
+func (exporter *fileExporter) Shutdown(ctx context.Context) error {
+	err := exporter.SpanExporter.Shutdown(ctx)
+	if closeErr := exporter.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// samplerRatio defaults to sampling every trace
+func samplerRatio(config util.Config) float64 {
+	if config.TracingSampleRatio <= 0 || config.TracingSampleRatio > 1 {
+		return 1
+	}
+	return config.TracingSampleRatio
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetupFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces", "traces.json")
+	shutdown, err := Setup(context.Background(), util.Config{
+		TracingExporter: ExporterFile,
+		TracingFile:     path,
+	})
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+
+	// spans are batched, and only written once flushed
+	require.NoError(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `"Name":"test-span"`)
+	require.Contains(t, string(content), `"Value":"simplebank"`)
+}
+
+func TestSetupInvalidExporter(t *testing.T) {
+	_, err := Setup(context.Background(), util.Config{TracingExporter: "jaeger"})
+	require.EqualError(t, err, "unsupported tracing exporter: jaeger")
+
+	_, err = Setup(context.Background(), util.Config{TracingExporter: ExporterFile})
+	require.Error(t, err)
+
+	shutdown, err := Setup(context.Background(), util.Config{TracingExporter: ExporterNone})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+}
+
+func TestGatewayHandler(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	pattern := "/v1/test/{id}"
+	var handlerSpan trace.SpanContext
+	gatewayMux := runtime.NewServeMux(runtime.WithMetadata(GatewayAnnotator))
+	err := gatewayMux.HandlePath(http.MethodGet, pattern, GatewayPath(pattern, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	require.NoError(t, err)
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	request := httptest.NewRequest(http.MethodGet, "/v1/test/1", nil)
+	request.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	GatewayHandler(gatewayMux).ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "GET "+pattern, spans[0].Name())
+	require.Equal(t, traceID, spans[0].SpanContext().TraceID().String())
+
+	// the handler runs within the span, continuing the trace of the caller
+	require.Equal(t, spans[0].SpanContext().SpanID(), handlerSpan.SpanID())
+}
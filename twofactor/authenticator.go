@@ -0,0 +1,331 @@
+package twofactor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/apperror"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// totpPeriod and totpDigits are the RFC 6238 defaults, which authenticator apps expect
+	totpPeriod = 30
+	totpDigits = otp.DigitsSix
+	// totpSkew is how many time steps before or after now a code is still accepted
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+	// maxChallengeAttempts is how many codes can be tried against one login challenge
+	maxChallengeAttempts = 5
+)
+
+var (
+	ErrAlreadyEnabled   = apperror.New(apperror.CodeTwoFactorEnabled, "two-factor authentication is already enabled")
+	ErrNotEnrolled      = apperror.New(apperror.CodeTwoFactorNotEnrolled, "two-factor authentication enrollment has not been started")
+	ErrNotEnabled       = apperror.New(apperror.CodeTwoFactorNotEnabled, "two-factor authentication is not enabled")
+	ErrInvalidCode      = apperror.New(apperror.CodeInvalidTwoFactor, "invalid two-factor authentication code")
+	ErrInvalidChallenge = apperror.New(apperror.CodeInvalidChallenge, "invalid or expired login challenge")
+	ErrUnavailable      = apperror.New(apperror.CodeTwoFactorUnavailable, "two-factor authentication is not configured")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Enrollment is a pending TOTP secret, to be added to an authenticator app
+type Enrollment struct {
+	Secret string
+	// ProvisioningURI is the otpauth:// URI apps scan from a QR code
+	ProvisioningURI string
+}
+
+// Authenticator manages RFC 6238 TOTP secrets, recovery codes and login challenges.
+// The secrets are encrypted with the key in TOTP_ENCRYPTION_KEY. Without one, nobody can enroll.
+type Authenticator struct {
+	config util.Config
+	store  db.Store
+	cipher *secretCipher
+}
+
+// NewAuthenticator creates a new two-factor Authenticator
+func NewAuthenticator(config util.Config, store db.Store) (*Authenticator, error) {
+	authenticator := &Authenticator{
+		config: config,
+		store:  store,
+	}
+
+	if config.TOTPEncryptionKey != "" {
+		var err error
+		authenticator.cipher, err = newSecretCipher(config.TOTPEncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return authenticator, nil
+}
+
+// Enroll starts TOTP enrollment with a new secret. It stays disabled until confirmed with a code.
+// An enabled secret is never replaced: it has to be disabled first, which takes a code from it.
+func (authenticator *Authenticator) Enroll(ctx context.Context, username string) (Enrollment, error) {
+	if authenticator.cipher == nil {
+		return Enrollment{}, ErrUnavailable
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      authenticator.config.TOTPIssuer,
+		AccountName: username,
+		Period:      totpPeriod,
+		Digits:      totpDigits,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return Enrollment{}, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+
+	encryptedSecret, err := authenticator.cipher.seal(username, key.Secret())
+	if err != nil {
+		return Enrollment{}, fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+
+	_, err = authenticator.store.UpsertTOTPSecret(ctx, db.UpsertTOTPSecretParams{
+		Username:        username,
+		EncryptedSecret: encryptedSecret,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return Enrollment{}, ErrAlreadyEnabled
+		}
+		return Enrollment{}, fmt.Errorf("failed to save totp secret: %w", err)
+	}
+
+	return Enrollment{
+		Secret:          key.Secret(),
+		ProvisioningURI: key.URL(),
+	}, nil
+}
+
+// Confirm enables the pending secret if code matches it, and returns a fresh set of recovery codes.
+// The recovery codes are only stored hashed, so this is the only time they can be shown.
+func (authenticator *Authenticator) Confirm(ctx context.Context, username string, code string) ([]string, error) {
+	secret, err := authenticator.store.GetTOTPSecret(ctx, username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil, ErrNotEnrolled
+		}
+		return nil, fmt.Errorf("failed to get totp secret: %w", err)
+	}
+	if secret.IsEnabled {
+		return nil, ErrAlreadyEnabled
+	}
+
+	plainSecret, err := authenticator.decrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := matchStep(plainSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	recoveryCodes := make([]string, recoveryCodeCount)
+	hashedRecoveryCodes := make([]string, recoveryCodeCount)
+	for i := range recoveryCodes {
+		recoveryCodes[i], err = newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hashedRecoveryCodes[i] = hashRecoveryCode(recoveryCodes[i])
+	}
+
+	_, err = authenticator.store.EnableTOTPTx(ctx, db.EnableTOTPTxParams{
+		Username:            username,
+		Step:                step,
+		HashedRecoveryCodes: hashedRecoveryCodes,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			// enabled concurrently
+			return nil, ErrAlreadyEnabled
+		}
+		return nil, fmt.Errorf("failed to enable totp: %w", err)
+	}
+
+	return recoveryCodes, nil
+}
+
+// Disable removes the enabled secret and the recovery codes of the user, who can then enroll again.
+// Callers check a code of the user first, as they do for any high-value operation.
+func (authenticator *Authenticator) Disable(ctx context.Context, username string) error {
+	err := authenticator.store.DisableTOTPTx(ctx, username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return ErrNotEnabled
+		}
+		return fmt.Errorf("failed to disable totp: %w", err)
+	}
+	return nil
+}
+
+// IsEnabled reports whether the user has confirmed TOTP enrollment
+func (authenticator *Authenticator) IsEnabled(ctx context.Context, username string) (bool, error) {
+	secret, err := authenticator.store.GetTOTPSecret(ctx, username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get totp secret: %w", err)
+	}
+	return secret.IsEnabled, nil
+}
+
+// Verify checks a TOTP code or, failing that, a recovery code of the user.
+// Each TOTP code and each recovery code is accepted only once.
+func (authenticator *Authenticator) Verify(ctx context.Context, username string, code string) error {
+	secret, err := authenticator.store.GetTOTPSecret(ctx, username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return ErrNotEnabled
+		}
+		return fmt.Errorf("failed to get totp secret: %w", err)
+	}
+	if !secret.IsEnabled {
+		return ErrNotEnabled
+	}
+
+	plainSecret, err := authenticator.decrypt(secret)
+	if err != nil {
+		return err
+	}
+
+	if step, ok := matchStep(plainSecret, code, time.Now()); ok {
+		_, err = authenticator.store.UseTOTPStep(ctx, db.UseTOTPStepParams{
+			Username: username,
+			Step:     step,
+		})
+		if err != nil {
+			if errors.Is(err, db.ErrRecordNotFound) {
+				// this code, or a later one, was already used
+				return ErrInvalidCode
+			}
+			return fmt.Errorf("failed to use totp code: %w", err)
+		}
+		return nil
+	}
+
+	_, err = authenticator.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+		Username:   username,
+		HashedCode: hashRecoveryCode(code),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return ErrInvalidCode
+		}
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return nil
+}
+
+// CreateChallenge opens a login challenge for a user whose password has been checked.
+// Its ID is the challenge token handed back to the client.
+func (authenticator *Authenticator) CreateChallenge(ctx context.Context, username string) (db.LoginChallenge, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return db.LoginChallenge{}, err
+	}
+
+	challenge, err := authenticator.store.CreateLoginChallenge(ctx, db.CreateLoginChallengeParams{
+		ID:        id,
+		Username:  username,
+		ExpiresAt: time.Now().Add(authenticator.config.LoginChallengeDuration),
+	})
+	if err != nil {
+		return db.LoginChallenge{}, fmt.Errorf("failed to create login challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// AnswerChallenge closes the login challenge if code is valid for its user.
+// The username is returned along with ErrInvalidCode, so that the failure can be recorded against it.
+func (authenticator *Authenticator) AnswerChallenge(ctx context.Context, challengeID uuid.UUID, code string) (string, error) {
+	challenge, err := authenticator.store.AttemptLoginChallenge(ctx, db.AttemptLoginChallengeParams{
+		ID:          challengeID,
+		MaxAttempts: maxChallengeAttempts,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return "", ErrInvalidChallenge
+		}
+		return "", fmt.Errorf("failed to get login challenge: %w", err)
+	}
+
+	if err := authenticator.Verify(ctx, challenge.Username, code); err != nil {
+		return challenge.Username, err
+	}
+
+	_, err = authenticator.store.CompleteLoginChallenge(ctx, challengeID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return challenge.Username, ErrInvalidChallenge
+		}
+		return challenge.Username, fmt.Errorf("failed to complete login challenge: %w", err)
+	}
+	return challenge.Username, nil
+}
+
+// decrypt returns the plain secret, to check codes against
+func (authenticator *Authenticator) decrypt(secret db.TotpSecret) (string, error) {
+	if authenticator.cipher == nil {
+		return "", ErrUnavailable
+	}
+	return authenticator.cipher.open(secret.Username, secret.EncryptedSecret)
+}
+
+// matchStep returns the time step around now for which code is valid
+func matchStep(secret string, code string, now time.Time) (int64, bool) {
+	if len(code) != totpDigits.Length() {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for _, step := range []int64{current, current - totpSkew, current + totpSkew} {
+		ok, err := hotp.ValidateCustom(code, uint64(step), secret, hotp.ValidateOpts{
+			Digits:    totpDigits,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err == nil && ok {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// newRecoveryCode returns a random code like "abcd-efgh"
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+	return code[:4] + "-" + code[4:], nil
+}
+
+// hashRecoveryCode ignores case, dashes and spaces, which users tend to get wrong when typing codes in.
+// The codes are random enough that a plain SHA-256 is sufficient.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
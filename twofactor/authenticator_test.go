@@ -0,0 +1,309 @@
+package twofactor
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// testEncryptionKey is the TOTP encryption key of the test authenticators
+var testEncryptionKey = base64.StdEncoding.EncodeToString([]byte(util.RandomString(secretKeySize)))
+
+func newTestAuthenticator(t *testing.T) (*Authenticator, *mockdb.MockStore) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	config := util.Config{
+		TOTPIssuer:             "Simple Bank",
+		TOTPEncryptionKey:      testEncryptionKey,
+		LoginChallengeDuration: 5 * time.Minute,
+	}
+	authenticator, err := NewAuthenticator(config, store)
+	require.NoError(t, err)
+	return authenticator, store
+}
+
+// randomSecret returns a TOTP secret as it is stored, and its plain secret to generate codes with
+func randomSecret(t *testing.T, username string, enabled bool) (db.TotpSecret, string) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      "Simple Bank",
+		AccountName: username,
+	})
+	require.NoError(t, err)
+
+	encryptedSecret, err := EncryptSecret(testEncryptionKey, username, key.Secret())
+	require.NoError(t, err)
+
+	return db.TotpSecret{
+		Username:        username,
+		EncryptedSecret: encryptedSecret,
+		IsEnabled:       enabled,
+	}, key.Secret()
+}
+
+func TestEnroll(t *testing.T) {
+	authenticator, store := newTestAuthenticator(t)
+	username := util.RandomOwner()
+
+	store.EXPECT().
+		UpsertTOTPSecret(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.UpsertTOTPSecretParams) (db.TotpSecret, error) {
+			return db.TotpSecret{Username: arg.Username, EncryptedSecret: arg.EncryptedSecret}, nil
+		})
+
+	enrollment, err := authenticator.Enroll(context.Background(), username)
+	require.NoError(t, err)
+	require.NotEmpty(t, enrollment.Secret)
+	require.Contains(t, enrollment.ProvisioningURI, "otpauth://totp/Simple%20Bank:"+username)
+	require.Contains(t, enrollment.ProvisioningURI, "secret="+enrollment.Secret)
+}
+
+func TestEnrollAlreadyEnabled(t *testing.T) {
+	authenticator, store := newTestAuthenticator(t)
+
+	store.EXPECT().
+		UpsertTOTPSecret(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.TotpSecret{}, db.ErrRecordNotFound)
+
+	_, err := authenticator.Enroll(context.Background(), util.RandomOwner())
+	require.ErrorIs(t, err, ErrAlreadyEnabled)
+}
+
+func TestConfirm(t *testing.T) {
+	authenticator, store := newTestAuthenticator(t)
+	secret, plainSecret := randomSecret(t, util.RandomOwner(), false)
+
+	now := time.Now()
+	code, err := totp.GenerateCode(plainSecret, now)
+	require.NoError(t, err)
+
+	store.EXPECT().
+		GetTOTPSecret(gomock.Any(), gomock.Eq(secret.Username)).
+		Times(1).
+		Return(secret, nil)
+
+	var hashedCodes []string
+	store.EXPECT().
+		EnableTOTPTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.EnableTOTPTxParams) (db.EnableTOTPTxResult, error) {
+			require.Equal(t, secret.Username, arg.Username)
+			require.InDelta(t, now.Unix()/totpPeriod, arg.Step, totpSkew)
+			hashedCodes = arg.HashedRecoveryCodes
+			return db.EnableTOTPTxResult{}, nil
+		})
+
+	recoveryCodes, err := authenticator.Confirm(context.Background(), secret.Username, code)
+	require.NoError(t, err)
+	require.Len(t, recoveryCodes, recoveryCodeCount)
+
+	for i, recoveryCode := range recoveryCodes {
+		require.Regexp(t, "^[a-z2-7]{4}-[a-z2-7]{4}$", recoveryCode)
+		require.Equal(t, hashRecoveryCode(recoveryCode), hashedCodes[i])
+	}
+}
+
+func TestConfirmErrors(t *testing.T) {
+	username := util.RandomOwner()
+	pending, plainSecret := randomSecret(t, username, false)
+	enabled, _ := randomSecret(t, username, true)
+
+	code, err := totp.GenerateCode(plainSecret, time.Now())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		secret db.TotpSecret
+		getErr error
+		code   string
+		err    error
+	}{
+		{name: "NotEnrolled", getErr: db.ErrRecordNotFound, code: code, err: ErrNotEnrolled},
+		{name: "AlreadyEnabled", secret: enabled, code: code, err: ErrAlreadyEnabled},
+		{name: "InvalidCode", secret: pending, code: "12345", err: ErrInvalidCode},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			authenticator, store := newTestAuthenticator(t)
+
+			store.EXPECT().
+				GetTOTPSecret(gomock.Any(), gomock.Eq(username)).
+				Times(1).
+				Return(tc.secret, tc.getErr)
+			store.EXPECT().
+				EnableTOTPTx(gomock.Any(), gomock.Any()).
+				Times(0)
+
+			_, err := authenticator.Confirm(context.Background(), username, tc.code)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestDisable(t *testing.T) {
+	authenticator, store := newTestAuthenticator(t)
+	username := util.RandomOwner()
+
+	store.EXPECT().
+		DisableTOTPTx(gomock.Any(), gomock.Eq(username)).
+		Times(1).
+		Return(nil)
+	require.NoError(t, authenticator.Disable(context.Background(), username))
+
+	store.EXPECT().
+		DisableTOTPTx(gomock.Any(), gomock.Eq(username)).
+		Times(1).
+		Return(db.ErrRecordNotFound)
+	require.ErrorIs(t, authenticator.Disable(context.Background(), username), ErrNotEnabled)
+}
+
+func TestVerifyTOTPCode(t *testing.T) {
+	authenticator, store := newTestAuthenticator(t)
+	secret, plainSecret := randomSecret(t, util.RandomOwner(), true)
+
+	code, err := totp.GenerateCode(plainSecret, time.Now())
+	require.NoError(t, err)
+
+	store.EXPECT().
+		GetTOTPSecret(gomock.Any(), gomock.Eq(secret.Username)).
+		Times(2).
+		Return(secret, nil)
+	gomock.InOrder(
+		store.EXPECT().
+			UseTOTPStep(gomock.Any(), gomock.Any()).
+			Times(1).
+			Return(secret, nil),
+		// the step has been used by the first call
+		store.EXPECT().
+			UseTOTPStep(gomock.Any(), gomock.Any()).
+			Times(1).
+			Return(db.TotpSecret{}, db.ErrRecordNotFound),
+	)
+
+	err = authenticator.Verify(context.Background(), secret.Username, code)
+	require.NoError(t, err)
+
+	err = authenticator.Verify(context.Background(), secret.Username, code)
+	require.ErrorIs(t, err, ErrInvalidCode)
+}
+
+func TestVerifyRecoveryCode(t *testing.T) {
+	authenticator, store := newTestAuthenticator(t)
+	secret, _ := randomSecret(t, util.RandomOwner(), true)
+
+	store.EXPECT().
+		GetTOTPSecret(gomock.Any(), gomock.Eq(secret.Username)).
+		Times(1).
+		Return(secret, nil)
+	store.EXPECT().
+		UseRecoveryCode(gomock.Any(), gomock.Eq(db.UseRecoveryCodeParams{
+			Username:   secret.Username,
+			HashedCode: hashRecoveryCode("abcd-efgh"),
+		})).
+		Times(1).
+		Return(db.RecoveryCode{Username: secret.Username, IsUsed: true}, nil)
+
+	err := authenticator.Verify(context.Background(), secret.Username, "ABCD EFGH")
+	require.NoError(t, err)
+}
+
+func TestVerifyNotEnabled(t *testing.T) {
+	authenticator, store := newTestAuthenticator(t)
+	secret, _ := randomSecret(t, util.RandomOwner(), false)
+
+	store.EXPECT().
+		GetTOTPSecret(gomock.Any(), gomock.Eq(secret.Username)).
+		Times(1).
+		Return(secret, nil)
+
+	err := authenticator.Verify(context.Background(), secret.Username, "123456")
+	require.ErrorIs(t, err, ErrNotEnabled)
+}
+
+func TestAnswerChallenge(t *testing.T) {
+	authenticator, store := newTestAuthenticator(t)
+	secret, plainSecret := randomSecret(t, util.RandomOwner(), true)
+	challenge := db.LoginChallenge{
+		ID:        uuid.New(),
+		Username:  secret.Username,
+		Attempts:  1,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	code, err := totp.GenerateCode(plainSecret, time.Now())
+	require.NoError(t, err)
+
+	store.EXPECT().
+		AttemptLoginChallenge(gomock.Any(), gomock.Eq(db.AttemptLoginChallengeParams{
+			ID:          challenge.ID,
+			MaxAttempts: maxChallengeAttempts,
+		})).
+		Times(1).
+		Return(challenge, nil)
+	store.EXPECT().
+		GetTOTPSecret(gomock.Any(), gomock.Eq(secret.Username)).
+		Times(1).
+		Return(secret, nil)
+	store.EXPECT().
+		UseTOTPStep(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(secret, nil)
+	store.EXPECT().
+		CompleteLoginChallenge(gomock.Any(), gomock.Eq(challenge.ID)).
+		Times(1).
+		Return(challenge, nil)
+
+	username, err := authenticator.AnswerChallenge(context.Background(), challenge.ID, code)
+	require.NoError(t, err)
+	require.Equal(t, secret.Username, username)
+}
+
+func TestAnswerChallengeInvalid(t *testing.T) {
+	authenticator, store := newTestAuthenticator(t)
+
+	store.EXPECT().
+		AttemptLoginChallenge(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.LoginChallenge{}, db.ErrRecordNotFound)
+	store.EXPECT().
+		GetTOTPSecret(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	username, err := authenticator.AnswerChallenge(context.Background(), uuid.New(), "123456")
+	require.ErrorIs(t, err, ErrInvalidChallenge)
+	require.Empty(t, username)
+}
+
+func TestMatchStep(t *testing.T) {
+	_, plainSecret := randomSecret(t, util.RandomOwner(), true)
+	now := time.Now()
+	current := now.Unix() / totpPeriod
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, err := totp.GenerateCode(plainSecret, now.Add(time.Duration(offset*totpPeriod)*time.Second))
+		require.NoError(t, err)
+
+		step, ok := matchStep(plainSecret, code, now)
+		require.True(t, ok)
+		require.Equal(t, current+offset, step)
+	}
+
+	code, err := totp.GenerateCode(plainSecret, now.Add(-5*totpPeriod*time.Second))
+	require.NoError(t, err)
+	_, ok := matchStep(plainSecret, code, now)
+	require.False(t, ok)
+}
@@ -0,0 +1,72 @@
+package twofactor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// secretKeySize makes the TOTP encryption key an AES-256 key
+const secretKeySize = 32
+
+// secretCipher encrypts TOTP secrets at rest. The username is authenticated along with each secret, so that a
+// secret copied to the row of another user does not decrypt.
+type secretCipher struct {
+	aead cipher.AEAD
+}
+
+// newSecretCipher parses the base64 encoded key set in TOTP_ENCRYPTION_KEY, e.g. from `openssl rand -base64 32`
+func newSecretCipher(encodedKey string) (*secretCipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP encryption key: %w", err)
+	}
+	if len(key) != secretKeySize {
+		return nil, fmt.Errorf("invalid TOTP encryption key: must be %d bytes, got %d", secretKeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &secretCipher{aead: aead}, nil
+}
+
+// seal encrypts the secret of username with a random nonce, which it puts in front
+func (c *secretCipher) seal(username string, secret string) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, []byte(secret), []byte(username)), nil
+}
+
+// open decrypts a secret sealed for username
+func (c *secretCipher) open(username string, sealed []byte) (string, error) {
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("encrypted totp secret is too short")
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	secret, err := c.aead.Open(nil, nonce, ciphertext, []byte(username))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// EncryptSecret encrypts the TOTP secret of username with encodedKey, as the Authenticator stores it
+func EncryptSecret(encodedKey string, username string, secret string) ([]byte, error) {
+	c, err := newSecretCipher(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+	return c.seal(username, secret)
+}
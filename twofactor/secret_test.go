@@ -0,0 +1,58 @@
+package twofactor
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretCipher(t *testing.T) {
+	c, err := newSecretCipher(testEncryptionKey)
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+	sealed, err := c.seal(username, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	require.NotContains(t, string(sealed), "JBSWY3DPEHPK3PXP")
+
+	secret, err := c.open(username, sealed)
+	require.NoError(t, err)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+
+	// the same secret never encrypts the same way twice
+	again, err := c.seal(username, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	require.NotEqual(t, sealed, again)
+
+	// a secret copied to another user does not decrypt
+	_, err = c.open(util.RandomOwner(), sealed)
+	require.Error(t, err)
+
+	// nor does one encrypted with another key
+	other, err := newSecretCipher(base64.StdEncoding.EncodeToString([]byte(util.RandomString(secretKeySize))))
+	require.NoError(t, err)
+	_, err = other.open(username, sealed)
+	require.Error(t, err)
+
+	_, err = c.open(username, sealed[:4])
+	require.Error(t, err)
+}
+
+func TestNewSecretCipherInvalidKey(t *testing.T) {
+	_, err := newSecretCipher("not base64!")
+	require.Error(t, err)
+
+	_, err = newSecretCipher(base64.StdEncoding.EncodeToString([]byte(util.RandomString(16))))
+	require.Error(t, err)
+}
+
+func TestEnrollWithoutEncryptionKey(t *testing.T) {
+	authenticator, err := NewAuthenticator(util.Config{TOTPIssuer: "Simple Bank"}, nil)
+	require.NoError(t, err)
+
+	_, err = authenticator.Enroll(context.Background(), util.RandomOwner())
+	require.ErrorIs(t, err, ErrUnavailable)
+}
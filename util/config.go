@@ -9,14 +9,60 @@ import (
 // Config stores all configuration variables for the app
 // The values are read from a config file or environment variables by viper.
 type Config struct {
-	DBDriver             string        `mapstructure:"DB_DRIVER"`
-	DBSource             string        `mapstructure:"DB_SOURCE"`
-	TestDBSource         string        `mapstructure:"TEST_DB_SOURCE"`
-	HTTPServerAddress    string        `mapstructure:"HTTP_SERVER_ADDRESS"`
-	GRPCServerAddress    string        `mapstructure:"GRPC_SERVER_ADDRESS"`
-	TokenSymmetricKey    string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
-	AccessTokenDuration  time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
-	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	DBSource                 string        `mapstructure:"DB_SOURCE"`
+	MigrateOnStart           bool          `mapstructure:"MIGRATE_ON_START"`
+	TestDBSource             string        `mapstructure:"TEST_DB_SOURCE"`
+	DBTxMaxRetries           int           `mapstructure:"DB_TX_MAX_RETRIES"`
+	DBMaxConns               int32         `mapstructure:"DB_MAX_CONNS"`
+	DBMinConns               int32         `mapstructure:"DB_MIN_CONNS"`
+	DBMaxConnLifetime        time.Duration `mapstructure:"DB_MAX_CONN_LIFETIME"`
+	DBMaxConnIdleTime        time.Duration `mapstructure:"DB_MAX_CONN_IDLE_TIME"`
+	DBHealthCheckPeriod      time.Duration `mapstructure:"DB_HEALTH_CHECK_PERIOD"`
+	HTTPServerAddress        string        `mapstructure:"HTTP_SERVER_ADDRESS"`
+	GRPCServerAddress        string        `mapstructure:"GRPC_SERVER_ADDRESS"`
+	MetricsServerAddress     string        `mapstructure:"METRICS_SERVER_ADDRESS"`
+	ShutdownTimeout          time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	ShutdownDrainDelay       time.Duration `mapstructure:"SHUTDOWN_DRAIN_DELAY"`
+	LogLevel                 string        `mapstructure:"LOG_LEVEL"`
+	LogFormat                string        `mapstructure:"LOG_FORMAT"`
+	TracingExporter          string        `mapstructure:"TRACING_EXPORTER"`
+	TracingServiceName       string        `mapstructure:"TRACING_SERVICE_NAME"`
+	TracingSampleRatio       float64       `mapstructure:"TRACING_SAMPLE_RATIO"`
+	TracingFile              string        `mapstructure:"TRACING_FILE"`
+	OTLPEndpoint             string        `mapstructure:"OTLP_ENDPOINT"`
+	OTLPInsecure             bool          `mapstructure:"OTLP_INSECURE"`
+	TokenSymmetricKey        string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	AccessTokenDuration      time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration     time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	MailerType               string        `mapstructure:"MAILER_TYPE"`
+	MailerFileDir            string        `mapstructure:"MAILER_FILE_DIR"`
+	SMTPServerAddress        string        `mapstructure:"SMTP_SERVER_ADDRESS"`
+	EmailSenderName          string        `mapstructure:"EMAIL_SENDER_NAME"`
+	EmailSenderAddress       string        `mapstructure:"EMAIL_SENDER_ADDRESS"`
+	EmailSenderPassword      string        `mapstructure:"EMAIL_SENDER_PASSWORD"`
+	VerifyEmailURL           string        `mapstructure:"VERIFY_EMAIL_URL"`
+	ResetPasswordURL         string        `mapstructure:"RESET_PASSWORD_URL"`
+	TaskWorkerCount          int           `mapstructure:"TASK_WORKER_COUNT"`
+	TaskPollInterval         time.Duration `mapstructure:"TASK_POLL_INTERVAL"`
+	LoginMaxFailures         int           `mapstructure:"LOGIN_MAX_FAILURES"`
+	LoginMaxFailuresPerIP    int           `mapstructure:"LOGIN_MAX_FAILURES_PER_IP"`
+	LoginFailureWindow       time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LoginLockoutDuration     time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	LoginChallengeDuration   time.Duration `mapstructure:"LOGIN_CHALLENGE_DURATION"`
+	TOTPIssuer               string        `mapstructure:"TOTP_ISSUER"`
+	TOTPEncryptionKey        string        `mapstructure:"TOTP_ENCRYPTION_KEY"`
+	TransferStepUpThreshold  int64         `mapstructure:"TRANSFER_STEP_UP_THRESHOLD"`
+	TransferFeeSchedule      string        `mapstructure:"TRANSFER_FEE_SCHEDULE"`
+	PaymentRailDelay         time.Duration `mapstructure:"PAYMENT_RAIL_DELAY"`
+	PaymentRailFailureRate   float64       `mapstructure:"PAYMENT_RAIL_FAILURE_RATE"`
+	PaymentReconcileInterval time.Duration `mapstructure:"PAYMENT_RECONCILE_INTERVAL"`
+	APIKeyDuration           time.Duration `mapstructure:"API_KEY_DURATION"`
+	APIKeyMaxDuration        time.Duration `mapstructure:"API_KEY_MAX_DURATION"`
+	OAuthCodeDuration        time.Duration `mapstructure:"OAUTH_CODE_DURATION"`
+	OAuthAccessTokenDuration time.Duration `mapstructure:"OAUTH_ACCESS_TOKEN_DURATION"`
+	LedgerAnchorDir          string        `mapstructure:"LEDGER_ANCHOR_DIR"`
+	LedgerAnchorKey          string        `mapstructure:"LEDGER_ANCHOR_KEY"`
+	LedgerAnchorPublicKey    string        `mapstructure:"LEDGER_ANCHOR_PUBLIC_KEY"`
 }
 
 // LoadConfig reads configuration from files and env variables
@@ -0,0 +1,18 @@
+package util
+
+// Scopes that can be granted to delegated credentials such as API keys
+const (
+	AccountsReadScope   = "accounts:read"
+	AccountsWriteScope  = "accounts:write"
+	TransfersWriteScope = "transfers:write"
+)
+
+// IsSupportedScope returns true if the scope is supported
+func IsSupportedScope(scope string) bool {
+	switch scope {
+	case AccountsReadScope, AccountsWriteScope, TransfersWriteScope:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,16 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// RandomSecret returns a URL-safe string built from n cryptographically random bytes.
+// Use it for anything that grants access, RandomString is only meant for test data.
+func RandomSecret(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"net/mail"
 	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/pakojabi/simplebank/util"
 )
 
 var (
 	isValidUsername = regexp.MustCompile(`^[a-z0-9_]+$`).MatchString
-	isValidFullname = regexp.MustCompile(`^[a-zA-Z\\s]+$`).MatchString
+	isValidFullname = regexp.MustCompile(`^[a-zA-Z\s]+$`).MatchString
+	isValidTOTPCode = regexp.MustCompile(`^[0-9]{6}$`).MatchString
 )
 
 
@@ -55,4 +59,52 @@ func ValidateEmail(value string) error {
 		return fmt.Errorf("%s is not a valid email address", value)
 	}
 	return nil
-}
\ No newline at end of file
+}
+func ValidateID(value int64) error {
+	if value < 1 {
+		return fmt.Errorf("must be a positive integer")
+	}
+	return nil
+}
+
+func ValidateSecretCode(value string) error {
+	return ValidateString(value, 32, 128)
+}
+
+func ValidateTOTPCode(value string) error {
+	if !isValidTOTPCode(value) {
+		return fmt.Errorf("must be a 6 digit code")
+	}
+	return nil
+}
+
+// ValidateTwoFactorCode accepts a TOTP code or a recovery code
+func ValidateTwoFactorCode(value string) error {
+	return ValidateString(value, 6, 20)
+}
+
+func ValidateChallengeToken(value string) error {
+	if _, err := uuid.Parse(value); err != nil {
+		return fmt.Errorf("must be a valid challenge token")
+	}
+	return nil
+}
+
+func ValidateScopes(values []string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("must have at least one scope")
+	}
+	for _, value := range values {
+		if !util.IsSupportedScope(value) {
+			return fmt.Errorf("%s is not a supported scope", value)
+		}
+	}
+	return nil
+}
+
+func ValidateUUID(value string) error {
+	if _, err := uuid.Parse(value); err != nil {
+		return fmt.Errorf("must be a valid id")
+	}
+	return nil
+}
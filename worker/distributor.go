@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+)
+
+const (
+	QueueDefault       = "default"
+	QueueCritical      = "critical"
+	defaultMaxAttempts = 10
+)
+
+// TaskDistributor enqueues background tasks in the tasks table.
+// Tasks are written through the given Querier, so passing a transaction's
+// Querier makes the task visible only if that transaction commits.
+type TaskDistributor interface {
+	DistributeTaskSendVerifyEmail(
+		ctx context.Context,
+		q db.Querier,
+		payload *PayloadSendVerifyEmail,
+		opts ...Option,
+	) error
+	DistributeTaskSendResetPassword(
+		ctx context.Context,
+		q db.Querier,
+		payload *PayloadSendResetPassword,
+		opts ...Option,
+	) error
+}
+
+type taskOptions struct {
+	queue       string
+	maxAttempts int32
+	processIn   time.Duration
+}
+
+// Option customises how a task is enqueued
+type Option func(*taskOptions)
+
+// Queue sets the queue the task is sent to
+func Queue(name string) Option {
+	return func(opts *taskOptions) {
+		opts.queue = name
+	}
+}
+
+// MaxAttempts sets how many times the task is tried before it is marked as failed
+func MaxAttempts(n int32) Option {
+	return func(opts *taskOptions) {
+		opts.maxAttempts = n
+	}
+}
+
+// ProcessIn delays the first attempt of the task
+func ProcessIn(d time.Duration) Option {
+	return func(opts *taskOptions) {
+		opts.processIn = d
+	}
+}
+
+type PGTaskDistributor struct{}
+
+func NewPGTaskDistributor() TaskDistributor {
+	return &PGTaskDistributor{}
+}
+
+func (distributor *PGTaskDistributor) enqueue(
+	ctx context.Context,
+	q db.Querier,
+	taskType string,
+	payload any,
+	opts ...Option,
+) (db.Task, error) {
+	options := taskOptions{
+		queue:       QueueDefault,
+		maxAttempts: defaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return db.Task{}, fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	task, err := q.CreateTask(ctx, db.CreateTaskParams{
+		Queue:       options.queue,
+		Type:        taskType,
+		Payload:     jsonPayload,
+		MaxAttempts: options.maxAttempts,
+		RunAt:       time.Now().Add(options.processIn),
+	})
+	if err != nil {
+		return db.Task{}, fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	return task, nil
+}
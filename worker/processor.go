@@ -0,0 +1,210 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/mail"
+	"github.com/pakojabi/simplebank/util"
+)
+
+const (
+	// taskLease is how long a claimed task stays hidden from other workers.
+	// If a worker dies mid-task, the task is picked up again once it expires.
+	taskLease        = 5 * time.Minute
+	retryBaseDelay   = 10 * time.Second
+	retryMaxDelay    = time.Hour
+	defaultPollDelay = time.Second
+)
+
+// ErrSkipRetry marks a task error as permanent: the task is failed without further attempts
+var ErrSkipRetry = errors.New("skip retry")
+
+// TaskProcessor runs background tasks from the tasks table
+type TaskProcessor interface {
+	Start() error
+	Shutdown()
+	// Running tells whether the workers have been started and not shut down yet
+	Running() bool
+	ProcessTaskSendVerifyEmail(ctx context.Context, task db.Task) error
+	ProcessTaskSendResetPassword(ctx context.Context, task db.Task) error
+}
+
+type taskHandler func(ctx context.Context, task db.Task) error
+
+type PGTaskProcessor struct {
+	config   util.Config
+	store    db.Store
+	mailer   mail.Mailer
+	queues   []string
+	handlers map[string]taskHandler
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	running  atomic.Bool
+}
+
+func NewPGTaskProcessor(config util.Config, store db.Store, mailer mail.Mailer) TaskProcessor {
+	processor := &PGTaskProcessor{
+		config: config,
+		store:  store,
+		mailer: mailer,
+		// critical tasks are always looked for first
+		queues: []string{QueueCritical, QueueDefault},
+	}
+
+	processor.handlers = map[string]taskHandler{
+		TaskSendVerifyEmail:   processor.ProcessTaskSendVerifyEmail,
+		TaskSendResetPassword: processor.ProcessTaskSendResetPassword,
+	}
+
+	return processor
+}
+
+// Start launches the workers in the background
+func (processor *PGTaskProcessor) Start() error {
+	if processor.cancel != nil {
+		return fmt.Errorf("task processor already started")
+	}
+
+	workers := processor.config.TaskWorkerCount
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	processor.cancel = cancel
+
+	for i := 0; i < workers; i++ {
+		processor.wg.Add(1)
+		go func() {
+			defer processor.wg.Done()
+			processor.run(ctx)
+		}()
+	}
+
+	processor.running.Store(true)
+	log.Printf("started %d task workers", workers)
+	return nil
+}
+
+// Shutdown stops claiming tasks and waits for the running ones to finish
+func (processor *PGTaskProcessor) Shutdown() {
+	if processor.cancel == nil {
+		return
+	}
+	processor.running.Store(false)
+	processor.cancel()
+	processor.wg.Wait()
+}
+
+// Running implements TaskProcessor.
+func (processor *PGTaskProcessor) Running() bool {
+	return processor.running.Load()
+}
+
+func (processor *PGTaskProcessor) run(ctx context.Context) {
+	pollInterval := processor.config.TaskPollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollDelay
+	}
+
+	for {
+		task, err := processor.claim(ctx)
+		if err == nil {
+			processor.process(ctx, task)
+			continue
+		}
+
+		if !errors.Is(err, db.ErrRecordNotFound) && ctx.Err() == nil {
+			log.Printf("failed to claim task: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func (processor *PGTaskProcessor) claim(ctx context.Context) (task db.Task, err error) {
+	for _, queue := range processor.queues {
+		task, err = processor.store.ClaimTask(ctx, db.ClaimTaskParams{
+			Queue:       queue,
+			LockedUntil: time.Now().Add(taskLease),
+		})
+		if !errors.Is(err, db.ErrRecordNotFound) {
+			return
+		}
+	}
+	return
+}
+
+func (processor *PGTaskProcessor) process(ctx context.Context, task db.Task) {
+	// bookkeeping must happen even if we are shutting down mid-task
+	bgCtx := context.WithoutCancel(ctx)
+
+	if task.Attempts > task.MaxAttempts {
+		processor.fail(bgCtx, task, fmt.Errorf("exceeded %d attempts", task.MaxAttempts))
+		return
+	}
+
+	handler, ok := processor.handlers[task.Type]
+	if !ok {
+		processor.fail(bgCtx, task, fmt.Errorf("unknown task type: %s", task.Type))
+		return
+	}
+
+	err := handler(ctx, task)
+	if err == nil {
+		if err := processor.store.CompleteTask(bgCtx, task.ID); err != nil {
+			log.Printf("failed to complete task %d: %s", task.ID, err)
+		}
+		return
+	}
+
+	if errors.Is(err, ErrSkipRetry) || task.Attempts >= task.MaxAttempts {
+		processor.fail(bgCtx, task, err)
+		return
+	}
+
+	delay := retryDelay(task.Attempts)
+	log.Printf("task %d (%s) failed, retrying in %s: %s", task.ID, task.Type, delay, err)
+	err = processor.store.RetryTask(bgCtx, db.RetryTaskParams{
+		ID:        task.ID,
+		RunAt:     time.Now().Add(delay),
+		LastError: err.Error(),
+	})
+	if err != nil {
+		log.Printf("failed to reschedule task %d: %s", task.ID, err)
+	}
+}
+
+func (processor *PGTaskProcessor) fail(ctx context.Context, task db.Task, taskErr error) {
+	log.Printf("task %d (%s) failed permanently: %s", task.ID, task.Type, taskErr)
+	err := processor.store.FailTask(ctx, db.FailTaskParams{
+		ID:        task.ID,
+		LastError: taskErr.Error(),
+	})
+	if err != nil {
+		log.Printf("failed to mark task %d as failed: %s", task.ID, err)
+	}
+}
+
+// retryDelay doubles the delay on every attempt, up to retryMaxDelay
+func retryDelay(attempts int32) time.Duration {
+	delay := retryBaseDelay
+	for i := int32(1); i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/mail"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRetryDelay(t *testing.T) {
+	require.Equal(t, retryBaseDelay, retryDelay(1))
+	require.Equal(t, 2*retryBaseDelay, retryDelay(2))
+	require.Equal(t, 4*retryBaseDelay, retryDelay(3))
+	require.Equal(t, retryMaxDelay, retryDelay(100))
+}
+
+func TestProcess(t *testing.T) {
+	payload, err := json.Marshal(PayloadSendVerifyEmail{Username: "someone"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		task       db.Task
+		buildStubs func(store *mockdb.MockStore)
+	}{
+		{
+			name: "Completed",
+			task: db.Task{ID: 1, Type: TaskSendVerifyEmail, Payload: payload, Attempts: 1, MaxAttempts: 3},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{IsEmailVerified: true}, nil)
+				store.EXPECT().CompleteTask(gomock.Any(), gomock.Eq(int64(1))).Times(1)
+			},
+		},
+		{
+			name: "Retried",
+			task: db.Task{ID: 1, Type: TaskSendVerifyEmail, Payload: payload, Attempts: 1, MaxAttempts: 3},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrConnDone)
+				store.EXPECT().
+					RetryTask(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.RetryTaskParams) error {
+						require.Equal(t, int64(1), arg.ID)
+						require.WithinDuration(t, time.Now().Add(retryBaseDelay), arg.RunAt, time.Second)
+						require.NotEmpty(t, arg.LastError)
+						return nil
+					})
+			},
+		},
+		{
+			name: "LastAttemptFailed",
+			task: db.Task{ID: 1, Type: TaskSendVerifyEmail, Payload: payload, Attempts: 3, MaxAttempts: 3},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrConnDone)
+				store.EXPECT().FailTask(gomock.Any(), gomock.Any()).Times(1)
+				store.EXPECT().RetryTask(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name: "SkipRetry",
+			task: db.Task{ID: 1, Type: TaskSendVerifyEmail, Payload: payload, Attempts: 1, MaxAttempts: 3},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, db.ErrRecordNotFound)
+				store.EXPECT().FailTask(gomock.Any(), gomock.Any()).Times(1)
+			},
+		},
+		{
+			name: "UnknownType",
+			task: db.Task{ID: 1, Type: "task:unknown", Payload: payload, Attempts: 1, MaxAttempts: 3},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().FailTask(gomock.Any(), gomock.Any()).Times(1)
+			},
+		},
+		{
+			name: "TooManyAttempts",
+			task: db.Task{ID: 1, Type: TaskSendVerifyEmail, Payload: payload, Attempts: 4, MaxAttempts: 3},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().FailTask(gomock.Any(), gomock.Any()).Times(1)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			processor := NewPGTaskProcessor(util.Config{}, store, mail.NewMemoryMailer()).(*PGTaskProcessor)
+			processor.process(context.Background(), tc.task)
+		})
+	}
+}
+
+func TestStartShutdown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().ClaimTask(gomock.Any(), gomock.Any()).AnyTimes().Return(db.Task{}, db.ErrRecordNotFound)
+
+	config := util.Config{TaskWorkerCount: 2, TaskPollInterval: 10 * time.Millisecond}
+	processor := NewPGTaskProcessor(config, store, nil)
+	require.False(t, processor.Running())
+
+	require.NoError(t, processor.Start())
+	require.True(t, processor.Running())
+	require.Error(t, processor.Start())
+
+	processor.Shutdown()
+	require.False(t, processor.Running())
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/mail"
+	"github.com/pakojabi/simplebank/util"
+)
+
+const TaskSendResetPassword = "task:send_reset_password"
+
+type PayloadSendResetPassword struct {
+	Username string `json:"username"`
+}
+
+func (distributor *PGTaskDistributor) DistributeTaskSendResetPassword(
+	ctx context.Context,
+	q db.Querier,
+	payload *PayloadSendResetPassword,
+	opts ...Option,
+) error {
+	task, err := distributor.enqueue(ctx, q, TaskSendResetPassword, payload, opts...)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("enqueued task %d (%s) on queue %s", task.ID, task.Type, task.Queue)
+	return nil
+}
+
+func (processor *PGTaskProcessor) ProcessTaskSendResetPassword(ctx context.Context, task db.Task) error {
+	var payload PayloadSendResetPassword
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %s: %w", err, ErrSkipRetry)
+	}
+
+	user, err := processor.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return fmt.Errorf("user %s does not exist: %w", payload.Username, ErrSkipRetry)
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	secretCode, err := util.RandomSecret(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate secret code: %w", err)
+	}
+
+	resetPassword, err := processor.store.CreateResetPassword(ctx, db.CreateResetPasswordParams{
+		Username:   user.Username,
+		HashedCode: db.HashResetCode(secretCode),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create reset password: %w", err)
+	}
+
+	resetURL := fmt.Sprintf("%s?reset_id=%d&secret_code=%s",
+		processor.config.ResetPasswordURL, resetPassword.ID, url.QueryEscape(secretCode))
+	content := fmt.Sprintf(`Hello %s,<br/>
+We received a request to reset your password.<br/>
+Please <a href="%s">click here</a> to choose a new one. The link expires in 15 minutes.<br/>
+If you did not ask for this, you can ignore this email.<br/>
+`, html.EscapeString(user.FullName), resetURL)
+
+	err = processor.mailer.Send(ctx, mail.Message{
+		To:      []string{user.Email},
+		Subject: "Reset your Simple Bank password",
+		Content: content,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send reset password email: %w", err)
+	}
+
+	log.Printf("sent reset password email for user %s", user.Username)
+	return nil
+}
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"testing"
+
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/mail"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestProcessTaskSendResetPassword(t *testing.T) {
+	user := db.User{
+		Username: util.RandomOwner(),
+		FullName: util.RandomString(6),
+		Email:    util.RandomString(6) + "@email.com",
+	}
+
+	payload, err := json.Marshal(PayloadSendResetPassword{Username: user.Username})
+	require.NoError(t, err)
+	task := db.Task{ID: 1, Type: TaskSendResetPassword, Payload: payload, Attempts: 1, MaxAttempts: 3}
+
+	var hashedCode string
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, err error, mailer *mail.MemoryMailer)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreateResetPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateResetPasswordParams) (db.ResetPassword, error) {
+						require.Equal(t, user.Username, arg.Username)
+						hashedCode = arg.HashedCode
+						return db.ResetPassword{ID: 9, Username: arg.Username, HashedCode: arg.HashedCode}, nil
+					})
+			},
+			check: func(t *testing.T, err error, mailer *mail.MemoryMailer) {
+				require.NoError(t, err)
+
+				messages := mailer.Messages()
+				require.Len(t, messages, 1)
+				require.Equal(t, []string{user.Email}, messages[0].To)
+				require.Contains(t, messages[0].Content, "http://localhost:3000/reset_password?reset_id=9&secret_code=")
+
+				// only the hash of the emailed code is stored
+				match := regexp.MustCompile(`secret_code=([^"]+)`).FindStringSubmatch(messages[0].Content)
+				require.Len(t, match, 2)
+				secretCode, err := url.QueryUnescape(match[1])
+				require.NoError(t, err)
+				require.GreaterOrEqual(t, len(secretCode), 32)
+				require.Equal(t, db.HashResetCode(secretCode), hashedCode)
+			},
+		},
+		{
+			name: "UserNotFound",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, db.ErrRecordNotFound)
+				store.EXPECT().CreateResetPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, err error, mailer *mail.MemoryMailer) {
+				require.ErrorIs(t, err, ErrSkipRetry)
+				require.Empty(t, mailer.Messages())
+			},
+		},
+		{
+			name: "InternalError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreateResetPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ResetPassword{}, sql.ErrConnDone)
+			},
+			check: func(t *testing.T, err error, mailer *mail.MemoryMailer) {
+				require.Error(t, err)
+				require.NotErrorIs(t, err, ErrSkipRetry)
+				require.Empty(t, mailer.Messages())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			mailer := mail.NewMemoryMailer()
+			config := util.Config{ResetPasswordURL: "http://localhost:3000/reset_password"}
+			processor := NewPGTaskProcessor(config, store, mailer)
+
+			err := processor.ProcessTaskSendResetPassword(context.Background(), task)
+			tc.check(t, err, mailer)
+		})
+	}
+}
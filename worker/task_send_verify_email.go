@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/mail"
+	"github.com/pakojabi/simplebank/util"
+)
+
+const TaskSendVerifyEmail = "task:send_verify_email"
+
+type PayloadSendVerifyEmail struct {
+	Username string `json:"username"`
+}
+
+func (distributor *PGTaskDistributor) DistributeTaskSendVerifyEmail(
+	ctx context.Context,
+	q db.Querier,
+	payload *PayloadSendVerifyEmail,
+	opts ...Option,
+) error {
+	task, err := distributor.enqueue(ctx, q, TaskSendVerifyEmail, payload, opts...)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("enqueued task %d (%s) on queue %s", task.ID, task.Type, task.Queue)
+	return nil
+}
+
+func (processor *PGTaskProcessor) ProcessTaskSendVerifyEmail(ctx context.Context, task db.Task) error {
+	var payload PayloadSendVerifyEmail
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %s: %w", err, ErrSkipRetry)
+	}
+
+	user, err := processor.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return fmt.Errorf("user %s does not exist: %w", payload.Username, ErrSkipRetry)
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user.IsEmailVerified {
+		return nil
+	}
+
+	secretCode, err := util.RandomSecret(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate secret code: %w", err)
+	}
+
+	verifyEmail, err := processor.store.CreateVerifyEmail(ctx, db.CreateVerifyEmailParams{
+		Username:   user.Username,
+		Email:      user.Email,
+		SecretCode: secretCode,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create verify email: %w", err)
+	}
+
+	verifyURL := fmt.Sprintf("%s?email_id=%d&secret_code=%s",
+		processor.config.VerifyEmailURL, verifyEmail.ID, url.QueryEscape(verifyEmail.SecretCode))
+	content := fmt.Sprintf(`Hello %s,<br/>
+Thank you for registering with us!<br/>
+Please <a href="%s">click here</a> to verify your email address.<br/>
+`, html.EscapeString(user.FullName), verifyURL)
+
+	err = processor.mailer.Send(ctx, mail.Message{
+		To:      []string{verifyEmail.Email},
+		Subject: "Welcome to Simple Bank",
+		Content: content,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send verify email: %w", err)
+	}
+
+	log.Printf("sent verify email to %s for user %s", verifyEmail.Email, user.Username)
+	return nil
+}
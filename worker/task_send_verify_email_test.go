@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	mockdb "github.com/pakojabi/simplebank/db/mock"
+	db "github.com/pakojabi/simplebank/db/sqlc"
+	"github.com/pakojabi/simplebank/mail"
+	"github.com/pakojabi/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestProcessTaskSendVerifyEmail(t *testing.T) {
+	user := db.User{
+		Username: util.RandomOwner(),
+		FullName: util.RandomString(6),
+		Email:    util.RandomString(6) + "@email.com",
+	}
+
+	payload, err := json.Marshal(PayloadSendVerifyEmail{Username: user.Username})
+	require.NoError(t, err)
+	task := db.Task{ID: 1, Type: TaskSendVerifyEmail, Payload: payload, Attempts: 1, MaxAttempts: 3}
+
+	testCases := []struct {
+		name       string
+		task       db.Task
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, err error, mailer *mail.MemoryMailer)
+	}{
+		{
+			name: "OK",
+			task: task,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreateVerifyEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, user.Email, arg.Email)
+						require.GreaterOrEqual(t, len(arg.SecretCode), 32)
+						return db.VerifyEmail{ID: 7, Username: arg.Username, Email: arg.Email, SecretCode: arg.SecretCode}, nil
+					})
+			},
+			check: func(t *testing.T, err error, mailer *mail.MemoryMailer) {
+				require.NoError(t, err)
+
+				messages := mailer.Messages()
+				require.Len(t, messages, 1)
+				require.Equal(t, []string{user.Email}, messages[0].To)
+				require.Contains(t, messages[0].Content, "http://localhost:8080/v1/verify_email?email_id=7&secret_code=")
+			},
+		},
+		{
+			name: "AlreadyVerified",
+			task: task,
+			buildStubs: func(store *mockdb.MockStore) {
+				verified := user
+				verified.IsEmailVerified = true
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(verified, nil)
+				store.EXPECT().CreateVerifyEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, err error, mailer *mail.MemoryMailer) {
+				require.NoError(t, err)
+				require.Empty(t, mailer.Messages())
+			},
+		},
+		{
+			name: "UserNotFound",
+			task: task,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, db.ErrRecordNotFound)
+			},
+			check: func(t *testing.T, err error, mailer *mail.MemoryMailer) {
+				require.ErrorIs(t, err, ErrSkipRetry)
+				require.Empty(t, mailer.Messages())
+			},
+		},
+		{
+			name: "InternalError",
+			task: task,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, sql.ErrConnDone)
+			},
+			check: func(t *testing.T, err error, mailer *mail.MemoryMailer) {
+				require.Error(t, err)
+				require.NotErrorIs(t, err, ErrSkipRetry)
+			},
+		},
+		{
+			name: "InvalidPayload",
+			task: db.Task{ID: 1, Type: TaskSendVerifyEmail, Payload: json.RawMessage(`"oops"`)},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, err error, mailer *mail.MemoryMailer) {
+				require.ErrorIs(t, err, ErrSkipRetry)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			mailer := mail.NewMemoryMailer()
+			config := util.Config{VerifyEmailURL: "http://localhost:8080/v1/verify_email"}
+			processor := NewPGTaskProcessor(config, store, mailer)
+
+			err := processor.ProcessTaskSendVerifyEmail(context.Background(), tc.task)
+			tc.check(t, err, mailer)
+		})
+	}
+}